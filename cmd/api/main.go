@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"vibhordubey333/loan-service/internal/config"
+	"vibhordubey333/loan-service/internal/domain"
 	"vibhordubey333/loan-service/internal/handler"
 	"vibhordubey333/loan-service/internal/repository"
 	"vibhordubey333/loan-service/internal/service"
@@ -23,30 +24,168 @@ import (
 func main() {
 	cfg := config.Load()
 
-	db, err := sql.Open("postgres", cfg.DatabaseURL)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+	var (
+		loanRepo      repository.LoanRepository
+		borrowerRepo  repository.BorrowerRepository
+		investorRepo  repository.InvestorRepository
+		outboxRepo    repository.OutboxRepository
+		historyRepo   repository.LoanHistoryRepository
+		eventStore    repository.LoanEventStore
+		scheduleRepo  repository.RepaymentScheduleRepository
+		repaymentRepo repository.RepaymentRepository
+		ledgerRepo    repository.LedgerRepository
+		walletRepo    repository.WalletRepository
+		transactor    repository.Transactor
+	)
+	switch cfg.Storage {
+	case "postgres":
+		db, err := sql.Open("postgres", cfg.DatabaseURL)
+		if err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		defer db.Close()
+
+		loanRepo = repository.NewLoanRepository(db)
+		borrowerRepo = repository.NewBorrowerRepository(db)
+		investorRepo = repository.NewInvestorRepository(db)
+		outboxRepo = repository.NewOutboxRepository(db)
+		historyRepo = repository.NewLoanHistoryRepository(db)
+		eventStore = repository.NewLoanEventStore(db)
+		scheduleRepo = repository.NewRepaymentScheduleRepository(db)
+		repaymentRepo = repository.NewRepaymentRepository(db)
+		ledgerRepo = repository.NewLedgerRepository(db)
+		walletRepo = repository.NewWalletRepository(db)
+		transactor = repository.NewTransactor(db)
+	case "memory":
+		log.Println("Using in-memory storage; data is lost on restart")
+		store := repository.NewMemoryStore()
+		loanRepo = store.Loans()
+		borrowerRepo = store.Borrowers()
+		investorRepo = store.Investors()
+		outboxRepo = store.Outbox()
+		historyRepo = store.History()
+		eventStore = store.Events()
+		scheduleRepo = store.Schedules()
+		repaymentRepo = store.Repayments()
+		ledgerRepo = store.Ledger()
+		walletRepo = store.Wallets()
+		transactor = store.Transactor()
+	default:
+		log.Fatalf("Unknown storage %q", cfg.Storage)
 	}
-	defer db.Close()
 
-	loanRepo := repository.NewLoanRepository(db)
+	emailService := service.NewEmailService(service.SMTPConfig(cfg.SMTPConfig), investorRepo)
+	// Only the local store's links are signed and served by this API; S3
+	// presigns its own, so /documents is not mounted for it.
+	var (
+		documentStore   service.DocumentStore
+		documentHandler *handler.DocumentHandler
+	)
+	switch cfg.Documents.Store {
+	case "local":
+		if cfg.Documents.SigningKey == "" {
+			log.Fatal("DOCUMENT_SIGNING_KEY must be set to sign document links")
+		}
+		signer := service.NewURLSigner(cfg.Documents.SigningKey)
+		documentStore = service.NewLocalDocumentStore(cfg.Documents.Dir, cfg.Documents.BaseURL, signer)
+		documentHandler = handler.NewDocumentHandler(documentStore, signer)
+	case "s3":
+		documentStore = service.NewS3DocumentStore(service.S3Config(cfg.Documents.S3), cfg.Documents.BaseURL, nil)
+	default:
+		log.Fatalf("Unknown document store %q", cfg.Documents.Store)
+	}
+	pdfService := service.NewPDFService(documentStore)
+	loanService := service.NewLoanService(loanRepo, borrowerRepo, investorRepo, outboxRepo, historyRepo, eventStore, scheduleRepo, repaymentRepo, ledgerRepo, walletRepo, transactor, pdfService, documentStore)
+	borrowerService := service.NewBorrowerService(borrowerRepo)
+	investorService := service.NewInvestorService(investorRepo)
+	outboxService := service.NewOutboxService(outboxRepo)
+	walletService := service.NewWalletService(walletRepo, investorRepo, ledgerRepo, transactor)
+	ledgerService := service.NewLedgerService(ledgerRepo, loanRepo, repaymentRepo, walletRepo)
+
+	dispatcher := service.NewOutboxDispatcher(outboxRepo, service.DispatcherConfig(cfg.Outbox),
+		map[string]service.OutboxHandler{
+			domain.TopicAgreementLetter:          service.NewAgreementLetterHandler(loanService),
+			domain.TopicInvestmentAgreementEmail: service.NewInvestmentAgreementEmailHandler(loanRepo, documentStore, emailService),
+			domain.TopicInvestmentRefund:         service.NewInvestmentRefundHandler(emailService),
+		})
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	dispatcherDone := make(chan struct{})
+	go func() {
+		dispatcher.Run(backgroundCtx)
+		close(dispatcherDone)
+	}()
+
+	expiryDone := make(chan struct{})
+	go func() {
+		if cfg.Loans.FundingWindow > 0 {
+			service.RunLoanExpiry(backgroundCtx, loanService, cfg.Loans.FundingWindow, cfg.Loans.ExpiryInterval)
+		}
+		close(expiryDone)
+	}()
 
-	emailService := service.NewEmailService(service.SMTPConfig(cfg.SMTPConfig))
-	pdfService := service.NewPDFService()
-	loanService := service.NewLoanService(loanRepo, emailService, pdfService)
+	defaultsDone := make(chan struct{})
+	go func() {
+		if cfg.Loans.DefaultAfter > 0 {
+			service.RunLoanDefaults(backgroundCtx, loanService, cfg.Loans.DefaultAfter, cfg.Loans.DefaultInterval)
+		}
+		close(defaultsDone)
+	}()
 
 	loanHandler := handler.NewLoanHandler(loanService)
+	borrowerHandler := handler.NewBorrowerHandler(borrowerService, loanService)
+	investorHandler := handler.NewInvestorHandler(investorService)
+	walletHandler := handler.NewWalletHandler(walletService)
+	adminHandler := handler.NewAdminHandler(outboxService)
+	ledgerHandler := handler.NewLedgerHandler(ledgerService)
 	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(handler.RequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Route("/loans", func(r chi.Router) {
 			r.Post("/", loanHandler.CreateLoan)
+			r.Get("/", loanHandler.ListLoans)
 			r.Get("/{id}", loanHandler.GetLoan)
+			r.Get("/{id}/history", loanHandler.GetLoanHistory)
+			r.Get("/{id}/schedule", loanHandler.GetRepaymentSchedule)
+			r.Get("/{id}/repayments", loanHandler.ListRepayments)
+			r.Post("/{id}/repayments", loanHandler.RecordRepayment)
 			r.Post("/{id}/approve", loanHandler.ApproveLoan)
 			r.Post("/{id}/invest", loanHandler.InvestInLoan)
 			r.Post("/{id}/disburse", loanHandler.DisburseLoan)
+			r.Post("/{id}/reject", loanHandler.RejectLoan)
+			r.Post("/{id}/cancel", loanHandler.CancelLoan)
+			r.With(handler.RequireBearerToken(cfg.StaffAPIToken)).Get("/{id}/documents/{document}", loanHandler.GetLoanDocument)
+		})
+		r.Route("/borrowers", func(r chi.Router) {
+			r.Post("/", borrowerHandler.RegisterBorrower)
+			r.Get("/", borrowerHandler.ListBorrowers)
+			r.Get("/{id}", borrowerHandler.GetBorrower)
+			r.Put("/{id}", borrowerHandler.UpdateBorrower)
+			r.Delete("/{id}", borrowerHandler.DeleteBorrower)
+			r.Get("/{id}/loans", borrowerHandler.ListBorrowerLoans)
+		})
+		r.Route("/investors", func(r chi.Router) {
+			r.Post("/", investorHandler.RegisterInvestor)
+			r.Get("/", investorHandler.ListInvestors)
+			r.Get("/{id}", investorHandler.GetInvestor)
+			r.Put("/{id}", investorHandler.UpdateInvestor)
+			r.Delete("/{id}", investorHandler.DeleteInvestor)
+			r.Get("/{id}/portfolio", investorHandler.GetPortfolio)
+			r.Get("/{id}/wallet", walletHandler.GetWallets)
+			r.Post("/{id}/wallet/deposits", walletHandler.Deposit)
+			r.Post("/{id}/wallet/withdrawals", walletHandler.Withdraw)
+		})
+		r.Get("/ledger/trial-balance", ledgerHandler.TrialBalance)
+		if documentHandler != nil {
+			r.Get("/documents/*", documentHandler.GetDocument)
+		}
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(handler.RequireBearerToken(cfg.StaffAPIToken))
+			r.Get("/outbox", adminHandler.ListOutbox)
+			r.Post("/outbox/{id}/retry", adminHandler.RetryOutboxMessage)
 		})
 	})
 
@@ -70,6 +209,13 @@ func main() {
 		if err := srv.Shutdown(ctx); err != nil {
 			log.Fatalf("Could not gracefully shutdown the server: %v\n", err)
 		}
+
+		// Messages being delivered when the dispatcher stops are retried by
+		// the next instance once their lease expires.
+		stopBackground()
+		<-dispatcherDone
+		<-expiryDone
+		<-defaultsDone
 		close(done)
 	}()
 
@@ -0,0 +1,51 @@
+// Command ledgercheck checks the ledger's invariants against the database:
+// every journal entry balances, the trial balance agrees, and each loan's
+// funding and receivable accounts, and the platform's fees, match the
+// loans' investments, disbursements and repayments. It prints what it finds
+// and exits with status 1 if anything is wrong.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+
+	"vibhordubey333/loan-service/internal/config"
+	"vibhordubey333/loan-service/internal/repository"
+	"vibhordubey333/loan-service/internal/service"
+
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	cfg := config.Load()
+
+	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ledger := service.NewLedgerService(repository.NewLedgerRepository(db),
+		repository.NewLoanRepository(db), repository.NewRepaymentRepository(db), repository.NewWalletRepository(db))
+	check, err := ledger.Check(context.Background())
+	if err != nil {
+		log.Fatalf("Ledger check failed: %v", err)
+	}
+
+	for _, id := range check.UnbalancedEntries {
+		fmt.Printf("entry %s does not balance\n", id)
+	}
+	for _, currency := range check.UnbalancedCurrencies {
+		fmt.Printf("trial balance in %s does not balance\n", currency)
+	}
+	for _, d := range check.Discrepancies {
+		fmt.Println(d)
+	}
+	if !check.OK() {
+		os.Exit(1)
+	}
+	fmt.Println("Ledger is consistent")
+}
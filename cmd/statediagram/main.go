@@ -0,0 +1,94 @@
+// Command statediagram renders domain.LoanLifecycle into the Readme (as
+// Mermaid) and architecture.plantuml, between generated-section markers.
+// Run it from the repository root after changing the state machine; with
+// -check it only reports diagrams that are out of date.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"vibhordubey333/loan-service/internal/domain"
+)
+
+// diagram is a generated section of a file.
+type diagram struct {
+	path       string
+	begin, end string
+	render     func() string
+}
+
+func diagrams() []diagram {
+	return []diagram{
+		{
+			path:  "Readme.md",
+			begin: "<!-- BEGIN loan-states: generated by go run ./cmd/statediagram -->\n",
+			end:   "<!-- END loan-states -->\n",
+			render: func() string {
+				return "```mermaid\n" + domain.LoanLifecycle.Mermaid() + "```\n"
+			},
+		},
+		{
+			path:   "architecture.plantuml",
+			begin:  "' BEGIN loan-states: generated by go run ./cmd/statediagram\n",
+			end:    "' END loan-states\n",
+			render: domain.LoanLifecycle.PlantUML,
+		},
+	}
+}
+
+func main() {
+	check := flag.Bool("check", false, "report out-of-date diagrams instead of rewriting them")
+	flag.Parse()
+
+	stale := false
+	for _, d := range diagrams() {
+		changed, err := update(d, !*check)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if changed && *check {
+			fmt.Fprintf(os.Stderr, "%s: loan state diagram is out of date; run go run ./cmd/statediagram\n", d.path)
+			stale = true
+		}
+	}
+	if stale {
+		os.Exit(1)
+	}
+}
+
+// update replaces the section of d.path between its markers with a freshly
+// rendered diagram, keeping the file's line endings. It reports whether the
+// section changed and only writes the file when write is set.
+func update(d diagram, write bool) (bool, error) {
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		return false, err
+	}
+	content := string(data)
+	eol := strings.NewReplacer("\n", "\n")
+	if strings.Contains(content, "\r\n") {
+		eol = strings.NewReplacer("\n", "\r\n")
+	}
+	begin, end := eol.Replace(d.begin), eol.Replace(d.end)
+
+	start := strings.Index(content, begin)
+	stop := strings.Index(content, end)
+	if start < 0 || stop < start {
+		return false, fmt.Errorf("%s: missing %q ... %q markers", d.path, strings.TrimSpace(d.begin), strings.TrimSpace(d.end))
+	}
+	start += len(begin)
+
+	rendered := eol.Replace(d.render())
+	if content[start:stop] == rendered {
+		return false, nil
+	}
+	if !write {
+		return true, nil
+	}
+	updated := content[:start] + rendered + content[stop:]
+	return true, os.WriteFile(d.path, []byte(updated), 0o644)
+}
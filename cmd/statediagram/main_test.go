@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDiagramsUpToDate fails when the state machine changed without the
+// diagrams being regenerated.
+func TestDiagramsUpToDate(t *testing.T) {
+	require.NoError(t, os.Chdir(filepath.Join("..", "..")))
+	for _, d := range diagrams() {
+		changed, err := update(d, false)
+		require.NoError(t, err)
+		assert.False(t, changed, "%s is out of date; run go run ./cmd/statediagram", d.path)
+	}
+}
+
+func TestUpdateRewritesOnlyTheGeneratedSection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.md")
+	require.NoError(t, os.WriteFile(path, []byte("intro\n<!-- b -->\nold\n<!-- e -->\noutro\n"), 0o644))
+	d := diagram{path: path, begin: "<!-- b -->\n", end: "<!-- e -->\n", render: func() string { return "new\n" }}
+
+	changed, err := update(d, true)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "intro\n<!-- b -->\nnew\n<!-- e -->\noutro\n", string(data))
+
+	changed, err = update(d, true)
+	require.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestUpdateKeepsCRLFLineEndings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.md")
+	require.NoError(t, os.WriteFile(path, []byte("intro\r\n<!-- b -->\r\nold\r\n<!-- e -->\r\n"), 0o644))
+	d := diagram{path: path, begin: "<!-- b -->\n", end: "<!-- e -->\n", render: func() string { return "new\nlines\n" }}
+
+	changed, err := update(d, true)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "intro\r\n<!-- b -->\r\nnew\r\nlines\r\n<!-- e -->\r\n", string(data))
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// KYCStatus is the outcome of checking a borrower's identity.
+type KYCStatus string
+
+const (
+	KYCStatusPending  KYCStatus = "PENDING"
+	KYCStatusVerified KYCStatus = "VERIFIED"
+	KYCStatusRejected KYCStatus = "REJECTED"
+)
+
+func (s KYCStatus) IsValid() bool {
+	switch s {
+	case KYCStatusPending, KYCStatusVerified, KYCStatusRejected:
+		return true
+	}
+	return false
+}
+
+// BorrowerDetails are the parts of a borrower that can change after they
+// are registered.
+type BorrowerDetails struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Phone   string `json:"phone"`
+	Address string `json:"address"`
+}
+
+// Borrower is someone loans are made to. Loans refer to their borrower by
+// NationalIDNumber, which is the loan's BorrowerIDNumber and cannot change.
+type Borrower struct {
+	ID               uuid.UUID `json:"id"`
+	NationalIDNumber string    `json:"national_id_number"`
+	BorrowerDetails
+	KYCStatus KYCStatus `json:"kyc_status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CheckCanBorrow returns an ErrValidation error unless the borrower has
+// passed KYC and may take out a new loan.
+func (b *Borrower) CheckCanBorrow() error {
+	if b.KYCStatus != KYCStatusVerified {
+		return Errorf(ErrValidation, "borrower %s has not passed KYC (status %s)", b.NationalIDNumber, b.KYCStatus)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds. Callers classify failures with errors.Is against these; the
+// message of the concrete error is meant for the API client.
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrInvalidTransition = errors.New("invalid state transition")
+	ErrOverInvestment    = errors.New("investment exceeds remaining principal")
+	// ErrInsufficientFunds is returned when an investor's wallet does not
+	// hold enough available money for an investment or withdrawal.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrConflict is returned when a loan was changed by someone else between
+	// being read and being written back.
+	ErrConflict = errors.New("loan was modified concurrently")
+	// ErrAlreadyExists is returned when a registration repeats a value that
+	// must be unique, such as a national ID number. Unlike ErrConflict,
+	// retrying will not help.
+	ErrAlreadyExists = errors.New("already exists")
+	ErrValidation    = errors.New("validation failed")
+)
+
+// Error is a domain failure of a given Kind with a client-facing message.
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// Errorf builds an Error of the given kind.
+func Errorf(kind error, format string, args ...any) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
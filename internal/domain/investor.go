@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InvestorStatus says whether an investor may make new investments.
+// Suspending an investor leaves their existing investments untouched.
+type InvestorStatus string
+
+const (
+	InvestorStatusActive    InvestorStatus = "ACTIVE"
+	InvestorStatusSuspended InvestorStatus = "SUSPENDED"
+)
+
+func (s InvestorStatus) IsValid() bool {
+	switch s {
+	case InvestorStatusActive, InvestorStatusSuspended:
+		return true
+	}
+	return false
+}
+
+// InvestmentLimits cap how much an investor may invest. A nil limit is no
+// limit. Limits are in one currency; an investor with limits can only invest
+// in loans in that currency.
+type InvestmentLimits struct {
+	// PerLoan caps the investor's investments in any one loan.
+	PerLoan *Money `json:"per_loan,omitempty"`
+	// Total caps the investor's investments across all loans, not counting
+	// voided ones.
+	Total *Money `json:"total,omitempty"`
+}
+
+// InvestorDetails are the parts of an investor that can change after they
+// are registered.
+type InvestorDetails struct {
+	Name   string           `json:"name"`
+	Email  string           `json:"email"`
+	Limits InvestmentLimits `json:"limits"`
+}
+
+// Investor funds loans. Investments refer to their investor by ID.
+type Investor struct {
+	ID uuid.UUID `json:"id"`
+	InvestorDetails
+	KYCStatus KYCStatus      `json:"kyc_status"`
+	Status    InvestorStatus `json:"status"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// CheckCanInvest returns an ErrValidation error unless the investor is
+// active and has passed KYC.
+func (i *Investor) CheckCanInvest() error {
+	if i.Status != InvestorStatusActive {
+		return Errorf(ErrValidation, "investor %s is not active (status %s)", i.ID, i.Status)
+	}
+	if i.KYCStatus != KYCStatusVerified {
+		return Errorf(ErrValidation, "investor %s has not passed KYC (status %s)", i.ID, i.KYCStatus)
+	}
+	return nil
+}
+
+// CheckLimits returns an ErrValidation error if the investor's investments
+// in a loan, inLoan, or across loans, total, exceed their limits. Both
+// include the investment being made.
+func (l InvestmentLimits) CheckLimits(inLoan, total Money) error {
+	if err := checkLimit("per-loan", l.PerLoan, inLoan); err != nil {
+		return err
+	}
+	return checkLimit("total", l.Total, total)
+}
+
+// Validate checks that the limits are positive and in one currency.
+func (l InvestmentLimits) Validate() error {
+	for _, limit := range []*Money{l.PerLoan, l.Total} {
+		if limit != nil && !limit.IsPositive() {
+			return Errorf(ErrValidation, "investment limits must be positive")
+		}
+	}
+	if l.PerLoan != nil && l.Total != nil && !l.PerLoan.SameCurrency(*l.Total) {
+		return Errorf(ErrValidation, "investment limits must be in one currency")
+	}
+	return nil
+}
+
+func checkLimit(name string, limit *Money, invested Money) error {
+	if limit == nil {
+		return nil
+	}
+	if !limit.SameCurrency(invested) {
+		return Errorf(ErrValidation, "investor's %s limit is in %s and cannot cover an investment in %s",
+			name, limit.Currency, invested.Currency)
+	}
+	if invested.Cmp(*limit) > 0 {
+		return Errorf(ErrValidation, "investment would bring the investor to %s %s, over their %s limit of %s",
+			invested, invested.Currency, name, limit)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvestmentLimits(t *testing.T) {
+	perLoan, total := NewMoney(10000, "IDR"), NewMoney(50000, "IDR")
+	limits := InvestmentLimits{PerLoan: &perLoan, Total: &total}
+	assert.NoError(t, limits.Validate())
+
+	cases := []struct {
+		name          string
+		inLoan, total Money
+		ok            bool
+	}{
+		{"within", NewMoney(10000, "IDR"), NewMoney(50000, "IDR"), true},
+		{"over per-loan", NewMoney(10001, "IDR"), NewMoney(20000, "IDR"), false},
+		{"over total", NewMoney(5000, "IDR"), NewMoney(50001, "IDR"), false},
+		{"other currency", NewMoney(1, "USD"), NewMoney(1, "USD"), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := limits.CheckLimits(tc.inLoan, tc.total)
+			if tc.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrValidation)
+			}
+		})
+	}
+
+	assert.NoError(t, InvestmentLimits{}.CheckLimits(NewMoney(1, "USD"), NewMoney(1, "USD")), "no limits")
+
+	usd := NewMoney(100, "USD")
+	assert.ErrorIs(t, InvestmentLimits{PerLoan: &perLoan, Total: &usd}.Validate(), ErrValidation)
+	zero := NewMoney(0, "IDR")
+	assert.ErrorIs(t, InvestmentLimits{Total: &zero}.Validate(), ErrValidation)
+}
+
+func TestInvestorCheckCanInvest(t *testing.T) {
+	i := &Investor{KYCStatus: KYCStatusVerified, Status: InvestorStatusActive}
+	assert.NoError(t, i.CheckCanInvest())
+
+	i.Status = InvestorStatusSuspended
+	assert.ErrorIs(t, i.CheckCanInvest(), ErrValidation)
+
+	i.Status, i.KYCStatus = InvestorStatusActive, KYCStatusRejected
+	assert.ErrorIs(t, i.CheckCanInvest(), ErrValidation)
+}
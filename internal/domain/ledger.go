@@ -0,0 +1,495 @@
+package domain
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccountType is a kind of ledger account. Each type has one account per
+// owner and currency.
+type AccountType string
+
+const (
+	// AccountInvestorWallet holds an investor's money on the platform.
+	// Owner: the investor's ID.
+	AccountInvestorWallet AccountType = "INVESTOR_WALLET"
+	// AccountLoanFunding is the investors' stake in a loan: what they put in
+	// and have not been paid back. Owner: the loan's ID.
+	AccountLoanFunding AccountType = "LOAN_FUNDING"
+	// AccountLoanReceivable is the principal the borrower owes on a
+	// disbursed loan. Owner: the loan's ID.
+	AccountLoanReceivable AccountType = "LOAN_RECEIVABLE"
+	// AccountBorrower is money paid to a borrower less what they paid back.
+	// Owner: the borrower's national ID number.
+	AccountBorrower AccountType = "BORROWER"
+	// AccountPlatformFees is what the platform keeps from repayments. It has
+	// no owner.
+	AccountPlatformFees AccountType = "PLATFORM_FEES"
+	// AccountDeposits is the money investors have paid into their wallets
+	// less what they withdrew, which the platform holds. It has no owner.
+	AccountDeposits AccountType = "DEPOSITS"
+)
+
+func (t AccountType) IsValid() bool {
+	switch t {
+	case AccountInvestorWallet, AccountLoanFunding, AccountLoanReceivable, AccountBorrower, AccountPlatformFees, AccountDeposits:
+		return true
+	}
+	return false
+}
+
+// NormalSide is the side an account of the type is expected to carry a
+// balance on.
+func (t AccountType) NormalSide() EntrySide {
+	if t == AccountLoanReceivable || t == AccountDeposits {
+		return Debit
+	}
+	return Credit
+}
+
+// Account identifies a ledger account. Accounts need not be opened: one
+// exists once a line is posted to it.
+type Account struct {
+	Type     AccountType `json:"type"`
+	OwnerID  string      `json:"owner_id,omitempty"`
+	Currency string      `json:"currency"`
+}
+
+func InvestorWalletAccount(investorID uuid.UUID, currency string) Account {
+	return Account{Type: AccountInvestorWallet, OwnerID: investorID.String(), Currency: currency}
+}
+
+func LoanFundingAccount(loanID uuid.UUID, currency string) Account {
+	return Account{Type: AccountLoanFunding, OwnerID: loanID.String(), Currency: currency}
+}
+
+func LoanReceivableAccount(loanID uuid.UUID, currency string) Account {
+	return Account{Type: AccountLoanReceivable, OwnerID: loanID.String(), Currency: currency}
+}
+
+func BorrowerAccount(borrowerIDNumber, currency string) Account {
+	return Account{Type: AccountBorrower, OwnerID: borrowerIDNumber, Currency: currency}
+}
+
+func PlatformFeesAccount(currency string) Account {
+	return Account{Type: AccountPlatformFees, Currency: currency}
+}
+
+func DepositsAccount(currency string) Account {
+	return Account{Type: AccountDeposits, Currency: currency}
+}
+
+func (a Account) String() string {
+	if a.OwnerID == "" {
+		return fmt.Sprintf("%s/%s", a.Type, a.Currency)
+	}
+	return fmt.Sprintf("%s/%s/%s", a.Type, a.OwnerID, a.Currency)
+}
+
+// CompareAccounts orders accounts by type, owner and currency, as the
+// repositories list them.
+func CompareAccounts(a, b Account) int {
+	return cmp.Or(
+		strings.Compare(string(a.Type), string(b.Type)),
+		strings.Compare(a.OwnerID, b.OwnerID),
+		strings.Compare(a.Currency, b.Currency),
+	)
+}
+
+type EntrySide string
+
+const (
+	Debit  EntrySide = "DEBIT"
+	Credit EntrySide = "CREDIT"
+)
+
+// EntryKind is the money movement a journal entry records.
+type EntryKind string
+
+const (
+	// EntryInvestment moves an investment from the investor's wallet into
+	// the loan. Reference: the investment.
+	EntryInvestment EntryKind = "INVESTMENT"
+	// EntryRefund returns a voided investment to the investor's wallet.
+	// Reference: the investment.
+	EntryRefund EntryKind = "REFUND"
+	// EntryDisbursement pays the principal to the borrower, who then owes
+	// it. Reference: the loan.
+	EntryDisbursement EntryKind = "DISBURSEMENT"
+	// EntryRepayment takes a repayment from the borrower and pays it out to
+	// the investors and the platform. Reference: the repayment.
+	EntryRepayment EntryKind = "REPAYMENT"
+	// EntryDeposit pays money into an investor's wallet. It is for no loan.
+	// Reference: the deposit.
+	EntryDeposit EntryKind = "DEPOSIT"
+	// EntryWithdrawal pays money out of an investor's wallet. It is for no
+	// loan. Reference: the withdrawal.
+	EntryWithdrawal EntryKind = "WITHDRAWAL"
+)
+
+// IsValid reports whether k is a known kind.
+func (k EntryKind) IsValid() bool {
+	switch k {
+	case EntryInvestment, EntryRefund, EntryDisbursement, EntryRepayment, EntryDeposit, EntryWithdrawal:
+		return true
+	}
+	return false
+}
+
+// ForLoan reports whether entries of kind k move money for a loan, and so
+// carry its ID, rather than in or out of the platform.
+func (k EntryKind) ForLoan() bool {
+	return k != EntryDeposit && k != EntryWithdrawal
+}
+
+// JournalLine debits or credits an account with a positive amount in the
+// account's currency.
+type JournalLine struct {
+	Account Account   `json:"account"`
+	Side    EntrySide `json:"side"`
+	Amount  Money     `json:"amount"`
+}
+
+// JournalEntry is one money movement. Its debits and credits balance in
+// every currency. Each movement is posted once: an entry is identified by
+// its kind and the ID of what it records. LoanID is uuid.Nil for deposits
+// and withdrawals.
+type JournalEntry struct {
+	ID          uuid.UUID     `json:"id"`
+	Kind        EntryKind     `json:"kind"`
+	LoanID      uuid.UUID     `json:"loan_id"`
+	ReferenceID uuid.UUID     `json:"reference_id"`
+	Lines       []JournalLine `json:"lines"`
+	PostedAt    time.Time     `json:"posted_at"`
+}
+
+// NewJournalEntry builds an entry from lines, leaving out those of zero
+// amount, and checks that it balances.
+func NewJournalEntry(kind EntryKind, loanID, referenceID uuid.UUID, lines []JournalLine, at time.Time) (*JournalEntry, error) {
+	e := &JournalEntry{
+		ID:          uuid.New(),
+		Kind:        kind,
+		LoanID:      loanID,
+		ReferenceID: referenceID,
+		Lines:       []JournalLine{},
+		PostedAt:    at,
+	}
+	for _, line := range lines {
+		if !line.Amount.IsZero() {
+			e.Lines = append(e.Lines, line)
+		}
+	}
+	if err := e.Check(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Check returns ErrValidation unless the entry is of a known kind, names a
+// loan if its kind is for one, every line posts a positive amount in its
+// account's currency and the entry balances.
+func (e *JournalEntry) Check() error {
+	if !e.Kind.IsValid() {
+		return Errorf(ErrValidation, "unknown journal entry kind %q", e.Kind)
+	}
+	if e.Kind.ForLoan() == (e.LoanID == uuid.Nil) {
+		return Errorf(ErrValidation, "journal entry %s must name a loan exactly when it moves money for one", e.Kind)
+	}
+	if len(e.Lines) < 2 {
+		return Errorf(ErrValidation, "journal entry %s must have at least two lines", e.Kind)
+	}
+	sums := make(map[string]int64)
+	for _, line := range e.Lines {
+		if !line.Account.Type.IsValid() {
+			return Errorf(ErrValidation, "unknown account type %q", line.Account.Type)
+		}
+		if !line.Amount.IsPositive() {
+			return Errorf(ErrValidation, "journal line amount must be positive")
+		}
+		if line.Amount.Currency != line.Account.Currency {
+			return Errorf(ErrValidation, "journal line in %s posted to %s account %s",
+				line.Amount.Currency, line.Account.Currency, line.Account)
+		}
+		switch line.Side {
+		case Debit:
+			sums[line.Amount.Currency] += line.Amount.MinorUnits
+		case Credit:
+			sums[line.Amount.Currency] -= line.Amount.MinorUnits
+		default:
+			return Errorf(ErrValidation, "unknown journal line side %q", line.Side)
+		}
+	}
+	for currency, sum := range sums {
+		if sum != 0 {
+			return Errorf(ErrValidation, "journal entry %s does not balance in %s: debits exceed credits by %s",
+				e.Kind, currency, NewMoney(sum, currency))
+		}
+	}
+	return nil
+}
+
+func debit(a Account, amount Money) JournalLine {
+	return JournalLine{Account: a, Side: Debit, Amount: amount}
+}
+
+func credit(a Account, amount Money) JournalLine {
+	return JournalLine{Account: a, Side: Credit, Amount: amount}
+}
+
+// InvestmentEntry moves inv from the investor's wallet into the loan.
+func InvestmentEntry(inv Investment) (*JournalEntry, error) {
+	currency := inv.Amount.Currency
+	return NewJournalEntry(EntryInvestment, inv.LoanID, inv.ID, []JournalLine{
+		debit(InvestorWalletAccount(inv.InvestorID, currency), inv.Amount),
+		credit(LoanFundingAccount(inv.LoanID, currency), inv.Amount),
+	}, inv.CreatedAt)
+}
+
+// RefundEntry returns the voided investment inv to the investor's wallet.
+func RefundEntry(inv Investment, at time.Time) (*JournalEntry, error) {
+	currency := inv.Amount.Currency
+	return NewJournalEntry(EntryRefund, inv.LoanID, inv.ID, []JournalLine{
+		debit(LoanFundingAccount(inv.LoanID, currency), inv.Amount),
+		credit(InvestorWalletAccount(inv.InvestorID, currency), inv.Amount),
+	}, at)
+}
+
+// DisbursementEntry pays the loan's principal to the borrower and records
+// that they owe it. The investors' funding stays in the loan until the
+// principal is repaid.
+func DisbursementEntry(l *Loan, at time.Time) (*JournalEntry, error) {
+	currency := l.PrincipalAmount.Currency
+	return NewJournalEntry(EntryDisbursement, l.ID, l.ID, []JournalLine{
+		debit(LoanReceivableAccount(l.ID, currency), l.PrincipalAmount),
+		credit(BorrowerAccount(l.BorrowerIDNumber, currency), l.PrincipalAmount),
+	}, at)
+}
+
+// RepaymentEntry takes r from the borrower, pays off the principal it
+// repaid and pays its payouts into the investors' wallets and the platform
+// fee to the platform.
+func RepaymentEntry(l *Loan, r *Repayment) (*JournalEntry, error) {
+	currency := r.Amount.Currency
+	lines := []JournalLine{
+		debit(BorrowerAccount(l.BorrowerIDNumber, currency), r.Amount),
+		credit(LoanReceivableAccount(l.ID, currency), r.Principal),
+		debit(LoanFundingAccount(l.ID, currency), r.Principal),
+	}
+	for _, p := range r.Payouts {
+		lines = append(lines, credit(InvestorWalletAccount(p.InvestorID, currency), p.Amount))
+	}
+	lines = append(lines, credit(PlatformFeesAccount(currency), r.PlatformFee))
+	return NewJournalEntry(EntryRepayment, l.ID, r.ID, lines, r.PaidAt)
+}
+
+// DepositEntry pays amount into the investor's wallet. depositID
+// identifies the deposit.
+func DepositEntry(investorID, depositID uuid.UUID, amount Money, at time.Time) (*JournalEntry, error) {
+	currency := amount.Currency
+	return NewJournalEntry(EntryDeposit, uuid.Nil, depositID, []JournalLine{
+		debit(DepositsAccount(currency), amount),
+		credit(InvestorWalletAccount(investorID, currency), amount),
+	}, at)
+}
+
+// WithdrawalEntry pays amount out of the investor's wallet. withdrawalID
+// identifies the withdrawal.
+func WithdrawalEntry(investorID, withdrawalID uuid.UUID, amount Money, at time.Time) (*JournalEntry, error) {
+	currency := amount.Currency
+	return NewJournalEntry(EntryWithdrawal, uuid.Nil, withdrawalID, []JournalLine{
+		debit(InvestorWalletAccount(investorID, currency), amount),
+		credit(DepositsAccount(currency), amount),
+	}, at)
+}
+
+// AccountBalance is what has been posted to an account. Balance is the net
+// on the account type's normal side, so it is negative when the account is
+// overdrawn.
+type AccountBalance struct {
+	Account Account `json:"account"`
+	Debits  Money   `json:"debits"`
+	Credits Money   `json:"credits"`
+	Balance Money   `json:"balance"`
+}
+
+func NewAccountBalance(a Account, debits, credits Money) AccountBalance {
+	balance := credits.Sub(debits)
+	if a.Type.NormalSide() == Debit {
+		balance = debits.Sub(credits)
+	}
+	return AccountBalance{Account: a, Debits: debits, Credits: credits, Balance: balance}
+}
+
+// TrialBalanceTotal sums the net balances of all accounts in one currency
+// by the side they fall on. The two agree when the ledger balances.
+type TrialBalanceTotal struct {
+	Currency string `json:"currency"`
+	Debits   Money  `json:"debits"`
+	Credits  Money  `json:"credits"`
+}
+
+type TrialBalance struct {
+	Accounts []AccountBalance    `json:"accounts"`
+	Totals   []TrialBalanceTotal `json:"totals"`
+	Balanced bool                `json:"balanced"`
+}
+
+// NewTrialBalance totals balances, keeping their order. Totals are ordered
+// by currency.
+func NewTrialBalance(balances []AccountBalance) *TrialBalance {
+	tb := &TrialBalance{Accounts: balances, Totals: []TrialBalanceTotal{}, Balanced: true}
+	if tb.Accounts == nil {
+		tb.Accounts = []AccountBalance{}
+	}
+
+	totals := make(map[string]*TrialBalanceTotal)
+	for _, b := range balances {
+		currency := b.Account.Currency
+		t, ok := totals[currency]
+		if !ok {
+			t = &TrialBalanceTotal{Currency: currency, Debits: NewMoney(0, currency), Credits: NewMoney(0, currency)}
+			totals[currency] = t
+		}
+		if net := b.Debits.Sub(b.Credits); net.IsPositive() {
+			t.Debits = t.Debits.Add(net)
+		} else {
+			t.Credits = t.Credits.Sub(net)
+		}
+	}
+	for _, t := range totals {
+		tb.Totals = append(tb.Totals, *t)
+		if t.Debits.Cmp(t.Credits) != 0 {
+			tb.Balanced = false
+		}
+	}
+	slices.SortFunc(tb.Totals, func(a, b TrialBalanceTotal) int {
+		return strings.Compare(a.Currency, b.Currency)
+	})
+	return tb
+}
+
+// LedgerDiscrepancy is an account whose balance differs from what the loans
+// and repayments it records add up to.
+type LedgerDiscrepancy struct {
+	Account  Account `json:"account"`
+	Expected Money   `json:"expected"`
+	Actual   Money   `json:"actual"`
+}
+
+func (d LedgerDiscrepancy) String() string {
+	return fmt.Sprintf("%s: balance %s, expected %s", d.Account, d.Actual, d.Expected)
+}
+
+// LedgerCheck is what checking the ledger against the loans found.
+type LedgerCheck struct {
+	// UnbalancedEntries are entries whose debits and credits differ.
+	UnbalancedEntries []uuid.UUID `json:"unbalanced_entries"`
+	// UnbalancedCurrencies are currencies whose trial balance totals
+	// differ.
+	UnbalancedCurrencies []string            `json:"unbalanced_currencies"`
+	Discrepancies        []LedgerDiscrepancy `json:"discrepancies"`
+}
+
+func (c *LedgerCheck) OK() bool {
+	return len(c.UnbalancedEntries) == 0 && len(c.UnbalancedCurrencies) == 0 && len(c.Discrepancies) == 0
+}
+
+// LedgerAudit works out the balances the loans' funding, receivable and
+// platform fee accounts should have from the loans and their repayments,
+// and those the investors' wallet accounts should have from their wallets.
+type LedgerAudit struct {
+	expected map[Account]Money
+}
+
+func NewLedgerAudit() *LedgerAudit {
+	return &LedgerAudit{expected: make(map[Account]Money)}
+}
+
+func (a *LedgerAudit) add(account Account, amount Money) {
+	balance, ok := a.expected[account]
+	if !ok {
+		balance = NewMoney(0, account.Currency)
+	}
+	a.expected[account] = balance.Add(amount)
+}
+
+// AddLoan adds the loan and its repayments. The investors' funding is what
+// they invested, less refunds and the principal repaid; the receivable is
+// the principal disbursed, less the principal repaid.
+func (a *LedgerAudit) AddLoan(l *Loan, repayments []*Repayment) {
+	currency := l.PrincipalAmount.Currency
+	funding := LoanFundingAccount(l.ID, currency)
+	receivable := LoanReceivableAccount(l.ID, currency)
+	zero := NewMoney(0, currency)
+
+	a.add(funding, zero)
+	for _, inv := range l.Investments {
+		if inv.VoidedAt == nil {
+			a.add(funding, inv.Amount)
+		}
+	}
+	a.add(receivable, zero)
+	if l.DisbursementDetails != nil {
+		a.add(receivable, l.PrincipalAmount)
+	}
+	for _, r := range repayments {
+		a.add(funding, zero.Sub(r.Principal))
+		a.add(receivable, zero.Sub(r.Principal))
+		a.add(PlatformFeesAccount(currency), r.PlatformFee)
+	}
+}
+
+// AddWallet adds the investor's wallet, whose Available the wallet account
+// should hold.
+func (a *LedgerAudit) AddWallet(w *Wallet) {
+	a.add(InvestorWalletAccount(w.InvestorID, w.Currency), w.Available)
+}
+
+// Check compares the audited accounts with balances, and any other loan,
+// platform fee or wallet account in balances with zero, and checks the
+// trial balance.
+func (a *LedgerAudit) Check(balances []AccountBalance) *LedgerCheck {
+	check := &LedgerCheck{
+		UnbalancedEntries:    []uuid.UUID{},
+		UnbalancedCurrencies: []string{},
+		Discrepancies:        []LedgerDiscrepancy{},
+	}
+
+	seen := make(map[Account]bool)
+	for _, b := range balances {
+		switch b.Account.Type {
+		case AccountLoanFunding, AccountLoanReceivable, AccountPlatformFees, AccountInvestorWallet:
+		default:
+			continue
+		}
+		seen[b.Account] = true
+		expected, ok := a.expected[b.Account]
+		if !ok {
+			expected = NewMoney(0, b.Account.Currency)
+		}
+		if b.Balance.Cmp(expected) != 0 {
+			check.Discrepancies = append(check.Discrepancies, LedgerDiscrepancy{Account: b.Account, Expected: expected, Actual: b.Balance})
+		}
+	}
+	for account, expected := range a.expected {
+		if !seen[account] && !expected.IsZero() {
+			actual := NewMoney(0, account.Currency)
+			check.Discrepancies = append(check.Discrepancies, LedgerDiscrepancy{Account: account, Expected: expected, Actual: actual})
+		}
+	}
+	slices.SortFunc(check.Discrepancies, func(x, y LedgerDiscrepancy) int {
+		return CompareAccounts(x.Account, y.Account)
+	})
+
+	for _, t := range NewTrialBalance(balances).Totals {
+		if t.Debits.Cmp(t.Credits) != 0 {
+			check.UnbalancedCurrencies = append(check.UnbalancedCurrencies, t.Currency)
+		}
+	}
+	return check
+}
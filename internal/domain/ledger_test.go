@@ -0,0 +1,167 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalEntries(t *testing.T) {
+	idr := func(v int64) Money { return NewMoney(v, "IDR") }
+	at := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	inv := Investment{ID: uuid.New(), LoanID: uuid.New(), InvestorID: uuid.New(), Amount: idr(60000), CreatedAt: at}
+	l := &Loan{ID: inv.LoanID, BorrowerIDNumber: "B-1", PrincipalAmount: idr(60000)}
+	wallet := InvestorWalletAccount(inv.InvestorID, "IDR")
+
+	e, err := InvestmentEntry(inv)
+	require.NoError(t, err)
+	assert.Equal(t, EntryInvestment, e.Kind)
+	assert.Equal(t, inv.ID, e.ReferenceID)
+	assert.Equal(t, []JournalLine{
+		{Account: wallet, Side: Debit, Amount: idr(60000)},
+		{Account: LoanFundingAccount(l.ID, "IDR"), Side: Credit, Amount: idr(60000)},
+	}, e.Lines)
+
+	e, err = RefundEntry(inv, at)
+	require.NoError(t, err)
+	assert.Equal(t, Credit, e.Lines[1].Side)
+	assert.Equal(t, wallet, e.Lines[1].Account)
+
+	// A repayment of interest only repays no principal and pays the
+	// platform nothing, so those lines are left out.
+	r := &Repayment{
+		ID:          uuid.New(),
+		LoanID:      l.ID,
+		Amount:      idr(600),
+		Interest:    idr(600),
+		Principal:   idr(0),
+		PlatformFee: idr(0),
+		Payouts:     []Payout{NewPayout(inv.ID, inv.InvestorID, idr(0), idr(600))},
+		PaidAt:      at,
+	}
+	e, err = RepaymentEntry(l, r)
+	require.NoError(t, err)
+	assert.Equal(t, []JournalLine{
+		{Account: BorrowerAccount("B-1", "IDR"), Side: Debit, Amount: idr(600)},
+		{Account: wallet, Side: Credit, Amount: idr(600)},
+	}, e.Lines)
+
+	r.PlatformFee = idr(100)
+	_, err = RepaymentEntry(l, r)
+	assert.ErrorIs(t, err, ErrValidation, "payouts and fee exceed the repayment")
+
+	// Deposits and withdrawals belong to no loan.
+	e, err = DepositEntry(inv.InvestorID, inv.ID, idr(5000), at)
+	require.NoError(t, err)
+	assert.Equal(t, EntryDeposit, e.Kind)
+	assert.Equal(t, uuid.Nil, e.LoanID)
+	assert.Equal(t, []JournalLine{
+		{Account: DepositsAccount("IDR"), Side: Debit, Amount: idr(5000)},
+		{Account: wallet, Side: Credit, Amount: idr(5000)},
+	}, e.Lines)
+
+	e, err = WithdrawalEntry(inv.InvestorID, inv.ID, idr(5000), at)
+	require.NoError(t, err)
+	assert.Equal(t, EntryWithdrawal, e.Kind)
+	assert.Equal(t, []JournalLine{
+		{Account: wallet, Side: Debit, Amount: idr(5000)},
+		{Account: DepositsAccount("IDR"), Side: Credit, Amount: idr(5000)},
+	}, e.Lines)
+
+	_, err = NewJournalEntry(EntryDeposit, l.ID, inv.ID, e.Lines, at)
+	assert.ErrorIs(t, err, ErrValidation, "deposit for a loan")
+	_, err = NewJournalEntry(EntryInvestment, uuid.Nil, inv.ID, e.Lines, at)
+	assert.ErrorIs(t, err, ErrValidation, "investment for no loan")
+
+	_, err = NewJournalEntry(EntryDisbursement, l.ID, l.ID, []JournalLine{
+		debit(LoanReceivableAccount(l.ID, "IDR"), idr(100)),
+		credit(BorrowerAccount("B-1", "USD"), NewMoney(100, "USD")),
+	}, at)
+	assert.ErrorIs(t, err, ErrValidation, "balances in neither currency")
+
+	_, err = NewJournalEntry(EntryDisbursement, l.ID, l.ID, []JournalLine{
+		debit(LoanReceivableAccount(l.ID, "IDR"), idr(100)),
+		credit(BorrowerAccount("B-1", "IDR"), idr(100)),
+		credit(BorrowerAccount("B-1", "IDR"), idr(-10)),
+		debit(BorrowerAccount("B-1", "IDR"), idr(-10)),
+	}, at)
+	assert.ErrorIs(t, err, ErrValidation, "negative amounts")
+}
+
+func TestTrialBalance(t *testing.T) {
+	idr := func(v int64) Money { return NewMoney(v, "IDR") }
+	loanID, investorID := uuid.New(), uuid.New()
+
+	tb := NewTrialBalance([]AccountBalance{
+		NewAccountBalance(InvestorWalletAccount(investorID, "IDR"), idr(100000), idr(10500)),
+		NewAccountBalance(LoanFundingAccount(loanID, "IDR"), idr(10000), idr(100000)),
+		NewAccountBalance(LoanReceivableAccount(loanID, "IDR"), idr(100000), idr(10000)),
+		NewAccountBalance(BorrowerAccount("B-1", "IDR"), idr(11000), idr(100000)),
+		NewAccountBalance(PlatformFeesAccount("IDR"), idr(0), idr(500)),
+	})
+	assert.Equal(t, idr(-89500), tb.Accounts[0].Balance, "the wallet is overdrawn")
+	assert.Equal(t, idr(90000), tb.Accounts[1].Balance)
+	assert.Equal(t, idr(90000), tb.Accounts[2].Balance)
+	assert.Equal(t, []TrialBalanceTotal{{Currency: "IDR", Debits: idr(179500), Credits: idr(179500)}}, tb.Totals)
+	assert.True(t, tb.Balanced)
+
+	tb = NewTrialBalance(nil)
+	assert.Empty(t, tb.Accounts)
+	assert.True(t, tb.Balanced)
+}
+
+func TestLedgerAudit(t *testing.T) {
+	idr := func(v int64) Money { return NewMoney(v, "IDR") }
+	at := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	disbursed := &Loan{
+		ID:                  uuid.New(),
+		PrincipalAmount:     idr(100000),
+		Investments:         []Investment{{Amount: idr(60000)}, {Amount: idr(40000)}, {Amount: idr(5000), VoidedAt: &at}},
+		DisbursementDetails: &DisbursementDetails{DisbursedAt: at},
+	}
+	approved := &Loan{ID: uuid.New(), PrincipalAmount: idr(50000), Investments: []Investment{{Amount: idr(20000)}}}
+	repayments := []*Repayment{{Principal: idr(10000), PlatformFee: idr(500)}, {Principal: idr(10000), PlatformFee: idr(400)}}
+
+	audit := NewLedgerAudit()
+	audit.AddLoan(disbursed, repayments)
+	audit.AddLoan(approved, nil)
+
+	balances := []AccountBalance{
+		NewAccountBalance(LoanFundingAccount(disbursed.ID, "IDR"), idr(25000), idr(105000)),
+		NewAccountBalance(LoanReceivableAccount(disbursed.ID, "IDR"), idr(100000), idr(20000)),
+		NewAccountBalance(PlatformFeesAccount("IDR"), idr(0), idr(900)),
+		NewAccountBalance(LoanFundingAccount(approved.ID, "IDR"), idr(0), idr(20000)),
+	}
+	assert.Empty(t, audit.Check(balances).Discrepancies)
+
+	// The approved loan's funding was never posted, and the fees are short.
+	balances[2] = NewAccountBalance(PlatformFeesAccount("IDR"), idr(0), idr(500))
+	check := audit.Check(balances[:3])
+	assert.False(t, check.OK())
+	assert.Equal(t, []LedgerDiscrepancy{
+		{Account: LoanFundingAccount(approved.ID, "IDR"), Expected: idr(20000), Actual: idr(0)},
+		{Account: PlatformFeesAccount("IDR"), Expected: idr(900), Actual: idr(500)},
+	}, check.Discrepancies)
+
+	// Wallet accounts must match what their wallets have available.
+	investorID := uuid.New()
+	audit = NewLedgerAudit()
+	audit.AddWallet(&Wallet{InvestorID: investorID, Currency: "IDR", Available: idr(3000), Reserved: idr(7000)})
+	check = audit.Check([]AccountBalance{
+		NewAccountBalance(InvestorWalletAccount(investorID, "IDR"), idr(0), idr(3000)),
+		NewAccountBalance(DepositsAccount("IDR"), idr(3000), idr(0)),
+		NewAccountBalance(InvestorWalletAccount(uuid.Nil, "IDR"), idr(0), idr(0)),
+	})
+	assert.True(t, check.OK(), "%+v", check)
+
+	check = audit.Check([]AccountBalance{
+		NewAccountBalance(InvestorWalletAccount(investorID, "IDR"), idr(0), idr(10000)),
+		NewAccountBalance(DepositsAccount("IDR"), idr(10000), idr(0)),
+	})
+	assert.Equal(t, []LedgerDiscrepancy{
+		{Account: InvestorWalletAccount(investorID, "IDR"), Expected: idr(3000), Actual: idr(10000)},
+	}, check.Discrepancies)
+}
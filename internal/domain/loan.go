@@ -4,7 +4,6 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"database/sql"
 )
 
 type LoanState string
@@ -14,23 +13,48 @@ const (
 	LoanStateApproved  LoanState = "APPROVED"
 	LoanStateInvested  LoanState = "INVESTED"
 	LoanStateDisbursed LoanState = "DISBURSED"
+	// A disbursed loan ends REPAID once its repayment schedule is settled,
+	// or DEFAULTED, with ClosureDetails saying why, if the borrower stops
+	// paying.
+	LoanStateRepaid    LoanState = "REPAID"
+	LoanStateDefaulted LoanState = "DEFAULTED"
+
+	// Loans that leave the lifecycle before being funded end in one of these
+	// states, with ClosureDetails saying why.
+	LoanStateRejected  LoanState = "REJECTED"
+	LoanStateCancelled LoanState = "CANCELLED"
+	LoanStateExpired   LoanState = "EXPIRED"
 )
 
+func (s LoanState) IsValid() bool {
+	switch s {
+	case LoanStateProposed, LoanStateApproved, LoanStateInvested, LoanStateDisbursed,
+		LoanStateRepaid, LoanStateDefaulted, LoanStateRejected, LoanStateCancelled, LoanStateExpired:
+		return true
+	}
+	return false
+}
+
 type Loan struct {
 	ID               uuid.UUID `json:"id"`
 	BorrowerIDNumber string    `json:"borrower_id_number"`
-	PrincipalAmount  float64   `json:"principal_amount"`
-	Rate             float64   `json:"rate"`
-	ROI              float64   `json:"roi"`
+	PrincipalAmount  Money     `json:"principal_amount"`
+	Rate             Percent   `json:"rate"`
+	ROI              Percent   `json:"roi"`
 	State            LoanState `json:"state"`
+	Version          int64     `json:"version"`
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
 
+	RepaymentTerms
+
 	ApprovalDetails *ApprovalDetails `json:"approval_details,omitempty"`
 	Investments     []Investment     `json:"investments,omitempty"`
 
 	DisbursementDetails *DisbursementDetails `json:"disbursement_details,omitempty"`
-	AgreementLetterURL  sql.NullString       `json:"agreement_letter_url,omitempty"`
+	AgreementLetterURL  string               `json:"agreement_letter_url,omitempty"`
+
+	ClosureDetails *ClosureDetails `json:"closure_details,omitempty"`
 }
 
 type ApprovalDetails struct {
@@ -43,8 +67,12 @@ type Investment struct {
 	ID         uuid.UUID `json:"id"`
 	LoanID     uuid.UUID `json:"loan_id"`
 	InvestorID uuid.UUID `json:"investor_id"`
-	Amount     float64   `json:"amount"`
+	Amount     Money     `json:"amount"`
 	CreatedAt  time.Time `json:"created_at"`
+	// VoidedAt is set when the loan was cancelled or expired and the
+	// investment refunded. Voided investments no longer count towards the
+	// principal.
+	VoidedAt *time.Time `json:"voided_at,omitempty"`
 }
 
 type DisbursementDetails struct {
@@ -53,26 +81,77 @@ type DisbursementDetails struct {
 	DisbursedAt        time.Time `json:"disbursed_at"`
 }
 
-func (l *Loan) CanApprove() bool {
-	return l.State == LoanStateProposed
+// ClosureDetails records who closed a loan short of repayment, before
+// disbursement or by declaring it in default, and why. ActorID is empty for
+// loans the service expired or defaulted itself.
+type ClosureDetails struct {
+	ActorID  string    `json:"actor_id,omitempty"`
+	Reason   string    `json:"reason"`
+	ClosedAt time.Time `json:"closed_at"`
 }
 
-func (l *Loan) CanInvest() bool {
-	return l.State == LoanStateApproved
+func (l *Loan) TotalInvestedAmount() Money {
+	total := NewMoney(0, l.PrincipalAmount.Currency)
+	for _, inv := range l.Investments {
+		if inv.VoidedAt != nil {
+			continue
+		}
+		total = total.Add(inv.Amount)
+	}
+	return total
 }
 
-func (l *Loan) CanDisburse() bool {
-	return l.State == LoanStateInvested
+func (l *Loan) RemainingAmount() Money {
+	return l.PrincipalAmount.Sub(l.TotalInvestedAmount())
+}
+
+func (l *Loan) IsFullyInvested() bool {
+	return l.TotalInvestedAmount().Cmp(l.PrincipalAmount) >= 0
 }
 
-func (l *Loan) TotalInvestedAmount() float64 {
-	var total float64
+// FullyInvestedAt returns when the investment that completed the loan's
+// funding was made. Unlike UpdatedAt it does not move with later
+// transitions. It is false while the loan is not fully invested.
+func (l *Loan) FullyInvestedAt() (time.Time, bool) {
+	if !l.IsFullyInvested() {
+		return time.Time{}, false
+	}
+	var at time.Time
 	for _, inv := range l.Investments {
-		total += inv.Amount
+		if inv.VoidedAt == nil && inv.CreatedAt.After(at) {
+			at = inv.CreatedAt
+		}
 	}
-	return total
+	return at, true
 }
 
-func (l *Loan) IsFullyInvested() bool {
-	return l.TotalInvestedAmount() >= l.PrincipalAmount
+// AddInvestment applies inv to an APPROVED loan and moves the loan to INVESTED
+// once the principal is fully covered. An investment without a currency is
+// taken to be in the loan's currency.
+func (l *Loan) AddInvestment(inv *Investment, at time.Time) error {
+	if err := LoanLifecycle.Check(l, LoanEventFund); err != nil {
+		return err
+	}
+
+	if inv.Amount.Currency == "" {
+		inv.Amount.Currency = l.PrincipalAmount.Currency
+	}
+	if !inv.Amount.SameCurrency(l.PrincipalAmount) {
+		return Errorf(ErrValidation, "investment currency %s does not match loan currency %s",
+			inv.Amount.Currency, l.PrincipalAmount.Currency)
+	}
+	if !inv.Amount.IsPositive() {
+		return Errorf(ErrValidation, "investment amount must be positive")
+	}
+	if inv.Amount.Cmp(l.RemainingAmount()) > 0 {
+		return Errorf(ErrOverInvestment, "investment amount %s exceeds remaining principal %s",
+			inv.Amount, l.RemainingAmount())
+	}
+
+	l.Investments = append(l.Investments, *inv)
+
+	if l.IsFullyInvested() {
+		return LoanLifecycle.Fire(l, LoanEventFund, nil, at)
+	}
+	return nil
 }
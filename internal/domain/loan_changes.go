@@ -0,0 +1,239 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoanChange is a fact in a loan's event stream. The stream is the loan's
+// source of truth: folding its changes in order rebuilds the loan, and the
+// loans table is a projection of it kept up to date in the same transaction.
+type LoanChange interface {
+	// ChangeType names the change in the event store.
+	ChangeType() string
+	// Apply records the change on the loan as of at. Transitions go through
+	// LoanLifecycle, so a change that does not fit the loan's state fails
+	// and leaves the loan unchanged.
+	Apply(l *Loan, at time.Time) error
+}
+
+type LoanCreated struct {
+	BorrowerIDNumber string  `json:"borrower_id_number"`
+	PrincipalAmount  Money   `json:"principal_amount"`
+	Rate             Percent `json:"rate"`
+	ROI              Percent `json:"roi"`
+	RepaymentTerms
+}
+
+type LoanApproved struct {
+	ApprovalDetails ApprovalDetails `json:"approval_details"`
+}
+
+type InvestmentAdded struct {
+	Investment Investment `json:"investment"`
+}
+
+// LoanFullyInvested follows the InvestmentAdded that completes the
+// principal.
+type LoanFullyInvested struct{}
+
+type AgreementLetterAttached struct {
+	AgreementLetterURL string `json:"agreement_letter_url"`
+}
+
+type LoanDisbursed struct {
+	DisbursementDetails DisbursementDetails `json:"disbursement_details"`
+}
+
+// RepaymentReceived records a repayment on a disbursed loan. It does not
+// settle the loan; LoanRepaid does.
+type RepaymentReceived struct {
+	Repayment Repayment `json:"repayment"`
+}
+
+// LoanRepaid follows the RepaymentReceived that settles the schedule.
+type LoanRepaid struct{}
+
+// LoanDefaulted declares a disbursed loan in default. Its investments stand:
+// investors keep whatever was repaid.
+type LoanDefaulted struct {
+	ClosureDetails ClosureDetails `json:"closure_details"`
+}
+
+// LoanClosed rejects, cancels or expires the loan, as given by Event, and
+// voids its investments.
+type LoanClosed struct {
+	Event          LoanEvent      `json:"event"`
+	ClosureDetails ClosureDetails `json:"closure_details"`
+}
+
+func (*LoanCreated) ChangeType() string             { return "LoanCreated" }
+func (*LoanApproved) ChangeType() string            { return "LoanApproved" }
+func (*InvestmentAdded) ChangeType() string         { return "InvestmentAdded" }
+func (*LoanFullyInvested) ChangeType() string       { return "LoanFullyInvested" }
+func (*AgreementLetterAttached) ChangeType() string { return "AgreementLetterAttached" }
+func (*LoanDisbursed) ChangeType() string           { return "LoanDisbursed" }
+func (*RepaymentReceived) ChangeType() string       { return "RepaymentReceived" }
+func (*LoanRepaid) ChangeType() string              { return "LoanRepaid" }
+func (*LoanDefaulted) ChangeType() string           { return "LoanDefaulted" }
+func (*LoanClosed) ChangeType() string              { return "LoanClosed" }
+
+// loanChangeTypes makes an empty change of each type for decoding.
+var loanChangeTypes = map[string]func() LoanChange{
+	"LoanCreated":             func() LoanChange { return new(LoanCreated) },
+	"LoanApproved":            func() LoanChange { return new(LoanApproved) },
+	"InvestmentAdded":         func() LoanChange { return new(InvestmentAdded) },
+	"LoanFullyInvested":       func() LoanChange { return new(LoanFullyInvested) },
+	"AgreementLetterAttached": func() LoanChange { return new(AgreementLetterAttached) },
+	"LoanDisbursed":           func() LoanChange { return new(LoanDisbursed) },
+	"RepaymentReceived":       func() LoanChange { return new(RepaymentReceived) },
+	"LoanRepaid":              func() LoanChange { return new(LoanRepaid) },
+	"LoanDefaulted":           func() LoanChange { return new(LoanDefaulted) },
+	"LoanClosed":              func() LoanChange { return new(LoanClosed) },
+}
+
+func (c *LoanCreated) Apply(l *Loan, at time.Time) error {
+	if l.State != "" {
+		return Errorf(ErrConflict, "loan %s already exists", l.ID)
+	}
+	l.BorrowerIDNumber = c.BorrowerIDNumber
+	l.PrincipalAmount = c.PrincipalAmount
+	l.Rate = c.Rate
+	l.ROI = c.ROI
+	l.RepaymentTerms = c.RepaymentTerms
+	l.State = LoanLifecycle.Initial
+	l.CreatedAt = at
+	l.UpdatedAt = at
+	return nil
+}
+
+func (c *LoanApproved) Apply(l *Loan, at time.Time) error {
+	details := c.ApprovalDetails
+	return LoanLifecycle.Fire(l, LoanEventApprove, &details, at)
+}
+
+// Apply records an investment that has already been accepted. It does not
+// fund the loan; LoanFullyInvested does.
+func (c *InvestmentAdded) Apply(l *Loan, _ time.Time) error {
+	if err := LoanLifecycle.Check(l, LoanEventFund); err != nil {
+		return err
+	}
+	l.Investments = append(l.Investments, c.Investment)
+	return nil
+}
+
+func (c *LoanFullyInvested) Apply(l *Loan, at time.Time) error {
+	return LoanLifecycle.Fire(l, LoanEventFund, nil, at)
+}
+
+func (c *AgreementLetterAttached) Apply(l *Loan, at time.Time) error {
+	l.AgreementLetterURL = c.AgreementLetterURL
+	l.UpdatedAt = at
+	return nil
+}
+
+func (c *LoanDisbursed) Apply(l *Loan, at time.Time) error {
+	details := c.DisbursementDetails
+	return LoanLifecycle.Fire(l, LoanEventDisburse, &details, at)
+}
+
+// Apply records a repayment that has already been allocated against the
+// schedule, which is not part of the loan.
+func (c *RepaymentReceived) Apply(l *Loan, at time.Time) error {
+	if err := LoanLifecycle.Check(l, LoanEventSettle); err != nil {
+		return err
+	}
+	l.UpdatedAt = at
+	return nil
+}
+
+func (c *LoanRepaid) Apply(l *Loan, at time.Time) error {
+	return LoanLifecycle.Fire(l, LoanEventSettle, nil, at)
+}
+
+func (c *LoanDefaulted) Apply(l *Loan, at time.Time) error {
+	details := c.ClosureDetails
+	return LoanLifecycle.Fire(l, LoanEventDefault, &details, at)
+}
+
+func (c *LoanClosed) Apply(l *Loan, at time.Time) error {
+	details := c.ClosureDetails
+	if err := LoanLifecycle.Fire(l, c.Event, &details, at); err != nil {
+		return err
+	}
+	for i := range l.Investments {
+		if l.Investments[i].VoidedAt == nil {
+			voidedAt := at
+			l.Investments[i].VoidedAt = &voidedAt
+		}
+	}
+	return nil
+}
+
+// LoanChangeRecord is a LoanChange as kept in the event store.
+type LoanChangeRecord struct {
+	LoanID uuid.UUID `json:"loan_id"`
+	// Sequence numbers the loan's stream from 1. The event store assigns it
+	// on append.
+	Sequence int64 `json:"sequence"`
+	// Version is the loan's version once the change is applied. Changes
+	// made together, such as the investment that funds a loan and the
+	// LoanFullyInvested that follows it, share a version.
+	Version    int64           `json:"version"`
+	Type       string          `json:"type"`
+	Data       json.RawMessage `json:"data"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// NewLoanChangeRecord records change as made to loan, which is at the
+// version the change brought it to.
+func NewLoanChangeRecord(loan *Loan, change LoanChange, at time.Time) (*LoanChangeRecord, error) {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return nil, err
+	}
+	return &LoanChangeRecord{
+		LoanID:     loan.ID,
+		Version:    loan.Version,
+		Type:       change.ChangeType(),
+		Data:       data,
+		OccurredAt: at,
+	}, nil
+}
+
+// Change decodes the recorded change.
+func (r *LoanChangeRecord) Change() (LoanChange, error) {
+	newChange, ok := loanChangeTypes[r.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown loan change type %q", r.Type)
+	}
+	change := newChange()
+	if err := json.Unmarshal(r.Data, change); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", r.Type, err)
+	}
+	return change, nil
+}
+
+// FoldLoan rebuilds a loan from its stream, which must start with
+// LoanCreated and be in sequence order.
+func FoldLoan(records []*LoanChangeRecord) (*Loan, error) {
+	if len(records) == 0 {
+		return nil, Errorf(ErrNotFound, "loan has no recorded changes")
+	}
+
+	loan := &Loan{ID: records[0].LoanID}
+	for _, r := range records {
+		change, err := r.Change()
+		if err != nil {
+			return nil, err
+		}
+		if err := change.Apply(loan, r.OccurredAt); err != nil {
+			return nil, fmt.Errorf("replaying %s #%d of loan %s: %w", r.Type, r.Sequence, r.LoanID, err)
+		}
+		loan.Version = r.Version
+	}
+	return loan, nil
+}
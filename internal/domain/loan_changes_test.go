@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFoldLoan(t *testing.T) {
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	loan := &Loan{ID: uuid.New()}
+	var records []*LoanChangeRecord
+	record := func(version int64, change LoanChange, at time.Time) {
+		loan.Version = version
+		r, err := NewLoanChangeRecord(loan, change, at)
+		require.NoError(t, err)
+		r.Sequence = int64(len(records)) + 1
+		records = append(records, r)
+	}
+
+	inv := Investment{ID: uuid.New(), LoanID: loan.ID, InvestorID: uuid.New(), Amount: NewMoney(100000, "IDR"), CreatedAt: start.Add(2 * time.Hour)}
+	record(1, &LoanCreated{BorrowerIDNumber: "B-1", PrincipalAmount: NewMoney(100000, "IDR"), Rate: 1000, ROI: 800}, start)
+	record(2, &LoanApproved{ApprovalDetails: ApprovalDetails{FieldValidatorID: "V1", ProofImageURL: "proof.jpg", ApprovedAt: start.Add(time.Hour)}}, start.Add(time.Hour))
+	record(3, &InvestmentAdded{Investment: inv}, inv.CreatedAt)
+	record(3, &LoanFullyInvested{}, inv.CreatedAt)
+	record(4, &AgreementLetterAttached{AgreementLetterURL: "agreement.pdf"}, start.Add(3*time.Hour))
+	record(5, &LoanDisbursed{DisbursementDetails: DisbursementDetails{FieldOfficerID: "F1", SignedAgreementURL: "signed.pdf", DisbursedAt: start.Add(4 * time.Hour)}}, start.Add(4*time.Hour))
+
+	got, err := FoldLoan(records)
+	require.NoError(t, err)
+	assert.Equal(t, loan.ID, got.ID)
+	assert.Equal(t, "B-1", got.BorrowerIDNumber)
+	assert.Equal(t, LoanStateDisbursed, got.State)
+	assert.Equal(t, int64(5), got.Version)
+	assert.Equal(t, start, got.CreatedAt)
+	assert.Equal(t, start.Add(4*time.Hour), got.UpdatedAt)
+	assert.Equal(t, []Investment{inv}, got.Investments)
+	assert.Equal(t, "agreement.pdf", got.AgreementLetterURL)
+	assert.Equal(t, "F1", got.DisbursementDetails.FieldOfficerID)
+
+	// A prefix of the stream is the loan as it was then.
+	got, err = FoldLoan(records[:3])
+	require.NoError(t, err)
+	assert.Equal(t, LoanStateApproved, got.State)
+	assert.True(t, got.IsFullyInvested())
+}
+
+func TestFoldLoanRejectsImpossibleStreams(t *testing.T) {
+	loan := &Loan{ID: uuid.New(), Version: 1}
+	at := time.Now()
+	created, err := NewLoanChangeRecord(loan, &LoanCreated{BorrowerIDNumber: "B-1", PrincipalAmount: NewMoney(100, "IDR"), Rate: 1, ROI: 1}, at)
+	require.NoError(t, err)
+	funded, err := NewLoanChangeRecord(loan, &LoanFullyInvested{}, at)
+	require.NoError(t, err)
+
+	_, err = FoldLoan(nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = FoldLoan([]*LoanChangeRecord{funded})
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+
+	_, err = FoldLoan([]*LoanChangeRecord{created, created})
+	assert.ErrorIs(t, err, ErrConflict)
+
+	_, err = FoldLoan([]*LoanChangeRecord{created, {LoanID: loan.ID, Type: "LoanRenamed", Data: []byte(`{}`)}})
+	assert.ErrorContains(t, err, `unknown loan change type "LoanRenamed"`)
+}
+
+func TestFoldLoanAfterDisbursement(t *testing.T) {
+	at := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	disbursed := func(t *testing.T) (*Loan, []*LoanChangeRecord) {
+		loan := &Loan{ID: uuid.New()}
+		var records []*LoanChangeRecord
+		for i, change := range []LoanChange{
+			&LoanCreated{BorrowerIDNumber: "B-1", PrincipalAmount: NewMoney(100, "IDR"), Rate: 1000, ROI: 800},
+			&LoanApproved{ApprovalDetails: ApprovalDetails{FieldValidatorID: "V1", ProofImageURL: "proof.jpg"}},
+			&InvestmentAdded{Investment: Investment{ID: uuid.New(), Amount: NewMoney(100, "IDR")}},
+			&LoanFullyInvested{},
+			&LoanDisbursed{DisbursementDetails: DisbursementDetails{FieldOfficerID: "F1", SignedAgreementURL: "signed.pdf"}},
+		} {
+			loan.Version = int64(i + 1)
+			r, err := NewLoanChangeRecord(loan, change, at)
+			require.NoError(t, err)
+			records = append(records, r)
+		}
+		return loan, records
+	}
+	record := func(t *testing.T, loan *Loan, change LoanChange, at time.Time) *LoanChangeRecord {
+		loan.Version++
+		r, err := NewLoanChangeRecord(loan, change, at)
+		require.NoError(t, err)
+		return r
+	}
+
+	t.Run("Repaid", func(t *testing.T) {
+		loan, records := disbursed(t)
+		paidAt := at.Add(30 * 24 * time.Hour)
+		repayment := Repayment{ID: uuid.New(), LoanID: loan.ID, Amount: NewMoney(110, "IDR"), PaidAt: paidAt}
+		records = append(records,
+			record(t, loan, &RepaymentReceived{Repayment: repayment}, paidAt),
+			record(t, loan, &LoanRepaid{}, paidAt))
+
+		got, err := FoldLoan(records)
+		require.NoError(t, err)
+		assert.Equal(t, LoanStateRepaid, got.State)
+		assert.Equal(t, paidAt, got.UpdatedAt)
+
+		got, err = FoldLoan(records[:len(records)-1])
+		require.NoError(t, err)
+		assert.Equal(t, LoanStateDisbursed, got.State, "a repayment alone does not settle the loan")
+	})
+
+	t.Run("Defaulted", func(t *testing.T) {
+		loan, records := disbursed(t)
+		closure := ClosureDetails{Reason: "installment 1 overdue", ClosedAt: at}
+		records = append(records, record(t, loan, &LoanDefaulted{ClosureDetails: closure}, at))
+
+		got, err := FoldLoan(records)
+		require.NoError(t, err)
+		assert.Equal(t, LoanStateDefaulted, got.State)
+		assert.Equal(t, &closure, got.ClosureDetails)
+		assert.Nil(t, got.Investments[0].VoidedAt, "investors keep their claim on a defaulted loan")
+
+		records = append(records, record(t, loan, &RepaymentReceived{}, at))
+		_, err = FoldLoan(records)
+		assert.ErrorIs(t, err, ErrInvalidTransition)
+	})
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLoanPageSize = 20
+	MaxLoanPageSize     = 100
+)
+
+var ErrInvalidCursor error = &Error{Kind: ErrValidation, Message: "invalid cursor"}
+
+// LoanSort orders a loan listing. A leading "-" sorts descending.
+type LoanSort string
+
+const (
+	LoanSortCreatedAtDesc LoanSort = "-created_at"
+	LoanSortCreatedAtAsc  LoanSort = "created_at"
+	LoanSortPrincipalDesc LoanSort = "-principal_amount"
+	LoanSortPrincipalAsc  LoanSort = "principal_amount"
+)
+
+func (s LoanSort) IsValid() bool {
+	switch s {
+	case LoanSortCreatedAtDesc, LoanSortCreatedAtAsc, LoanSortPrincipalDesc, LoanSortPrincipalAsc:
+		return true
+	}
+	return false
+}
+
+// LoanFilter narrows a loan listing. Zero-valued fields do not filter.
+type LoanFilter struct {
+	States           []LoanState
+	BorrowerIDNumber string
+	// MinPrincipal and MaxPrincipal are inclusive and also restrict the
+	// listing to their currency.
+	MinPrincipal *Money
+	MaxPrincipal *Money
+	// CreatedFrom is inclusive, CreatedTo exclusive.
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	InvestorID  uuid.UUID
+
+	Sort LoanSort
+	// Cursor is the NextCursor of the previous page; it is only valid with
+	// the same Sort.
+	Cursor string
+	Limit  int
+}
+
+type LoanPage struct {
+	Loans      []*Loan `json:"loans"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Events recorded in a loan's history that are not state machine
+// transitions.
+const (
+	LoanEventCreate LoanEvent = "create"
+	// LoanEventInvest records one investment. The investment that completes
+	// the principal also fires LoanEventFund, so its entry moves the loan to
+	// INVESTED.
+	LoanEventInvest          LoanEvent = "invest"
+	LoanEventAttachAgreement LoanEvent = "attach_agreement"
+	// LoanEventRepay records one repayment. The repayment that settles the
+	// schedule also fires LoanEventSettle, so its entry moves the loan to
+	// REPAID.
+	LoanEventRepay LoanEvent = "repay"
+)
+
+// LoanHistoryEntry records one change to a loan. Every change bumps the
+// loan's version, so Version orders a loan's history and identifies the
+// entry within it.
+type LoanHistoryEntry struct {
+	LoanID  uuid.UUID `json:"loan_id"`
+	Version int64     `json:"version"`
+	Event   LoanEvent `json:"event"`
+	// FromState is empty for LoanEventCreate.
+	FromState LoanState `json:"from_state,omitempty"`
+	ToState   LoanState `json:"to_state"`
+	// ActorID is who caused the change: the field validator or officer, the
+	// investor, or whoever closed the loan. It is empty for changes the
+	// service makes on its own.
+	ActorID    string          `json:"actor_id,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// NewLoanHistoryEntry records event as the change that brought loan to its
+// current state and version.
+func NewLoanHistoryEntry(loan *Loan, event LoanEvent, from LoanState, actorID string, payload any, at time.Time) (*LoanHistoryEntry, error) {
+	entry := &LoanHistoryEntry{
+		LoanID:     loan.ID,
+		Version:    loan.Version,
+		Event:      event,
+		FromState:  from,
+		ToState:    loan.State,
+		ActorID:    actorID,
+		OccurredAt: at,
+	}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		entry.Payload = data
+	}
+	return entry, nil
+}
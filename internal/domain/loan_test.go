@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddInvestment(t *testing.T) {
+	loan := &Loan{State: LoanStateApproved, PrincipalAmount: NewMoney(100000, "IDR")}
+	now := time.Now()
+
+	inv := &Investment{Amount: NewMoney(60000, "")}
+	require.NoError(t, loan.AddInvestment(inv, now))
+	assert.Equal(t, "IDR", inv.Amount.Currency)
+	assert.Equal(t, LoanStateApproved, loan.State)
+
+	assert.ErrorIs(t, loan.AddInvestment(&Investment{Amount: NewMoney(40001, "IDR")}, now), ErrOverInvestment)
+	assert.ErrorIs(t, loan.AddInvestment(&Investment{Amount: NewMoney(40000, "USD")}, now), ErrValidation)
+	assert.Len(t, loan.Investments, 1)
+
+	require.NoError(t, loan.AddInvestment(&Investment{Amount: NewMoney(40000, "IDR")}, now))
+	assert.Equal(t, LoanStateInvested, loan.State)
+	assert.Equal(t, now, loan.UpdatedAt)
+
+	assert.ErrorIs(t, loan.AddInvestment(&Investment{Amount: NewMoney(1, "IDR")}, now), ErrInvalidTransition)
+}
+
+func TestFullyInvestedAt(t *testing.T) {
+	first := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	voided := first.Add(48 * time.Hour)
+	loan := &Loan{
+		PrincipalAmount: NewMoney(100000, "IDR"),
+		Investments: []Investment{
+			{Amount: NewMoney(60000, "IDR"), CreatedAt: first},
+			{Amount: NewMoney(40000, "IDR"), CreatedAt: voided, VoidedAt: &voided},
+		},
+	}
+	_, ok := loan.FullyInvestedAt()
+	assert.False(t, ok)
+
+	loan.Investments = append(loan.Investments, Investment{Amount: NewMoney(40000, "IDR"), CreatedAt: first.Add(24 * time.Hour)})
+	loan.UpdatedAt = first.Add(30 * 24 * time.Hour)
+	at, ok := loan.FullyInvestedAt()
+	require.True(t, ok)
+	assert.Equal(t, first.Add(24*time.Hour), at)
+}
+
+func TestVoidedInvestmentsDoNotCount(t *testing.T) {
+	now := time.Now()
+	loan := &Loan{
+		State:           LoanStateApproved,
+		PrincipalAmount: NewMoney(100000, "IDR"),
+		Investments: []Investment{
+			{Amount: NewMoney(60000, "IDR"), VoidedAt: &now},
+			{Amount: NewMoney(30000, "IDR")},
+		},
+	}
+	assert.Equal(t, NewMoney(30000, "IDR"), loan.TotalInvestedAmount())
+	assert.Equal(t, NewMoney(70000, "IDR"), loan.RemainingAmount())
+}
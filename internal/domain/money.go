@@ -0,0 +1,293 @@
+package domain
+
+import (
+	"cmp"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the ISO 4217 code used when a request does not name one.
+const DefaultCurrency = "IDR"
+
+const (
+	// maxMoneyDigits matches the integer part of the DECIMAL(15,2) amount columns.
+	maxMoneyDigits = 13
+	// maxPercentDigits matches the integer part of the DECIMAL(5,2) rate columns.
+	maxPercentDigits = 3
+)
+
+var ErrInvalidDecimal error = &Error{Kind: ErrValidation, Message: "invalid decimal"}
+
+// Money is an exact amount of a currency, held in minor units (hundredths).
+// All amounts carry two decimal places, matching the database columns.
+type Money struct {
+	MinorUnits int64
+	Currency   string
+}
+
+func NewMoney(minorUnits int64, currency string) Money {
+	return Money{MinorUnits: minorUnits, Currency: currency}
+}
+
+// ParseMoney parses a decimal string such as "1000", "333.3" or "333.33".
+// Exponents, separators and more than two fractional digits are rejected.
+func ParseMoney(amount, currency string) (Money, error) {
+	minor, err := parseDecimal(amount, maxMoneyDigits)
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(minor, currency), nil
+}
+
+func (m Money) String() string {
+	return formatDecimal(m.MinorUnits)
+}
+
+func (m Money) IsZero() bool {
+	return m.MinorUnits == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.MinorUnits > 0
+}
+
+func (m Money) SameCurrency(other Money) bool {
+	return m.Currency == other.Currency
+}
+
+// Add panics if the currencies differ; callers check SameCurrency at the
+// boundary so a mismatch here is a programming error.
+func (m Money) Add(other Money) Money {
+	m.mustMatch(other)
+	return NewMoney(m.MinorUnits+other.MinorUnits, m.Currency)
+}
+
+func (m Money) Sub(other Money) Money {
+	m.mustMatch(other)
+	return NewMoney(m.MinorUnits-other.MinorUnits, m.Currency)
+}
+
+// Cmp returns -1, 0 or +1 depending on whether m is less than, equal to or
+// greater than other.
+func (m Money) Cmp(other Money) int {
+	m.mustMatch(other)
+	switch {
+	case m.MinorUnits < other.MinorUnits:
+		return -1
+	case m.MinorUnits > other.MinorUnits:
+		return 1
+	}
+	return 0
+}
+
+// Percent returns p percent of m, rounded half away from zero to the minor
+// unit.
+func (m Money) Percent(p Percent) Money {
+	return NewMoney(mulDivRound(m.MinorUnits, int64(p), 100*100), m.Currency)
+}
+
+// ShareOf returns m as a percentage of whole, rounded half away from zero
+// to two decimal places. whole must not be zero.
+func (m Money) ShareOf(whole Money) Percent {
+	m.mustMatch(whole)
+	return Percent(mulDivRound(m.MinorUnits, 100*100, whole.MinorUnits))
+}
+
+// Split divides m, which must not be negative, in proportion to weights,
+// which must be in m's currency and add up to a positive amount. The parts
+// add up to m exactly: minor units left over from rounding down go to the
+// parts that lost the most, earlier parts first.
+func (m Money) Split(weights []Money) []Money {
+	var total int64
+	for _, w := range weights {
+		m.mustMatch(w)
+		total += w.MinorUnits
+	}
+	if total <= 0 {
+		panic("domain: splitting money by weights that are not positive")
+	}
+
+	parts := make([]Money, len(weights))
+	remainders := make([]int64, len(weights))
+	left := m.MinorUnits
+	for i, w := range weights {
+		n := new(big.Int).Mul(big.NewInt(m.MinorUnits), big.NewInt(w.MinorUnits))
+		q, r := new(big.Int).QuoRem(n, big.NewInt(total), new(big.Int))
+		parts[i] = NewMoney(q.Int64(), m.Currency)
+		remainders[i] = r.Int64()
+		left -= q.Int64()
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(remainders[b], remainders[a])
+	})
+	for _, i := range order[:left] {
+		parts[i].MinorUnits++
+	}
+	return parts
+}
+
+func (m Money) mustMatch(other Money) {
+	if !m.SameCurrency(other) {
+		panic(fmt.Sprintf("domain: currency mismatch %q and %q", m.Currency, other.Currency))
+	}
+}
+
+type moneyJSON struct {
+	Amount   *string `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	amount := m.String()
+	return json.Marshal(moneyJSON{Amount: &amount, Currency: m.Currency})
+}
+
+// UnmarshalJSON only accepts {"amount": "<decimal>", "currency": "<code>"};
+// JSON numbers are rejected so amounts never pass through a float.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw moneyJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Amount == nil {
+		return fmt.Errorf("%w: money amount is required", ErrInvalidDecimal)
+	}
+	parsed, err := ParseMoney(*raw.Amount, raw.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Percent is an exact percentage with two decimal places, held in hundredths
+// of a percent: 5.25% is Percent(525).
+type Percent int64
+
+func ParsePercent(s string) (Percent, error) {
+	v, err := parseDecimal(s, maxPercentDigits)
+	if err != nil {
+		return 0, err
+	}
+	return Percent(v), nil
+}
+
+func (p Percent) String() string {
+	return formatDecimal(int64(p))
+}
+
+func (p Percent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+func (p *Percent) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParsePercent(s)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// Value stores the percentage as its decimal string so DECIMAL columns
+// receive it without a float conversion.
+func (p Percent) Value() (driver.Value, error) {
+	return p.String(), nil
+}
+
+func (p *Percent) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("domain: cannot scan %T into Percent", src)
+	}
+	parsed, err := ParsePercent(s)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// parseDecimal converts a plain decimal string with at most two fractional
+// digits into hundredths.
+func parseDecimal(s string, maxIntDigits int) (int64, error) {
+	digits := s
+	negative := strings.HasPrefix(digits, "-")
+	if negative {
+		digits = digits[1:]
+	}
+
+	intPart, fracPart, hasFrac := strings.Cut(digits, ".")
+	if intPart == "" || len(intPart) > maxIntDigits || (hasFrac && (fracPart == "" || len(fracPart) > 2)) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidDecimal, s)
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidDecimal, s)
+	}
+
+	for len(fracPart) < 2 {
+		fracPart += "0"
+	}
+	v, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidDecimal, s)
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+// mulDivRound returns a*b/c rounded half away from zero, without
+// overflowing in the product.
+func mulDivRound(a, b, c int64) int64 {
+	n := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	d := big.NewInt(c)
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	twiceR := new(big.Int).Lsh(new(big.Int).Abs(r), 1)
+	if twiceR.Cmp(new(big.Int).Abs(d)) >= 0 {
+		if n.Sign()*d.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q.Int64()
+}
+
+func formatDecimal(v int64) string {
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := []struct {
+		in    string
+		minor int64
+		ok    bool
+	}{
+		{"1000", 100000, true},
+		{"333.3", 33330, true},
+		{"333.33", 33333, true},
+		{"0.01", 1, true},
+		{"-5.50", -550, true},
+		{"333.333", 0, false},
+		{"1e3", 0, false},
+		{"1,000.00", 0, false},
+		{".50", 0, false},
+		{"10.", 0, false},
+		{"", 0, false},
+		{"12345678901234", 0, false},
+	}
+
+	for _, tc := range cases {
+		m, err := ParseMoney(tc.in, "IDR")
+		if !tc.ok {
+			assert.ErrorIs(t, err, ErrInvalidDecimal, tc.in)
+			continue
+		}
+		require.NoError(t, err, tc.in)
+		assert.Equal(t, tc.minor, m.MinorUnits, tc.in)
+	}
+}
+
+func TestIsFullyInvestedIsExact(t *testing.T) {
+	loan := &Loan{PrincipalAmount: NewMoney(100000, "IDR")}
+	for _, amount := range []string{"333.33", "333.33", "333.34"} {
+		m, err := ParseMoney(amount, "IDR")
+		require.NoError(t, err)
+		loan.Investments = append(loan.Investments, Investment{Amount: m})
+	}
+
+	assert.True(t, loan.IsFullyInvested())
+	assert.True(t, loan.RemainingAmount().IsZero())
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(NewMoney(123405, "IDR"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"1234.05","currency":"IDR"}`, string(data))
+
+	var m Money
+	require.NoError(t, json.Unmarshal(data, &m))
+	assert.Equal(t, NewMoney(123405, "IDR"), m)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount":1234.05,"currency":"IDR"}`), &m))
+}
+
+func TestPercentJSON(t *testing.T) {
+	data, err := json.Marshal(Percent(525))
+	require.NoError(t, err)
+	assert.Equal(t, `"5.25"`, string(data))
+
+	var p Percent
+	assert.Error(t, json.Unmarshal([]byte(`5.25`), &p))
+	require.NoError(t, json.Unmarshal([]byte(`"5.25"`), &p))
+	assert.Equal(t, Percent(525), p)
+}
+
+func TestMoneyPercentAndShare(t *testing.T) {
+	cases := []struct {
+		amount int64
+		pct    Percent
+		want   int64
+	}{
+		{100000, Percent(1000), 10000},                      // 10% of 1000.00
+		{333, Percent(5000), 167},                           // 1.665 rounds up
+		{-333, Percent(5000), -167},                         // and away from zero
+		{100, Percent(1), 0},                                // 0.0001 rounds down
+		{999999999999999, Percent(99999), 9999899999999990}, // product overflows int64
+	}
+	for _, c := range cases {
+		assert.Equal(t, NewMoney(c.want, "IDR"), NewMoney(c.amount, "IDR").Percent(c.pct), "%d x %s%%", c.amount, c.pct)
+	}
+
+	principal := NewMoney(300000, "IDR")
+	assert.Equal(t, Percent(3333), NewMoney(100000, "IDR").ShareOf(principal))
+	assert.Equal(t, Percent(6667), NewMoney(200000, "IDR").ShareOf(principal))
+	assert.Equal(t, Percent(10000), principal.ShareOf(principal))
+}
+
+func TestMoneySplit(t *testing.T) {
+	idr := func(units ...int64) []Money {
+		out := make([]Money, len(units))
+		for i, u := range units {
+			out[i] = NewMoney(u, "IDR")
+		}
+		return out
+	}
+	cases := []struct {
+		amount  int64
+		weights []Money
+		want    []Money
+	}{
+		{100, idr(1, 1, 1), idr(34, 33, 33)},
+		{1000, idr(100000, 200000), idr(333, 667)},
+		{0, idr(5, 7), idr(0, 0)},
+		{999999999999999, idr(1, 1), idr(500000000000000, 499999999999999)},
+		{10, idr(0, 3), idr(0, 10)},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, NewMoney(c.amount, "IDR").Split(c.weights), "%d by %v", c.amount, c.weights)
+	}
+
+	assert.Panics(t, func() { NewMoney(10, "IDR").Split(idr(0)) })
+	assert.Panics(t, func() { NewMoney(10, "IDR").Split([]Money{NewMoney(1, "USD")}) })
+}
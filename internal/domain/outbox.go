@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending OutboxStatus = "PENDING"
+	OutboxStatusSent    OutboxStatus = "SENT"
+	// OutboxStatusDead marks a message that ran out of attempts. It stays in
+	// the outbox until an operator retries it.
+	OutboxStatusDead OutboxStatus = "DEAD"
+)
+
+func (s OutboxStatus) IsValid() bool {
+	switch s {
+	case OutboxStatusPending, OutboxStatusSent, OutboxStatusDead:
+		return true
+	}
+	return false
+}
+
+// Outbox topics.
+const (
+	// TopicAgreementLetter generates the agreement letter of a loan that has
+	// become fully invested, attaches it to the loan and queues its
+	// agreement emails. Payload: AgreementLetter.
+	TopicAgreementLetter = "agreement_letter"
+	// TopicInvestmentAgreementEmail sends an investor the agreement letter of
+	// a loan they funded. Payload: InvestmentAgreementEmail.
+	TopicInvestmentAgreementEmail = "investment_agreement_email"
+	// TopicInvestmentRefund notifies an investor that their voided
+	// investment was refunded; the funds are released to their wallet when
+	// the loan is closed, not by this message. Payload: InvestmentRefund.
+	TopicInvestmentRefund = "investment_refund"
+)
+
+// OutboxMessage is a side effect recorded in the same transaction as the
+// state change that causes it and delivered afterwards by a dispatcher, at
+// least once.
+type OutboxMessage struct {
+	ID            uuid.UUID       `json:"id"`
+	Topic         string          `json:"topic"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        OutboxStatus    `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	SentAt        *time.Time      `json:"sent_at,omitempty"`
+}
+
+type AgreementLetter struct {
+	LoanID uuid.UUID `json:"loan_id"`
+}
+
+type InvestmentAgreementEmail struct {
+	LoanID     uuid.UUID `json:"loan_id"`
+	InvestorID uuid.UUID `json:"investor_id"`
+}
+
+type InvestmentRefund struct {
+	LoanID       uuid.UUID `json:"loan_id"`
+	InvestmentID uuid.UUID `json:"investment_id"`
+	InvestorID   uuid.UUID `json:"investor_id"`
+	Amount       Money     `json:"amount"`
+}
+
+// NewOutboxMessage builds a pending message due immediately.
+func NewOutboxMessage(topic string, payload any, at time.Time) (*OutboxMessage, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxMessage{
+		ID:            uuid.New(),
+		Topic:         topic,
+		Payload:       data,
+		Status:        OutboxStatusPending,
+		NextAttemptAt: at,
+		CreatedAt:     at,
+	}, nil
+}
@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Holding is an investment together with the parts of its loan a portfolio
+// needs.
+type Holding struct {
+	Investment    Investment
+	LoanState     LoanState
+	LoanPrincipal Money
+	LoanROI       Percent
+}
+
+// PortfolioInvestment is one of an investor's investments as shown in their
+// portfolio.
+type PortfolioInvestment struct {
+	InvestmentID uuid.UUID `json:"investment_id"`
+	LoanID       uuid.UUID `json:"loan_id"`
+	LoanState    LoanState `json:"loan_state"`
+	Amount       Money     `json:"amount"`
+	// Share is the investment's share of the loan's principal.
+	Share Percent `json:"share"`
+	ROI   Percent `json:"roi"`
+	// ExpectedReturn is the loan's ROI applied to the amount, on top of the
+	// amount itself. Refunded investments return nothing.
+	ExpectedReturn Money      `json:"expected_return"`
+	InvestedAt     time.Time  `json:"invested_at"`
+	RefundedAt     *time.Time `json:"refunded_at,omitempty"`
+}
+
+// PortfolioTotal sums a group of an investor's investments in one currency.
+type PortfolioTotal struct {
+	// State is the loan state the group is in, or empty for a total across
+	// states.
+	State          LoanState `json:"state,omitempty"`
+	Investments    int       `json:"investments"`
+	Invested       Money     `json:"invested"`
+	ExpectedReturn Money     `json:"expected_return"`
+}
+
+// Portfolio is what an investor has put into loans and expects to earn.
+type Portfolio struct {
+	InvestorID  uuid.UUID             `json:"investor_id"`
+	Investments []PortfolioInvestment `json:"investments"`
+	// ByState totals the investments by loan state and currency, in
+	// lifecycle order.
+	ByState []PortfolioTotal `json:"by_state"`
+	// Total totals the investments that have not been refunded, by
+	// currency.
+	Total []PortfolioTotal `json:"total"`
+}
+
+// NewPortfolio builds the investor's portfolio from their holdings, keeping
+// their order.
+func NewPortfolio(investorID uuid.UUID, holdings []Holding) *Portfolio {
+	p := &Portfolio{
+		InvestorID:  investorID,
+		Investments: []PortfolioInvestment{},
+		ByState:     []PortfolioTotal{},
+		Total:       []PortfolioTotal{},
+	}
+
+	type key struct {
+		state    LoanState
+		currency string
+	}
+	totals := make(map[key]*PortfolioTotal)
+	add := func(k key, inv PortfolioInvestment) {
+		t, ok := totals[k]
+		if !ok {
+			zero := NewMoney(0, k.currency)
+			t = &PortfolioTotal{State: k.state, Invested: zero, ExpectedReturn: zero}
+			totals[k] = t
+		}
+		t.Investments++
+		t.Invested = t.Invested.Add(inv.Amount)
+		t.ExpectedReturn = t.ExpectedReturn.Add(inv.ExpectedReturn)
+	}
+
+	for _, h := range holdings {
+		inv := PortfolioInvestment{
+			InvestmentID:   h.Investment.ID,
+			LoanID:         h.Investment.LoanID,
+			LoanState:      h.LoanState,
+			Amount:         h.Investment.Amount,
+			Share:          h.Investment.Amount.ShareOf(h.LoanPrincipal),
+			ROI:            h.LoanROI,
+			ExpectedReturn: h.Investment.Amount.Percent(h.LoanROI),
+			InvestedAt:     h.Investment.CreatedAt,
+			RefundedAt:     h.Investment.VoidedAt,
+		}
+		if inv.RefundedAt != nil {
+			inv.ExpectedReturn = NewMoney(0, inv.Amount.Currency)
+		}
+		p.Investments = append(p.Investments, inv)
+
+		add(key{h.LoanState, inv.Amount.Currency}, inv)
+		if inv.RefundedAt == nil {
+			add(key{"", inv.Amount.Currency}, inv)
+		}
+	}
+
+	states := LoanLifecycle.states()
+	for k, t := range totals {
+		if k.state == "" {
+			p.Total = append(p.Total, *t)
+		} else {
+			p.ByState = append(p.ByState, *t)
+		}
+	}
+	byCurrency := func(a, b PortfolioTotal) int {
+		return strings.Compare(a.Invested.Currency, b.Invested.Currency)
+	}
+	slices.SortFunc(p.ByState, func(a, b PortfolioTotal) int {
+		return cmp.Or(
+			cmp.Compare(slices.Index(states, a.State), slices.Index(states, b.State)),
+			byCurrency(a, b),
+		)
+	})
+	slices.SortFunc(p.Total, byCurrency)
+	return p
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPortfolio(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	holding := func(state LoanState, amount, principal int64, currency string, roi Percent) Holding {
+		return Holding{
+			Investment: Investment{
+				ID: uuid.New(), LoanID: uuid.New(),
+				Amount: NewMoney(amount, currency), CreatedAt: now,
+			},
+			LoanState:     state,
+			LoanPrincipal: NewMoney(principal, currency),
+			LoanROI:       roi,
+		}
+	}
+	refunded := holding(LoanStateCancelled, 100000, 400000, "IDR", Percent(1000))
+	refunded.Investment.VoidedAt = &now
+	holdings := []Holding{
+		holding(LoanStateDisbursed, 100000, 300000, "IDR", Percent(1000)),
+		holding(LoanStateApproved, 50000, 200000, "IDR", Percent(1250)),
+		refunded,
+		holding(LoanStateApproved, 20000, 20000, "USD", Percent(800)),
+		holding(LoanStateDisbursed, 200000, 300000, "IDR", Percent(1000)),
+	}
+
+	p := NewPortfolio(uuid.Nil, holdings)
+
+	require.Len(t, p.Investments, 5)
+	first := p.Investments[0]
+	assert.Equal(t, holdings[0].Investment.ID, first.InvestmentID)
+	assert.Equal(t, Percent(3333), first.Share)
+	assert.Equal(t, NewMoney(10000, "IDR"), first.ExpectedReturn)
+	assert.Equal(t, NewMoney(0, "IDR"), p.Investments[2].ExpectedReturn, "refunded investments return nothing")
+	assert.Equal(t, &now, p.Investments[2].RefundedAt)
+
+	assert.Equal(t, []PortfolioTotal{
+		{State: LoanStateApproved, Investments: 1, Invested: NewMoney(50000, "IDR"), ExpectedReturn: NewMoney(6250, "IDR")},
+		{State: LoanStateApproved, Investments: 1, Invested: NewMoney(20000, "USD"), ExpectedReturn: NewMoney(1600, "USD")},
+		{State: LoanStateDisbursed, Investments: 2, Invested: NewMoney(300000, "IDR"), ExpectedReturn: NewMoney(30000, "IDR")},
+		{State: LoanStateCancelled, Investments: 1, Invested: NewMoney(100000, "IDR"), ExpectedReturn: NewMoney(0, "IDR")},
+	}, p.ByState)
+	assert.Equal(t, []PortfolioTotal{
+		{Investments: 3, Invested: NewMoney(350000, "IDR"), ExpectedReturn: NewMoney(36250, "IDR")},
+		{Investments: 1, Invested: NewMoney(20000, "USD"), ExpectedReturn: NewMoney(1600, "USD")},
+	}, p.Total, "refunded investments are left out of the total")
+
+	empty := NewPortfolio(uuid.Nil, nil)
+	assert.NotNil(t, empty.Investments)
+	assert.Empty(t, empty.ByState)
+}
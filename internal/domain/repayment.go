@@ -0,0 +1,186 @@
+package domain
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LateFeeRate is charged on the amount of an installment still owed the day
+// after it was due. Each installment is charged once.
+const LateFeeRate = Percent(500)
+
+// Repayment is a payment the borrower made on a disbursed loan and how it
+// was allocated.
+type Repayment struct {
+	ID     uuid.UUID `json:"id"`
+	LoanID uuid.UUID `json:"loan_id"`
+	Amount Money     `json:"amount"`
+	// Interest, Principal and LateFee split Amount by what it paid off.
+	Interest  Money `json:"interest"`
+	Principal Money `json:"principal"`
+	LateFee   Money `json:"late_fee"`
+	// PlatformFee is what the platform keeps: the late fee, and the
+	// interest the borrower pays over the ROI promised to investors.
+	PlatformFee Money     `json:"platform_fee"`
+	Payouts     []Payout  `json:"payouts"`
+	PaidAt      time.Time `json:"paid_at"`
+}
+
+// Payout is an investor's part of a repayment, in proportion to their
+// investment in the loan.
+type Payout struct {
+	InvestmentID uuid.UUID `json:"investment_id"`
+	InvestorID   uuid.UUID `json:"investor_id"`
+	Principal    Money     `json:"principal"`
+	Interest     Money     `json:"interest"`
+	Amount       Money     `json:"amount"`
+}
+
+func NewPayout(investmentID, investorID uuid.UUID, principal, interest Money) Payout {
+	return Payout{
+		InvestmentID: investmentID,
+		InvestorID:   investorID,
+		Principal:    principal,
+		Interest:     interest,
+		Amount:       principal.Add(interest),
+	}
+}
+
+// NewRepayment applies a payment of amount made at at to the loan's
+// schedule s, which it updates. An amount without a currency is taken to
+// be in the loan's currency.
+//
+// Overdue installments are charged LateFeeRate first. The payment then
+// pays off installments oldest first, each one's interest, then its
+// principal, then its late fee; it may not exceed what is outstanding.
+// Investors are paid the principal, and the share of the interest that
+// makes up the loan's ROI over the whole schedule, in proportion to their
+// investments.
+func NewRepayment(l *Loan, s *RepaymentSchedule, amount Money, at time.Time) (*Repayment, error) {
+	if err := LoanLifecycle.Check(l, LoanEventSettle); err != nil {
+		return nil, err
+	}
+	if amount.Currency == "" {
+		amount.Currency = l.PrincipalAmount.Currency
+	}
+	if !amount.SameCurrency(l.PrincipalAmount) {
+		return nil, Errorf(ErrValidation, "repayment currency %s does not match loan currency %s",
+			amount.Currency, l.PrincipalAmount.Currency)
+	}
+	if !amount.IsPositive() {
+		return nil, Errorf(ErrValidation, "repayment amount must be positive")
+	}
+
+	installments := slices.Clone(s.Installments)
+	for i := range installments {
+		inst := &installments[i]
+		if inst.OverdueAt(at) && inst.LateFee.IsZero() {
+			inst.LateFee = inst.Outstanding().Percent(LateFeeRate)
+		}
+	}
+	outstanding := NewMoney(0, amount.Currency)
+	for _, inst := range installments {
+		outstanding = outstanding.Add(inst.Outstanding())
+	}
+	if amount.Cmp(outstanding) > 0 {
+		return nil, Errorf(ErrValidation, "repayment amount %s exceeds outstanding %s", amount, outstanding)
+	}
+
+	zero := NewMoney(0, amount.Currency)
+	r := &Repayment{
+		ID:        uuid.New(),
+		LoanID:    l.ID,
+		Amount:    amount,
+		Interest:  zero,
+		Principal: zero,
+		LateFee:   zero,
+		Payouts:   []Payout{},
+		PaidAt:    at,
+	}
+	paidInterest := zero
+	for _, inst := range installments {
+		paidInterest = paidInterest.Add(inst.PaidInterest)
+	}
+
+	left := amount
+	pay := func(owed Money, paid *Money) Money {
+		part := owed.Sub(*paid)
+		if left.Cmp(part) < 0 {
+			part = left
+		}
+		*paid = paid.Add(part)
+		left = left.Sub(part)
+		return part
+	}
+	for i := range installments {
+		if !left.IsPositive() {
+			break
+		}
+		inst := &installments[i]
+		if !inst.Outstanding().IsPositive() {
+			continue
+		}
+		r.Interest = r.Interest.Add(pay(inst.Interest, &inst.PaidInterest))
+		r.Principal = r.Principal.Add(pay(inst.Principal, &inst.PaidPrincipal))
+		r.LateFee = r.LateFee.Add(pay(inst.LateFee, &inst.PaidLateFee))
+		if !inst.Outstanding().IsPositive() {
+			settledAt := at
+			inst.SettledAt = &settledAt
+		}
+	}
+
+	// Investors' interest is worked out on the interest paid so far, so
+	// that rounding does not add up across repayments.
+	investorsInterest := investorsInterest(l, s, paidInterest.Add(r.Interest)).
+		Sub(investorsInterest(l, s, paidInterest))
+	r.payOut(l, investorsInterest)
+
+	s.Installments = installments
+	s.total()
+	return r, nil
+}
+
+// investorsInterest is the investors' part of paidInterest: the loan's ROI
+// on its principal, in proportion to how much of the schedule's interest
+// has been paid, and never more than was paid.
+func investorsInterest(l *Loan, s *RepaymentSchedule, paidInterest Money) Money {
+	if !s.TotalInterest.IsPositive() {
+		return NewMoney(0, paidInterest.Currency)
+	}
+	promised := l.PrincipalAmount.Percent(l.ROI)
+	share := NewMoney(mulDivRound(promised.MinorUnits, paidInterest.MinorUnits, s.TotalInterest.MinorUnits), paidInterest.Currency)
+	if share.Cmp(paidInterest) > 0 {
+		return paidInterest
+	}
+	return share
+}
+
+// payOut splits the repayment's principal and the investors' interest
+// across the loan's investments, and leaves the rest to the platform.
+func (r *Repayment) payOut(l *Loan, interest Money) {
+	var investments []Investment
+	var weights []Money
+	for _, inv := range l.Investments {
+		if inv.VoidedAt == nil {
+			investments = append(investments, inv)
+			weights = append(weights, inv.Amount)
+		}
+	}
+
+	paidOut := NewMoney(0, r.Amount.Currency)
+	if len(investments) > 0 {
+		principals := r.Principal.Split(weights)
+		interests := interest.Split(weights)
+		for i, inv := range investments {
+			if principals[i].IsZero() && interests[i].IsZero() {
+				continue
+			}
+			p := NewPayout(inv.ID, inv.InvestorID, principals[i], interests[i])
+			r.Payouts = append(r.Payouts, p)
+			paidOut = paidOut.Add(p.Amount)
+		}
+	}
+	r.PlatformFee = r.Amount.Sub(paidOut)
+}
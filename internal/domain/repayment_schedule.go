@@ -0,0 +1,261 @@
+package domain
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RepaymentType is how a loan's principal and interest are spread over its
+// tenor. Every type has one installment a month; they differ in how much of
+// each is principal and how interest is charged.
+type RepaymentType string
+
+const (
+	// RepaymentFlat repays the principal in equal parts, with interest
+	// charged on the original principal every month.
+	RepaymentFlat RepaymentType = "FLAT"
+	// RepaymentEMI repays in equal monthly installments, with interest
+	// charged on the reducing balance.
+	RepaymentEMI RepaymentType = "EMI"
+	// RepaymentBullet pays interest every month and the whole principal with
+	// the last installment.
+	RepaymentBullet RepaymentType = "BULLET"
+)
+
+func (t RepaymentType) IsValid() bool {
+	switch t {
+	case RepaymentFlat, RepaymentEMI, RepaymentBullet:
+		return true
+	}
+	return false
+}
+
+// MaxTenorMonths matches the loans_repayment_terms_check constraint.
+const MaxTenorMonths = 360
+
+// DefaultTenorMonths is the tenor of a loan whose proposal does not name one.
+const DefaultTenorMonths = 12
+
+// RepaymentTerms say over how many months, and how, the borrower repays a
+// loan. Loans proposed before terms were recorded have none, and get no
+// repayment schedule.
+type RepaymentTerms struct {
+	TenorMonths   int           `json:"tenor_months,omitempty"`
+	RepaymentType RepaymentType `json:"repayment_type,omitempty"`
+}
+
+func (t RepaymentTerms) IsZero() bool {
+	return t == RepaymentTerms{}
+}
+
+func (t RepaymentTerms) Validate() error {
+	if t.TenorMonths < 1 || t.TenorMonths > MaxTenorMonths {
+		return Errorf(ErrValidation, "tenor must be between 1 and %d months", MaxTenorMonths)
+	}
+	if !t.RepaymentType.IsValid() {
+		return Errorf(ErrValidation, "unknown repayment type %q", t.RepaymentType)
+	}
+	return nil
+}
+
+// Installment is one payment due from the borrower, and how much of it has
+// been paid.
+type Installment struct {
+	Number int `json:"number"`
+	// DueDate is a calendar date, held as midnight UTC.
+	DueDate   time.Time `json:"due_date"`
+	Principal Money     `json:"principal"`
+	Interest  Money     `json:"interest"`
+	// Amount is the principal and interest due together.
+	Amount Money `json:"amount"`
+	// LateFee is charged on top of Amount once the installment is overdue.
+	LateFee       Money `json:"late_fee"`
+	PaidInterest  Money `json:"paid_interest"`
+	PaidPrincipal Money `json:"paid_principal"`
+	PaidLateFee   Money `json:"paid_late_fee"`
+	// SettledAt is when the installment was paid in full.
+	SettledAt *time.Time `json:"settled_at,omitempty"`
+}
+
+// NewInstallment returns an installment nothing has been paid on yet.
+func NewInstallment(number int, dueDate time.Time, principal, interest Money) Installment {
+	zero := NewMoney(0, principal.Currency)
+	return Installment{
+		Number:        number,
+		DueDate:       dueDate,
+		Principal:     principal,
+		Interest:      interest,
+		Amount:        principal.Add(interest),
+		LateFee:       zero,
+		PaidInterest:  zero,
+		PaidPrincipal: zero,
+		PaidLateFee:   zero,
+	}
+}
+
+// Paid is what has been paid on the installment, late fee included.
+func (i Installment) Paid() Money {
+	return i.PaidInterest.Add(i.PaidPrincipal).Add(i.PaidLateFee)
+}
+
+// Outstanding is what is left to pay on the installment, late fee
+// included.
+func (i Installment) Outstanding() Money {
+	return i.Amount.Add(i.LateFee).Sub(i.Paid())
+}
+
+// OverdueAt reports whether the installment is still owed on at's date,
+// in UTC, after the day it was due.
+func (i Installment) OverdueAt(at time.Time) bool {
+	y, m, d := at.UTC().Date()
+	return i.Outstanding().IsPositive() && i.DueDate.Before(time.Date(y, m, d, 0, 0, 0, 0, time.UTC))
+}
+
+// RepaymentSchedule is what the borrower owes on a disbursed loan, when,
+// and how much of it they have paid.
+type RepaymentSchedule struct {
+	LoanID uuid.UUID `json:"loan_id"`
+	RepaymentTerms
+	Rate          Percent       `json:"rate"`
+	Principal     Money         `json:"principal"`
+	Installments  []Installment `json:"installments"`
+	TotalInterest Money         `json:"total_interest"`
+	// TotalAmount is the principal and interest due over the schedule,
+	// without late fees.
+	TotalAmount   Money `json:"total_amount"`
+	TotalLateFees Money `json:"total_late_fees"`
+	TotalPaid     Money `json:"total_paid"`
+	Outstanding   Money `json:"outstanding"`
+}
+
+// NewRepaymentSchedule totals the loan's installments, which are in order.
+func NewRepaymentSchedule(l *Loan, installments []Installment) *RepaymentSchedule {
+	s := &RepaymentSchedule{
+		LoanID:         l.ID,
+		RepaymentTerms: l.RepaymentTerms,
+		Rate:           l.Rate,
+		Principal:      l.PrincipalAmount,
+		Installments:   installments,
+	}
+	if s.Installments == nil {
+		s.Installments = []Installment{}
+	}
+	s.total()
+	return s
+}
+
+func (s *RepaymentSchedule) total() {
+	zero := NewMoney(0, s.Principal.Currency)
+	s.TotalInterest, s.TotalAmount, s.TotalLateFees, s.TotalPaid, s.Outstanding = zero, zero, zero, zero, zero
+	for _, inst := range s.Installments {
+		s.TotalInterest = s.TotalInterest.Add(inst.Interest)
+		s.TotalAmount = s.TotalAmount.Add(inst.Amount)
+		s.TotalLateFees = s.TotalLateFees.Add(inst.LateFee)
+		s.TotalPaid = s.TotalPaid.Add(inst.Paid())
+		s.Outstanding = s.Outstanding.Add(inst.Outstanding())
+	}
+}
+
+// FirstUnpaid returns the earliest installment still owed, or nil once the
+// schedule is settled.
+func (s *RepaymentSchedule) FirstUnpaid() *Installment {
+	for i := range s.Installments {
+		if s.Installments[i].Outstanding().IsPositive() {
+			return &s.Installments[i]
+		}
+	}
+	return nil
+}
+
+// ScheduleInstallments works out the installments of a loan disbursed at
+// disbursedAt from its principal, its Rate taken as a yearly rate, and its
+// repayment terms. The first installment is due a month after disbursement;
+// due dates falling past the end of a month move back to its last day.
+// Amounts are rounded half away from zero to the minor unit, and the last
+// installment repays whatever principal rounding left over.
+func ScheduleInstallments(l *Loan, disbursedAt time.Time) ([]Installment, error) {
+	if err := l.RepaymentTerms.Validate(); err != nil {
+		return nil, err
+	}
+
+	n := l.TenorMonths
+	principal := l.PrincipalAmount.MinorUnits
+	currency := l.PrincipalAmount.Currency
+	start := disbursedAt.UTC()
+
+	// monthlyInterest is a month's interest on balance: the yearly rate, in
+	// hundredths of a percent, over 100 * 100 * 12.
+	monthlyInterest := func(balance int64) int64 {
+		return mulDivRound(balance, int64(l.Rate), 120000)
+	}
+
+	var payment int64
+	if l.RepaymentType == RepaymentEMI {
+		payment = emiPayment(principal, l.Rate, n)
+	}
+
+	installments := make([]Installment, 0, n)
+	balance := principal
+	for i := 1; i <= n; i++ {
+		var p, interest int64
+		switch l.RepaymentType {
+		case RepaymentFlat:
+			p = principal / int64(n)
+			interest = monthlyInterest(principal)
+		case RepaymentEMI:
+			interest = monthlyInterest(balance)
+			p = min(payment-interest, balance)
+		case RepaymentBullet:
+			interest = monthlyInterest(principal)
+		}
+		if i == n {
+			p = balance
+		}
+		balance -= p
+
+		installments = append(installments, NewInstallment(i, addMonths(start, i),
+			NewMoney(p, currency), NewMoney(interest, currency)))
+	}
+	return installments, nil
+}
+
+// emiPayment returns the equal monthly installment repaying principal over
+// n months at the yearly rate: P·r / (1 - (1+r)^-n) with r the monthly
+// rate, rounded half away from zero.
+func emiPayment(principal int64, rate Percent, n int) int64 {
+	if rate == 0 {
+		return (principal + int64(n) - 1) / int64(n)
+	}
+
+	const prec = 256
+	r := new(big.Float).SetPrec(prec).Quo(
+		new(big.Float).SetPrec(prec).SetInt64(int64(rate)),
+		new(big.Float).SetPrec(prec).SetInt64(120000))
+	growth := new(big.Float).SetPrec(prec).SetInt64(1)
+	base := new(big.Float).SetPrec(prec).Add(growth, r)
+	for range n {
+		growth.Mul(growth, base)
+	}
+
+	// P·r·(1+r)^n / ((1+r)^n - 1) is the same payment without a division
+	// by a number close to one.
+	num := new(big.Float).SetPrec(prec).SetInt64(principal)
+	num.Mul(num, r).Mul(num, growth)
+	den := new(big.Float).SetPrec(prec).Sub(growth, big.NewFloat(1))
+	payment := new(big.Float).SetPrec(prec).Quo(num, den)
+
+	payment.Add(payment, big.NewFloat(0.5))
+	rounded, _ := payment.Int64()
+	return rounded
+}
+
+// addMonths returns the date n months after t's date, clamped to the last
+// day of that month.
+func addMonths(t time.Time, n int) time.Time {
+	y, m, d := t.Date()
+	first := time.Date(y, m+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(d, last)-1)
+}
@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleInstallments(t *testing.T) {
+	disbursedAt := time.Date(2025, 1, 31, 10, 0, 0, 0, time.UTC)
+	loan := func(repaymentType RepaymentType) *Loan {
+		return &Loan{
+			PrincipalAmount: NewMoney(120000000, "IDR"),
+			Rate:            Percent(1200),
+			RepaymentTerms:  RepaymentTerms{TenorMonths: 12, RepaymentType: repaymentType},
+		}
+	}
+	idr := func(v int64) Money { return NewMoney(v, "IDR") }
+	sum := func(installments []Installment) (principal, interest int64) {
+		for _, inst := range installments {
+			principal += inst.Principal.MinorUnits
+			interest += inst.Interest.MinorUnits
+		}
+		return principal, interest
+	}
+
+	t.Run("EMI", func(t *testing.T) {
+		got, err := ScheduleInstallments(loan(RepaymentEMI), disbursedAt)
+		require.NoError(t, err)
+		require.Len(t, got, 12)
+
+		assert.Equal(t, NewInstallment(1, time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC),
+			idr(9461855), idr(1200000)), got[0], "interest on the full principal, due at the end of February")
+		assert.Equal(t, time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC), got[1].DueDate)
+		assert.Equal(t, idr(1105381), got[1].Interest, "interest on the reduced balance")
+		for _, inst := range got[:11] {
+			assert.Equal(t, idr(10661855), inst.Amount)
+		}
+		assert.Equal(t, NewInstallment(12, time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC),
+			idr(10556288), idr(105563)), got[11], "the last installment clears the balance")
+
+		principal, interest := sum(got)
+		assert.Equal(t, int64(120000000), principal)
+		assert.Equal(t, int64(7942256), interest)
+	})
+
+	t.Run("Flat", func(t *testing.T) {
+		l := loan(RepaymentFlat)
+		l.PrincipalAmount = idr(100000001)
+		got, err := ScheduleInstallments(l, disbursedAt)
+		require.NoError(t, err)
+		require.Len(t, got, 12)
+
+		for _, inst := range got[:11] {
+			assert.Equal(t, idr(8333333), inst.Principal)
+			assert.Equal(t, idr(1000000), inst.Interest)
+		}
+		assert.Equal(t, idr(8333338), got[11].Principal, "the last installment takes the remainder")
+
+		principal, interest := sum(got)
+		assert.Equal(t, int64(100000001), principal)
+		assert.Equal(t, int64(12000000), interest)
+	})
+
+	t.Run("Bullet", func(t *testing.T) {
+		got, err := ScheduleInstallments(loan(RepaymentBullet), disbursedAt)
+		require.NoError(t, err)
+		require.Len(t, got, 12)
+
+		for _, inst := range got[:11] {
+			assert.Equal(t, idr(0), inst.Principal)
+			assert.Equal(t, idr(1200000), inst.Amount)
+		}
+		assert.Equal(t, idr(120000000), got[11].Principal)
+		assert.Equal(t, idr(121200000), got[11].Amount)
+	})
+
+	t.Run("NoTerms", func(t *testing.T) {
+		l := loan(RepaymentEMI)
+		l.RepaymentTerms = RepaymentTerms{}
+		_, err := ScheduleInstallments(l, disbursedAt)
+		assert.ErrorIs(t, err, ErrValidation)
+
+		l.RepaymentTerms = RepaymentTerms{TenorMonths: 12, RepaymentType: "BALLOON"}
+		_, err = ScheduleInstallments(l, disbursedAt)
+		assert.ErrorIs(t, err, ErrValidation)
+	})
+}
+
+func TestNewRepaymentSchedule(t *testing.T) {
+	l := &Loan{
+		PrincipalAmount: NewMoney(120000000, "IDR"),
+		Rate:            Percent(1200),
+		RepaymentTerms:  RepaymentTerms{TenorMonths: 12, RepaymentType: RepaymentFlat},
+	}
+	installments, err := ScheduleInstallments(l, time.Now())
+	require.NoError(t, err)
+
+	s := NewRepaymentSchedule(l, installments)
+	assert.Equal(t, l.RepaymentTerms, s.RepaymentTerms)
+	assert.Equal(t, NewMoney(14400000, "IDR"), s.TotalInterest)
+	assert.Equal(t, NewMoney(134400000, "IDR"), s.TotalAmount)
+
+	empty := NewRepaymentSchedule(l, nil)
+	assert.NotNil(t, empty.Installments)
+	assert.Equal(t, NewMoney(0, "IDR"), empty.TotalAmount)
+}
@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRepayment(t *testing.T) {
+	idr := func(v int64) Money { return NewMoney(v, "IDR") }
+	disbursedAt := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	investments := []Investment{
+		{ID: uuid.New(), InvestorID: uuid.New(), Amount: idr(60000)},
+		{ID: uuid.New(), InvestorID: uuid.New(), Amount: idr(30000)},
+		{ID: uuid.New(), InvestorID: uuid.New(), Amount: idr(30000)},
+		{ID: uuid.New(), InvestorID: uuid.New(), Amount: idr(50000), VoidedAt: &disbursedAt},
+	}
+	l := &Loan{
+		ID:              uuid.New(),
+		PrincipalAmount: idr(120000),
+		Rate:            Percent(1200),
+		ROI:             Percent(100),
+		State:           LoanStateDisbursed,
+		RepaymentTerms:  RepaymentTerms{TenorMonths: 2, RepaymentType: RepaymentFlat},
+		Investments:     investments,
+	}
+	installments, err := ScheduleInstallments(l, disbursedAt)
+	require.NoError(t, err)
+	s := NewRepaymentSchedule(l, installments)
+	require.Equal(t, idr(61200), s.Installments[0].Amount)
+	require.Equal(t, idr(2400), s.TotalInterest)
+
+	payouts := func(r *Repayment) [][2]int64 {
+		var got [][2]int64
+		for _, p := range r.Payouts {
+			got = append(got, [2]int64{p.Principal.MinorUnits, p.Interest.MinorUnits})
+		}
+		return got
+	}
+
+	t.Run("OnTime", func(t *testing.T) {
+		at := time.Date(2025, 2, 15, 23, 0, 0, 0, time.UTC)
+		r, err := NewRepayment(l, s, NewMoney(61200, ""), at)
+		require.NoError(t, err)
+		assert.Equal(t, l.ID, r.LoanID)
+		assert.Equal(t, idr(1200), r.Interest)
+		assert.Equal(t, idr(60000), r.Principal)
+		assert.Equal(t, idr(0), r.LateFee)
+		assert.Equal(t, [][2]int64{{30000, 300}, {15000, 150}, {15000, 150}}, payouts(r),
+			"half the interest makes up the 1% ROI; voided investments get nothing")
+		assert.Equal(t, idr(600), r.PlatformFee)
+		assert.Equal(t, investments[0].InvestorID, r.Payouts[0].InvestorID)
+
+		assert.Equal(t, at, *s.Installments[0].SettledAt)
+		assert.Equal(t, idr(61200), s.TotalPaid)
+		assert.Equal(t, idr(61200), s.Outstanding)
+		assert.Equal(t, 2, s.FirstUnpaid().Number)
+	})
+
+	t.Run("Late", func(t *testing.T) {
+		at := time.Date(2025, 3, 16, 0, 0, 0, 0, time.UTC)
+		r, err := NewRepayment(l, s, idr(30000), at)
+		require.NoError(t, err)
+		assert.Equal(t, idr(1200), r.Interest, "interest first")
+		assert.Equal(t, idr(28800), r.Principal)
+		assert.Equal(t, idr(0), r.LateFee, "the late fee comes last")
+		assert.Equal(t, idr(3060), s.Installments[1].LateFee, "5% of the installment")
+		assert.Equal(t, idr(3060), s.TotalLateFees)
+		assert.Nil(t, s.Installments[1].SettledAt)
+
+		_, err = NewRepayment(l, s, idr(34261), at)
+		assert.ErrorIs(t, err, ErrValidation, "more than is outstanding")
+
+		r, err = NewRepayment(l, s, idr(34260), at.Add(48*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, idr(0), r.Interest)
+		assert.Equal(t, idr(31200), r.Principal)
+		assert.Equal(t, idr(3060), r.LateFee)
+		assert.Equal(t, idr(3060), s.Installments[1].LateFee, "charged once")
+		assert.Equal(t, [][2]int64{{15600, 0}, {7800, 0}, {7800, 0}}, payouts(r))
+		assert.Equal(t, idr(3060), r.PlatformFee)
+
+		assert.True(t, s.Outstanding.IsZero())
+		assert.Nil(t, s.FirstUnpaid())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := NewRepayment(l, s, NewMoney(100, "USD"), disbursedAt)
+		assert.ErrorIs(t, err, ErrValidation)
+		_, err = NewRepayment(l, s, idr(0), disbursedAt)
+		assert.ErrorIs(t, err, ErrValidation)
+
+		approved := &Loan{State: LoanStateApproved, PrincipalAmount: idr(100)}
+		_, err = NewRepayment(approved, s, idr(100), disbursedAt)
+		assert.ErrorIs(t, err, ErrInvalidTransition)
+	})
+}
+
+func TestInvestorsInterestAddsUp(t *testing.T) {
+	idr := func(v int64) Money { return NewMoney(v, "IDR") }
+	l := &Loan{
+		ID:              uuid.New(),
+		PrincipalAmount: idr(100001),
+		Rate:            Percent(1999),
+		ROI:             Percent(333),
+		State:           LoanStateDisbursed,
+		RepaymentTerms:  RepaymentTerms{TenorMonths: 7, RepaymentType: RepaymentEMI},
+		Investments: []Investment{
+			{ID: uuid.New(), InvestorID: uuid.New(), Amount: idr(33333)},
+			{ID: uuid.New(), InvestorID: uuid.New(), Amount: idr(66668)},
+		},
+	}
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	installments, err := ScheduleInstallments(l, at)
+	require.NoError(t, err)
+	s := NewRepaymentSchedule(l, installments)
+
+	interest, paid := idr(0), idr(0)
+	for s.Outstanding.IsPositive() {
+		r, err := NewRepayment(l, s, NewMoney(min(997, s.Outstanding.MinorUnits), "IDR"), at)
+		require.NoError(t, err)
+		sum := r.PlatformFee
+		for _, p := range r.Payouts {
+			interest = interest.Add(p.Interest)
+			sum = sum.Add(p.Amount)
+		}
+		assert.Equal(t, r.Amount, sum, "every repayment is allocated in full")
+		paid = paid.Add(r.Amount)
+	}
+	assert.Equal(t, s.TotalAmount, paid)
+	assert.Equal(t, l.PrincipalAmount.Percent(l.ROI), interest, "investors earn exactly the ROI")
+}
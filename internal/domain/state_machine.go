@@ -0,0 +1,280 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LoanEvent is something that happens to a loan and may move it to another
+// state.
+type LoanEvent string
+
+const (
+	LoanEventApprove  LoanEvent = "approve"
+	LoanEventFund     LoanEvent = "fund"
+	LoanEventDisburse LoanEvent = "disburse"
+	// LoanEventSettle moves a disbursed loan to REPAID. It carries no
+	// guard: only the repayment schedule knows the loan is settled, so the
+	// service fires it with the repayment that settles it.
+	LoanEventSettle  LoanEvent = "settle"
+	LoanEventDefault LoanEvent = "default"
+	LoanEventReject  LoanEvent = "reject"
+	LoanEventCancel  LoanEvent = "cancel"
+	LoanEventExpire  LoanEvent = "expire"
+)
+
+// TransitionPayload is the record a transition writes onto the loan, such
+// as the approval details.
+type TransitionPayload interface {
+	payloadName() string
+	validate() error
+	applyTo(l *Loan)
+}
+
+func (d *ApprovalDetails) payloadName() string { return "ApprovalDetails" }
+
+func (d *ApprovalDetails) validate() error {
+	if d.FieldValidatorID == "" || d.ProofImageURL == "" {
+		return Errorf(ErrValidation, "approval requires a field validator and a proof image")
+	}
+	return nil
+}
+
+func (d *ApprovalDetails) applyTo(l *Loan) { l.ApprovalDetails = d }
+
+func (d *DisbursementDetails) payloadName() string { return "DisbursementDetails" }
+
+func (d *DisbursementDetails) validate() error {
+	if d.FieldOfficerID == "" || d.SignedAgreementURL == "" {
+		return Errorf(ErrValidation, "disbursement requires a field officer and a signed agreement")
+	}
+	return nil
+}
+
+func (d *DisbursementDetails) applyTo(l *Loan) { l.DisbursementDetails = d }
+
+func (d *ClosureDetails) payloadName() string { return "ClosureDetails" }
+
+func (d *ClosureDetails) validate() error {
+	if d.Reason == "" {
+		return Errorf(ErrValidation, "closing a loan requires a reason")
+	}
+	return nil
+}
+
+func (d *ClosureDetails) applyTo(l *Loan) { l.ClosureDetails = d }
+
+// Guard is an extra condition on a transition. Check returns why the
+// transition may not happen, or nil.
+type Guard struct {
+	// Name labels the guard in diagrams.
+	Name  string
+	Check func(l *Loan, payload TransitionPayload) error
+}
+
+// Transition allows Event to move a loan from From to To. Payload names the
+// TransitionPayload type the event must carry, if any.
+type Transition struct {
+	Event   LoanEvent
+	From    LoanState
+	To      LoanState
+	Payload string
+	Guards  []Guard
+}
+
+// TransitionError is returned for an event the state machine does not allow
+// on a loan. It matches ErrInvalidTransition.
+type TransitionError struct {
+	Event LoanEvent
+	From  LoanState
+	// Reason is why a guard refused the transition. It is empty when the
+	// event is not allowed from From at all.
+	Reason string
+}
+
+func (e *TransitionError) Error() string {
+	msg := fmt.Sprintf("cannot %s a loan in state %s", e.Event, e.From)
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// StateMachine is a table of allowed transitions, checked in order.
+type StateMachine struct {
+	Initial     LoanState
+	Transitions []Transition
+}
+
+// LoanLifecycle is the lifecycle every loan follows. Every change of
+// Loan.State goes through it.
+var LoanLifecycle = &StateMachine{
+	Initial: LoanStateProposed,
+	Transitions: []Transition{
+		{Event: LoanEventApprove, From: LoanStateProposed, To: LoanStateApproved, Payload: "ApprovalDetails"},
+		{Event: LoanEventFund, From: LoanStateApproved, To: LoanStateInvested, Guards: []Guard{fullyInvested}},
+		{Event: LoanEventDisburse, From: LoanStateInvested, To: LoanStateDisbursed, Payload: "DisbursementDetails"},
+		{Event: LoanEventSettle, From: LoanStateDisbursed, To: LoanStateRepaid},
+		{Event: LoanEventDefault, From: LoanStateDisbursed, To: LoanStateDefaulted, Payload: "ClosureDetails"},
+		{Event: LoanEventReject, From: LoanStateProposed, To: LoanStateRejected, Payload: "ClosureDetails", Guards: []Guard{actorGiven}},
+		{Event: LoanEventCancel, From: LoanStateProposed, To: LoanStateCancelled, Payload: "ClosureDetails", Guards: []Guard{actorGiven}},
+		{Event: LoanEventCancel, From: LoanStateApproved, To: LoanStateCancelled, Payload: "ClosureDetails", Guards: []Guard{actorGiven}},
+		{Event: LoanEventExpire, From: LoanStateApproved, To: LoanStateExpired, Payload: "ClosureDetails"},
+	},
+}
+
+var fullyInvested = Guard{
+	Name: "fully invested",
+	Check: func(l *Loan, _ TransitionPayload) error {
+		if !l.IsFullyInvested() {
+			return fmt.Errorf("%s of the principal is still open", l.RemainingAmount())
+		}
+		return nil
+	},
+}
+
+var actorGiven = Guard{
+	Name: "actor given",
+	Check: func(_ *Loan, payload TransitionPayload) error {
+		if closure, ok := payload.(*ClosureDetails); !ok || closure.ActorID == "" {
+			return fmt.Errorf("the actor closing the loan must be identified")
+		}
+		return nil
+	},
+}
+
+func (m *StateMachine) find(from LoanState, event LoanEvent) (Transition, bool) {
+	for _, t := range m.Transitions {
+		if t.From == from && t.Event == event {
+			return t, true
+		}
+	}
+	return Transition{}, false
+}
+
+// Permits reports whether event is allowed from state, before any guards.
+func (m *StateMachine) Permits(state LoanState, event LoanEvent) bool {
+	_, ok := m.find(state, event)
+	return ok
+}
+
+// Check returns a *TransitionError unless event is allowed from the loan's
+// current state. Guards are not evaluated.
+func (m *StateMachine) Check(l *Loan, event LoanEvent) error {
+	if !m.Permits(l.State, event) {
+		return &TransitionError{Event: event, From: l.State}
+	}
+	return nil
+}
+
+// Fire applies event to the loan: it checks the transition is allowed, that
+// payload is the one the transition requires and is complete, and that every
+// guard passes, then records payload and moves the loan to the new state as
+// of at. On error the loan is left unchanged.
+func (m *StateMachine) Fire(l *Loan, event LoanEvent, payload TransitionPayload, at time.Time) error {
+	t, ok := m.find(l.State, event)
+	if !ok {
+		return &TransitionError{Event: event, From: l.State}
+	}
+
+	switch {
+	case t.Payload == "" && payload != nil:
+		return Errorf(ErrValidation, "%s takes no details", event)
+	case t.Payload != "" && (payload == nil || payload.payloadName() != t.Payload):
+		return Errorf(ErrValidation, "%s requires %s", event, t.Payload)
+	case payload != nil:
+		if err := payload.validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, g := range t.Guards {
+		if err := g.Check(l, payload); err != nil {
+			return &TransitionError{Event: event, From: l.State, Reason: err.Error()}
+		}
+	}
+
+	if payload != nil {
+		payload.applyTo(l)
+	}
+	l.State = t.To
+	l.UpdatedAt = at
+	return nil
+}
+
+// states lists the states in the order they first appear in the table.
+func (m *StateMachine) states() []LoanState {
+	seen := map[LoanState]bool{m.Initial: true}
+	states := []LoanState{m.Initial}
+	for _, t := range m.Transitions {
+		for _, s := range []LoanState{t.From, t.To} {
+			if !seen[s] {
+				seen[s] = true
+				states = append(states, s)
+			}
+		}
+	}
+	return states
+}
+
+// final reports whether no transition leaves state.
+func (m *StateMachine) final(state LoanState) bool {
+	for _, t := range m.Transitions {
+		if t.From == state {
+			return false
+		}
+	}
+	return true
+}
+
+// label describes a transition as "event (Payload) [guard, ...]".
+func (t Transition) label() string {
+	label := string(t.Event)
+	if t.Payload != "" {
+		label += " (" + t.Payload + ")"
+	}
+	if len(t.Guards) > 0 {
+		names := make([]string, len(t.Guards))
+		for i, g := range t.Guards {
+			names[i] = g.Name
+		}
+		label += " [" + strings.Join(names, ", ") + "]"
+	}
+	return label
+}
+
+// edges writes the diagram body shared by PlantUML and Mermaid, one line
+// per transition plus the initial and final pseudo-states.
+func (m *StateMachine) edges(b *strings.Builder, indent string) {
+	fmt.Fprintf(b, "%s[*] --> %s\n", indent, m.Initial)
+	for _, t := range m.Transitions {
+		fmt.Fprintf(b, "%s%s --> %s : %s\n", indent, t.From, t.To, t.label())
+	}
+	for _, s := range m.states() {
+		if m.final(s) {
+			fmt.Fprintf(b, "%s%s --> [*]\n", indent, s)
+		}
+	}
+}
+
+// PlantUML renders the state machine as a PlantUML state diagram.
+func (m *StateMachine) PlantUML() string {
+	var b strings.Builder
+	b.WriteString("@startuml Loan States\n\n")
+	m.edges(&b, "")
+	b.WriteString("\n@enduml\n")
+	return b.String()
+}
+
+// Mermaid renders the state machine as a Mermaid state diagram.
+func (m *StateMachine) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("stateDiagram-v2\n")
+	m.edges(&b, "    ")
+	return b.String()
+}
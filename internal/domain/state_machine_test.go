@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoanLifecyclePermits(t *testing.T) {
+	events := []LoanEvent{LoanEventApprove, LoanEventFund, LoanEventDisburse, LoanEventSettle, LoanEventDefault,
+		LoanEventReject, LoanEventCancel, LoanEventExpire}
+	allowed := map[LoanState][]LoanEvent{
+		LoanStateProposed:  {LoanEventApprove, LoanEventReject, LoanEventCancel},
+		LoanStateApproved:  {LoanEventFund, LoanEventCancel, LoanEventExpire},
+		LoanStateInvested:  {LoanEventDisburse},
+		LoanStateDisbursed: {LoanEventSettle, LoanEventDefault},
+		LoanStateRepaid:    nil,
+		LoanStateDefaulted: nil,
+		LoanStateRejected:  nil,
+		LoanStateCancelled: nil,
+		LoanStateExpired:   nil,
+	}
+	for state, want := range allowed {
+		assert.True(t, state.IsValid())
+		for _, event := range events {
+			permitted := false
+			for _, e := range want {
+				permitted = permitted || e == event
+			}
+			assert.Equal(t, permitted, LoanLifecycle.Permits(state, event), "%s from %s", event, state)
+		}
+	}
+}
+
+func TestFire(t *testing.T) {
+	at := time.Now()
+	loan := &Loan{State: LoanStateProposed}
+
+	approval := &ApprovalDetails{FieldValidatorID: "V1", ProofImageURL: "https://example.com/p.jpg", ApprovedAt: at}
+	require.NoError(t, LoanLifecycle.Fire(loan, LoanEventApprove, approval, at))
+	assert.Equal(t, LoanStateApproved, loan.State)
+	assert.Same(t, approval, loan.ApprovalDetails)
+	assert.Equal(t, at, loan.UpdatedAt)
+
+	err := LoanLifecycle.Fire(loan, LoanEventApprove, approval, at)
+	var transitionErr *TransitionError
+	require.True(t, errors.As(err, &transitionErr))
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+	assert.Equal(t, LoanEventApprove, transitionErr.Event)
+	assert.Equal(t, LoanStateApproved, transitionErr.From)
+	assert.Equal(t, "cannot approve a loan in state APPROVED", err.Error())
+}
+
+func TestFireChecksPayloadAndGuards(t *testing.T) {
+	at := time.Now()
+	cases := []struct {
+		name    string
+		loan    Loan
+		event   LoanEvent
+		payload TransitionPayload
+		kind    error
+	}{
+		{"missing payload", Loan{State: LoanStateProposed}, LoanEventApprove, nil, ErrValidation},
+		{"wrong payload", Loan{State: LoanStateProposed}, LoanEventApprove, &ClosureDetails{Reason: "r"}, ErrValidation},
+		{"incomplete payload", Loan{State: LoanStateProposed}, LoanEventApprove, &ApprovalDetails{FieldValidatorID: "V1"}, ErrValidation},
+		{"unexpected payload", Loan{State: LoanStateApproved, PrincipalAmount: NewMoney(100, "IDR")}, LoanEventFund, &ClosureDetails{Reason: "r"}, ErrValidation},
+		{"guard: not fully invested", Loan{State: LoanStateApproved, PrincipalAmount: NewMoney(100, "IDR")}, LoanEventFund, nil, ErrInvalidTransition},
+		{"guard: no actor", Loan{State: LoanStateProposed}, LoanEventReject, &ClosureDetails{Reason: "fake address"}, ErrInvalidTransition},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			loan := tc.loan
+			err := LoanLifecycle.Fire(&loan, tc.event, tc.payload, at)
+			assert.ErrorIs(t, err, tc.kind)
+			assert.Equal(t, tc.loan.State, loan.State, "a refused transition changes nothing")
+			assert.Nil(t, loan.ApprovalDetails)
+			assert.Nil(t, loan.ClosureDetails)
+		})
+	}
+
+	loan := &Loan{State: LoanStateApproved, PrincipalAmount: NewMoney(100, "IDR")}
+	err := LoanLifecycle.Fire(loan, LoanEventFund, nil, at)
+	assert.EqualError(t, err, "cannot fund a loan in state APPROVED: 1.00 of the principal is still open")
+
+	require.NoError(t, LoanLifecycle.Fire(loan, LoanEventExpire, &ClosureDetails{Reason: "funding window elapsed"}, at))
+	assert.Equal(t, LoanStateExpired, loan.State)
+}
+
+func TestDiagrams(t *testing.T) {
+	m := &StateMachine{
+		Initial: LoanStateProposed,
+		Transitions: []Transition{
+			{Event: LoanEventApprove, From: LoanStateProposed, To: LoanStateApproved, Payload: "ApprovalDetails"},
+			{Event: LoanEventFund, From: LoanStateApproved, To: LoanStateInvested, Guards: []Guard{fullyInvested, actorGiven}},
+		},
+	}
+
+	assert.Equal(t, `@startuml Loan States
+
+[*] --> PROPOSED
+PROPOSED --> APPROVED : approve (ApprovalDetails)
+APPROVED --> INVESTED : fund [fully invested, actor given]
+INVESTED --> [*]
+
+@enduml
+`, m.PlantUML())
+
+	assert.Equal(t, `stateDiagram-v2
+    [*] --> PROPOSED
+    PROPOSED --> APPROVED : approve (ApprovalDetails)
+    APPROVED --> INVESTED : fund [fully invested, actor given]
+    INVESTED --> [*]
+`, m.Mermaid())
+
+	// Every state in the lifecycle is reachable and drawn.
+	for _, s := range []LoanState{LoanStateProposed, LoanStateApproved, LoanStateInvested, LoanStateDisbursed,
+		LoanStateRepaid, LoanStateDefaulted, LoanStateRejected, LoanStateCancelled, LoanStateExpired} {
+		assert.True(t, strings.Contains(LoanLifecycle.Mermaid(), string(s)), s)
+	}
+}
@@ -0,0 +1,128 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Wallet is an investor's money on the platform in one currency. Available
+// is what they can invest or withdraw and matches their INVESTOR_WALLET
+// ledger account. Reserved is what they have invested in loans that are
+// not yet disbursed: it is captured when a loan is disbursed and released
+// back to Available when a loan is closed.
+type Wallet struct {
+	InvestorID uuid.UUID `json:"investor_id"`
+	Currency   string    `json:"currency"`
+	Available  Money     `json:"available"`
+	Reserved   Money     `json:"reserved"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// NewWallet returns an empty wallet.
+func NewWallet(investorID uuid.UUID, currency string, at time.Time) *Wallet {
+	return &Wallet{
+		InvestorID: investorID,
+		Currency:   currency,
+		Available:  NewMoney(0, currency),
+		Reserved:   NewMoney(0, currency),
+		UpdatedAt:  at,
+	}
+}
+
+// Deposit adds money the investor paid in to Available.
+func (w *Wallet) Deposit(amount Money, at time.Time) error {
+	if err := w.checkAmount(amount); err != nil {
+		return err
+	}
+	w.Available = w.Available.Add(amount)
+	w.UpdatedAt = at
+	return nil
+}
+
+// Withdraw takes money the investor pays out from Available. It fails with
+// ErrInsufficientFunds if Available does not cover it.
+func (w *Wallet) Withdraw(amount Money, at time.Time) error {
+	if err := w.checkAvailable(amount); err != nil {
+		return err
+	}
+	w.Available = w.Available.Sub(amount)
+	w.UpdatedAt = at
+	return nil
+}
+
+// Reserve moves an investment from Available to Reserved. It fails with
+// ErrInsufficientFunds if Available does not cover it.
+func (w *Wallet) Reserve(amount Money, at time.Time) error {
+	if err := w.checkAvailable(amount); err != nil {
+		return err
+	}
+	w.Available = w.Available.Sub(amount)
+	w.Reserved = w.Reserved.Add(amount)
+	w.UpdatedAt = at
+	return nil
+}
+
+// Capture takes reserved investments out of the wallet once their loan is
+// disbursed.
+func (w *Wallet) Capture(amount Money, at time.Time) error {
+	if err := w.checkReserved(amount); err != nil {
+		return err
+	}
+	w.Reserved = w.Reserved.Sub(amount)
+	w.UpdatedAt = at
+	return nil
+}
+
+// Release returns reserved investments to Available once their loan is
+// closed.
+func (w *Wallet) Release(amount Money, at time.Time) error {
+	if err := w.checkReserved(amount); err != nil {
+		return err
+	}
+	w.Reserved = w.Reserved.Sub(amount)
+	w.Available = w.Available.Add(amount)
+	w.UpdatedAt = at
+	return nil
+}
+
+// Credit adds money the investor earned, such as a repayment payout, to
+// Available.
+func (w *Wallet) Credit(amount Money, at time.Time) error {
+	return w.Deposit(amount, at)
+}
+
+func (w *Wallet) checkAmount(amount Money) error {
+	if amount.Currency != w.Currency {
+		return Errorf(ErrValidation, "amount in %s cannot be moved through a %s wallet", amount.Currency, w.Currency)
+	}
+	if !amount.IsPositive() {
+		return Errorf(ErrValidation, "amount must be positive")
+	}
+	return nil
+}
+
+func (w *Wallet) checkAvailable(amount Money) error {
+	if err := w.checkAmount(amount); err != nil {
+		return err
+	}
+	if w.Available.Cmp(amount) < 0 {
+		return Errorf(ErrInsufficientFunds, "investor %s has %s %s available, less than %s",
+			w.InvestorID, w.Available, w.Currency, amount)
+	}
+	return nil
+}
+
+// checkReserved fails with ErrValidation rather than ErrInsufficientFunds:
+// investments are reserved before they are captured or released, so a
+// shortfall is a bookkeeping error, not the investor's.
+func (w *Wallet) checkReserved(amount Money) error {
+	if err := w.checkAmount(amount); err != nil {
+		return err
+	}
+	if w.Reserved.Cmp(amount) < 0 {
+		return Errorf(ErrValidation, "investor %s has %s %s reserved, less than %s",
+			w.InvestorID, w.Reserved, w.Currency, amount)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWallet(t *testing.T) {
+	idr := func(v int64) Money { return NewMoney(v, "IDR") }
+	at := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	later := at.Add(time.Hour)
+	w := NewWallet(uuid.New(), "IDR", at)
+	assert.Equal(t, idr(0), w.Available)
+	assert.Equal(t, idr(0), w.Reserved)
+
+	require.NoError(t, w.Deposit(idr(100000), at))
+	require.NoError(t, w.Reserve(idr(60000), at))
+	require.NoError(t, w.Reserve(idr(30000), at))
+	assert.Equal(t, idr(10000), w.Available)
+	assert.Equal(t, idr(90000), w.Reserved)
+
+	err := w.Reserve(idr(20000), later)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+	assert.ErrorIs(t, w.Withdraw(idr(10001), later), ErrInsufficientFunds, "reserved money cannot be withdrawn")
+	assert.Equal(t, idr(10000), w.Available, "a failed move changes nothing")
+	assert.Equal(t, at, w.UpdatedAt)
+
+	require.NoError(t, w.Capture(idr(60000), later))
+	require.NoError(t, w.Release(idr(30000), later))
+	require.NoError(t, w.Credit(idr(500), later))
+	require.NoError(t, w.Withdraw(idr(40500), later))
+	assert.Equal(t, idr(0), w.Available)
+	assert.Equal(t, idr(0), w.Reserved)
+	assert.Equal(t, later, w.UpdatedAt)
+
+	assert.ErrorIs(t, w.Release(idr(1), later), ErrValidation, "nothing is reserved")
+	assert.ErrorIs(t, w.Capture(idr(1), later), ErrValidation, "nothing is reserved")
+	assert.ErrorIs(t, w.Deposit(idr(0), later), ErrValidation, "zero amount")
+	assert.ErrorIs(t, w.Deposit(idr(-1), later), ErrValidation, "negative amount")
+	assert.ErrorIs(t, w.Deposit(NewMoney(100, "USD"), later), ErrValidation, "other currency")
+	assert.ErrorIs(t, w.Withdraw(NewMoney(100, "USD"), later), ErrValidation, "other currency")
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type AdminHandler struct {
+	outbox service.OutboxService
+}
+
+func NewAdminHandler(outbox service.OutboxService) *AdminHandler {
+	return &AdminHandler{outbox: outbox}
+}
+
+type OutboxMessagesResponse struct {
+	Messages []*domain.OutboxMessage `json:"messages"`
+}
+
+// ListOutbox serves GET /admin/outbox. Query parameters: status (PENDING,
+// SENT or DEAD) and limit.
+func (h *AdminHandler) ListOutbox(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	status := domain.OutboxStatus(strings.ToUpper(q.Get("status")))
+	if status != "" && !status.IsValid() {
+		writeError(w, r, invalidParam("status", fmt.Sprintf("%q is not an outbox status", status)))
+		return
+	}
+
+	var limit int
+	if v := q.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			writeError(w, r, invalidParam("limit", "must be a positive integer"))
+			return
+		}
+	}
+
+	messages, err := h.outbox.ListMessages(r.Context(), status, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if messages == nil {
+		messages = []*domain.OutboxMessage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(OutboxMessagesResponse{Messages: messages})
+}
+
+// RetryOutboxMessage serves POST /admin/outbox/{id}/retry, requeueing a
+// dead-lettered message.
+func (h *AdminHandler) RetryOutboxMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, invalidParam("id", "must be a UUID"))
+		return
+	}
+
+	message, err := h.outbox.RetryMessage(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type BorrowerHandler struct {
+	borrowers service.BorrowerService
+	loans     service.LoanService
+	validate  *validator.Validate
+}
+
+func NewBorrowerHandler(borrowers service.BorrowerService, loans service.LoanService) *BorrowerHandler {
+	return &BorrowerHandler{
+		borrowers: borrowers,
+		loans:     loans,
+		validate:  newValidator(),
+	}
+}
+
+type RegisterBorrowerRequest struct {
+	NationalIDNumber string `json:"national_id_number" validate:"required"`
+	Name             string `json:"name" validate:"required"`
+	Email            string `json:"email" validate:"required,email"`
+	Phone            string `json:"phone" validate:"required"`
+	Address          string `json:"address" validate:"required"`
+}
+
+// UpdateBorrowerRequest replaces the borrower's details. KYCStatus is left
+// unchanged when omitted.
+type UpdateBorrowerRequest struct {
+	Name      string           `json:"name" validate:"required"`
+	Email     string           `json:"email" validate:"required,email"`
+	Phone     string           `json:"phone" validate:"required"`
+	Address   string           `json:"address" validate:"required"`
+	KYCStatus domain.KYCStatus `json:"kyc_status" validate:"omitempty,oneof=PENDING VERIFIED REJECTED"`
+}
+
+type BorrowersResponse struct {
+	Borrowers []*domain.Borrower `json:"borrowers"`
+}
+
+// RegisterBorrower serves POST /borrowers. New borrowers are pending KYC.
+func (h *BorrowerHandler) RegisterBorrower(w http.ResponseWriter, r *http.Request) {
+	var req RegisterBorrowerRequest
+	if err := h.decode(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	borrower, err := h.borrowers.RegisterBorrower(r.Context(), req.NationalIDNumber, domain.BorrowerDetails{
+		Name:    req.Name,
+		Email:   req.Email,
+		Phone:   req.Phone,
+		Address: req.Address,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(borrower)
+}
+
+// ListBorrowers serves GET /borrowers. Query parameters: kyc_status and
+// limit.
+func (h *BorrowerHandler) ListBorrowers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	status := domain.KYCStatus(strings.ToUpper(q.Get("kyc_status")))
+	if status != "" && !status.IsValid() {
+		writeError(w, r, invalidParam("kyc_status", fmt.Sprintf("%q is not a KYC status", status)))
+		return
+	}
+
+	var limit int
+	if v := q.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			writeError(w, r, invalidParam("limit", "must be a positive integer"))
+			return
+		}
+	}
+
+	borrowers, err := h.borrowers.ListBorrowers(r.Context(), status, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if borrowers == nil {
+		borrowers = []*domain.Borrower{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BorrowersResponse{Borrowers: borrowers})
+}
+
+func (h *BorrowerHandler) GetBorrower(w http.ResponseWriter, r *http.Request) {
+	id, err := borrowerID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	borrower, err := h.borrowers.GetBorrower(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(borrower)
+}
+
+// UpdateBorrower serves PUT /borrowers/{id}. The national ID number cannot
+// be changed, as loans refer to the borrower by it.
+func (h *BorrowerHandler) UpdateBorrower(w http.ResponseWriter, r *http.Request) {
+	id, err := borrowerID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req UpdateBorrowerRequest
+	if err := h.decode(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	borrower, err := h.borrowers.UpdateBorrower(r.Context(), id, domain.BorrowerDetails{
+		Name:    req.Name,
+		Email:   req.Email,
+		Phone:   req.Phone,
+		Address: req.Address,
+	}, req.KYCStatus)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(borrower)
+}
+
+// DeleteBorrower serves DELETE /borrowers/{id}. Borrowers with loans cannot
+// be deleted.
+func (h *BorrowerHandler) DeleteBorrower(w http.ResponseWriter, r *http.Request) {
+	id, err := borrowerID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.borrowers.DeleteBorrower(r.Context(), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListBorrowerLoans serves GET /borrowers/{id}/loans. It takes the same query
+// parameters as GET /loans, except borrower_id_number.
+func (h *BorrowerHandler) ListBorrowerLoans(w http.ResponseWriter, r *http.Request) {
+	id, err := borrowerID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	filter, err := parseLoanFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	borrower, err := h.borrowers.GetBorrower(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	filter.BorrowerIDNumber = borrower.NationalIDNumber
+
+	page, err := h.loans.ListLoans(r.Context(), filter)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func (h *BorrowerHandler) decode(r *http.Request, req any) error {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return decodeError(err)
+	}
+	return h.validate.Struct(req)
+}
+
+func borrowerID(r *http.Request) (uuid.UUID, error) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return uuid.Nil, invalidParam("id", "must be a UUID")
+	}
+	return id, nil
+}
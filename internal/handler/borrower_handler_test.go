@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBorrowerRouter() (chi.Router, service.LoanService) {
+	store := repository.NewMemoryStore()
+	loans := service.NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), nil, nil)
+	h := NewBorrowerHandler(service.NewBorrowerService(store.Borrowers()), loans)
+
+	r := chi.NewRouter()
+	r.Post("/borrowers", h.RegisterBorrower)
+	r.Get("/borrowers", h.ListBorrowers)
+	r.Get("/borrowers/{id}", h.GetBorrower)
+	r.Put("/borrowers/{id}", h.UpdateBorrower)
+	r.Delete("/borrowers/{id}", h.DeleteBorrower)
+	r.Get("/borrowers/{id}/loans", h.ListBorrowerLoans)
+	return r, loans
+}
+
+func serve(r http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestBorrowerEndpoints(t *testing.T) {
+	r, loans := newBorrowerRouter()
+
+	rec := serve(r, http.MethodPost, "/borrowers",
+		`{"national_id_number": "B-1", "name": "Ani", "email": "ani@example.com", "phone": "+62 811", "address": "Jakarta"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var borrower domain.Borrower
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &borrower))
+	assert.Equal(t, domain.KYCStatusPending, borrower.KYCStatus)
+	path := "/borrowers/" + borrower.ID.String()
+
+	_, err := loans.CreateLoan(context.Background(), "B-1", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+	require.ErrorIs(t, err, domain.ErrValidation)
+
+	rec = serve(r, http.MethodPut, path,
+		`{"name": "Ani", "email": "ani@example.com", "phone": "+62 811", "address": "Bandung", "kyc_status": "VERIFIED"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	loan, err := loans.CreateLoan(context.Background(), "B-1", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+	require.NoError(t, err)
+
+	rec = serve(r, http.MethodGet, path+"/loans?state=PROPOSED", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var page domain.LoanPage
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Loans, 1)
+	assert.Equal(t, loan.ID, page.Loans[0].ID)
+
+	rec = serve(r, http.MethodGet, "/borrowers?kyc_status=verified", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var list BorrowersResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Borrowers, 1)
+	assert.Equal(t, "Bandung", list.Borrowers[0].Address)
+
+	rec = serve(r, http.MethodDelete, path, "")
+	assert.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+}
+
+func TestBorrowerEndpointErrors(t *testing.T) {
+	r, _ := newBorrowerRouter()
+	rec := serve(r, http.MethodPost, "/borrowers",
+		`{"national_id_number": "B-1", "name": "Ani", "email": "ani@example.com", "phone": "+62 811", "address": "Jakarta"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	cases := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{"duplicate national ID", http.MethodPost, "/borrowers",
+			`{"national_id_number": "B-1", "name": "Ani", "email": "ani@example.com", "phone": "+62 811", "address": "Jakarta"}`, http.StatusConflict},
+		{"bad email", http.MethodPost, "/borrowers",
+			`{"national_id_number": "B-2", "name": "Ani", "email": "ani", "phone": "+62 811", "address": "Jakarta"}`, http.StatusUnprocessableEntity},
+		{"bad KYC status", http.MethodPut, "/borrowers/" + "00000000-0000-0000-0000-000000000001",
+			`{"name": "Ani", "email": "ani@example.com", "phone": "+62 811", "address": "Jakarta", "kyc_status": "DONE"}`, http.StatusUnprocessableEntity},
+		{"bad filter", http.MethodGet, "/borrowers?kyc_status=DONE", "", http.StatusBadRequest},
+		{"bad limit", http.MethodGet, "/borrowers?limit=0", "", http.StatusBadRequest},
+		{"bad ID", http.MethodGet, "/borrowers/x", "", http.StatusBadRequest},
+		{"not found", http.MethodGet, "/borrowers/00000000-0000-0000-0000-000000000001", "", http.StatusNotFound},
+		{"loans of unknown borrower", http.MethodGet, "/borrowers/00000000-0000-0000-0000-000000000001/loans", "", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(r, tc.method, tc.target, tc.body)
+			assert.Equal(t, tc.status, rec.Code, rec.Body.String())
+		})
+	}
+
+	rec = serve(r, http.MethodPost, "/borrowers",
+		`{"national_id_number": "B-1", "name": "Ani", "email": "ani@example.com", "phone": "+62 811", "address": "Jakarta"}`)
+	assert.Contains(t, rec.Body.String(), problemAlreadyExists)
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// documentLinkTTL is how long the signed URL GET /loans/{id}/documents
+// redirects to stays valid.
+const documentLinkTTL = 15 * time.Minute
+
+type DocumentHandler struct {
+	store  service.DocumentStore
+	signer service.URLSigner
+}
+
+func NewDocumentHandler(store service.DocumentStore, signer service.URLSigner) *DocumentHandler {
+	return &DocumentHandler{store: store, signer: signer}
+}
+
+// GetDocument serves GET /documents/*, the signed links issued by the local
+// store. The signature is the only credential, so a bare link, as stored on
+// a loan, is refused with 403.
+func (h *DocumentHandler) GetDocument(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+
+	if err := h.signer.Verify(key, r.URL.Query(), time.Now()); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	body, err := h.store.Get(r.Context(), key)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	defer body.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, no-store")
+	io.Copy(w, body)
+}
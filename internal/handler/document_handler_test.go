@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDocumentServesOnlySignedLinks(t *testing.T) {
+	signer := service.NewURLSigner("secret")
+	store := service.NewLocalDocumentStore(t.TempDir(), "http://example.com/api/v1/documents", signer)
+	_, err := store.Put(context.Background(), "agreements/a.pdf", strings.NewReader("%PDF-1.4"), "application/pdf")
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Get("/api/v1/documents/*", NewDocumentHandler(store, signer).GetDocument)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/documents/agreements/a.pdf", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, rec.Header().Get("Location"))
+
+	signed, err := store.SignedURL(context.Background(), "agreements/a.pdf", time.Minute)
+	require.NoError(t, err)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, signed, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "%PDF-1.4", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, strings.Replace(signed, "agreements/a.pdf", "agreements/b.pdf", 1), nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// requestError is a malformed path, query or body parameter.
+type requestError struct {
+	field   string
+	code    string
+	message string
+}
+
+func (e *requestError) Error() string {
+	return fmt.Sprintf("%s %s", e.field, e.message)
+}
+
+func invalidParam(field, message string) error {
+	return &requestError{field: field, code: "invalid", message: message}
+}
+
+// uploadError rejects an uploaded file for its size or detected type.
+type uploadError struct {
+	field    string
+	tooLarge bool
+	message  string
+}
+
+func (e *uploadError) Error() string {
+	return fmt.Sprintf("%s %s", e.field, e.message)
+}
+
+// writeError is the single place errors become HTTP responses. Domain errors
+// carry a client-facing message; anything else is logged and reported as a
+// bare 500 so database and driver details do not leak.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		reqErr     *requestError
+		upErr      *uploadError
+		validation validator.ValidationErrors
+		p          *Problem
+	)
+
+	switch {
+	case errors.As(err, &reqErr):
+		p = newProblem(r, problemBadRequest, http.StatusBadRequest, "The request could not be parsed.")
+		p.Errors = []FieldError{{Field: reqErr.field, Code: reqErr.code, Message: reqErr.message}}
+	case errors.As(err, &upErr) && upErr.tooLarge:
+		p = newProblem(r, problemPayloadTooLarge, http.StatusRequestEntityTooLarge, "The uploaded file is too large.")
+		p.Errors = []FieldError{{Field: upErr.field, Code: "size", Message: upErr.message}}
+	case errors.As(err, &upErr):
+		p = newProblem(r, problemUnsupportedMedia, http.StatusUnsupportedMediaType, "The uploaded file type is not accepted.")
+		p.Errors = []FieldError{{Field: upErr.field, Code: "type", Message: upErr.message}}
+	case errors.As(err, &validation):
+		p = newProblem(r, problemValidation, http.StatusUnprocessableEntity, "One or more fields are invalid.")
+		p.Errors = validationFieldErrors(validation)
+	case errors.Is(err, service.ErrInvalidSignature):
+		p = newProblem(r, problemForbidden, http.StatusForbidden, err.Error())
+	case errors.Is(err, domain.ErrNotFound):
+		p = newProblem(r, problemNotFound, http.StatusNotFound, err.Error())
+	case errors.Is(err, domain.ErrConflict):
+		p = newProblem(r, problemConflict, http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrAlreadyExists):
+		p = newProblem(r, problemAlreadyExists, http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrInvalidTransition):
+		p = newProblem(r, problemInvalidTransition, http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrOverInvestment):
+		p = newProblem(r, problemOverInvestment, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, domain.ErrInsufficientFunds):
+		p = newProblem(r, problemInsufficientFunds, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, domain.ErrValidation):
+		p = newProblem(r, problemValidation, http.StatusUnprocessableEntity, err.Error())
+	default:
+		p = newProblem(r, problemInternal, http.StatusInternalServerError, "")
+		log.Printf("internal error [%s] %s %s: %v", p.RequestID, r.Method, r.URL.Path, err)
+	}
+
+	writeProblem(w, p)
+}
+
+// decodeError turns encoding/json failures into messages that name the
+// offending field without exposing Go type names.
+func decodeError(err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.Is(err, io.EOF):
+		return &requestError{field: "body", code: "required", message: "must not be empty"}
+	case errors.As(err, &syntaxErr):
+		return &requestError{field: "body", code: "malformed",
+			message: fmt.Sprintf("is not valid JSON (offset %d)", syntaxErr.Offset)}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return &requestError{field: typeErr.Field, code: "type",
+			message: "must be a JSON " + jsonKind(typeErr.Type.Kind().String())}
+	}
+	return &requestError{field: "body", code: "malformed", message: "is not a valid request body"}
+}
+
+func jsonKind(goKind string) string {
+	switch {
+	case goKind == "string", goKind == "array": // uuid.UUID is a [16]byte
+		return "string"
+	case strings.HasPrefix(goKind, "int"), strings.HasPrefix(goKind, "float"):
+		return "number"
+	case goKind == "bool":
+		return "boolean"
+	}
+	return "object"
+}
+
+func validationFieldErrors(errs validator.ValidationErrors) []FieldError {
+	fields := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, FieldError{
+			Field:   fe.Field(),
+			Code:    fe.Tag(),
+			Message: validationMessage(fe),
+		})
+	}
+	return fields
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "amount":
+		return "must be a positive decimal string with at most two fractional digits"
+	case "percent":
+		return "must be a positive percentage string with at most two fractional digits"
+	case "iso4217":
+		return "must be an ISO 4217 currency code"
+	case "url":
+		return "must be a URL"
+	case "uuid":
+		return "must be a UUID"
+	case "email":
+		return "must be an email address"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	}
+	return "is invalid"
+}
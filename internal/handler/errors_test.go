@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	var p Problem
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+	return p
+}
+
+func TestWriteError(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		typ    string
+		detail string
+	}{
+		{domain.Errorf(domain.ErrNotFound, "loan x not found"), http.StatusNotFound, problemNotFound, "loan x not found"},
+		{domain.ErrConflict, http.StatusConflict, problemConflict, "loan was modified concurrently"},
+		{domain.Errorf(domain.ErrAlreadyExists, "taken"), http.StatusConflict, problemAlreadyExists, "taken"},
+		{domain.Errorf(domain.ErrInvalidTransition, "nope"), http.StatusConflict, problemInvalidTransition, "nope"},
+		{domain.Errorf(domain.ErrOverInvestment, "too much"), http.StatusUnprocessableEntity, problemOverInvestment, "too much"},
+		{domain.Errorf(domain.ErrInsufficientFunds, "not enough"), http.StatusUnprocessableEntity, problemInsufficientFunds, "not enough"},
+		{fmt.Errorf("wrapped: %w", domain.ErrInvalidDecimal), http.StatusUnprocessableEntity, problemValidation, "wrapped: invalid decimal"},
+		{service.ErrInvalidSignature, http.StatusForbidden, problemForbidden, "invalid or expired document link"},
+		{errors.New("pq: connection refused"), http.StatusInternalServerError, problemInternal, ""},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/loans/x", nil)
+		rec := httptest.NewRecorder()
+		middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeError(w, r, tc.err)
+		})).ServeHTTP(rec, req)
+
+		p := decodeProblem(t, rec)
+		assert.Equal(t, tc.status, rec.Code, tc.err.Error())
+		assert.Equal(t, tc.status, p.Status)
+		assert.Equal(t, tc.typ, p.Type)
+		assert.NotEmpty(t, p.Title)
+		assert.Equal(t, tc.detail, p.Detail)
+		assert.Equal(t, "/api/v1/loans/x", p.Instance)
+		assert.NotEmpty(t, p.RequestID)
+	}
+}
+
+func TestCreateLoanValidationProblem(t *testing.T) {
+	h := NewLoanHandler(nil)
+
+	body := `{"borrower_id_number": "", "principal_amount": "10.999", "rate": "5", "roi": "4", "currency": "XYZ",
+		"tenor_months": 400, "repayment_type": "BALLOON"}`
+	rec := httptest.NewRecorder()
+	h.CreateLoan(rec, httptest.NewRequest(http.MethodPost, "/api/v1/loans", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	p := decodeProblem(t, rec)
+	assert.Equal(t, problemValidation, p.Type)
+	assert.ElementsMatch(t, []FieldError{
+		{Field: "borrower_id_number", Code: "required", Message: "is required"},
+		{Field: "principal_amount", Code: "amount", Message: "must be a positive decimal string with at most two fractional digits"},
+		{Field: "currency", Code: "iso4217", Message: "must be an ISO 4217 currency code"},
+		{Field: "tenor_months", Code: "max", Message: "must be at most 360"},
+		{Field: "repayment_type", Code: "oneof", Message: "must be one of FLAT, EMI, BULLET"},
+	}, p.Errors)
+}
+
+func TestCreateLoanRejectsJSONNumbers(t *testing.T) {
+	h := NewLoanHandler(nil)
+
+	body := `{"borrower_id_number": "b", "principal_amount": 1000.5, "rate": "5", "roi": "4"}`
+	rec := httptest.NewRecorder()
+	h.CreateLoan(rec, httptest.NewRequest(http.MethodPost, "/api/v1/loans", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	p := decodeProblem(t, rec)
+	assert.Equal(t, problemBadRequest, p.Type)
+	assert.Equal(t, []FieldError{
+		{Field: "principal_amount", Code: "type", Message: "must be a JSON string"},
+	}, p.Errors)
+}
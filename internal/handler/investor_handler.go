@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type InvestorHandler struct {
+	service  service.InvestorService
+	validate *validator.Validate
+}
+
+func NewInvestorHandler(service service.InvestorService) *InvestorHandler {
+	return &InvestorHandler{
+		service:  service,
+		validate: newValidator(),
+	}
+}
+
+// RegisterInvestorRequest describes a new investor. Limits are optional
+// decimal strings in limit_currency, which defaults to IDR.
+type RegisterInvestorRequest struct {
+	Name          string `json:"name" validate:"required"`
+	Email         string `json:"email" validate:"required,email"`
+	PerLoanLimit  string `json:"per_loan_limit" validate:"omitempty,amount"`
+	TotalLimit    string `json:"total_limit" validate:"omitempty,amount"`
+	LimitCurrency string `json:"limit_currency" validate:"omitempty,iso4217"`
+}
+
+// UpdateInvestorRequest replaces the investor's details, including their
+// limits: omitted limits are removed. KYCStatus and Status are left
+// unchanged when omitted.
+type UpdateInvestorRequest struct {
+	RegisterInvestorRequest
+	KYCStatus domain.KYCStatus      `json:"kyc_status" validate:"omitempty,oneof=PENDING VERIFIED REJECTED"`
+	Status    domain.InvestorStatus `json:"status" validate:"omitempty,oneof=ACTIVE SUSPENDED"`
+}
+
+type InvestorsResponse struct {
+	Investors []*domain.Investor `json:"investors"`
+}
+
+func (req *RegisterInvestorRequest) details() (domain.InvestorDetails, error) {
+	details := domain.InvestorDetails{Name: req.Name, Email: req.Email}
+
+	currency := req.LimitCurrency
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
+	var err error
+	if details.Limits.PerLoan, err = parseLimit(req.PerLoanLimit, currency); err != nil {
+		return domain.InvestorDetails{}, err
+	}
+	if details.Limits.Total, err = parseLimit(req.TotalLimit, currency); err != nil {
+		return domain.InvestorDetails{}, err
+	}
+	return details, nil
+}
+
+// parseLimit parses an optional limit; an empty amount is no limit.
+func parseLimit(amount, currency string) (*domain.Money, error) {
+	if amount == "" {
+		return nil, nil
+	}
+	m, err := domain.ParseMoney(amount, currency)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// RegisterInvestor serves POST /investors. New investors are active and
+// pending KYC.
+func (h *InvestorHandler) RegisterInvestor(w http.ResponseWriter, r *http.Request) {
+	var req RegisterInvestorRequest
+	if err := h.decode(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	details, err := req.details()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	investor, err := h.service.RegisterInvestor(r.Context(), details)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(investor)
+}
+
+// ListInvestors serves GET /investors. Query parameters: status and limit.
+func (h *InvestorHandler) ListInvestors(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	status := domain.InvestorStatus(strings.ToUpper(q.Get("status")))
+	if status != "" && !status.IsValid() {
+		writeError(w, r, invalidParam("status", fmt.Sprintf("%q is not an investor status", status)))
+		return
+	}
+
+	var limit int
+	if v := q.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			writeError(w, r, invalidParam("limit", "must be a positive integer"))
+			return
+		}
+	}
+
+	investors, err := h.service.ListInvestors(r.Context(), status, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if investors == nil {
+		investors = []*domain.Investor{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(InvestorsResponse{Investors: investors})
+}
+
+func (h *InvestorHandler) GetInvestor(w http.ResponseWriter, r *http.Request) {
+	id, err := investorID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	investor, err := h.service.GetInvestor(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(investor)
+}
+
+// UpdateInvestor serves PUT /investors/{id}.
+func (h *InvestorHandler) UpdateInvestor(w http.ResponseWriter, r *http.Request) {
+	id, err := investorID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req UpdateInvestorRequest
+	if err := h.decode(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	details, err := req.details()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	investor, err := h.service.UpdateInvestor(r.Context(), id, details, req.KYCStatus, req.Status)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(investor)
+}
+
+// DeleteInvestor serves DELETE /investors/{id}. Investors who have invested
+// or hold a wallet cannot be deleted; suspend them instead.
+func (h *InvestorHandler) DeleteInvestor(w http.ResponseWriter, r *http.Request) {
+	id, err := investorID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.service.DeleteInvestor(r.Context(), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPortfolio serves GET /investors/{id}/portfolio.
+func (h *InvestorHandler) GetPortfolio(w http.ResponseWriter, r *http.Request) {
+	id, err := investorID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	portfolio, err := h.service.GetPortfolio(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(portfolio)
+}
+
+func (h *InvestorHandler) decode(r *http.Request, req any) error {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return decodeError(err)
+	}
+	return h.validate.Struct(req)
+}
+
+func investorID(r *http.Request) (uuid.UUID, error) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return uuid.Nil, invalidParam("id", "must be a UUID")
+	}
+	return id, nil
+}
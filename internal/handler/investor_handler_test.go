@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newInvestorRouter() chi.Router {
+	h := NewInvestorHandler(service.NewInvestorService(repository.NewMemoryStore().Investors()))
+
+	r := chi.NewRouter()
+	r.Post("/investors", h.RegisterInvestor)
+	r.Get("/investors", h.ListInvestors)
+	r.Get("/investors/{id}", h.GetInvestor)
+	r.Put("/investors/{id}", h.UpdateInvestor)
+	r.Delete("/investors/{id}", h.DeleteInvestor)
+	r.Get("/investors/{id}/portfolio", h.GetPortfolio)
+	return r
+}
+
+func TestInvestorEndpoints(t *testing.T) {
+	r := newInvestorRouter()
+
+	rec := serve(r, http.MethodPost, "/investors",
+		`{"name": "Budi", "email": "budi@example.com", "per_loan_limit": "5000000", "total_limit": "20000000.50"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var investor domain.Investor
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &investor))
+	assert.Equal(t, domain.KYCStatusPending, investor.KYCStatus)
+	assert.Equal(t, domain.InvestorStatusActive, investor.Status)
+	require.NotNil(t, investor.Limits.Total)
+	assert.Equal(t, domain.NewMoney(2000000050, "IDR"), *investor.Limits.Total)
+	path := "/investors/" + investor.ID.String()
+
+	rec = serve(r, http.MethodPut, path,
+		`{"name": "Budi", "email": "budi@example.com", "total_limit": "100", "limit_currency": "USD", "kyc_status": "VERIFIED", "status": "SUSPENDED"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	investor = domain.Investor{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &investor))
+	assert.Nil(t, investor.Limits.PerLoan, "omitted limits are removed")
+	assert.Equal(t, domain.NewMoney(10000, "USD"), *investor.Limits.Total)
+	assert.Equal(t, domain.KYCStatusVerified, investor.KYCStatus)
+
+	rec = serve(r, http.MethodGet, "/investors?status=suspended", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var list InvestorsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Investors, 1)
+	assert.Equal(t, investor.ID, list.Investors[0].ID)
+
+	rec = serve(r, http.MethodGet, path+"/portfolio", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"investor_id": "`+investor.ID.String()+`", "investments": [], "by_state": [], "total": []}`, rec.Body.String())
+
+	rec = serve(r, http.MethodDelete, path, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	rec = serve(r, http.MethodGet, path, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+}
+
+func TestInvestorEndpointErrors(t *testing.T) {
+	r := newInvestorRouter()
+	rec := serve(r, http.MethodPost, "/investors", `{"name": "Budi", "email": "budi@example.com"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	cases := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{"duplicate email", http.MethodPost, "/investors", `{"name": "Budi", "email": "BUDI@example.com"}`, http.StatusConflict},
+		{"bad email", http.MethodPost, "/investors", `{"name": "Budi", "email": "budi"}`, http.StatusUnprocessableEntity},
+		{"bad limit", http.MethodPost, "/investors", `{"name": "Ani", "email": "ani@example.com", "per_loan_limit": "-5"}`, http.StatusUnprocessableEntity},
+		{"bad status", http.MethodPut, "/investors/00000000-0000-0000-0000-000000000001",
+			`{"name": "Budi", "email": "budi@example.com", "status": "RETIRED"}`, http.StatusUnprocessableEntity},
+		{"bad filter", http.MethodGet, "/investors?status=RETIRED", "", http.StatusBadRequest},
+		{"bad ID", http.MethodGet, "/investors/x", "", http.StatusBadRequest},
+		{"portfolio of unknown investor", http.MethodGet, "/investors/00000000-0000-0000-0000-000000000001/portfolio", "", http.StatusNotFound},
+		{"not found", http.MethodPut, "/investors/00000000-0000-0000-0000-000000000001",
+			`{"name": "Budi", "email": "budi@example.com"}`, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(r, tc.method, tc.target, tc.body)
+			assert.Equal(t, tc.status, rec.Code, rec.Body.String())
+		})
+	}
+
+	rec = serve(r, http.MethodPost, "/investors", `{"name": "Budi", "email": "BUDI@example.com"}`)
+	assert.Contains(t, rec.Body.String(), problemAlreadyExists)
+}
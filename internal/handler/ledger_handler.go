@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"vibhordubey333/loan-service/internal/service"
+)
+
+type LedgerHandler struct {
+	ledger service.LedgerService
+}
+
+func NewLedgerHandler(ledger service.LedgerService) *LedgerHandler {
+	return &LedgerHandler{ledger: ledger}
+}
+
+// TrialBalance serves GET /ledger/trial-balance.
+func (h *LedgerHandler) TrialBalance(w http.ResponseWriter, r *http.Request) {
+	tb, err := h.ledger.TrialBalance(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tb)
+}
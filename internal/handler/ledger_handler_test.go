@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type trialBalanceStub struct {
+	service.LedgerService
+	balances []domain.AccountBalance
+	err      error
+}
+
+func (s *trialBalanceStub) TrialBalance(ctx context.Context) (*domain.TrialBalance, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return domain.NewTrialBalance(s.balances), nil
+}
+
+func TestTrialBalance(t *testing.T) {
+	investorID := uuid.MustParse("8f14e45f-ceea-467f-a0e6-4bde9d4a1c2b")
+	loanID := uuid.MustParse("1b7a4a0c-3d2e-4b8f-9a51-7c6e2f0d9e13")
+	idr := func(v int64) domain.Money { return domain.NewMoney(v, "IDR") }
+	svc := &trialBalanceStub{balances: []domain.AccountBalance{
+		domain.NewAccountBalance(domain.InvestorWalletAccount(investorID, "IDR"), idr(100000), idr(0)),
+		domain.NewAccountBalance(domain.LoanFundingAccount(loanID, "IDR"), idr(0), idr(100000)),
+	}}
+	r := chi.NewRouter()
+	r.Get("/ledger/trial-balance", NewLedgerHandler(svc).TrialBalance)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ledger/trial-balance", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{
+		"accounts": [
+			{
+				"account": {"type": "INVESTOR_WALLET", "owner_id": "8f14e45f-ceea-467f-a0e6-4bde9d4a1c2b", "currency": "IDR"},
+				"debits": {"amount": "1000.00", "currency": "IDR"},
+				"credits": {"amount": "0.00", "currency": "IDR"},
+				"balance": {"amount": "-1000.00", "currency": "IDR"}
+			},
+			{
+				"account": {"type": "LOAN_FUNDING", "owner_id": "1b7a4a0c-3d2e-4b8f-9a51-7c6e2f0d9e13", "currency": "IDR"},
+				"debits": {"amount": "0.00", "currency": "IDR"},
+				"credits": {"amount": "1000.00", "currency": "IDR"},
+				"balance": {"amount": "1000.00", "currency": "IDR"}
+			}
+		],
+		"totals": [
+			{"currency": "IDR", "debits": {"amount": "1000.00", "currency": "IDR"}, "credits": {"amount": "1000.00", "currency": "IDR"}}
+		],
+		"balanced": true
+	}`, rec.Body.String())
+
+	svc.err = errors.New("connection refused")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ledger/trial-balance", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
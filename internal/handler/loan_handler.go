@@ -1,9 +1,17 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
+	"vibhordubey333/loan-service/internal/domain"
 	"vibhordubey333/loan-service/internal/service"
 
 	"github.com/go-chi/chi/v5"
@@ -19,15 +27,30 @@ type LoanHandler struct {
 func NewLoanHandler(service service.LoanService) *LoanHandler {
 	return &LoanHandler{
 		service:  service,
-		validate: validator.New(),
+		validate: newValidator(),
 	}
 }
 
+// newValidator checks request bodies, naming fields by their JSON names.
+func newValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(jsonFieldName)
+	validate.RegisterValidation("amount", validateAmount)
+	validate.RegisterValidation("percent", validatePercent)
+	return validate
+}
+
+// Amounts and rates are decimal strings ("1000.50") so they are never
+// rounded through a float on the way in. Rate is yearly; RepaymentType
+// defaults to EMI.
 type CreateLoanRequest struct {
-	BorrowerIDNumber string  `json:"borrower_id_number" validate:"required"`
-	PrincipalAmount  float64 `json:"principal_amount" validate:"required,gt=0"`
-	Rate             float64 `json:"rate" validate:"required,gt=0"`
-	ROI              float64 `json:"roi" validate:"required,gt=0"`
+	BorrowerIDNumber string               `json:"borrower_id_number" validate:"required"`
+	PrincipalAmount  string               `json:"principal_amount" validate:"required,amount"`
+	Currency         string               `json:"currency" validate:"omitempty,iso4217"`
+	Rate             string               `json:"rate" validate:"required,percent"`
+	ROI              string               `json:"roi" validate:"required,percent"`
+	TenorMonths      int                  `json:"tenor_months" validate:"omitempty,min=1,max=360"`
+	RepaymentType    domain.RepaymentType `json:"repayment_type" validate:"omitempty,oneof=FLAT EMI BULLET"`
 }
 
 type ApproveLoanRequest struct {
@@ -35,9 +58,24 @@ type ApproveLoanRequest struct {
 	ProofImageURL    string `json:"proof_image_url" validate:"required,url"`
 }
 
+// ApproveLoanForm holds the fields sent alongside a proof_image file in a
+// multipart approval.
+type ApproveLoanForm struct {
+	FieldValidatorID string `json:"field_validator_id" validate:"required"`
+}
+
+// InvestmentRequest.Currency defaults to the loan's currency when omitted.
 type InvestmentRequest struct {
 	InvestorID uuid.UUID `json:"investor_id" validate:"required"`
-	Amount     float64   `json:"amount" validate:"required,gt=0"`
+	Amount     string    `json:"amount" validate:"required,amount"`
+	Currency   string    `json:"currency" validate:"omitempty,iso4217"`
+}
+
+// RepaymentRequest is a payment from the borrower. Currency defaults to the
+// loan's.
+type RepaymentRequest struct {
+	Amount   string `json:"amount" validate:"required,amount"`
+	Currency string `json:"currency" validate:"omitempty,iso4217"`
 }
 
 type DisbursementRequest struct {
@@ -45,22 +83,61 @@ type DisbursementRequest struct {
 	SignedAgreementURL string `json:"signed_agreement_url" validate:"required,url"`
 }
 
+// DisbursementForm holds the fields sent alongside a signed_agreement file
+// in a multipart disbursement.
+type DisbursementForm struct {
+	FieldOfficerID string `json:"field_officer_id" validate:"required"`
+}
+
+type RejectLoanRequest struct {
+	FieldValidatorID string `json:"field_validator_id" validate:"required"`
+	Reason           string `json:"reason" validate:"required"`
+}
+
+type CancelLoanRequest struct {
+	ActorID string `json:"actor_id" validate:"required"`
+	Reason  string `json:"reason" validate:"required"`
+}
+
 func (h *LoanHandler) CreateLoan(w http.ResponseWriter, r *http.Request) {
 	var req CreateLoanRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := h.decode(r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err := h.validate.Struct(req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	currency := req.Currency
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
+	principal, err := domain.ParseMoney(req.PrincipalAmount, currency)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	rate, err := domain.ParsePercent(req.Rate)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	roi, err := domain.ParsePercent(req.ROI)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
+	terms := domain.RepaymentTerms{TenorMonths: req.TenorMonths, RepaymentType: req.RepaymentType}
+	if terms.TenorMonths == 0 {
+		terms.TenorMonths = domain.DefaultTenorMonths
+	}
+	if terms.RepaymentType == "" {
+		terms.RepaymentType = domain.RepaymentEMI
+	}
+
 	loan, err := h.service.CreateLoan(r.Context(), req.BorrowerIDNumber,
-		req.PrincipalAmount, req.Rate, req.ROI)
+		principal, rate, roi, terms)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -69,16 +146,42 @@ func (h *LoanHandler) CreateLoan(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(loan)
 }
 
+// GetLoan serves GET /loans/{id}. With as_of (RFC 3339) it returns the loan
+// as it stood at that time, rebuilt from its event stream; such a response
+// describes the past, so it carries no ETag.
 func (h *LoanHandler) GetLoan(w http.ResponseWriter, r *http.Request) {
-	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	id, err := loanID(r)
 	if err != nil {
-		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
+		writeError(w, r, err)
+		return
+	}
+
+	if v := r.URL.Query().Get("as_of"); v != "" {
+		asOf, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, r, invalidParam("as_of", "must be an RFC 3339 timestamp"))
+			return
+		}
+		loan, err := h.service.GetLoanAsOf(r.Context(), id, asOf)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(loan)
 		return
 	}
 
 	loan, err := h.service.GetLoan(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, r, err)
+		return
+	}
+
+	etag := loanETag(loan.Version)
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -86,27 +189,273 @@ func (h *LoanHandler) GetLoan(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(loan)
 }
 
+type LoanHistoryResponse struct {
+	Events []*domain.LoanHistoryEntry `json:"events"`
+}
+
+type RepaymentsResponse struct {
+	Repayments []*domain.Repayment `json:"repayments"`
+}
+
+// GetLoanHistory serves GET /loans/{id}/history: every change made to the
+// loan, oldest first.
+func (h *LoanHandler) GetLoanHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := loanID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	events, err := h.service.GetLoanHistory(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if events == nil {
+		events = []*domain.LoanHistoryEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LoanHistoryResponse{Events: events})
+}
+
+// GetLoanDocument serves GET /loans/{id}/documents/{document}, redirecting to
+// a short-lived signed URL for the loan's proof-image, agreement-letter or
+// signed-agreement. It is for staff and must be mounted behind
+// RequireBearerToken.
+func (h *LoanHandler) GetLoanDocument(w http.ResponseWriter, r *http.Request) {
+	id, err := loanID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	signed, err := h.service.DocumentURL(r.Context(), id, service.LoanDocument(chi.URLParam(r, "document")), documentLinkTTL)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, signed, http.StatusFound)
+}
+
+// GetRepaymentSchedule serves GET /loans/{id}/schedule. Loans have a
+// schedule once disbursed.
+func (h *LoanHandler) GetRepaymentSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := loanID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	schedule, err := h.service.GetRepaymentSchedule(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// RecordRepayment serves POST /loans/{id}/repayments. It responds 201 with
+// the repayment and its payouts to investors.
+func (h *LoanHandler) RecordRepayment(w http.ResponseWriter, r *http.Request) {
+	id, err := loanID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	ctx, err := withIfMatch(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req RepaymentRequest
+	if err := h.decode(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	amount, err := domain.ParseMoney(req.Amount, req.Currency)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	repayment, err := h.service.RecordRepayment(ctx, id, amount)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(repayment)
+}
+
+// ListRepayments serves GET /loans/{id}/repayments.
+func (h *LoanHandler) ListRepayments(w http.ResponseWriter, r *http.Request) {
+	id, err := loanID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	repayments, err := h.service.ListRepayments(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if repayments == nil {
+		repayments = []*domain.Repayment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RepaymentsResponse{Repayments: repayments})
+}
+
+// ListLoans serves GET /loans. Query parameters: state (repeatable),
+// borrower_id_number, min_principal, max_principal, currency, created_from,
+// created_to (RFC 3339), investor_id, sort, cursor and limit.
+func (h *LoanHandler) ListLoans(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseLoanFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	page, err := h.service.ListLoans(r.Context(), filter)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func parseLoanFilter(q url.Values) (domain.LoanFilter, error) {
+	filter := domain.LoanFilter{
+		BorrowerIDNumber: q.Get("borrower_id_number"),
+		Sort:             domain.LoanSort(q.Get("sort")),
+		Cursor:           q.Get("cursor"),
+	}
+
+	for _, state := range q["state"] {
+		state := domain.LoanState(strings.ToUpper(state))
+		if !state.IsValid() {
+			return filter, invalidParam("state", fmt.Sprintf("%q is not a loan state", state))
+		}
+		filter.States = append(filter.States, state)
+	}
+
+	if filter.Sort != "" && !filter.Sort.IsValid() {
+		return filter, invalidParam("sort", fmt.Sprintf("%q is not a supported sort", filter.Sort))
+	}
+
+	currency := q.Get("currency")
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
+	for name, dst := range map[string]**domain.Money{
+		"min_principal": &filter.MinPrincipal,
+		"max_principal": &filter.MaxPrincipal,
+	} {
+		if v := q.Get(name); v != "" {
+			m, err := domain.ParseMoney(v, currency)
+			if err != nil {
+				return filter, invalidParam(name, "must be a decimal with at most two fractional digits")
+			}
+			*dst = &m
+		}
+	}
+
+	for name, dst := range map[string]*time.Time{
+		"created_from": &filter.CreatedFrom,
+		"created_to":   &filter.CreatedTo,
+	} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, invalidParam(name, "must be an RFC 3339 timestamp")
+			}
+			*dst = t
+		}
+	}
+
+	if v := q.Get("investor_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return filter, invalidParam("investor_id", "must be a UUID")
+		}
+		filter.InvestorID = id
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return filter, invalidParam("limit", "must be a positive integer")
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
 func (h *LoanHandler) ApproveLoan(w http.ResponseWriter, r *http.Request) {
-	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	id, err := loanID(r)
 	if err != nil {
-		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
+		writeError(w, r, err)
+		return
+	}
+
+	ctx, err := withIfMatch(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if isMultipart(r) {
+		h.approveLoanWithProof(w, r.WithContext(ctx), id)
 		return
 	}
 
 	var req ApproveLoanRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := h.decode(r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err := h.validate.Struct(req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	err = h.service.ApproveLoan(ctx, id, req.FieldValidatorID, req.ProofImageURL)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	err = h.service.ApproveLoan(r.Context(), id, req.FieldValidatorID, req.ProofImageURL)
+	w.WriteHeader(http.StatusOK)
+}
+
+// approveLoanWithProof handles a multipart approval carrying the
+// proof-of-visit image, which is stored rather than linked.
+func (h *LoanHandler) approveLoanWithProof(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	proof, cleanup, err := parseUpload(w, r, proofImageUpload)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
+		return
+	}
+	defer cleanup()
+
+	form := ApproveLoanForm{FieldValidatorID: r.PostFormValue("field_validator_id")}
+	if err := h.validate.Struct(&form); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.service.ApproveLoanWithProof(r.Context(), id, form.FieldValidatorID, proof)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
@@ -114,26 +463,33 @@ func (h *LoanHandler) ApproveLoan(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *LoanHandler) InvestInLoan(w http.ResponseWriter, r *http.Request) {
-	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	id, err := loanID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	ctx, err := withIfMatch(r)
 	if err != nil {
-		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
 	var req InvestmentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := h.decode(r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err := h.validate.Struct(req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	amount, err := domain.ParseMoney(req.Amount, req.Currency)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	err = h.service.InvestInLoan(r.Context(), id, req.InvestorID, req.Amount)
+	err = h.service.InvestInLoan(ctx, id, req.InvestorID, amount)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -141,28 +497,175 @@ func (h *LoanHandler) InvestInLoan(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *LoanHandler) DisburseLoan(w http.ResponseWriter, r *http.Request) {
-	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	id, err := loanID(r)
 	if err != nil {
-		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
+		writeError(w, r, err)
+		return
+	}
+
+	ctx, err := withIfMatch(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if isMultipart(r) {
+		h.disburseLoanWithAgreement(w, r.WithContext(ctx), id)
 		return
 	}
 
 	var req DisbursementRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := h.decode(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.service.DisburseLoan(ctx, id, req.FieldOfficerID, req.SignedAgreementURL)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// disburseLoanWithAgreement handles a multipart disbursement carrying the
+// signed agreement, which is stored rather than linked.
+func (h *LoanHandler) disburseLoanWithAgreement(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	agreement, cleanup, err := parseUpload(w, r, signedAgreementUpload)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
+	defer cleanup()
 
-	if err := h.validate.Struct(req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	form := DisbursementForm{FieldOfficerID: r.PostFormValue("field_officer_id")}
+	if err := h.validate.Struct(&form); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	err = h.service.DisburseLoan(r.Context(), id, req.FieldOfficerID, req.SignedAgreementURL)
+	err = h.service.DisburseLoanWithAgreement(r.Context(), id, form.FieldOfficerID, agreement)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
+
+func (h *LoanHandler) RejectLoan(w http.ResponseWriter, r *http.Request) {
+	id, err := loanID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	ctx, err := withIfMatch(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req RejectLoanRequest
+	if err := h.decode(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.service.RejectLoan(ctx, id, req.FieldValidatorID, req.Reason)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// CancelLoan withdraws a PROPOSED or APPROVED loan. Investments already made
+// in it are voided and refunded.
+func (h *LoanHandler) CancelLoan(w http.ResponseWriter, r *http.Request) {
+	id, err := loanID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	ctx, err := withIfMatch(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req CancelLoanRequest
+	if err := h.decode(r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.service.CancelLoan(ctx, id, req.ActorID, req.Reason)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// decode reads a JSON body into req and validates it.
+func (h *LoanHandler) decode(r *http.Request, req any) error {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return decodeError(err)
+	}
+	return h.validate.Struct(req)
+}
+
+func loanID(r *http.Request) (uuid.UUID, error) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return uuid.Nil, invalidParam("id", "must be a UUID")
+	}
+	return id, nil
+}
+
+// loanETag is a strong entity tag derived from the loan version.
+func loanETag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// withIfMatch carries the version from an If-Match header into the request
+// context so the service can reject stale transitions. The header must be an
+// entity tag issued by loanETag.
+func withIfMatch(r *http.Request) (context.Context, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return r.Context(), nil
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(header, `"`), 10, 64)
+	if err != nil {
+		return nil, invalidParam("If-Match", "must be an ETag returned by GET")
+	}
+	return service.WithExpectedVersion(r.Context(), version), nil
+}
+
+// validateAmount accepts strictly positive decimal strings with at most two
+// fractional digits.
+func validateAmount(fl validator.FieldLevel) bool {
+	m, err := domain.ParseMoney(fl.Field().String(), "")
+	return err == nil && m.IsPositive()
+}
+
+func validatePercent(fl validator.FieldLevel) bool {
+	p, err := domain.ParsePercent(fl.Field().String())
+	return err == nil && p > 0
+}
+
+// jsonFieldName makes validation errors name fields as clients send them.
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
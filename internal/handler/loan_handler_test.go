@@ -0,0 +1,447 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// emiTerms are the repayment terms of loans whose terms do not matter to
+// the test.
+var emiTerms = domain.RepaymentTerms{TenorMonths: 12, RepaymentType: domain.RepaymentEMI}
+
+type closeRecorder struct {
+	service.LoanService
+	id      uuid.UUID
+	actorID string
+	reason  string
+	err     error
+}
+
+func (s *closeRecorder) CancelLoan(ctx context.Context, id uuid.UUID, actorID, reason string) error {
+	s.id, s.actorID, s.reason = id, actorID, reason
+	return s.err
+}
+
+func (s *closeRecorder) RejectLoan(ctx context.Context, id uuid.UUID, validatorID, reason string) error {
+	s.id, s.actorID, s.reason = id, validatorID, reason
+	return s.err
+}
+
+func serveClose(svc service.LoanService, req *http.Request) *httptest.ResponseRecorder {
+	h := NewLoanHandler(svc)
+	r := chi.NewRouter()
+	r.Post("/loans/{id}/reject", h.RejectLoan)
+	r.Post("/loans/{id}/cancel", h.CancelLoan)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCancelLoan(t *testing.T) {
+	svc := &closeRecorder{}
+	id := uuid.New()
+	req := httptest.NewRequest(http.MethodPost, "/loans/"+id.String()+"/cancel",
+		strings.NewReader(`{"actor_id": "ops-1", "reason": "borrower withdrew"}`))
+
+	rec := serveClose(svc, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, id, svc.id)
+	assert.Equal(t, "ops-1", svc.actorID)
+	assert.Equal(t, "borrower withdrew", svc.reason)
+}
+
+func TestCloseLoanErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		path    string
+		body    string
+		ifMatch string
+		err     error
+		status  int
+	}{
+		{"missing reason", "reject", `{"field_validator_id": "V1"}`, "", nil, http.StatusUnprocessableEntity},
+		{"missing actor", "cancel", `{"reason": "duplicate"}`, "", nil, http.StatusUnprocessableEntity},
+		{"malformed If-Match", "cancel", `{"actor_id": "ops-1", "reason": "duplicate"}`, "v1", nil, http.StatusBadRequest},
+		{"wrong state", "reject", `{"field_validator_id": "V1", "reason": "fake address"}`, "",
+			&domain.TransitionError{Event: domain.LoanEventReject, From: domain.LoanStateApproved}, http.StatusConflict},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &closeRecorder{err: tc.err}
+			req := httptest.NewRequest(http.MethodPost, "/loans/"+uuid.NewString()+"/"+tc.path, strings.NewReader(tc.body))
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+
+			rec := serveClose(svc, req)
+
+			assert.Equal(t, tc.status, rec.Code, rec.Body.String())
+			assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+		})
+	}
+}
+
+type historyStub struct {
+	service.LoanService
+	events []*domain.LoanHistoryEntry
+	err    error
+}
+
+func (s *historyStub) GetLoanHistory(ctx context.Context, id uuid.UUID) ([]*domain.LoanHistoryEntry, error) {
+	return s.events, s.err
+}
+
+func TestGetLoanHistory(t *testing.T) {
+	cases := []struct {
+		name   string
+		svc    *historyStub
+		status int
+		body   string
+	}{
+		{"empty", &historyStub{}, http.StatusOK, `{"events": []}`},
+		{"entries", &historyStub{events: []*domain.LoanHistoryEntry{{
+			Version: 2, Event: domain.LoanEventApprove,
+			FromState: domain.LoanStateProposed, ToState: domain.LoanStateApproved,
+			ActorID: "V1", OccurredAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		}}}, http.StatusOK, `{"events": [{
+			"loan_id": "00000000-0000-0000-0000-000000000000", "version": 2, "event": "approve",
+			"from_state": "PROPOSED", "to_state": "APPROVED", "actor_id": "V1",
+			"occurred_at": "2024-01-02T03:04:05Z"}]}`},
+		{"not found", &historyStub{err: domain.ErrNotFound}, http.StatusNotFound, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.Get("/loans/{id}/history", NewLoanHandler(tc.svc).GetLoanHistory)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/loans/"+uuid.NewString()+"/history", nil))
+
+			require.Equal(t, tc.status, rec.Code, rec.Body.String())
+			if tc.body != "" {
+				assert.JSONEq(t, tc.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequestIDIsRecordedInHistory(t *testing.T) {
+	store := repository.NewMemoryStore()
+	require.NoError(t, store.Borrowers().Create(context.Background(), &domain.Borrower{
+		ID: uuid.New(), NationalIDNumber: "B-1", KYCStatus: domain.KYCStatusVerified,
+	}))
+	svc := service.NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), nil, nil)
+	loan, err := svc.CreateLoan(context.Background(), "B-1", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+	require.NoError(t, err)
+
+	h := NewLoanHandler(svc)
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(RequestID)
+	r.Post("/loans/{id}/cancel", h.CancelLoan)
+	req := httptest.NewRequest(http.MethodPost, "/loans/"+loan.ID.String()+"/cancel",
+		strings.NewReader(`{"actor_id": "ops-1", "reason": "duplicate"}`))
+	req.Header.Set("X-Request-Id", "req-42")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	history, err := svc.GetLoanHistory(context.Background(), loan.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Empty(t, history[0].RequestID)
+	assert.Equal(t, domain.LoanEventCancel, history[1].Event)
+	assert.Equal(t, "req-42", history[1].RequestID)
+}
+
+type documentStub struct {
+	service.LoanService
+	document service.LoanDocument
+}
+
+func (s *documentStub) DocumentURL(ctx context.Context, id uuid.UUID, document service.LoanDocument, ttl time.Duration) (string, error) {
+	if document != service.LoanDocumentAgreementLetter {
+		return "", domain.ErrNotFound
+	}
+	s.document = document
+	return "https://example.com/api/v1/documents/agreements/a.pdf?signature=s", nil
+}
+
+func TestGetLoanDocumentRequiresStaffToken(t *testing.T) {
+	svc := &documentStub{}
+	r := chi.NewRouter()
+	r.With(RequireBearerToken("staff-token")).Get("/loans/{id}/documents/{document}", NewLoanHandler(svc).GetLoanDocument)
+	path := "/loans/" + uuid.NewString() + "/documents/"
+
+	cases := []struct {
+		name          string
+		document      string
+		authorization string
+		status        int
+	}{
+		{"no token", "agreement-letter", "", http.StatusUnauthorized},
+		{"wrong token", "agreement-letter", "Bearer other", http.StatusUnauthorized},
+		{"not a bearer token", "agreement-letter", "staff-token", http.StatusUnauthorized},
+		{"unknown document", "passport", "Bearer staff-token", http.StatusNotFound},
+		{"signed", "agreement-letter", "Bearer staff-token", http.StatusFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path+tc.document, nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			require.Equal(t, tc.status, rec.Code, rec.Body.String())
+			switch tc.status {
+			case http.StatusUnauthorized:
+				assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+				assert.Contains(t, rec.Body.String(), problemUnauthorized)
+			case http.StatusFound:
+				assert.Equal(t, service.LoanDocumentAgreementLetter, svc.document)
+				assert.Equal(t, "https://example.com/api/v1/documents/agreements/a.pdf?signature=s", rec.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestRequireBearerTokenWithoutToken(t *testing.T) {
+	h := RequireBearerToken("")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("request let through")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+type createStub struct {
+	service.LoanService
+	terms domain.RepaymentTerms
+}
+
+func (s *createStub) CreateLoan(ctx context.Context, borrowerID string, principal domain.Money, rate, roi domain.Percent, terms domain.RepaymentTerms) (*domain.Loan, error) {
+	s.terms = terms
+	return &domain.Loan{ID: uuid.New(), RepaymentTerms: terms}, nil
+}
+
+func TestCreateLoanRepaymentTerms(t *testing.T) {
+	cases := []struct {
+		body string
+		want domain.RepaymentTerms
+	}{
+		{`"tenor_months": 12`, domain.RepaymentTerms{TenorMonths: 12, RepaymentType: domain.RepaymentEMI}},
+		{`"tenor_months": 6, "repayment_type": "BULLET"`, domain.RepaymentTerms{TenorMonths: 6, RepaymentType: domain.RepaymentBullet}},
+		{`"repayment_type": "FLAT"`, domain.RepaymentTerms{TenorMonths: domain.DefaultTenorMonths, RepaymentType: domain.RepaymentFlat}},
+	}
+	for _, tc := range cases {
+		svc := &createStub{}
+		body := `{"borrower_id_number": "B-1", "principal_amount": "1000", "rate": "12", "roi": "10", ` + tc.body + `}`
+		rec := httptest.NewRecorder()
+		NewLoanHandler(svc).CreateLoan(rec, httptest.NewRequest(http.MethodPost, "/api/v1/loans", strings.NewReader(body)))
+
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		assert.Equal(t, tc.want, svc.terms)
+		assert.Contains(t, rec.Body.String(), `"tenor_months":`+strconv.Itoa(tc.want.TenorMonths))
+	}
+}
+
+type scheduleStub struct {
+	service.LoanService
+	schedule *domain.RepaymentSchedule
+	err      error
+}
+
+func (s *scheduleStub) GetRepaymentSchedule(ctx context.Context, id uuid.UUID) (*domain.RepaymentSchedule, error) {
+	return s.schedule, s.err
+}
+
+func TestGetRepaymentSchedule(t *testing.T) {
+	loan := &domain.Loan{
+		PrincipalAmount: domain.NewMoney(200000, "IDR"),
+		Rate:            domain.Percent(1200),
+		RepaymentTerms:  domain.RepaymentTerms{TenorMonths: 2, RepaymentType: domain.RepaymentBullet},
+	}
+	installments, err := domain.ScheduleInstallments(loan, time.Date(2024, 1, 31, 8, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	settledAt := time.Date(2024, 2, 28, 9, 0, 0, 0, time.UTC)
+	installments[0].PaidInterest = installments[0].Interest
+	installments[0].SettledAt = &settledAt
+
+	cases := []struct {
+		name   string
+		svc    *scheduleStub
+		status int
+		body   string
+	}{
+		{"schedule", &scheduleStub{schedule: domain.NewRepaymentSchedule(loan, installments)}, http.StatusOK, `{
+			"loan_id": "00000000-0000-0000-0000-000000000000",
+			"tenor_months": 2, "repayment_type": "BULLET", "rate": "12.00",
+			"principal": {"amount": "2000.00", "currency": "IDR"},
+			"installments": [
+				{"number": 1, "due_date": "2024-02-29T00:00:00Z",
+					"principal": {"amount": "0.00", "currency": "IDR"},
+					"interest": {"amount": "20.00", "currency": "IDR"},
+					"amount": {"amount": "20.00", "currency": "IDR"},
+					"late_fee": {"amount": "0.00", "currency": "IDR"},
+					"paid_interest": {"amount": "20.00", "currency": "IDR"},
+					"paid_principal": {"amount": "0.00", "currency": "IDR"},
+					"paid_late_fee": {"amount": "0.00", "currency": "IDR"},
+					"settled_at": "2024-02-28T09:00:00Z"},
+				{"number": 2, "due_date": "2024-03-31T00:00:00Z",
+					"principal": {"amount": "2000.00", "currency": "IDR"},
+					"interest": {"amount": "20.00", "currency": "IDR"},
+					"amount": {"amount": "2020.00", "currency": "IDR"},
+					"late_fee": {"amount": "0.00", "currency": "IDR"},
+					"paid_interest": {"amount": "0.00", "currency": "IDR"},
+					"paid_principal": {"amount": "0.00", "currency": "IDR"},
+					"paid_late_fee": {"amount": "0.00", "currency": "IDR"}}
+			],
+			"total_interest": {"amount": "40.00", "currency": "IDR"},
+			"total_amount": {"amount": "2040.00", "currency": "IDR"},
+			"total_late_fees": {"amount": "0.00", "currency": "IDR"},
+			"total_paid": {"amount": "20.00", "currency": "IDR"},
+			"outstanding": {"amount": "2020.00", "currency": "IDR"}}`},
+		{"not found", &scheduleStub{err: domain.ErrNotFound}, http.StatusNotFound, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.Get("/loans/{id}/schedule", NewLoanHandler(tc.svc).GetRepaymentSchedule)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/loans/"+uuid.NewString()+"/schedule", nil))
+
+			require.Equal(t, tc.status, rec.Code, rec.Body.String())
+			if tc.body != "" {
+				assert.JSONEq(t, tc.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+type repaymentStub struct {
+	service.LoanService
+	amount     domain.Money
+	repayments []*domain.Repayment
+	err        error
+}
+
+func (s *repaymentStub) RecordRepayment(ctx context.Context, id uuid.UUID, amount domain.Money) (*domain.Repayment, error) {
+	s.amount = amount
+	if s.err != nil {
+		return nil, s.err
+	}
+	zero := domain.NewMoney(0, "IDR")
+	return &domain.Repayment{
+		ID: uuid.MustParse("6f1c2b1e-8a43-4f6e-9d0b-1c6a0e7d5a10"), LoanID: id, Amount: amount,
+		Interest: domain.NewMoney(2000, "IDR"), Principal: domain.NewMoney(8000, "IDR"), LateFee: zero,
+		PlatformFee: domain.NewMoney(1000, "IDR"),
+		Payouts:     []domain.Payout{domain.NewPayout(uuid.Nil, uuid.Nil, domain.NewMoney(8000, "IDR"), domain.NewMoney(1000, "IDR"))},
+		PaidAt:      time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+	}, nil
+}
+
+func (s *repaymentStub) ListRepayments(ctx context.Context, id uuid.UUID) ([]*domain.Repayment, error) {
+	return s.repayments, s.err
+}
+
+func TestRecordRepayment(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		err    error
+		status int
+		amount domain.Money
+	}{
+		{"created", `{"amount": "100"}`, nil, http.StatusCreated, domain.NewMoney(10000, "")},
+		{"currency", `{"amount": "100.50", "currency": "IDR"}`, nil, http.StatusCreated, domain.NewMoney(10050, "IDR")},
+		{"missing amount", `{}`, nil, http.StatusUnprocessableEntity, domain.Money{}},
+		{"overpaid", `{"amount": "100"}`, domain.Errorf(domain.ErrValidation, "too much"), http.StatusUnprocessableEntity, domain.NewMoney(10000, "")},
+		{"not disbursed", `{"amount": "100"}`, &domain.TransitionError{Event: domain.LoanEventSettle, From: domain.LoanStateApproved}, http.StatusConflict, domain.NewMoney(10000, "")},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &repaymentStub{err: tc.err}
+			r := chi.NewRouter()
+			r.Post("/loans/{id}/repayments", NewLoanHandler(svc).RecordRepayment)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/loans/"+uuid.NewString()+"/repayments", strings.NewReader(tc.body)))
+
+			require.Equal(t, tc.status, rec.Code, rec.Body.String())
+			assert.Equal(t, tc.amount, svc.amount)
+			if tc.status == http.StatusCreated {
+				assert.Contains(t, rec.Body.String(), `"id":"6f1c2b1e-8a43-4f6e-9d0b-1c6a0e7d5a10"`)
+				assert.Contains(t, rec.Body.String(), `"platform_fee":{"amount":"10.00","currency":"IDR"}`)
+			}
+		})
+	}
+}
+
+func TestListRepayments(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/loans/{id}/repayments", NewLoanHandler(&repaymentStub{}).ListRepayments)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/loans/"+uuid.NewString()+"/repayments", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"repayments": []}`, rec.Body.String())
+}
+
+type asOfStub struct {
+	service.LoanService
+	asOf time.Time
+	err  error
+}
+
+func (s *asOfStub) GetLoanAsOf(ctx context.Context, id uuid.UUID, asOf time.Time) (*domain.Loan, error) {
+	s.asOf = asOf
+	return &domain.Loan{ID: id, State: domain.LoanStateApproved, Version: 2}, s.err
+}
+
+func TestGetLoanAsOf(t *testing.T) {
+	cases := []struct {
+		name   string
+		query  string
+		err    error
+		status int
+	}{
+		{"found", "as_of=2025-01-02T03:04:05Z", nil, http.StatusOK},
+		{"offset", "as_of=2025-01-02T10:04:05%2B07:00", nil, http.StatusOK},
+		{"malformed", "as_of=yesterday", nil, http.StatusBadRequest},
+		{"before creation", "as_of=2025-01-02T03:04:05Z", domain.ErrNotFound, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &asOfStub{err: tc.err}
+			r := chi.NewRouter()
+			r.Get("/loans/{id}", NewLoanHandler(svc).GetLoan)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/loans/"+uuid.NewString()+"?"+tc.query, nil))
+
+			require.Equal(t, tc.status, rec.Code, rec.Body.String())
+			if tc.status == http.StatusOK {
+				assert.True(t, svc.asOf.Equal(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)), svc.asOf)
+				assert.Empty(t, rec.Header().Get("ETag"))
+				assert.Contains(t, rec.Body.String(), `"state":"APPROVED"`)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// RequestID passes the ID assigned by chi's middleware.RequestID on to the
+// service, which records it against the changes the request makes. It must
+// be mounted after middleware.RequestID.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := middleware.GetReqID(r.Context()); id != "" {
+			r = r.WithContext(service.WithRequestID(r.Context(), id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireBearerToken lets through requests whose Authorization header
+// carries token as a bearer token and refuses the rest with 401. An empty
+// token refuses every request.
+func RequireBearerToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeProblem(w, newProblem(r, problemUnauthorized, http.StatusUnauthorized, "A valid staff API token is required."))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
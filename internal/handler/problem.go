@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Problem types. They are relative URIs (RFC 7807 section 3.1) the frontend
+// can switch on; titles are fixed per type and details vary per occurrence.
+const (
+	problemBadRequest        = "/problems/bad-request"
+	problemValidation        = "/problems/validation-error"
+	problemUnauthorized      = "/problems/unauthorized"
+	problemForbidden         = "/problems/forbidden"
+	problemPayloadTooLarge   = "/problems/payload-too-large"
+	problemUnsupportedMedia  = "/problems/unsupported-media-type"
+	problemNotFound          = "/problems/not-found"
+	problemConflict          = "/problems/conflict"
+	problemAlreadyExists     = "/problems/already-exists"
+	problemInvalidTransition = "/problems/invalid-state-transition"
+	problemOverInvestment    = "/problems/over-investment"
+	problemInsufficientFunds = "/problems/insufficient-funds"
+	problemInternal          = "/problems/internal-error"
+)
+
+var problemTitles = map[string]string{
+	problemBadRequest:        "Malformed request",
+	problemValidation:        "Request failed validation",
+	problemUnauthorized:      "Authentication required",
+	problemForbidden:         "Access denied",
+	problemPayloadTooLarge:   "Uploaded file is too large",
+	problemUnsupportedMedia:  "Uploaded file type is not supported",
+	problemNotFound:          "Resource not found",
+	problemConflict:          "Resource was modified concurrently",
+	problemAlreadyExists:     "Resource already exists",
+	problemInvalidTransition: "Action not allowed in the loan's current state",
+	problemOverInvestment:    "Investment exceeds remaining principal",
+	problemInsufficientFunds: "Wallet has insufficient available funds",
+	problemInternal:          "Internal server error",
+}
+
+// Problem is an RFC 7807 application/problem+json body.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError points at one offending request field. Field is the JSON,
+// query or path parameter name and Code a stable machine-readable reason.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func newProblem(r *http.Request, problemType string, status int, detail string) *Problem {
+	return &Problem{
+		Type:      problemType,
+		Title:     problemTitles[problemType],
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+}
+
+func writeProblem(w http.ResponseWriter, p *Problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// multipartMemory is how much of a multipart form is held in memory; larger
+// files spill to temporary files. It also bounds the non-file form fields.
+const multipartMemory = 1 << 20
+
+// uploadSpec describes the file a multipart endpoint accepts. Types are
+// checked against the sniffed content, not the client's Content-Type.
+type uploadSpec struct {
+	field   string
+	maxSize int64
+	types   []string
+}
+
+var (
+	proofImageUpload = uploadSpec{
+		field:   "proof_image",
+		maxSize: 10 << 20,
+		types:   []string{"image/jpeg", "image/png", "image/webp"},
+	}
+	signedAgreementUpload = uploadSpec{
+		field:   "signed_agreement",
+		maxSize: 20 << 20,
+		types:   []string{"application/pdf", "image/jpeg", "image/png"},
+	}
+)
+
+func isMultipart(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "multipart/form-data"
+}
+
+// parseUpload parses a multipart request and returns the file named by spec
+// once its size and sniffed type are acceptable. Form values can be read
+// with r.PostFormValue afterwards. cleanup closes the file and removes any
+// temporary files; it must be called once the upload has been stored.
+func parseUpload(w http.ResponseWriter, r *http.Request, spec uploadSpec) (upload service.Upload, cleanup func(), err error) {
+	r.Body = http.MaxBytesReader(w, r.Body, spec.maxSize+multipartMemory)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return upload, nil, &uploadError{field: spec.field, tooLarge: true, message: maxSizeMessage(spec)}
+		}
+		return upload, nil, &requestError{field: "body", code: "malformed", message: "is not a valid multipart form"}
+	}
+
+	file, header, err := r.FormFile(spec.field)
+	if err != nil {
+		r.MultipartForm.RemoveAll()
+		return upload, nil, &requestError{field: spec.field, code: "required", message: "must be attached as a file"}
+	}
+	cleanup = func() {
+		file.Close()
+		r.MultipartForm.RemoveAll()
+	}
+
+	if header.Size > spec.maxSize {
+		cleanup()
+		return upload, nil, &uploadError{field: spec.field, tooLarge: true, message: maxSizeMessage(spec)}
+	}
+
+	detected, err := mimetype.DetectReader(file)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return upload, nil, err
+	}
+	if !mimetype.EqualsAny(detected.String(), spec.types...) {
+		cleanup()
+		return upload, nil, &uploadError{field: spec.field,
+			message: fmt.Sprintf("is %s; must be one of %s", detected.String(), strings.Join(spec.types, ", "))}
+	}
+
+	return service.Upload{
+		Body:        file,
+		ContentType: detected.String(),
+		Extension:   detected.Extension(),
+	}, cleanup, nil
+}
+
+func maxSizeMessage(spec uploadSpec) string {
+	return fmt.Sprintf("must be at most %d MiB", spec.maxSize>>20)
+}
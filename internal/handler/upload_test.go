@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pngHeader is enough of a PNG for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+type uploadRecorder struct {
+	service.LoanService
+	validatorID string
+	upload      service.Upload
+	body        []byte
+}
+
+func (s *uploadRecorder) ApproveLoanWithProof(ctx context.Context, id uuid.UUID, validatorID string, proof service.Upload) error {
+	s.validatorID = validatorID
+	s.upload = proof
+	s.body, _ = io.ReadAll(proof.Body)
+	return nil
+}
+
+func multipartApproval(t *testing.T, validatorID string, file []byte) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if validatorID != "" {
+		require.NoError(t, mw.WriteField("field_validator_id", validatorID))
+	}
+	part, err := mw.CreateFormFile("proof_image", "visit.png")
+	require.NoError(t, err)
+	part.Write(file)
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/loans/"+uuid.NewString()+"/approve", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func serveApproval(svc service.LoanService, req *http.Request) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Post("/loans/{id}/approve", NewLoanHandler(svc).ApproveLoan)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestApproveLoanMultipart(t *testing.T) {
+	svc := &uploadRecorder{}
+	file := append(append([]byte{}, pngHeader...), "rest of image"...)
+
+	rec := serveApproval(svc, multipartApproval(t, "V123", file))
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "V123", svc.validatorID)
+	assert.Equal(t, "image/png", svc.upload.ContentType)
+	assert.Equal(t, ".png", svc.upload.Extension)
+	assert.Equal(t, file, svc.body)
+}
+
+func TestApproveLoanMultipartRejectsUpload(t *testing.T) {
+	cases := []struct {
+		name        string
+		validatorID string
+		file        []byte
+		status      int
+		typ         string
+		field       string
+	}{
+		{"sniffed type", "V123", []byte("#!/bin/sh\nrm -rf /\n"), http.StatusUnsupportedMediaType, problemUnsupportedMedia, "proof_image"},
+		{"too large", "V123", append(append([]byte{}, pngHeader...), make([]byte, proofImageUpload.maxSize)...), http.StatusRequestEntityTooLarge, problemPayloadTooLarge, "proof_image"},
+		{"missing field", "", pngHeader, http.StatusUnprocessableEntity, problemValidation, "field_validator_id"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serveApproval(&uploadRecorder{}, multipartApproval(t, tc.validatorID, tc.file))
+
+			assert.Equal(t, tc.status, rec.Code)
+			p := decodeProblem(t, rec)
+			assert.Equal(t, tc.typ, p.Type)
+			require.Len(t, p.Errors, 1)
+			assert.Equal(t, tc.field, p.Errors[0].Field)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type WalletHandler struct {
+	service  service.WalletService
+	validate *validator.Validate
+}
+
+func NewWalletHandler(service service.WalletService) *WalletHandler {
+	return &WalletHandler{
+		service:  service,
+		validate: newValidator(),
+	}
+}
+
+// WalletTransferRequest moves money in or out of an investor's wallet.
+// Currency defaults to IDR.
+type WalletTransferRequest struct {
+	Amount   string `json:"amount" validate:"required,amount"`
+	Currency string `json:"currency" validate:"omitempty,iso4217"`
+}
+
+type WalletsResponse struct {
+	Wallets []*domain.Wallet `json:"wallets"`
+}
+
+// GetWallets serves GET /investors/{id}/wallet with the investor's wallet
+// in each currency money has moved through.
+func (h *WalletHandler) GetWallets(w http.ResponseWriter, r *http.Request) {
+	id, err := investorID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	wallets, err := h.service.GetWallets(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if wallets == nil {
+		wallets = []*domain.Wallet{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WalletsResponse{Wallets: wallets})
+}
+
+// Deposit serves POST /investors/{id}/wallet/deposits and responds with the
+// wallet the money was paid into.
+func (h *WalletHandler) Deposit(w http.ResponseWriter, r *http.Request) {
+	h.transfer(w, r, h.service.Deposit)
+}
+
+// Withdraw serves POST /investors/{id}/wallet/withdrawals and responds with
+// the wallet the money was paid out of. Asking for more than is available
+// is a 422 insufficient-funds problem.
+func (h *WalletHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
+	h.transfer(w, r, h.service.Withdraw)
+}
+
+func (h *WalletHandler) transfer(w http.ResponseWriter, r *http.Request, move func(ctx context.Context, investorID uuid.UUID, amount domain.Money) (*domain.Wallet, error)) {
+	id, err := investorID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req WalletTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, decodeError(err))
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
+	amount, err := domain.ParseMoney(req.Amount, currency)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	wallet, err := move(r.Context(), id, amount)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wallet)
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+	"vibhordubey333/loan-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newWalletRouter serves the wallet endpoints for one registered investor.
+func newWalletRouter(t *testing.T) (chi.Router, string) {
+	t.Helper()
+	store := repository.NewMemoryStore()
+	investor := &domain.Investor{ID: uuid.New(), KYCStatus: domain.KYCStatusVerified, Status: domain.InvestorStatusActive}
+	require.NoError(t, store.Investors().Create(context.Background(), investor))
+	h := NewWalletHandler(service.NewWalletService(store.Wallets(), store.Investors(), store.Ledger(), store.Transactor()))
+
+	r := chi.NewRouter()
+	r.Get("/investors/{id}/wallet", h.GetWallets)
+	r.Post("/investors/{id}/wallet/deposits", h.Deposit)
+	r.Post("/investors/{id}/wallet/withdrawals", h.Withdraw)
+	return r, "/investors/" + investor.ID.String() + "/wallet"
+}
+
+func TestWalletEndpoints(t *testing.T) {
+	r, path := newWalletRouter(t)
+
+	rec := serve(r, http.MethodGet, path, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"wallets": []}`, rec.Body.String())
+
+	rec = serve(r, http.MethodPost, path+"/deposits", `{"amount": "1500.50"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var wallet domain.Wallet
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &wallet))
+	assert.Equal(t, "IDR", wallet.Currency)
+	assert.Equal(t, domain.NewMoney(150050, "IDR"), wallet.Available)
+
+	rec = serve(r, http.MethodPost, path+"/withdrawals", `{"amount": "500", "currency": "IDR"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = serve(r, http.MethodPost, path+"/deposits", `{"amount": "10", "currency": "USD"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = serve(r, http.MethodGet, path, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var list WalletsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Wallets, 2)
+	assert.Equal(t, domain.NewMoney(100050, "IDR"), list.Wallets[0].Available)
+	assert.Equal(t, domain.NewMoney(1000, "USD"), list.Wallets[1].Available)
+}
+
+func TestWalletEndpointErrors(t *testing.T) {
+	r, path := newWalletRouter(t)
+	rec := serve(r, http.MethodPost, path+"/deposits", `{"amount": "100"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = serve(r, http.MethodPost, path+"/withdrawals", `{"amount": "100.01"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+	assert.Equal(t, problemInsufficientFunds, decodeProblem(t, rec).Type)
+
+	unknown := "/investors/00000000-0000-0000-0000-000000000001/wallet"
+	cases := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{"missing amount", http.MethodPost, path + "/deposits", `{}`, http.StatusUnprocessableEntity},
+		{"negative amount", http.MethodPost, path + "/deposits", `{"amount": "-5"}`, http.StatusUnprocessableEntity},
+		{"bad currency", http.MethodPost, path + "/deposits", `{"amount": "5", "currency": "XYZ"}`, http.StatusUnprocessableEntity},
+		{"malformed body", http.MethodPost, path + "/withdrawals", `{`, http.StatusBadRequest},
+		{"bad ID", http.MethodGet, "/investors/x/wallet", "", http.StatusBadRequest},
+		{"wallets of unknown investor", http.MethodGet, unknown, "", http.StatusNotFound},
+		{"deposit for unknown investor", http.MethodPost, unknown + "/deposits", `{"amount": "5"}`, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(r, tc.method, tc.target, tc.body)
+			assert.Equal(t, tc.status, rec.Code, rec.Body.String())
+		})
+	}
+}
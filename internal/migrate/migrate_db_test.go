@@ -0,0 +1,48 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+
+	"vibhordubey333/loan-service/internal/migrate"
+	"vibhordubey333/loan-service/internal/testdb"
+	"vibhordubey333/loan-service/schema"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpDownStatus(t *testing.T) {
+	db := testdb.OpenEmpty(t)
+	ctx := context.Background()
+
+	migrations, err := migrate.Load(schema.Migrations, "migrations")
+	require.NoError(t, err)
+	migrator := migrate.New(db, migrations)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrations))
+
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied, "up is idempotent")
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, s := range statuses {
+		assert.NotNil(t, s.AppliedAt, "%d_%s", s.Version, s.Name)
+	}
+
+	reverted, err := migrator.Down(ctx, len(migrations))
+	require.NoError(t, err)
+	require.Len(t, reverted, len(migrations))
+	assert.Equal(t, migrations[len(migrations)-1].Version, reverted[0].Version)
+
+	var tables int
+	require.NoError(t, db.QueryRow(
+		`SELECT count(*) FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_name <> 'schema_migrations'`,
+	).Scan(&tables))
+	assert.Zero(t, tables)
+}
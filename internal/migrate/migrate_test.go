@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"vibhordubey333/loan-service/schema"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrdersMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0010_add_index.up.sql":   {Data: []byte("CREATE INDEX ...")},
+		"m/0010_add_index.down.sql": {Data: []byte("DROP INDEX ...")},
+		"m/0002_seed.up.sql":        {Data: []byte("INSERT ...")},
+		"m/0002_seed.down.sql":      {Data: []byte("DELETE ...")},
+		"m/README.md":               {Data: []byte("ignored")},
+	}
+
+	migrations, err := Load(fsys, "m")
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, Migration{Version: 2, Name: "seed", Up: "INSERT ...", Down: "DELETE ..."}, migrations[0])
+	assert.Equal(t, int64(10), migrations[1].Version)
+}
+
+func TestLoadRejectsInvalidMigrations(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"bad name": {"m/initial.sql": {Data: []byte("x")}},
+		"no down":  {"m/0001_a.up.sql": {Data: []byte("x")}},
+		"name clash": {
+			"m/0001_a.up.sql":   {Data: []byte("x")},
+			"m/0001_b.down.sql": {Data: []byte("x")},
+		},
+	}
+	for name, fsys := range cases {
+		_, err := Load(fsys, "m")
+		assert.Error(t, err, name)
+	}
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	migrations, err := Load(schema.Migrations, "migrations")
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	assert.Equal(t, int64(1), migrations[0].Version)
+}
@@ -0,0 +1,38 @@
+package pdf
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBytesProducesConsistentCrossReference(t *testing.T) {
+	doc := New("Test (1)")
+	doc.Text(50, 800, HelveticaBold, 14, "Hello (world) \\ café")
+	doc.AddPage()
+	doc.Line(50, 50, 100, 50)
+	out := doc.Bytes()
+
+	require.True(t, bytes.HasPrefix(out, []byte("%PDF-1.4\n")))
+	require.True(t, bytes.HasSuffix(out, []byte("%%EOF\n")))
+	assert.Contains(t, string(out), `(Hello \(world\) \\ caf\351) Tj`)
+	assert.Contains(t, string(out), "/Count 2")
+
+	// startxref must point at the xref table, and every entry at its object.
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(out)
+	require.NotNil(t, m)
+	xref, err := strconv.Atoi(string(m[1]))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(out[xref:], []byte("xref\n")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(out[xref:], -1)
+	require.Len(t, entries, 9)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		assert.True(t, bytes.HasPrefix(out[off:], []byte(strconv.Itoa(i+1)+" 0 obj\n")), "object %d", i+1)
+	}
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type BorrowerRepository interface {
+	// Create fails with domain.ErrAlreadyExists if the national ID number is
+	// already registered.
+	Create(ctx context.Context, borrower *domain.Borrower) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Borrower, error)
+	// GetByNationalID locks the borrower for the rest of the transaction
+	// carried by ctx, so a loan created for them commits before they can be
+	// changed or deleted.
+	GetByNationalID(ctx context.Context, nationalID string) (*domain.Borrower, error)
+	// List returns the most recently registered borrowers, optionally only
+	// those with the given KYC status.
+	List(ctx context.Context, status domain.KYCStatus, limit int) ([]*domain.Borrower, error)
+	// Update writes the borrower's details and KYC status.
+	Update(ctx context.Context, borrower *domain.Borrower) error
+	// Delete fails with domain.ErrConflict if any loan refers to the
+	// borrower.
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type borrowerRepository struct {
+	db *sql.DB
+}
+
+func NewBorrowerRepository(db *sql.DB) BorrowerRepository {
+	return &borrowerRepository{db: db}
+}
+
+const borrowerColumns = `
+		id, national_id_number, name, email, phone, address,
+		kyc_status, created_at, updated_at`
+
+func (r *borrowerRepository) Create(ctx context.Context, b *domain.Borrower) error {
+	query := `
+		INSERT INTO borrowers (` + borrowerColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query,
+			b.ID, b.NationalIDNumber, b.Name, b.Email, b.Phone, b.Address,
+			b.KYCStatus, b.CreatedAt, b.UpdatedAt,
+		)
+		return err
+	})
+	return dbError(err)
+}
+
+func (r *borrowerRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Borrower, error) {
+	return r.get(ctx, `SELECT `+borrowerColumns+` FROM borrowers WHERE id = $1`, id)
+}
+
+func (r *borrowerRepository) GetByNationalID(ctx context.Context, nationalID string) (*domain.Borrower, error) {
+	return r.get(ctx, `SELECT `+borrowerColumns+` FROM borrowers WHERE national_id_number = $1 FOR SHARE`, nationalID)
+}
+
+func (r *borrowerRepository) get(ctx context.Context, query string, key any) (*domain.Borrower, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, key)
+	if err != nil {
+		return nil, err
+	}
+	borrowers, err := scanBorrowers(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(borrowers) == 0 {
+		return nil, domain.Errorf(domain.ErrNotFound, "borrower %v not found", key)
+	}
+	return borrowers[0], nil
+}
+
+func (r *borrowerRepository) List(ctx context.Context, status domain.KYCStatus, limit int) ([]*domain.Borrower, error) {
+	query := `
+		SELECT ` + borrowerColumns + `
+		FROM borrowers
+		WHERE $1 = '' OR kyc_status = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanBorrowers(rows)
+}
+
+func (r *borrowerRepository) Update(ctx context.Context, b *domain.Borrower) error {
+	query := `
+		UPDATE borrowers
+		SET name = $1, email = $2, phone = $3, address = $4, kyc_status = $5, updated_at = $6
+		WHERE id = $7`
+
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, query,
+			b.Name, b.Email, b.Phone, b.Address, b.KYCStatus, b.UpdatedAt, b.ID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return domain.Errorf(domain.ErrNotFound, "borrower %s not found", b.ID)
+		}
+		return nil
+	})
+	return dbError(err)
+}
+
+func (r *borrowerRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		var nationalID string
+		err := tx.QueryRowContext(ctx,
+			`SELECT national_id_number FROM borrowers WHERE id = $1 FOR UPDATE`, id,
+		).Scan(&nationalID)
+		if err == sql.ErrNoRows {
+			return domain.Errorf(domain.ErrNotFound, "borrower %s not found", id)
+		}
+		if err != nil {
+			return err
+		}
+
+		var hasLoans bool
+		err = tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM loans WHERE borrower_id_number = $1)`, nationalID,
+		).Scan(&hasLoans)
+		if err != nil {
+			return err
+		}
+		if hasLoans {
+			return domain.Errorf(domain.ErrConflict, "borrower %s has loans and cannot be deleted", id)
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM borrowers WHERE id = $1`, id)
+		return err
+	})
+}
+
+func scanBorrowers(rows *sql.Rows) ([]*domain.Borrower, error) {
+	defer rows.Close()
+
+	var borrowers []*domain.Borrower
+	for rows.Next() {
+		var b domain.Borrower
+		if err := rows.Scan(
+			&b.ID, &b.NationalIDNumber, &b.Name, &b.Email, &b.Phone, &b.Address,
+			&b.KYCStatus, &b.CreatedAt, &b.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		borrowers = append(borrowers, &b)
+	}
+	return borrowers, rows.Err()
+}
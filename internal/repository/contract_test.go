@@ -0,0 +1,134 @@
+package repository_test
+
+import (
+	"testing"
+
+	"vibhordubey333/loan-service/internal/repository"
+	"vibhordubey333/loan-service/internal/repository/repositorytest"
+	"vibhordubey333/loan-service/internal/testdb"
+)
+
+func TestPostgresLoanRepositoryContract(t *testing.T) {
+	db := testdb.Open(t)
+	repositorytest.LoanRepository(t, func(t *testing.T) repository.LoanRepository {
+		return repository.NewLoanRepository(db)
+	})
+}
+
+func TestMemoryLoanRepositoryContract(t *testing.T) {
+	repositorytest.LoanRepository(t, func(t *testing.T) repository.LoanRepository {
+		return repository.NewMemoryStore().Loans()
+	})
+}
+
+func TestPostgresLoanHistoryRepositoryContract(t *testing.T) {
+	db := testdb.Open(t)
+	repositorytest.LoanHistoryRepository(t, func(t *testing.T) (repository.LoanRepository, repository.LoanHistoryRepository) {
+		return repository.NewLoanRepository(db), repository.NewLoanHistoryRepository(db)
+	})
+}
+
+func TestMemoryLoanHistoryRepositoryContract(t *testing.T) {
+	repositorytest.LoanHistoryRepository(t, func(t *testing.T) (repository.LoanRepository, repository.LoanHistoryRepository) {
+		store := repository.NewMemoryStore()
+		return store.Loans(), store.History()
+	})
+}
+
+func TestPostgresLoanEventStoreContract(t *testing.T) {
+	db := testdb.Open(t)
+	repositorytest.LoanEventStore(t, func(t *testing.T) (repository.LoanRepository, repository.LoanEventStore) {
+		return repository.NewLoanRepository(db), repository.NewLoanEventStore(db)
+	})
+}
+
+func TestMemoryLoanEventStoreContract(t *testing.T) {
+	repositorytest.LoanEventStore(t, func(t *testing.T) (repository.LoanRepository, repository.LoanEventStore) {
+		store := repository.NewMemoryStore()
+		return store.Loans(), store.Events()
+	})
+}
+
+func TestPostgresBorrowerRepositoryContract(t *testing.T) {
+	db := testdb.Open(t)
+	repositorytest.BorrowerRepository(t, func(t *testing.T) (repository.LoanRepository, repository.BorrowerRepository) {
+		return repository.NewLoanRepository(db), repository.NewBorrowerRepository(db)
+	})
+}
+
+func TestMemoryBorrowerRepositoryContract(t *testing.T) {
+	repositorytest.BorrowerRepository(t, func(t *testing.T) (repository.LoanRepository, repository.BorrowerRepository) {
+		store := repository.NewMemoryStore()
+		return store.Loans(), store.Borrowers()
+	})
+}
+
+func TestPostgresInvestorRepositoryContract(t *testing.T) {
+	db := testdb.Open(t)
+	repositorytest.InvestorRepository(t, func(t *testing.T) (repository.LoanRepository, repository.InvestorRepository) {
+		return repository.NewLoanRepository(db), repository.NewInvestorRepository(db)
+	})
+}
+
+func TestMemoryInvestorRepositoryContract(t *testing.T) {
+	repositorytest.InvestorRepository(t, func(t *testing.T) (repository.LoanRepository, repository.InvestorRepository) {
+		store := repository.NewMemoryStore()
+		return store.Loans(), store.Investors()
+	})
+}
+
+func TestPostgresRepaymentScheduleRepositoryContract(t *testing.T) {
+	db := testdb.Open(t)
+	repositorytest.RepaymentScheduleRepository(t, func(t *testing.T) (repository.LoanRepository, repository.RepaymentScheduleRepository) {
+		return repository.NewLoanRepository(db), repository.NewRepaymentScheduleRepository(db)
+	})
+}
+
+func TestMemoryRepaymentScheduleRepositoryContract(t *testing.T) {
+	repositorytest.RepaymentScheduleRepository(t, func(t *testing.T) (repository.LoanRepository, repository.RepaymentScheduleRepository) {
+		store := repository.NewMemoryStore()
+		return store.Loans(), store.Schedules()
+	})
+}
+
+func TestPostgresRepaymentRepositoryContract(t *testing.T) {
+	db := testdb.Open(t)
+	repositorytest.RepaymentRepository(t, func(t *testing.T) (repository.LoanRepository, repository.RepaymentRepository) {
+		return repository.NewLoanRepository(db), repository.NewRepaymentRepository(db)
+	})
+}
+
+func TestMemoryRepaymentRepositoryContract(t *testing.T) {
+	repositorytest.RepaymentRepository(t, func(t *testing.T) (repository.LoanRepository, repository.RepaymentRepository) {
+		store := repository.NewMemoryStore()
+		return store.Loans(), store.Repayments()
+	})
+}
+
+func TestPostgresLedgerRepositoryContract(t *testing.T) {
+	db := testdb.Open(t)
+	repositorytest.LedgerRepository(t, func(t *testing.T) (repository.LoanRepository, repository.LedgerRepository) {
+		return repository.NewLoanRepository(db), repository.NewLedgerRepository(db)
+	})
+}
+
+func TestMemoryLedgerRepositoryContract(t *testing.T) {
+	repositorytest.LedgerRepository(t, func(t *testing.T) (repository.LoanRepository, repository.LedgerRepository) {
+		store := repository.NewMemoryStore()
+		return store.Loans(), store.Ledger()
+	})
+}
+
+func TestPostgresWalletRepositoryContract(t *testing.T) {
+	db := testdb.Open(t)
+	repositorytest.WalletRepository(t, func(t *testing.T) (repository.InvestorRepository, repository.WalletRepository, repository.Transactor) {
+		return repository.NewInvestorRepository(db), repository.NewWalletRepository(db), repository.NewTransactor(db)
+	})
+}
+
+func TestMemoryWalletRepositoryContract(t *testing.T) {
+	repositorytest.WalletRepository(t, func(t *testing.T) (repository.InvestorRepository, repository.WalletRepository, repository.Transactor) {
+		store := repository.NewMemoryStore()
+		return store.Investors(), store.Wallets(), store.Transactor()
+	})
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"errors"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/lib/pq"
+)
+
+// constraintErrors maps schema constraints to the domain errors they guard.
+// They back up checks the domain makes first, so hitting one usually means a
+// row was written by something other than this service's domain logic.
+var constraintErrors = map[string]error{
+	"investments_loan_id_fkey":             &domain.Error{Kind: domain.ErrNotFound, Message: "loan not found"},
+	"investments_within_principal":         &domain.Error{Kind: domain.ErrOverInvestment, Message: "investment exceeds remaining principal"},
+	"investments_currency_check":           &domain.Error{Kind: domain.ErrValidation, Message: "investment currency must match the loan currency"},
+	"investments_amount_check":             &domain.Error{Kind: domain.ErrValidation, Message: "investment amount must be positive"},
+	"loans_state_check":                    &domain.Error{Kind: domain.ErrValidation, Message: "unknown loan state"},
+	"loans_principal_amount_check":         &domain.Error{Kind: domain.ErrValidation, Message: "principal amount must be positive"},
+	"loans_rate_check":                     &domain.Error{Kind: domain.ErrValidation, Message: "rate must be positive"},
+	"loans_roi_check":                      &domain.Error{Kind: domain.ErrValidation, Message: "roi must be positive"},
+	"loans_repayment_terms_check":          &domain.Error{Kind: domain.ErrValidation, Message: "tenor must be between 1 and 360 months with a known repayment type"},
+	"loan_events_loan_id_fkey":             &domain.Error{Kind: domain.ErrNotFound, Message: "loan not found"},
+	"loan_events_pkey":                     &domain.Error{Kind: domain.ErrConflict, Message: "loan history already has an entry for this version"},
+	"loan_changes_loan_id_fkey":            &domain.Error{Kind: domain.ErrNotFound, Message: "loan not found"},
+	"loan_changes_pkey":                    &domain.Error{Kind: domain.ErrConflict, Message: "loan changed concurrently"},
+	"repayment_installments_loan_id_fkey":  &domain.Error{Kind: domain.ErrNotFound, Message: "loan not found"},
+	"repayment_installments_pkey":          &domain.Error{Kind: domain.ErrConflict, Message: "loan already has a repayment schedule"},
+	"repayment_installments_amounts_check": &domain.Error{Kind: domain.ErrValidation, Message: "installment amounts must not be negative"},
+	"repayment_installments_paid_check":    &domain.Error{Kind: domain.ErrValidation, Message: "installment payments must not be negative or exceed what is due"},
+	"repayments_loan_id_fkey":              &domain.Error{Kind: domain.ErrNotFound, Message: "loan not found"},
+	"repayments_amount_check":              &domain.Error{Kind: domain.ErrValidation, Message: "repayment amount must be positive and add up to what it paid off"},
+	"repayment_payouts_repayment_id_fkey":  &domain.Error{Kind: domain.ErrNotFound, Message: "repayment not found"},
+	"repayment_payouts_investment_id_fkey": &domain.Error{Kind: domain.ErrNotFound, Message: "investment not found"},
+	"repayment_payouts_pkey":               &domain.Error{Kind: domain.ErrConflict, Message: "repayment already pays out on this investment"},
+	"repayment_payouts_amounts_check":      &domain.Error{Kind: domain.ErrValidation, Message: "payout amounts must not be negative"},
+	"ledger_entries_loan_id_fkey":          &domain.Error{Kind: domain.ErrNotFound, Message: "loan not found"},
+	"ledger_entries_kind_check":            &domain.Error{Kind: domain.ErrValidation, Message: "unknown journal entry kind"},
+	"ledger_entries_kind_reference_id_key": &domain.Error{Kind: domain.ErrConflict, Message: "money movement already posted to the ledger"},
+	"ledger_entries_loan_id_check":         &domain.Error{Kind: domain.ErrValidation, Message: "journal entry must name a loan exactly when it moves money for one"},
+	"ledger_entries_balanced":              &domain.Error{Kind: domain.ErrValidation, Message: "journal entry does not balance"},
+	"ledger_lines_entry_id_fkey":           &domain.Error{Kind: domain.ErrNotFound, Message: "journal entry not found"},
+	"ledger_lines_pkey":                    &domain.Error{Kind: domain.ErrConflict, Message: "journal entry already has this line"},
+	"ledger_lines_check":                   &domain.Error{Kind: domain.ErrValidation, Message: "journal lines must post a positive amount to a known account"},
+	"borrowers_national_id_number_key":     &domain.Error{Kind: domain.ErrAlreadyExists, Message: "a borrower with this national ID number is already registered"},
+	"borrowers_kyc_status_check":           &domain.Error{Kind: domain.ErrValidation, Message: "unknown KYC status"},
+	"investors_email_key":                  &domain.Error{Kind: domain.ErrAlreadyExists, Message: "an investor with this email address is already registered"},
+	"investors_kyc_status_check":           &domain.Error{Kind: domain.ErrValidation, Message: "unknown KYC status"},
+	"investors_status_check":               &domain.Error{Kind: domain.ErrValidation, Message: "unknown investor status"},
+	"investors_limits_check":               &domain.Error{Kind: domain.ErrValidation, Message: "investment limits must be positive and in one currency"},
+	"investor_wallets_investor_id_fkey":    &domain.Error{Kind: domain.ErrNotFound, Message: "investor not found"},
+	"investor_wallets_pkey":                &domain.Error{Kind: domain.ErrConflict, Message: "investor already has a wallet in this currency"},
+	"investor_wallets_balances_check":      &domain.Error{Kind: domain.ErrInsufficientFunds, Message: "wallet balances must not be negative"},
+}
+
+// dbError translates integrity violations reported by Postgres into domain
+// errors and returns any other error unchanged.
+func dbError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	if mapped, ok := constraintErrors[pqErr.Constraint]; ok {
+		return mapped
+	}
+
+	switch pqErr.Code.Name() {
+	case "unique_violation":
+		return &domain.Error{Kind: domain.ErrConflict, Message: "resource already exists"}
+	case "foreign_key_violation":
+		return &domain.Error{Kind: domain.ErrNotFound, Message: "referenced resource not found"}
+	case "check_violation", "not_null_violation":
+		return &domain.Error{Kind: domain.ErrValidation, Message: "value violates a database constraint"}
+	}
+	return err
+}
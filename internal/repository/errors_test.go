@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDBError(t *testing.T) {
+	other := errors.New("connection refused")
+	cases := []struct {
+		err  error
+		kind error
+	}{
+		{&pq.Error{Code: "23514", Constraint: "investments_within_principal"}, domain.ErrOverInvestment},
+		{fmt.Errorf("insert: %w", &pq.Error{Code: "23503", Constraint: "investments_loan_id_fkey"}), domain.ErrNotFound},
+		{&pq.Error{Code: "23514", Constraint: "loans_state_check"}, domain.ErrValidation},
+		{&pq.Error{Code: "23514", Constraint: "investor_wallets_balances_check"}, domain.ErrInsufficientFunds},
+		{&pq.Error{Code: "23514", Constraint: "some_future_check"}, domain.ErrValidation},
+		{&pq.Error{Code: "23505", Constraint: "loans_pkey"}, domain.ErrConflict},
+		{&pq.Error{Code: "23505", Constraint: "borrowers_national_id_number_key"}, domain.ErrAlreadyExists},
+		{&pq.Error{Code: "23505", Constraint: "investors_email_key"}, domain.ErrAlreadyExists},
+		{&pq.Error{Code: "40P01"}, nil},
+		{other, nil},
+	}
+
+	for _, tc := range cases {
+		got := dbError(tc.err)
+		if tc.kind == nil {
+			assert.Equal(t, tc.err, got)
+			continue
+		}
+		assert.ErrorIs(t, got, tc.kind, tc.err.Error())
+	}
+	assert.NoError(t, dbError(nil))
+}
+
+func TestSchemaRejectsInvalidInvestments(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewLoanRepository(db)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	loan := &domain.Loan{
+		ID:               uuid.New(),
+		BorrowerIDNumber: "constraints-" + uuid.NewString(),
+		PrincipalAmount:  domain.NewMoney(10000, "IDR"),
+		Rate:             domain.Percent(500),
+		ROI:              domain.Percent(400),
+		State:            domain.LoanStateApproved,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	require.NoError(t, repo.Create(ctx, loan))
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM investments WHERE loan_id = $1`, loan.ID)
+		db.Exec(`DELETE FROM loans WHERE id = $1`, loan.ID)
+	})
+
+	insert := func(loanID uuid.UUID, amount, currency string) error {
+		_, err := db.Exec(`INSERT INTO investments (id, loan_id, investor_id, amount, currency, created_at)
+			VALUES ($1, $2, $3, $4, $5, now())`, uuid.New(), loanID, uuid.New(), amount, currency)
+		return dbError(err)
+	}
+
+	require.NoError(t, insert(loan.ID, "60.00", "IDR"))
+	assert.ErrorIs(t, insert(loan.ID, "40.01", "IDR"), domain.ErrOverInvestment)
+	assert.ErrorIs(t, insert(loan.ID, "10.00", "USD"), domain.ErrValidation)
+	assert.ErrorIs(t, insert(loan.ID, "-1.00", "IDR"), domain.ErrValidation)
+	assert.ErrorIs(t, insert(uuid.New(), "1.00", "IDR"), domain.ErrNotFound)
+
+	loan.State = "approved"
+	assert.ErrorIs(t, repo.Update(ctx, loan), domain.ErrValidation)
+}
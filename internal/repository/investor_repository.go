@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type InvestorRepository interface {
+	// Create fails with domain.ErrAlreadyExists if the email address is
+	// already registered.
+	Create(ctx context.Context, investor *domain.Investor) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Investor, error)
+	// GetForUpdate locks the investor for the rest of the transaction carried
+	// by ctx, so their investments are checked against their limits one at a
+	// time.
+	GetForUpdate(ctx context.Context, id uuid.UUID) (*domain.Investor, error)
+	// List returns the most recently registered investors, optionally only
+	// those with the given status.
+	List(ctx context.Context, status domain.InvestorStatus, limit int) ([]*domain.Investor, error)
+	// Update writes the investor's details, KYC status and status. It fails
+	// with domain.ErrAlreadyExists if the email address belongs to another
+	// investor.
+	Update(ctx context.Context, investor *domain.Investor) error
+	// Delete fails with domain.ErrConflict if the investor has invested or
+	// has a wallet.
+	Delete(ctx context.Context, id uuid.UUID) error
+	// TotalInvested sums the investor's investments in currency that have
+	// not been voided.
+	TotalInvested(ctx context.Context, id uuid.UUID, currency string) (domain.Money, error)
+	// Holdings returns all of the investor's investments, voided ones
+	// included, with their loans, oldest first.
+	Holdings(ctx context.Context, id uuid.UUID) ([]domain.Holding, error)
+}
+
+type investorRepository struct {
+	db *sql.DB
+}
+
+func NewInvestorRepository(db *sql.DB) InvestorRepository {
+	return &investorRepository{db: db}
+}
+
+const investorColumns = `
+		id, name, email, kyc_status, status,
+		per_loan_limit, total_limit, limit_currency, created_at, updated_at`
+
+func (r *investorRepository) Create(ctx context.Context, i *domain.Investor) error {
+	query := `
+		INSERT INTO investors (` + investorColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	perLoan, total, currency := limitColumns(i.Limits)
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query,
+			i.ID, i.Name, i.Email, i.KYCStatus, i.Status,
+			perLoan, total, currency, i.CreatedAt, i.UpdatedAt,
+		)
+		return err
+	})
+	return dbError(err)
+}
+
+func (r *investorRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Investor, error) {
+	return r.get(ctx, `SELECT `+investorColumns+` FROM investors WHERE id = $1`, id)
+}
+
+func (r *investorRepository) GetForUpdate(ctx context.Context, id uuid.UUID) (*domain.Investor, error) {
+	return r.get(ctx, `SELECT `+investorColumns+` FROM investors WHERE id = $1 FOR UPDATE`, id)
+}
+
+func (r *investorRepository) get(ctx context.Context, query string, id uuid.UUID) (*domain.Investor, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	investors, err := scanInvestors(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(investors) == 0 {
+		return nil, domain.Errorf(domain.ErrNotFound, "investor %s not found", id)
+	}
+	return investors[0], nil
+}
+
+func (r *investorRepository) List(ctx context.Context, status domain.InvestorStatus, limit int) ([]*domain.Investor, error) {
+	query := `
+		SELECT ` + investorColumns + `
+		FROM investors
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanInvestors(rows)
+}
+
+func (r *investorRepository) Update(ctx context.Context, i *domain.Investor) error {
+	query := `
+		UPDATE investors
+		SET name = $1, email = $2, kyc_status = $3, status = $4,
+			per_loan_limit = $5, total_limit = $6, limit_currency = $7, updated_at = $8
+		WHERE id = $9`
+
+	perLoan, total, currency := limitColumns(i.Limits)
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, query,
+			i.Name, i.Email, i.KYCStatus, i.Status, perLoan, total, currency, i.UpdatedAt, i.ID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return domain.Errorf(domain.ErrNotFound, "investor %s not found", i.ID)
+		}
+		return nil
+	})
+	return dbError(err)
+}
+
+func (r *investorRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		var exists bool
+		err := tx.QueryRowContext(ctx,
+			`SELECT true FROM investors WHERE id = $1 FOR UPDATE`, id,
+		).Scan(&exists)
+		if err == sql.ErrNoRows {
+			return domain.Errorf(domain.ErrNotFound, "investor %s not found", id)
+		}
+		if err != nil {
+			return err
+		}
+
+		var hasInvestments, hasWallet bool
+		err = tx.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM investments WHERE investor_id = $1),
+				EXISTS (SELECT 1 FROM investor_wallets WHERE investor_id = $1)`, id,
+		).Scan(&hasInvestments, &hasWallet)
+		if err != nil {
+			return err
+		}
+		if hasInvestments {
+			return domain.Errorf(domain.ErrConflict, "investor %s has investments and cannot be deleted", id)
+		}
+		if hasWallet {
+			return domain.Errorf(domain.ErrConflict, "investor %s has a wallet and cannot be deleted", id)
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM investors WHERE id = $1`, id)
+		return err
+	})
+}
+
+func (r *investorRepository) TotalInvested(ctx context.Context, id uuid.UUID, currency string) (domain.Money, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0)::TEXT
+		FROM investments
+		WHERE investor_id = $1 AND currency = $2 AND voided_at IS NULL`
+
+	var total string
+	if err := conn(ctx, r.db).QueryRowContext(ctx, query, id, currency).Scan(&total); err != nil {
+		return domain.Money{}, err
+	}
+	return domain.ParseMoney(total, currency)
+}
+
+func (r *investorRepository) Holdings(ctx context.Context, id uuid.UUID) ([]domain.Holding, error) {
+	query := `
+		SELECT i.id, i.loan_id, i.investor_id, i.amount, i.currency, i.created_at, i.voided_at,
+			l.state, l.principal_amount, l.currency, l.roi
+		FROM investments i
+		JOIN loans l ON l.id = i.loan_id
+		WHERE i.investor_id = $1
+		ORDER BY i.created_at, i.id`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holdings []domain.Holding
+	for rows.Next() {
+		var (
+			h                                         domain.Holding
+			amount, currency, principal, loanCurrency string
+			voidedAt                                  sql.NullTime
+		)
+		if err := rows.Scan(
+			&h.Investment.ID, &h.Investment.LoanID, &h.Investment.InvestorID,
+			&amount, &currency, &h.Investment.CreatedAt, &voidedAt,
+			&h.LoanState, &principal, &loanCurrency, &h.LoanROI,
+		); err != nil {
+			return nil, err
+		}
+		if h.Investment.Amount, err = domain.ParseMoney(amount, currency); err != nil {
+			return nil, err
+		}
+		if h.LoanPrincipal, err = domain.ParseMoney(principal, loanCurrency); err != nil {
+			return nil, err
+		}
+		if voidedAt.Valid {
+			h.Investment.VoidedAt = &voidedAt.Time
+		}
+		holdings = append(holdings, h)
+	}
+	return holdings, rows.Err()
+}
+
+// limitColumns splits limits into the per_loan_limit, total_limit and
+// limit_currency columns.
+func limitColumns(l domain.InvestmentLimits) (perLoan, total, currency sql.NullString) {
+	if l.PerLoan != nil {
+		perLoan = sql.NullString{String: l.PerLoan.String(), Valid: true}
+		currency = sql.NullString{String: l.PerLoan.Currency, Valid: true}
+	}
+	if l.Total != nil {
+		total = sql.NullString{String: l.Total.String(), Valid: true}
+		currency = sql.NullString{String: l.Total.Currency, Valid: true}
+	}
+	return perLoan, total, currency
+}
+
+func scanInvestors(rows *sql.Rows) ([]*domain.Investor, error) {
+	defer rows.Close()
+
+	var investors []*domain.Investor
+	for rows.Next() {
+		var (
+			i                        domain.Investor
+			perLoan, total, currency sql.NullString
+		)
+		if err := rows.Scan(
+			&i.ID, &i.Name, &i.Email, &i.KYCStatus, &i.Status,
+			&perLoan, &total, &currency, &i.CreatedAt, &i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		var err error
+		if i.Limits.PerLoan, err = limitMoney(perLoan, currency); err != nil {
+			return nil, err
+		}
+		if i.Limits.Total, err = limitMoney(total, currency); err != nil {
+			return nil, err
+		}
+		investors = append(investors, &i)
+	}
+	return investors, rows.Err()
+}
+
+func limitMoney(amount, currency sql.NullString) (*domain.Money, error) {
+	if !amount.Valid {
+		return nil, nil
+	}
+	m, err := domain.ParseMoney(amount.String, currency.String)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
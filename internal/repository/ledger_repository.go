@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type LedgerRepository interface {
+	// Post records journal entries. Posting a second entry of the same kind
+	// for the same reference is a conflict, and an entry that does not
+	// balance is rejected when its transaction commits.
+	Post(ctx context.Context, entries ...*domain.JournalEntry) error
+	// Entries returns the loan's entries in the order they were posted.
+	Entries(ctx context.Context, loanID uuid.UUID) ([]*domain.JournalEntry, error)
+	// Balances returns what has been posted to each account, ordered by
+	// account type, owner and currency, byte by byte.
+	Balances(ctx context.Context) ([]domain.AccountBalance, error)
+	// UnbalancedEntries returns the IDs of entries whose debits and credits
+	// differ, which the schema should never have let through.
+	UnbalancedEntries(ctx context.Context) ([]uuid.UUID, error)
+}
+
+type ledgerRepository struct {
+	db *sql.DB
+}
+
+func NewLedgerRepository(db *sql.DB) LedgerRepository {
+	return &ledgerRepository{db: db}
+}
+
+func (r *ledgerRepository) Post(ctx context.Context, entries ...*domain.JournalEntry) error {
+	query := `
+		INSERT INTO ledger_entries (id, kind, loan_id, reference_id, posted_at)
+		VALUES ($1, $2, $3, $4, $5)`
+	lineQuery := `
+		INSERT INTO ledger_lines (
+			entry_id, line_no, account_type, owner_id, currency, side, amount
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, e := range entries {
+			// Deposits and withdrawals are for no loan.
+			loanID := uuid.NullUUID{UUID: e.LoanID, Valid: e.LoanID != uuid.Nil}
+			if _, err := tx.ExecContext(ctx, query, e.ID, e.Kind, loanID, e.ReferenceID, e.PostedAt); err != nil {
+				return err
+			}
+			for i, line := range e.Lines {
+				if _, err := tx.ExecContext(ctx, lineQuery,
+					e.ID, i+1, line.Account.Type, line.Account.OwnerID, line.Account.Currency,
+					line.Side, line.Amount.String(),
+				); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return dbError(err)
+}
+
+func (r *ledgerRepository) Entries(ctx context.Context, loanID uuid.UUID) ([]*domain.JournalEntry, error) {
+	query := `
+		SELECT e.id, e.kind, e.loan_id, e.reference_id, e.posted_at,
+			l.account_type, l.owner_id, l.currency, l.side, l.amount
+		FROM ledger_entries e
+		JOIN ledger_lines l ON l.entry_id = e.id
+		WHERE e.loan_id = $1
+		ORDER BY e.posted_at, e.seq, l.line_no`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, loanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*domain.JournalEntry
+	for rows.Next() {
+		var (
+			e      domain.JournalEntry
+			line   domain.JournalLine
+			amount string
+		)
+		if err := rows.Scan(&e.ID, &e.Kind, &e.LoanID, &e.ReferenceID, &e.PostedAt,
+			&line.Account.Type, &line.Account.OwnerID, &line.Account.Currency, &line.Side, &amount); err != nil {
+			return nil, err
+		}
+		if line.Amount, err = domain.ParseMoney(amount, line.Account.Currency); err != nil {
+			return nil, err
+		}
+		if n := len(entries); n == 0 || entries[n-1].ID != e.ID {
+			e.Lines = []domain.JournalLine{}
+			entries = append(entries, &e)
+		}
+		last := entries[len(entries)-1]
+		last.Lines = append(last.Lines, line)
+	}
+	return entries, rows.Err()
+}
+
+func (r *ledgerRepository) Balances(ctx context.Context) ([]domain.AccountBalance, error) {
+	query := `
+		SELECT account_type, owner_id, currency,
+			COALESCE(SUM(amount) FILTER (WHERE side = 'DEBIT'), 0),
+			COALESCE(SUM(amount) FILTER (WHERE side = 'CREDIT'), 0)
+		FROM ledger_lines
+		GROUP BY account_type, owner_id, currency
+		ORDER BY account_type COLLATE "C", owner_id COLLATE "C", currency COLLATE "C"`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []domain.AccountBalance
+	for rows.Next() {
+		var (
+			account         domain.Account
+			debits, credits string
+		)
+		if err := rows.Scan(&account.Type, &account.OwnerID, &account.Currency, &debits, &credits); err != nil {
+			return nil, err
+		}
+		d, err := domain.ParseMoney(debits, account.Currency)
+		if err != nil {
+			return nil, err
+		}
+		c, err := domain.ParseMoney(credits, account.Currency)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, domain.NewAccountBalance(account, d, c))
+	}
+	return balances, rows.Err()
+}
+
+func (r *ledgerRepository) UnbalancedEntries(ctx context.Context) ([]uuid.UUID, error) {
+	query := `
+		SELECT DISTINCT entry_id
+		FROM ledger_lines
+		GROUP BY entry_id, currency
+		HAVING SUM(CASE side WHEN 'DEBIT' THEN amount ELSE -amount END) <> 0
+		ORDER BY entry_id`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// LoanEventStore keeps each loan's stream of changes, from which the loan
+// can be rebuilt as of any time.
+type LoanEventStore interface {
+	// Append adds the records to the end of their loans' streams in the
+	// order given and numbers them. It joins the transaction carried by ctx,
+	// which should also update the loans projection.
+	Append(ctx context.Context, records ...*domain.LoanChangeRecord) error
+	// Load returns the loan's stream up to and including asOf, in sequence
+	// order. A zero asOf loads the whole stream.
+	Load(ctx context.Context, loanID uuid.UUID, asOf time.Time) ([]*domain.LoanChangeRecord, error)
+}
+
+type loanEventStore struct {
+	db *sql.DB
+}
+
+func NewLoanEventStore(db *sql.DB) LoanEventStore {
+	return &loanEventStore{db: db}
+}
+
+func (s *loanEventStore) Append(ctx context.Context, records ...*domain.LoanChangeRecord) error {
+	// Appends to one loan are serialised by the loans row the same
+	// transaction updates; the primary key catches any that are not.
+	query := `
+		INSERT INTO loan_changes (loan_id, sequence, version, type, data, occurred_at)
+		SELECT $1, COALESCE(MAX(sequence), 0) + 1, $2, $3, $4, $5
+		FROM loan_changes WHERE loan_id = $1
+		RETURNING sequence`
+
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		for _, r := range records {
+			if err := tx.QueryRowContext(ctx, query,
+				r.LoanID, r.Version, r.Type, []byte(r.Data), r.OccurredAt,
+			).Scan(&r.Sequence); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return dbError(err)
+}
+
+func (s *loanEventStore) Load(ctx context.Context, loanID uuid.UUID, asOf time.Time) ([]*domain.LoanChangeRecord, error) {
+	query := `
+		SELECT loan_id, sequence, version, type, data, occurred_at
+		FROM loan_changes
+		WHERE loan_id = $1 AND ($2::timestamptz IS NULL OR occurred_at <= $2)
+		ORDER BY sequence`
+
+	var until sql.NullTime
+	if !asOf.IsZero() {
+		until = sql.NullTime{Time: asOf, Valid: true}
+	}
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query, loanID, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*domain.LoanChangeRecord
+	for rows.Next() {
+		var (
+			r    domain.LoanChangeRecord
+			data []byte
+		)
+		if err := rows.Scan(&r.LoanID, &r.Sequence, &r.Version, &r.Type, &data, &r.OccurredAt); err != nil {
+			return nil, err
+		}
+		r.Data = data
+		records = append(records, &r)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type LoanHistoryRepository interface {
+	// Append joins the transaction carried by ctx, so an entry is written
+	// if and only if the change it records is.
+	Append(ctx context.Context, entries ...*domain.LoanHistoryEntry) error
+	// List returns the loan's history, oldest first.
+	List(ctx context.Context, loanID uuid.UUID) ([]*domain.LoanHistoryEntry, error)
+}
+
+type loanHistoryRepository struct {
+	db *sql.DB
+}
+
+func NewLoanHistoryRepository(db *sql.DB) LoanHistoryRepository {
+	return &loanHistoryRepository{db: db}
+}
+
+func (r *loanHistoryRepository) Append(ctx context.Context, entries ...*domain.LoanHistoryEntry) error {
+	query := `
+		INSERT INTO loan_events (
+			loan_id, version, event, from_state, to_state,
+			actor_id, request_id, payload, occurred_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, e := range entries {
+			var payload []byte
+			if len(e.Payload) > 0 {
+				payload = e.Payload
+			}
+			if _, err := tx.ExecContext(ctx, query,
+				e.LoanID, e.Version, e.Event,
+				sql.NullString{String: string(e.FromState), Valid: e.FromState != ""},
+				e.ToState,
+				sql.NullString{String: e.ActorID, Valid: e.ActorID != ""},
+				sql.NullString{String: e.RequestID, Valid: e.RequestID != ""},
+				payload, e.OccurredAt,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return dbError(err)
+}
+
+func (r *loanHistoryRepository) List(ctx context.Context, loanID uuid.UUID) ([]*domain.LoanHistoryEntry, error) {
+	query := `
+		SELECT loan_id, version, event, from_state, to_state,
+			actor_id, request_id, payload, occurred_at
+		FROM loan_events
+		WHERE loan_id = $1
+		ORDER BY version`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, loanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*domain.LoanHistoryEntry
+	for rows.Next() {
+		var (
+			e                           domain.LoanHistoryEntry
+			from, actor, request, event sql.NullString
+			payload                     []byte
+		)
+		if err := rows.Scan(&e.LoanID, &e.Version, &event, &from, &e.ToState,
+			&actor, &request, &payload, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		e.Event = domain.LoanEvent(event.String)
+		e.FromState = domain.LoanState(from.String)
+		e.ActorID = actor.String
+		e.RequestID = request.String
+		e.Payload = payload
+		entries = append(entries, &e)
+	}
+	return entries, rows.Err()
+}
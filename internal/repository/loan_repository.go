@@ -3,17 +3,30 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
 	"vibhordubey333/loan-service/internal/domain"
+
 	"github.com/google/uuid"
-	"encoding/json"
-	"errors"
+	"github.com/lib/pq"
 )
 
 type LoanRepository interface {
 	Create(ctx context.Context, loan *domain.Loan) error
 	GetByID(ctx context.Context, id uuid.UUID) (*domain.Loan, error)
 	Update(ctx context.Context, loan *domain.Loan) error
-	AddInvestment(ctx context.Context, investment *domain.Investment) error
+	// AddInvestment locks the loan for the rest of the transaction carried
+	// by ctx, adds the investment and bumps the loan's version by one.
+	AddInvestment(ctx context.Context, investment *domain.Investment) (*domain.Loan, error)
+	// VoidInvestments marks every investment in the loan that is not yet
+	// voided as voided at the given time and returns them.
+	VoidInvestments(ctx context.Context, loanID uuid.UUID, at time.Time) ([]domain.Investment, error)
+	List(ctx context.Context, filter domain.LoanFilter) (*domain.LoanPage, error)
 }
 
 type loanRepository struct {
@@ -25,44 +38,38 @@ func NewLoanRepository(db *sql.DB) LoanRepository {
 }
 
 func (r *loanRepository) Create(ctx context.Context, loan *domain.Loan) error {
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
 	query := `
 		INSERT INTO loans (
-			id, borrower_id_number, principal_amount, rate, roi, 
-			state, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id`
-
-	err = tx.QueryRowContext(ctx, query,
-		loan.ID, loan.BorrowerIDNumber, loan.PrincipalAmount,
-		loan.Rate, loan.ROI, loan.State, loan.CreatedAt, loan.UpdatedAt,
-	).Scan(&loan.ID)
-
-	if err != nil {
-		return err
-	}
-
-	return tx.Commit()
+			id, borrower_id_number, principal_amount, currency, rate, roi, 
+			tenor_months, repayment_type, state, version, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 1, $10, $11)
+		RETURNING id, version`
+
+	tenor, repaymentType := repaymentTermColumns(loan.RepaymentTerms)
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query,
+			loan.ID, loan.BorrowerIDNumber, loan.PrincipalAmount.String(), loan.PrincipalAmount.Currency,
+			loan.Rate, loan.ROI, tenor, repaymentType, loan.State, loan.CreatedAt, loan.UpdatedAt,
+		).Scan(&loan.ID, &loan.Version)
+	})
+	return dbError(err)
 }
 
+// Update writes the loan back only if its version still matches the one it
+// was read at, and bumps the version. A stale version yields
+// domain.ErrConflict.
 func (r *loanRepository) Update(ctx context.Context, loan *domain.Loan) error {
-	tx, err := r.db.BeginTx(ctx, nil)
+	approvalJSON, err := nullJSON(loan.ApprovalDetails)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
 
-	approvalJSON, err := json.Marshal(loan.ApprovalDetails)
+	disbursementJSON, err := nullJSON(loan.DisbursementDetails)
 	if err != nil {
 		return err
 	}
 
-	disbursementJSON, err := json.Marshal(loan.DisbursementDetails)
+	closureJSON, err := nullJSON(loan.ClosureDetails)
 	if err != nil {
 		return err
 	}
@@ -73,85 +80,398 @@ func (r *loanRepository) Update(ctx context.Context, loan *domain.Loan) error {
 			approval_details = $2,
 			disbursement_details = $3,
 			agreement_letter_url = $4,
-			updated_at = $5
-		WHERE id = $6`
-
-	result, err := tx.ExecContext(ctx, query,
-		loan.State,
-		approvalJSON,
-		disbursementJSON,
-		loan.AgreementLetterURL,
-		loan.UpdatedAt,
-		loan.ID,
-	)
+			closure_details = $5,
+			updated_at = $6,
+			version = version + 1
+		WHERE id = $7 AND version = $8
+		RETURNING version`
+
+	var version int64
+	err = inTx(ctx, r.db, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query,
+			loan.State,
+			approvalJSON,
+			disbursementJSON,
+			sql.NullString{String: loan.AgreementLetterURL, Valid: loan.AgreementLetterURL != ""},
+			closureJSON,
+			loan.UpdatedAt,
+			loan.ID,
+			loan.Version,
+		).Scan(&version)
 
-	if err != nil {
+		if err == sql.ErrNoRows {
+			var exists bool
+			if err := tx.QueryRowContext(ctx,
+				`SELECT EXISTS (SELECT 1 FROM loans WHERE id = $1)`, loan.ID,
+			).Scan(&exists); err != nil {
+				return err
+			}
+			if !exists {
+				return domain.Errorf(domain.ErrNotFound, "loan %s not found", loan.ID)
+			}
+			return domain.ErrConflict
+		}
 		return err
+	})
+	if err != nil {
+		return dbError(err)
 	}
 
-	rows, err := result.RowsAffected()
+	loan.Version = version
+	return nil
+}
+
+// AddInvestment locks the loan row for the duration of the transaction,
+// re-checks the investment against the current state and remaining principal,
+// inserts it and, if it completes the principal, moves the loan to INVESTED.
+// Concurrent investors are serialised on the row lock so the loan can never be
+// over-funded. The returned loan reflects the committed state.
+func (r *loanRepository) AddInvestment(ctx context.Context, investment *domain.Investment) (*domain.Loan, error) {
+	var loan *domain.Loan
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		var err error
+		loan, err = getLoan(ctx, tx, investment.LoanID, true)
+		if err != nil {
+			return err
+		}
+
+		if err := loan.AddInvestment(investment, investment.CreatedAt); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO investments (
+				id, loan_id, investor_id, amount, currency, created_at
+			) VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id`
+
+		err = tx.QueryRowContext(ctx, query,
+			investment.ID, investment.LoanID, investment.InvestorID,
+			investment.Amount.String(), investment.Amount.Currency, investment.CreatedAt,
+		).Scan(&investment.ID)
+
+		if err != nil {
+			return err
+		}
+
+		// Every investment changes the loan's representation, so it bumps the
+		// version even when the state stays APPROVED.
+		return tx.QueryRowContext(ctx,
+			`UPDATE loans SET state = $1, updated_at = $2, version = version + 1
+			WHERE id = $3
+			RETURNING version`,
+			loan.State, loan.UpdatedAt, loan.ID,
+		).Scan(&loan.Version)
+	})
 	if err != nil {
-		return err
+		return nil, dbError(err)
 	}
 
-	if rows == 0 {
-		return errors.New("loan not found")
+	return loan, nil
+}
+
+func (r *loanRepository) VoidInvestments(ctx context.Context, loanID uuid.UUID, at time.Time) ([]domain.Investment, error) {
+	query := `
+		UPDATE investments SET voided_at = $2
+		WHERE loan_id = $1 AND voided_at IS NULL
+		RETURNING ` + investmentColumns
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, loanID, at)
+	if err != nil {
+		return nil, dbError(err)
 	}
+	defer rows.Close()
 
-	return tx.Commit()
+	var voided []domain.Investment
+	for rows.Next() {
+		inv, err := scanInvestment(rows)
+		if err != nil {
+			return nil, err
+		}
+		voided = append(voided, *inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING has no order; report them in the order they were made.
+	slices.SortFunc(voided, compareInvestments)
+	return voided, nil
 }
 
-func (r *loanRepository) AddInvestment(ctx context.Context, investment *domain.Investment) error {
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
+func (r *loanRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Loan, error) {
+	return getLoan(ctx, conn(ctx, r.db), id, false)
+}
+
+// List returns one page of loans matching filter. Rows are ordered by the
+// sort column with the loan id as tie-breaker, and pages are walked with a
+// keyset cursor so they stay stable while loans are being created.
+func (r *loanRepository) List(ctx context.Context, filter domain.LoanFilter) (*domain.LoanPage, error) {
+	sort := filter.Sort
+	if sort == "" {
+		sort = domain.LoanSortCreatedAtDesc
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = domain.DefaultLoanPageSize
+	}
+
+	var (
+		where []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(filter.States) > 0 {
+		states := make([]string, len(filter.States))
+		for i, state := range filter.States {
+			states[i] = string(state)
+		}
+		where = append(where, "l.state = ANY("+arg(pq.Array(states))+")")
+	}
+	if filter.BorrowerIDNumber != "" {
+		where = append(where, "l.borrower_id_number = "+arg(filter.BorrowerIDNumber))
+	}
+	if filter.MinPrincipal != nil {
+		where = append(where, "l.principal_amount >= "+arg(filter.MinPrincipal.String()),
+			"l.currency = "+arg(filter.MinPrincipal.Currency))
+	}
+	if filter.MaxPrincipal != nil {
+		where = append(where, "l.principal_amount <= "+arg(filter.MaxPrincipal.String()),
+			"l.currency = "+arg(filter.MaxPrincipal.Currency))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		where = append(where, "l.created_at >= "+arg(filter.CreatedFrom))
+	}
+	if !filter.CreatedTo.IsZero() {
+		where = append(where, "l.created_at < "+arg(filter.CreatedTo))
+	}
+	if filter.InvestorID != uuid.Nil {
+		where = append(where, `EXISTS (
+			SELECT 1 FROM investments i
+			WHERE i.loan_id = l.id AND i.investor_id = `+arg(filter.InvestorID)+`)`)
+	}
+
+	column, desc := sortColumn(sort)
+	direction, comparison := "ASC", ">"
+	if desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeLoanCursor(filter.Cursor, sort)
+		if err != nil {
+			return nil, err
+		}
+		cast := "timestamptz"
+		if column == "l.principal_amount" {
+			cast = "numeric"
+		}
+		where = append(where, fmt.Sprintf("(%s, l.id) %s (%s::%s, %s::uuid)",
+			column, comparison, arg(cursor.Key), cast, arg(cursor.ID)))
 	}
-	defer tx.Rollback()
 
 	query := `
-		INSERT INTO investments (
-			id, loan_id, investor_id, amount, created_at
-		) VALUES ($1, $2, $3, $4, $5)
-		RETURNING id`
+		SELECT ` + loanColumns + `
+		FROM loans l`
+	if len(where) > 0 {
+		query += `
+		WHERE ` + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(`
+		ORDER BY %s %s, l.id %s
+		LIMIT %s`, column, direction, direction, arg(limit+1))
+
+	q := conn(ctx, r.db)
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &domain.LoanPage{Loans: []*domain.Loan{}}
+	for rows.Next() {
+		loan, err := scanLoan(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Loans = append(page.Loans, loan)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The extra row only tells us there is another page.
+	if len(page.Loans) > limit {
+		page.Loans = page.Loans[:limit]
+		page.NextCursor = encodeLoanCursor(page.Loans[limit-1], sort)
+	}
 
-	err = tx.QueryRowContext(ctx, query,
-		investment.ID, investment.LoanID, investment.InvestorID,
-		investment.Amount, investment.CreatedAt,
-	).Scan(&investment.ID)
+	ids := make([]uuid.UUID, len(page.Loans))
+	for i, loan := range page.Loans {
+		ids[i] = loan.ID
+	}
+	investments, err := loadInvestments(ctx, q, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, loan := range page.Loans {
+		loan.Investments = investments[loan.ID]
+	}
 
+	return page, nil
+}
+
+// loanCursor is the position of the last loan on a page. It is serialised
+// as base64 JSON so clients treat it as opaque.
+type loanCursor struct {
+	Sort domain.LoanSort `json:"s"`
+	Key  string          `json:"k"`
+	ID   uuid.UUID       `json:"id"`
+}
+
+func sortColumn(sort domain.LoanSort) (column string, desc bool) {
+	switch sort {
+	case domain.LoanSortCreatedAtAsc:
+		return "l.created_at", false
+	case domain.LoanSortPrincipalDesc:
+		return "l.principal_amount", true
+	case domain.LoanSortPrincipalAsc:
+		return "l.principal_amount", false
+	}
+	return "l.created_at", true
+}
+
+func encodeLoanCursor(loan *domain.Loan, sort domain.LoanSort) string {
+	cursor := loanCursor{Sort: sort, ID: loan.ID}
+	if column, _ := sortColumn(sort); column == "l.principal_amount" {
+		cursor.Key = loan.PrincipalAmount.String()
+	} else {
+		cursor.Key = loan.CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeLoanCursor(s string, sort domain.LoanSort) (*loanCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
 	if err != nil {
-		return err
+		return nil, domain.ErrInvalidCursor
+	}
+
+	var cursor loanCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	// Validate the key here so a tampered cursor is a client error rather
+	// than a database one.
+	if column, _ := sortColumn(sort); column == "l.principal_amount" {
+		_, err = domain.ParseMoney(cursor.Key, "")
+	} else {
+		_, err = time.Parse(time.RFC3339Nano, cursor.Key)
+	}
+	if err != nil {
+		return nil, domain.ErrInvalidCursor
 	}
 
-	return tx.Commit()
+	return &cursor, nil
 }
 
-func (r *loanRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Loan, error) {
-	loan := &domain.Loan{}
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
 
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+const loanColumns = `
+			l.id, l.borrower_id_number, l.principal_amount, l.currency, l.rate, 
+			l.roi, l.tenor_months, l.repayment_type, l.state, l.version, l.created_at, l.updated_at,
+			l.approval_details, l.disbursement_details, l.agreement_letter_url,
+			l.closure_details`
+
+// getLoan loads a loan with its investments. With forUpdate set the loan row is
+// locked until the surrounding transaction ends.
+func getLoan(ctx context.Context, q queryer, id uuid.UUID, forUpdate bool) (*domain.Loan, error) {
 	query := `
-		SELECT 
-			l.id, l.borrower_id_number, l.principal_amount, l.rate, 
-			l.roi, l.state, l.created_at, l.updated_at,
-			l.approval_details, l.disbursement_details, l.agreement_letter_url
+		SELECT ` + loanColumns + `
 		FROM loans l
 		WHERE l.id = $1`
+	if forUpdate {
+		query += `
+		FOR UPDATE`
+	}
 
-	var approvalJSON, disbursementJSON sql.NullString
-
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&loan.ID, &loan.BorrowerIDNumber, &loan.PrincipalAmount,
-		&loan.Rate, &loan.ROI, &loan.State, &loan.CreatedAt, &loan.UpdatedAt,
-		&approvalJSON, &disbursementJSON, &loan.AgreementLetterURL,
-	)
-
+	loan, err := scanLoan(q.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("loan not found")
+			return nil, domain.Errorf(domain.ErrNotFound, "loan %s not found", id)
 		}
 		return nil, err
 	}
 
+	investments, err := loadInvestments(ctx, q, []uuid.UUID{id})
+	if err != nil {
+		return nil, err
+	}
+	loan.Investments = investments[id]
+
+	return loan, nil
+}
+
+// nullJSON marshals v for a JSONB column, storing a nil pointer as SQL NULL
+// rather than the JSON literal null so scanLoan reads it back as nil.
+func nullJSON[T any](v *T) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// repaymentTermColumns stores a loan without repayment terms as NULLs.
+func repaymentTermColumns(t domain.RepaymentTerms) (sql.NullInt64, sql.NullString) {
+	if t.IsZero() {
+		return sql.NullInt64{}, sql.NullString{}
+	}
+	return sql.NullInt64{Int64: int64(t.TenorMonths), Valid: true},
+		sql.NullString{String: string(t.RepaymentType), Valid: true}
+}
+
+// scanLoan reads one row selected with loanColumns.
+func scanLoan(row rowScanner) (*domain.Loan, error) {
+	loan := &domain.Loan{}
+
+	var approvalJSON, disbursementJSON, closureJSON, agreementURL sql.NullString
+	var principal, currency string
+	var tenor sql.NullInt64
+	var repaymentType sql.NullString
+
+	err := row.Scan(
+		&loan.ID, &loan.BorrowerIDNumber, &principal, &currency,
+		&loan.Rate, &loan.ROI, &tenor, &repaymentType,
+		&loan.State, &loan.Version, &loan.CreatedAt, &loan.UpdatedAt,
+		&approvalJSON, &disbursementJSON, &agreementURL, &closureJSON,
+	)
+	if err != nil {
+		return nil, err
+	}
+	loan.AgreementLetterURL = agreementURL.String
+	loan.TenorMonths = int(tenor.Int64)
+	loan.RepaymentType = domain.RepaymentType(repaymentType.String)
+
+	if loan.PrincipalAmount, err = domain.ParseMoney(principal, currency); err != nil {
+		return nil, err
+	}
+
 	// Unmarshal approval details if present
 	if approvalJSON.Valid {
 		var approval domain.ApprovalDetails
@@ -170,25 +490,77 @@ func (r *loanRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Loa
 		loan.DisbursementDetails = &disbursement
 	}
 
-	// Get investments
-	investmentsQuery := `
-		SELECT id, loan_id, investor_id, amount, created_at
+	if closureJSON.Valid {
+		var closure domain.ClosureDetails
+		if err := json.Unmarshal([]byte(closureJSON.String), &closure); err != nil {
+			return nil, err
+		}
+		loan.ClosureDetails = &closure
+	}
+
+	return loan, nil
+}
+
+// loadInvestments returns the investments of the given loans keyed by loan id.
+func loadInvestments(ctx context.Context, q queryer, loanIDs []uuid.UUID) (map[uuid.UUID][]domain.Investment, error) {
+	ids := make([]string, len(loanIDs))
+	for i, id := range loanIDs {
+		ids[i] = id.String()
+	}
+
+	query := `
+		SELECT ` + investmentColumns + `
 		FROM investments
-		WHERE loan_id = $1`
+		WHERE loan_id = ANY($1::uuid[])
+		ORDER BY created_at, id`
 
-	rows, err := r.db.QueryContext(ctx, investmentsQuery, id)
+	rows, err := q.QueryContext(ctx, query, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	investments := make(map[uuid.UUID][]domain.Investment, len(loanIDs))
 	for rows.Next() {
-		var inv domain.Investment
-		if err := rows.Scan(&inv.ID, &inv.LoanID, &inv.InvestorID, &inv.Amount, &inv.CreatedAt); err != nil {
+		inv, err := scanInvestment(rows)
+		if err != nil {
 			return nil, err
 		}
-		loan.Investments = append(loan.Investments, inv)
+		investments[inv.LoanID] = append(investments[inv.LoanID], *inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return loan, nil
+	return investments, nil
+}
+
+const investmentColumns = `id, loan_id, investor_id, amount, currency, created_at, voided_at`
+
+// scanInvestment reads one row selected with investmentColumns.
+func scanInvestment(row rowScanner) (*domain.Investment, error) {
+	var (
+		inv              domain.Investment
+		amount, currency string
+		voidedAt         sql.NullTime
+	)
+	if err := row.Scan(&inv.ID, &inv.LoanID, &inv.InvestorID, &amount, &currency, &inv.CreatedAt, &voidedAt); err != nil {
+		return nil, err
+	}
+	var err error
+	if inv.Amount, err = domain.ParseMoney(amount, currency); err != nil {
+		return nil, err
+	}
+	if voidedAt.Valid {
+		inv.VoidedAt = &voidedAt.Time
+	}
+	return &inv, nil
+}
+
+// compareInvestments orders investments as loadInvestments does.
+func compareInvestments(a, b domain.Investment) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID.String(), b.ID.String())
 }
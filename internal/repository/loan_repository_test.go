@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/testdb"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestDB returns a freshly migrated schema of its own on the database
+// named by TEST_DATABASE_URL.
+func openTestDB(t *testing.T) *sql.DB {
+	return testdb.Open(t)
+}
+
+func TestAddInvestmentConcurrentInvestorsCannotOverFund(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewLoanRepository(db)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	loan := &domain.Loan{
+		ID:               uuid.New(),
+		BorrowerIDNumber: "race-" + uuid.NewString(),
+		PrincipalAmount:  domain.NewMoney(100000, "IDR"),
+		Rate:             domain.Percent(500),
+		ROI:              domain.Percent(400),
+		State:            domain.LoanStateApproved,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	require.NoError(t, repo.Create(ctx, loan))
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM investments WHERE loan_id = $1`, loan.ID)
+		db.Exec(`DELETE FROM loans WHERE id = $1`, loan.ID)
+	})
+
+	// 50 investors race for a principal that only fits 10 of them.
+	const investors = 50
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+		flipped   int
+	)
+	start := make(chan struct{})
+	for i := 0; i < investors; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			updated, err := repo.AddInvestment(ctx, &domain.Investment{
+				ID:         uuid.New(),
+				LoanID:     loan.ID,
+				InvestorID: uuid.New(),
+				Amount:     domain.NewMoney(10000, "IDR"),
+				CreatedAt:  time.Now(),
+			})
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			succeeded++
+			if updated.State == domain.LoanStateInvested {
+				flipped++
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	assert.Equal(t, 10, succeeded)
+	assert.Equal(t, 1, flipped, "exactly one investor should complete the loan")
+
+	got, err := repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateInvested, got.State)
+	assert.Len(t, got.Investments, 10)
+	assert.Equal(t, loan.PrincipalAmount, got.TotalInvestedAmount())
+}
+
+func TestUpdateRejectsStaleVersion(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewLoanRepository(db)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	loan := &domain.Loan{
+		ID:               uuid.New(),
+		BorrowerIDNumber: "version-" + uuid.NewString(),
+		PrincipalAmount:  domain.NewMoney(100000, "IDR"),
+		Rate:             domain.Percent(500),
+		ROI:              domain.Percent(400),
+		State:            domain.LoanStateProposed,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	require.NoError(t, repo.Create(ctx, loan))
+	t.Cleanup(func() { db.Exec(`DELETE FROM loans WHERE id = $1`, loan.ID) })
+
+	first, err := repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	second, err := repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+
+	first.State = domain.LoanStateApproved
+	require.NoError(t, repo.Update(ctx, first))
+	assert.Equal(t, int64(2), first.Version)
+
+	second.State = domain.LoanStateApproved
+	assert.ErrorIs(t, repo.Update(ctx, second), domain.ErrConflict)
+}
+
+func TestListPaginatesWithFilters(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewLoanRepository(db)
+	ctx := context.Background()
+
+	borrower := "list-" + uuid.NewString()
+	investor := uuid.New()
+	base := time.Now().UTC().Truncate(time.Microsecond)
+
+	var ids []uuid.UUID
+	for i := 0; i < 5; i++ {
+		loan := &domain.Loan{
+			ID:               uuid.New(),
+			BorrowerIDNumber: borrower,
+			PrincipalAmount:  domain.NewMoney(int64(i+1)*10000, "IDR"),
+			Rate:             domain.Percent(500),
+			ROI:              domain.Percent(400),
+			State:            domain.LoanStateApproved,
+			CreatedAt:        base.Add(time.Duration(i) * time.Second),
+			UpdatedAt:        base,
+		}
+		require.NoError(t, repo.Create(ctx, loan))
+		ids = append(ids, loan.ID)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM investments WHERE investor_id = $1`, investor)
+		db.Exec(`DELETE FROM loans WHERE borrower_id_number = $1`, borrower)
+	})
+
+	_, err := repo.AddInvestment(ctx, &domain.Investment{
+		ID: uuid.New(), LoanID: ids[1], InvestorID: investor,
+		Amount: domain.NewMoney(100, "IDR"), CreatedAt: base,
+	})
+	require.NoError(t, err)
+
+	filter := domain.LoanFilter{BorrowerIDNumber: borrower, Limit: 2}
+	var seen []uuid.UUID
+	for {
+		page, err := repo.List(ctx, filter)
+		require.NoError(t, err)
+		for _, loan := range page.Loans {
+			seen = append(seen, loan.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []uuid.UUID{ids[4], ids[3], ids[2], ids[1], ids[0]}, seen)
+
+	min := domain.NewMoney(20000, "IDR")
+	page, err := repo.List(ctx, domain.LoanFilter{
+		BorrowerIDNumber: borrower,
+		MinPrincipal:     &min,
+		Sort:             domain.LoanSortPrincipalAsc,
+	})
+	require.NoError(t, err)
+	require.Len(t, page.Loans, 4)
+	assert.Equal(t, ids[1], page.Loans[0].ID)
+
+	page, err = repo.List(ctx, domain.LoanFilter{InvestorID: investor})
+	require.NoError(t, err)
+	require.Len(t, page.Loans, 1)
+	assert.Len(t, page.Loans[0].Investments, 1)
+
+	_, err = repo.List(ctx, domain.LoanFilter{Cursor: "not-a-cursor"})
+	assert.ErrorIs(t, err, domain.ErrInvalidCursor)
+}
@@ -0,0 +1,1240 @@
+package repository
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps loans, their history, event streams, repayment
+// schedules and repayments, the ledger, borrowers, investors, their wallets
+// and outbox messages in process memory. It backs the same repository interfaces as
+// Postgres, with the same errors, so the API can run without a database and
+// tests can exercise real behaviour.
+// Values are copied on the way in and out, as they would be by a database.
+type MemoryStore struct {
+	mu        sync.Mutex
+	loans     map[uuid.UUID]*domain.Loan
+	history   map[uuid.UUID][]*domain.LoanHistoryEntry
+	changes   map[uuid.UUID][]*domain.LoanChangeRecord
+	schedules map[uuid.UUID][]domain.Installment
+	// Stored repayments, like history entries, are only appended to.
+	repayments map[uuid.UUID][]*domain.Repayment
+	// ledger holds journal entries in the order they were posted. They too
+	// are only appended to.
+	ledger []*domain.JournalEntry
+	// Borrowers hold no pointers, so copying one copies it entirely.
+	borrowers map[uuid.UUID]*domain.Borrower
+	investors map[uuid.UUID]*domain.Investor
+	// Wallets, like borrowers, hold no pointers.
+	wallets map[walletKey]*domain.Wallet
+	outbox  map[uuid.UUID]*domain.OutboxMessage
+}
+
+type walletKey struct {
+	investorID uuid.UUID
+	currency   string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		loans:      make(map[uuid.UUID]*domain.Loan),
+		history:    make(map[uuid.UUID][]*domain.LoanHistoryEntry),
+		changes:    make(map[uuid.UUID][]*domain.LoanChangeRecord),
+		schedules:  make(map[uuid.UUID][]domain.Installment),
+		repayments: make(map[uuid.UUID][]*domain.Repayment),
+		borrowers:  make(map[uuid.UUID]*domain.Borrower),
+		investors:  make(map[uuid.UUID]*domain.Investor),
+		wallets:    make(map[walletKey]*domain.Wallet),
+		outbox:     make(map[uuid.UUID]*domain.OutboxMessage),
+	}
+}
+
+func (s *MemoryStore) Loans() LoanRepository {
+	return &memoryLoanRepository{store: s}
+}
+
+func (s *MemoryStore) History() LoanHistoryRepository {
+	return &memoryLoanHistoryRepository{store: s}
+}
+
+func (s *MemoryStore) Events() LoanEventStore {
+	return &memoryLoanEventStore{store: s}
+}
+
+func (s *MemoryStore) Schedules() RepaymentScheduleRepository {
+	return &memoryRepaymentScheduleRepository{store: s}
+}
+
+func (s *MemoryStore) Repayments() RepaymentRepository {
+	return &memoryRepaymentRepository{store: s}
+}
+
+func (s *MemoryStore) Ledger() LedgerRepository {
+	return &memoryLedgerRepository{store: s}
+}
+
+func (s *MemoryStore) Borrowers() BorrowerRepository {
+	return &memoryBorrowerRepository{store: s}
+}
+
+func (s *MemoryStore) Investors() InvestorRepository {
+	return &memoryInvestorRepository{store: s}
+}
+
+func (s *MemoryStore) Wallets() WalletRepository {
+	return &memoryWalletRepository{store: s}
+}
+
+func (s *MemoryStore) Outbox() OutboxRepository {
+	return &memoryOutboxRepository{store: s}
+}
+
+// Transactor serialises transactions on the store and restores a snapshot
+// when fn fails.
+func (s *MemoryStore) Transactor() Transactor {
+	return &memoryTransactor{store: s}
+}
+
+type memoryTxKey struct{}
+
+// lock takes the store lock unless ctx is inside one of its transactions,
+// which already hold it.
+func (s *MemoryStore) lock(ctx context.Context) func() {
+	if ctx.Value(memoryTxKey{}) == s {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+type memoryTransactor struct {
+	store *MemoryStore
+}
+
+func (t *memoryTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	s := t.store
+	if ctx.Value(memoryTxKey{}) == s {
+		return fn(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loans := make(map[uuid.UUID]*domain.Loan, len(s.loans))
+	for id, loan := range s.loans {
+		loans[id] = copyLoan(loan)
+	}
+	// Stored history entries and changes are never modified, only appended
+	// to.
+	history := make(map[uuid.UUID][]*domain.LoanHistoryEntry, len(s.history))
+	for id, entries := range s.history {
+		history[id] = slices.Clone(entries)
+	}
+	changes := make(map[uuid.UUID][]*domain.LoanChangeRecord, len(s.changes))
+	for id, records := range s.changes {
+		changes[id] = slices.Clone(records)
+	}
+	schedules := make(map[uuid.UUID][]domain.Installment, len(s.schedules))
+	for id, installments := range s.schedules {
+		schedules[id] = copyInstallments(installments)
+	}
+	repayments := make(map[uuid.UUID][]*domain.Repayment, len(s.repayments))
+	for id, rs := range s.repayments {
+		repayments[id] = slices.Clone(rs)
+	}
+	ledger := slices.Clone(s.ledger)
+	borrowers := make(map[uuid.UUID]*domain.Borrower, len(s.borrowers))
+	for id, b := range s.borrowers {
+		c := *b
+		borrowers[id] = &c
+	}
+	investors := make(map[uuid.UUID]*domain.Investor, len(s.investors))
+	for id, i := range s.investors {
+		investors[id] = copyInvestor(i)
+	}
+	wallets := make(map[walletKey]*domain.Wallet, len(s.wallets))
+	for k, w := range s.wallets {
+		c := *w
+		wallets[k] = &c
+	}
+	outbox := make(map[uuid.UUID]*domain.OutboxMessage, len(s.outbox))
+	for id, m := range s.outbox {
+		outbox[id] = copyOutboxMessage(m)
+	}
+
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, s)); err != nil {
+		s.loans, s.history, s.changes, s.schedules, s.repayments = loans, history, changes, schedules, repayments
+		s.ledger, s.borrowers, s.investors, s.wallets, s.outbox = ledger, borrowers, investors, wallets, outbox
+		return err
+	}
+	return nil
+}
+
+type memoryLoanRepository struct {
+	store *MemoryStore
+}
+
+func (r *memoryLoanRepository) Create(ctx context.Context, loan *domain.Loan) error {
+	defer r.store.lock(ctx)()
+
+	if _, ok := r.store.loans[loan.ID]; ok {
+		return &domain.Error{Kind: domain.ErrConflict, Message: "resource already exists"}
+	}
+	if err := checkLoan(loan); err != nil {
+		return err
+	}
+
+	// Like the Postgres Create, only the loan's own terms are written.
+	loan.Version = 1
+	r.store.loans[loan.ID] = &domain.Loan{
+		ID:               loan.ID,
+		BorrowerIDNumber: loan.BorrowerIDNumber,
+		PrincipalAmount:  loan.PrincipalAmount,
+		Rate:             loan.Rate,
+		ROI:              loan.ROI,
+		RepaymentTerms:   loan.RepaymentTerms,
+		State:            loan.State,
+		Version:          1,
+		CreatedAt:        dbTime(loan.CreatedAt),
+		UpdatedAt:        dbTime(loan.UpdatedAt),
+	}
+	return nil
+}
+
+func (r *memoryLoanRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Loan, error) {
+	defer r.store.lock(ctx)()
+
+	loan, ok := r.store.loans[id]
+	if !ok {
+		return nil, domain.Errorf(domain.ErrNotFound, "loan %s not found", id)
+	}
+	return copyLoan(loan), nil
+}
+
+func (r *memoryLoanRepository) Update(ctx context.Context, loan *domain.Loan) error {
+	defer r.store.lock(ctx)()
+
+	stored, ok := r.store.loans[loan.ID]
+	if !ok {
+		return domain.Errorf(domain.ErrNotFound, "loan %s not found", loan.ID)
+	}
+	if stored.Version != loan.Version {
+		return domain.ErrConflict
+	}
+	if err := checkLoan(loan); err != nil {
+		return err
+	}
+
+	// Only the columns the Postgres Update writes are taken from loan.
+	written := copyLoan(loan)
+	updated := copyLoan(stored)
+	updated.State = written.State
+	updated.ApprovalDetails = written.ApprovalDetails
+	updated.DisbursementDetails = written.DisbursementDetails
+	updated.AgreementLetterURL = loan.AgreementLetterURL
+	updated.ClosureDetails = written.ClosureDetails
+	updated.UpdatedAt = dbTime(loan.UpdatedAt)
+	updated.Version++
+	r.store.loans[loan.ID] = updated
+
+	loan.Version = updated.Version
+	return nil
+}
+
+func (r *memoryLoanRepository) AddInvestment(ctx context.Context, investment *domain.Investment) (*domain.Loan, error) {
+	defer r.store.lock(ctx)()
+
+	stored, ok := r.store.loans[investment.LoanID]
+	if !ok {
+		return nil, domain.Errorf(domain.ErrNotFound, "loan %s not found", investment.LoanID)
+	}
+
+	loan := copyLoan(stored)
+	if err := loan.AddInvestment(investment, investment.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	last := &loan.Investments[len(loan.Investments)-1]
+	last.CreatedAt = dbTime(last.CreatedAt)
+	loan.UpdatedAt = dbTime(loan.UpdatedAt)
+	loan.Version++
+	// Stored investments are kept in the order Postgres reads them back.
+	stored = copyLoan(loan)
+	slices.SortFunc(stored.Investments, compareInvestments)
+	r.store.loans[loan.ID] = stored
+
+	return loan, nil
+}
+
+func (r *memoryLoanRepository) VoidInvestments(ctx context.Context, loanID uuid.UUID, at time.Time) ([]domain.Investment, error) {
+	defer r.store.lock(ctx)()
+
+	loan, ok := r.store.loans[loanID]
+	if !ok {
+		return nil, nil
+	}
+
+	var voided []domain.Investment
+	for i := range loan.Investments {
+		inv := &loan.Investments[i]
+		if inv.VoidedAt != nil {
+			continue
+		}
+		voidedAt := dbTime(at)
+		inv.VoidedAt = &voidedAt
+		voided = append(voided, copyInvestment(*inv))
+	}
+	return voided, nil
+}
+
+func (r *memoryLoanRepository) List(ctx context.Context, filter domain.LoanFilter) (*domain.LoanPage, error) {
+	sort := filter.Sort
+	if sort == "" {
+		sort = domain.LoanSortCreatedAtDesc
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = domain.DefaultLoanPageSize
+	}
+
+	var cursor *loanCursor
+	if filter.Cursor != "" {
+		var err error
+		if cursor, err = decodeLoanCursor(filter.Cursor, sort); err != nil {
+			return nil, err
+		}
+	}
+
+	column, desc := sortColumn(sort)
+	// compare orders loans by the sort column, then id, in page order.
+	compare := func(a, b *domain.Loan) int {
+		var c int
+		if column == "l.principal_amount" {
+			c = cmp.Compare(a.PrincipalAmount.MinorUnits, b.PrincipalAmount.MinorUnits)
+		} else {
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		if c == 0 {
+			c = strings.Compare(a.ID.String(), b.ID.String())
+		}
+		if desc {
+			return -c
+		}
+		return c
+	}
+
+	var after *domain.Loan
+	if cursor != nil {
+		after = &domain.Loan{ID: cursor.ID}
+		if column == "l.principal_amount" {
+			after.PrincipalAmount, _ = domain.ParseMoney(cursor.Key, "")
+		} else {
+			after.CreatedAt, _ = time.Parse(time.RFC3339Nano, cursor.Key)
+		}
+	}
+
+	unlock := r.store.lock(ctx)
+	var matches []*domain.Loan
+	for _, loan := range r.store.loans {
+		if matchesFilter(loan, filter) && (after == nil || compare(loan, after) > 0) {
+			matches = append(matches, copyLoan(loan))
+		}
+	}
+	unlock()
+
+	slices.SortFunc(matches, compare)
+
+	page := &domain.LoanPage{Loans: []*domain.Loan{}}
+	if len(matches) > limit {
+		page.Loans = matches[:limit]
+		page.NextCursor = encodeLoanCursor(page.Loans[limit-1], sort)
+	} else {
+		page.Loans = append(page.Loans, matches...)
+	}
+	return page, nil
+}
+
+func matchesFilter(loan *domain.Loan, filter domain.LoanFilter) bool {
+	if len(filter.States) > 0 && !slices.Contains(filter.States, loan.State) {
+		return false
+	}
+	if filter.BorrowerIDNumber != "" && loan.BorrowerIDNumber != filter.BorrowerIDNumber {
+		return false
+	}
+	if m := filter.MinPrincipal; m != nil &&
+		(loan.PrincipalAmount.Currency != m.Currency || loan.PrincipalAmount.MinorUnits < m.MinorUnits) {
+		return false
+	}
+	if m := filter.MaxPrincipal; m != nil &&
+		(loan.PrincipalAmount.Currency != m.Currency || loan.PrincipalAmount.MinorUnits > m.MinorUnits) {
+		return false
+	}
+	if !filter.CreatedFrom.IsZero() && loan.CreatedAt.Before(filter.CreatedFrom) {
+		return false
+	}
+	if !filter.CreatedTo.IsZero() && !loan.CreatedAt.Before(filter.CreatedTo) {
+		return false
+	}
+	if filter.InvestorID != uuid.Nil && !slices.ContainsFunc(loan.Investments, func(inv domain.Investment) bool {
+		return inv.InvestorID == filter.InvestorID
+	}) {
+		return false
+	}
+	return true
+}
+
+// checkLoan applies the schema's CHECK constraints on loans.
+func checkLoan(loan *domain.Loan) error {
+	switch {
+	case !loan.State.IsValid():
+		return constraintErrors["loans_state_check"]
+	case !loan.PrincipalAmount.IsPositive():
+		return constraintErrors["loans_principal_amount_check"]
+	case loan.Rate <= 0:
+		return constraintErrors["loans_rate_check"]
+	case loan.ROI <= 0:
+		return constraintErrors["loans_roi_check"]
+	case !loan.RepaymentTerms.IsZero() && loan.RepaymentTerms.Validate() != nil:
+		return constraintErrors["loans_repayment_terms_check"]
+	}
+	return nil
+}
+
+type memoryLoanHistoryRepository struct {
+	store *MemoryStore
+}
+
+func (r *memoryLoanHistoryRepository) Append(ctx context.Context, entries ...*domain.LoanHistoryEntry) error {
+	defer r.store.lock(ctx)()
+
+	appended := make(map[uuid.UUID][]*domain.LoanHistoryEntry)
+	for _, e := range entries {
+		if _, ok := r.store.loans[e.LoanID]; !ok {
+			return constraintErrors["loan_events_loan_id_fkey"]
+		}
+		existing := slices.Concat(r.store.history[e.LoanID], appended[e.LoanID])
+		if slices.ContainsFunc(existing, func(x *domain.LoanHistoryEntry) bool { return x.Version == e.Version }) {
+			return constraintErrors["loan_events_pkey"]
+		}
+		c := *e
+		c.Payload = slices.Clone(e.Payload)
+		c.OccurredAt = dbTime(e.OccurredAt)
+		appended[e.LoanID] = append(appended[e.LoanID], &c)
+	}
+
+	for id, added := range appended {
+		history := append(slices.Clone(r.store.history[id]), added...)
+		slices.SortFunc(history, func(a, b *domain.LoanHistoryEntry) int {
+			return cmp.Compare(a.Version, b.Version)
+		})
+		r.store.history[id] = history
+	}
+	return nil
+}
+
+func (r *memoryLoanHistoryRepository) List(ctx context.Context, loanID uuid.UUID) ([]*domain.LoanHistoryEntry, error) {
+	defer r.store.lock(ctx)()
+
+	var entries []*domain.LoanHistoryEntry
+	for _, e := range r.store.history[loanID] {
+		c := *e
+		c.Payload = slices.Clone(e.Payload)
+		entries = append(entries, &c)
+	}
+	return entries, nil
+}
+
+type memoryLoanEventStore struct {
+	store *MemoryStore
+}
+
+func (s *memoryLoanEventStore) Append(ctx context.Context, records ...*domain.LoanChangeRecord) error {
+	defer s.store.lock(ctx)()
+
+	for _, r := range records {
+		if _, ok := s.store.loans[r.LoanID]; !ok {
+			return constraintErrors["loan_changes_loan_id_fkey"]
+		}
+	}
+	for _, r := range records {
+		stream := s.store.changes[r.LoanID]
+		r.Sequence = int64(len(stream)) + 1
+		c := *r
+		c.Data = slices.Clone(r.Data)
+		c.OccurredAt = dbTime(r.OccurredAt)
+		// Clipped so append copies: a transaction snapshot may share the
+		// backing array.
+		s.store.changes[r.LoanID] = append(slices.Clip(stream), &c)
+	}
+	return nil
+}
+
+func (s *memoryLoanEventStore) Load(ctx context.Context, loanID uuid.UUID, asOf time.Time) ([]*domain.LoanChangeRecord, error) {
+	defer s.store.lock(ctx)()
+
+	var records []*domain.LoanChangeRecord
+	for _, r := range s.store.changes[loanID] {
+		if !asOf.IsZero() && r.OccurredAt.After(asOf) {
+			continue
+		}
+		c := *r
+		c.Data = slices.Clone(r.Data)
+		records = append(records, &c)
+	}
+	return records, nil
+}
+
+type memoryRepaymentScheduleRepository struct {
+	store *MemoryStore
+}
+
+func (r *memoryRepaymentScheduleRepository) Save(ctx context.Context, loanID uuid.UUID, installments []domain.Installment) error {
+	defer r.store.lock(ctx)()
+
+	if _, ok := r.store.loans[loanID]; !ok {
+		return constraintErrors["repayment_installments_loan_id_fkey"]
+	}
+	saved := slices.Clone(r.store.schedules[loanID])
+	for _, inst := range installments {
+		if slices.ContainsFunc(saved, func(x domain.Installment) bool { return x.Number == inst.Number }) {
+			return constraintErrors["repayment_installments_pkey"]
+		}
+		if inst.Principal.MinorUnits < 0 || inst.Interest.MinorUnits < 0 {
+			return constraintErrors["repayment_installments_amounts_check"]
+		}
+		if err := checkInstallmentPaid(inst); err != nil {
+			return err
+		}
+		y, m, d := inst.DueDate.Date()
+		stored := domain.NewInstallment(inst.Number, time.Date(y, m, d, 0, 0, 0, 0, time.UTC), inst.Principal, inst.Interest)
+		setInstallmentPaid(&stored, inst)
+		saved = append(saved, stored)
+	}
+	slices.SortFunc(saved, func(a, b domain.Installment) int {
+		return cmp.Compare(a.Number, b.Number)
+	})
+	r.store.schedules[loanID] = saved
+	return nil
+}
+
+func (r *memoryRepaymentScheduleRepository) Update(ctx context.Context, loanID uuid.UUID, installments []domain.Installment) error {
+	defer r.store.lock(ctx)()
+
+	saved := copyInstallments(r.store.schedules[loanID])
+	for _, inst := range installments {
+		i := slices.IndexFunc(saved, func(x domain.Installment) bool { return x.Number == inst.Number })
+		if i < 0 {
+			return domain.Errorf(domain.ErrNotFound, "installment %d of loan %s not found", inst.Number, loanID)
+		}
+		updated := saved[i]
+		setInstallmentPaid(&updated, inst)
+		if err := checkInstallmentPaid(updated); err != nil {
+			return err
+		}
+		saved[i] = updated
+	}
+	r.store.schedules[loanID] = saved
+	return nil
+}
+
+func (r *memoryRepaymentScheduleRepository) List(ctx context.Context, loanID uuid.UUID) ([]domain.Installment, error) {
+	defer r.store.lock(ctx)()
+
+	return copyInstallments(r.store.schedules[loanID]), nil
+}
+
+// setInstallmentPaid copies what has been paid on from onto inst, as the
+// Postgres Update does.
+func setInstallmentPaid(inst *domain.Installment, from domain.Installment) {
+	inst.LateFee = from.LateFee
+	inst.PaidInterest = from.PaidInterest
+	inst.PaidPrincipal = from.PaidPrincipal
+	inst.PaidLateFee = from.PaidLateFee
+	inst.SettledAt = nil
+	if from.SettledAt != nil {
+		settledAt := dbTime(*from.SettledAt)
+		inst.SettledAt = &settledAt
+	}
+}
+
+// checkInstallmentPaid mirrors repayment_installments_paid_check.
+func checkInstallmentPaid(inst domain.Installment) error {
+	within := func(paid, due domain.Money) bool {
+		return paid.MinorUnits >= 0 && paid.MinorUnits <= due.MinorUnits
+	}
+	if inst.LateFee.MinorUnits < 0 || !within(inst.PaidInterest, inst.Interest) ||
+		!within(inst.PaidPrincipal, inst.Principal) || !within(inst.PaidLateFee, inst.LateFee) {
+		return constraintErrors["repayment_installments_paid_check"]
+	}
+	return nil
+}
+
+func copyInstallments(installments []domain.Installment) []domain.Installment {
+	out := slices.Clone(installments)
+	for i := range out {
+		if out[i].SettledAt != nil {
+			settledAt := *out[i].SettledAt
+			out[i].SettledAt = &settledAt
+		}
+	}
+	return out
+}
+
+type memoryRepaymentRepository struct {
+	store *MemoryStore
+}
+
+func (r *memoryRepaymentRepository) Create(ctx context.Context, rp *domain.Repayment) error {
+	defer r.store.lock(ctx)()
+
+	loan, ok := r.store.loans[rp.LoanID]
+	if !ok {
+		return constraintErrors["repayments_loan_id_fkey"]
+	}
+	for _, rs := range r.store.repayments {
+		if slices.ContainsFunc(rs, func(x *domain.Repayment) bool { return x.ID == rp.ID }) {
+			return &domain.Error{Kind: domain.ErrConflict, Message: "resource already exists"}
+		}
+	}
+	if !rp.Amount.IsPositive() || rp.Interest.MinorUnits < 0 || rp.Principal.MinorUnits < 0 ||
+		rp.LateFee.MinorUnits < 0 || rp.PlatformFee.MinorUnits < 0 ||
+		rp.Amount.MinorUnits != rp.Interest.MinorUnits+rp.Principal.MinorUnits+rp.LateFee.MinorUnits {
+		return constraintErrors["repayments_amount_check"]
+	}
+
+	stored := *rp
+	stored.PaidAt = dbTime(rp.PaidAt)
+	stored.Payouts = make([]domain.Payout, 0, len(rp.Payouts))
+	for _, p := range rp.Payouts {
+		if !slices.ContainsFunc(loan.Investments, func(inv domain.Investment) bool { return inv.ID == p.InvestmentID }) {
+			return constraintErrors["repayment_payouts_investment_id_fkey"]
+		}
+		if slices.ContainsFunc(stored.Payouts, func(x domain.Payout) bool { return x.InvestmentID == p.InvestmentID }) {
+			return constraintErrors["repayment_payouts_pkey"]
+		}
+		if p.Principal.MinorUnits < 0 || p.Interest.MinorUnits < 0 {
+			return constraintErrors["repayment_payouts_amounts_check"]
+		}
+		stored.Payouts = append(stored.Payouts, p)
+	}
+	r.store.repayments[rp.LoanID] = append(r.store.repayments[rp.LoanID], &stored)
+	return nil
+}
+
+func (r *memoryRepaymentRepository) List(ctx context.Context, loanID uuid.UUID) ([]*domain.Repayment, error) {
+	defer r.store.lock(ctx)()
+
+	var repayments []*domain.Repayment
+	for _, rp := range r.store.repayments[loanID] {
+		c := *rp
+		c.Payouts = slices.Clone(rp.Payouts)
+		repayments = append(repayments, &c)
+	}
+	slices.SortStableFunc(repayments, func(a, b *domain.Repayment) int {
+		return cmp.Or(a.PaidAt.Compare(b.PaidAt), strings.Compare(a.ID.String(), b.ID.String()))
+	})
+	return repayments, nil
+}
+
+type memoryLedgerRepository struct {
+	store *MemoryStore
+}
+
+func (r *memoryLedgerRepository) Post(ctx context.Context, entries ...*domain.JournalEntry) error {
+	defer r.store.lock(ctx)()
+
+	// Entries are checked before any is stored, so a failed post leaves the
+	// ledger as it was, as the rolled back Postgres transaction does.
+	posted := slices.Clone(r.store.ledger)
+	for _, e := range entries {
+		if !e.Kind.IsValid() {
+			return constraintErrors["ledger_entries_kind_check"]
+		}
+		if e.Kind.ForLoan() == (e.LoanID == uuid.Nil) {
+			return constraintErrors["ledger_entries_loan_id_check"]
+		}
+		if _, ok := r.store.loans[e.LoanID]; !ok && e.LoanID != uuid.Nil {
+			return constraintErrors["ledger_entries_loan_id_fkey"]
+		}
+		for _, x := range posted {
+			if x.ID == e.ID {
+				return &domain.Error{Kind: domain.ErrConflict, Message: "resource already exists"}
+			}
+			if x.Kind == e.Kind && x.ReferenceID == e.ReferenceID {
+				return constraintErrors["ledger_entries_kind_reference_id_key"]
+			}
+		}
+
+		sums := make(map[string]int64)
+		for _, line := range e.Lines {
+			if !line.Account.Type.IsValid() || !line.Amount.IsPositive() ||
+				(line.Side != domain.Debit && line.Side != domain.Credit) {
+				return constraintErrors["ledger_lines_check"]
+			}
+			// Lines are stored in the account's currency.
+			if line.Side == domain.Debit {
+				sums[line.Account.Currency] += line.Amount.MinorUnits
+			} else {
+				sums[line.Account.Currency] -= line.Amount.MinorUnits
+			}
+		}
+		for _, sum := range sums {
+			if sum != 0 {
+				return constraintErrors["ledger_entries_balanced"]
+			}
+		}
+
+		stored := *e
+		stored.PostedAt = dbTime(e.PostedAt)
+		stored.Lines = make([]domain.JournalLine, len(e.Lines))
+		for i, line := range e.Lines {
+			line.Amount.Currency = line.Account.Currency
+			stored.Lines[i] = line
+		}
+		posted = append(posted, &stored)
+	}
+	r.store.ledger = posted
+	return nil
+}
+
+func (r *memoryLedgerRepository) Entries(ctx context.Context, loanID uuid.UUID) ([]*domain.JournalEntry, error) {
+	defer r.store.lock(ctx)()
+
+	var entries []*domain.JournalEntry
+	for _, e := range r.store.ledger {
+		if e.LoanID == loanID {
+			c := *e
+			c.Lines = slices.Clone(e.Lines)
+			entries = append(entries, &c)
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b *domain.JournalEntry) int {
+		return a.PostedAt.Compare(b.PostedAt)
+	})
+	return entries, nil
+}
+
+func (r *memoryLedgerRepository) Balances(ctx context.Context) ([]domain.AccountBalance, error) {
+	defer r.store.lock(ctx)()
+
+	type totals struct{ debits, credits int64 }
+	byAccount := make(map[domain.Account]*totals)
+	for _, e := range r.store.ledger {
+		for _, line := range e.Lines {
+			t, ok := byAccount[line.Account]
+			if !ok {
+				t = &totals{}
+				byAccount[line.Account] = t
+			}
+			if line.Side == domain.Debit {
+				t.debits += line.Amount.MinorUnits
+			} else {
+				t.credits += line.Amount.MinorUnits
+			}
+		}
+	}
+
+	var balances []domain.AccountBalance
+	for a, t := range byAccount {
+		balances = append(balances, domain.NewAccountBalance(a,
+			domain.NewMoney(t.debits, a.Currency), domain.NewMoney(t.credits, a.Currency)))
+	}
+	slices.SortFunc(balances, func(a, b domain.AccountBalance) int {
+		return domain.CompareAccounts(a.Account, b.Account)
+	})
+	return balances, nil
+}
+
+// UnbalancedEntries finds nothing: Post rejects entries that do not
+// balance.
+func (r *memoryLedgerRepository) UnbalancedEntries(ctx context.Context) ([]uuid.UUID, error) {
+	return nil, nil
+}
+
+type memoryBorrowerRepository struct {
+	store *MemoryStore
+}
+
+func (r *memoryBorrowerRepository) Create(ctx context.Context, b *domain.Borrower) error {
+	defer r.store.lock(ctx)()
+
+	if _, ok := r.store.borrowers[b.ID]; ok {
+		return &domain.Error{Kind: domain.ErrConflict, Message: "resource already exists"}
+	}
+	if r.byNationalID(b.NationalIDNumber) != nil {
+		return constraintErrors["borrowers_national_id_number_key"]
+	}
+	if !b.KYCStatus.IsValid() {
+		return constraintErrors["borrowers_kyc_status_check"]
+	}
+
+	c := *b
+	c.CreatedAt = dbTime(b.CreatedAt)
+	c.UpdatedAt = dbTime(b.UpdatedAt)
+	r.store.borrowers[b.ID] = &c
+	return nil
+}
+
+func (r *memoryBorrowerRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Borrower, error) {
+	defer r.store.lock(ctx)()
+
+	b, ok := r.store.borrowers[id]
+	if !ok {
+		return nil, domain.Errorf(domain.ErrNotFound, "borrower %v not found", id)
+	}
+	c := *b
+	return &c, nil
+}
+
+func (r *memoryBorrowerRepository) GetByNationalID(ctx context.Context, nationalID string) (*domain.Borrower, error) {
+	defer r.store.lock(ctx)()
+
+	b := r.byNationalID(nationalID)
+	if b == nil {
+		return nil, domain.Errorf(domain.ErrNotFound, "borrower %v not found", nationalID)
+	}
+	c := *b
+	return &c, nil
+}
+
+func (r *memoryBorrowerRepository) byNationalID(nationalID string) *domain.Borrower {
+	for _, b := range r.store.borrowers {
+		if b.NationalIDNumber == nationalID {
+			return b
+		}
+	}
+	return nil
+}
+
+func (r *memoryBorrowerRepository) List(ctx context.Context, status domain.KYCStatus, limit int) ([]*domain.Borrower, error) {
+	defer r.store.lock(ctx)()
+
+	var borrowers []*domain.Borrower
+	for _, b := range r.store.borrowers {
+		if status == "" || b.KYCStatus == status {
+			c := *b
+			borrowers = append(borrowers, &c)
+		}
+	}
+	slices.SortFunc(borrowers, func(a, b *domain.Borrower) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID.String(), a.ID.String())
+	})
+	if len(borrowers) > limit {
+		borrowers = borrowers[:limit]
+	}
+	return borrowers, nil
+}
+
+func (r *memoryBorrowerRepository) Update(ctx context.Context, b *domain.Borrower) error {
+	defer r.store.lock(ctx)()
+
+	stored, ok := r.store.borrowers[b.ID]
+	if !ok {
+		return domain.Errorf(domain.ErrNotFound, "borrower %s not found", b.ID)
+	}
+	if !b.KYCStatus.IsValid() {
+		return constraintErrors["borrowers_kyc_status_check"]
+	}
+
+	// Like the Postgres Update, the national ID number and creation time
+	// are kept.
+	updated := *stored
+	updated.BorrowerDetails = b.BorrowerDetails
+	updated.KYCStatus = b.KYCStatus
+	updated.UpdatedAt = dbTime(b.UpdatedAt)
+	r.store.borrowers[b.ID] = &updated
+	return nil
+}
+
+func (r *memoryBorrowerRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer r.store.lock(ctx)()
+
+	b, ok := r.store.borrowers[id]
+	if !ok {
+		return domain.Errorf(domain.ErrNotFound, "borrower %s not found", id)
+	}
+	for _, loan := range r.store.loans {
+		if loan.BorrowerIDNumber == b.NationalIDNumber {
+			return domain.Errorf(domain.ErrConflict, "borrower %s has loans and cannot be deleted", id)
+		}
+	}
+	delete(r.store.borrowers, id)
+	return nil
+}
+
+type memoryInvestorRepository struct {
+	store *MemoryStore
+}
+
+func (r *memoryInvestorRepository) Create(ctx context.Context, i *domain.Investor) error {
+	defer r.store.lock(ctx)()
+
+	if _, ok := r.store.investors[i.ID]; ok {
+		return &domain.Error{Kind: domain.ErrConflict, Message: "resource already exists"}
+	}
+	if err := r.check(i); err != nil {
+		return err
+	}
+
+	c := copyInvestor(i)
+	c.CreatedAt = dbTime(i.CreatedAt)
+	c.UpdatedAt = dbTime(i.UpdatedAt)
+	r.store.investors[i.ID] = c
+	return nil
+}
+
+// check enforces the constraints of the investors table.
+func (r *memoryInvestorRepository) check(i *domain.Investor) error {
+	if i.Email != "" {
+		for _, other := range r.store.investors {
+			if other.ID != i.ID && strings.EqualFold(other.Email, i.Email) {
+				return constraintErrors["investors_email_key"]
+			}
+		}
+	}
+	if !i.KYCStatus.IsValid() {
+		return constraintErrors["investors_kyc_status_check"]
+	}
+	if !i.Status.IsValid() {
+		return constraintErrors["investors_status_check"]
+	}
+	if i.Limits.Validate() != nil {
+		return constraintErrors["investors_limits_check"]
+	}
+	return nil
+}
+
+func (r *memoryInvestorRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Investor, error) {
+	defer r.store.lock(ctx)()
+
+	i, ok := r.store.investors[id]
+	if !ok {
+		return nil, domain.Errorf(domain.ErrNotFound, "investor %s not found", id)
+	}
+	return copyInvestor(i), nil
+}
+
+// GetForUpdate needs no lock of its own: transactions on the store already
+// run one at a time.
+func (r *memoryInvestorRepository) GetForUpdate(ctx context.Context, id uuid.UUID) (*domain.Investor, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *memoryInvestorRepository) List(ctx context.Context, status domain.InvestorStatus, limit int) ([]*domain.Investor, error) {
+	defer r.store.lock(ctx)()
+
+	var investors []*domain.Investor
+	for _, i := range r.store.investors {
+		if status == "" || i.Status == status {
+			investors = append(investors, copyInvestor(i))
+		}
+	}
+	slices.SortFunc(investors, func(a, b *domain.Investor) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID.String(), a.ID.String())
+	})
+	if len(investors) > limit {
+		investors = investors[:limit]
+	}
+	return investors, nil
+}
+
+func (r *memoryInvestorRepository) Update(ctx context.Context, i *domain.Investor) error {
+	defer r.store.lock(ctx)()
+
+	stored, ok := r.store.investors[i.ID]
+	if !ok {
+		return domain.Errorf(domain.ErrNotFound, "investor %s not found", i.ID)
+	}
+	if err := r.check(i); err != nil {
+		return err
+	}
+
+	// Like the Postgres Update, the creation time is kept.
+	updated := copyInvestor(i)
+	updated.CreatedAt = stored.CreatedAt
+	updated.UpdatedAt = dbTime(i.UpdatedAt)
+	r.store.investors[i.ID] = updated
+	return nil
+}
+
+func (r *memoryInvestorRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer r.store.lock(ctx)()
+
+	if _, ok := r.store.investors[id]; !ok {
+		return domain.Errorf(domain.ErrNotFound, "investor %s not found", id)
+	}
+	for _, loan := range r.store.loans {
+		for _, inv := range loan.Investments {
+			if inv.InvestorID == id {
+				return domain.Errorf(domain.ErrConflict, "investor %s has investments and cannot be deleted", id)
+			}
+		}
+	}
+	for k := range r.store.wallets {
+		if k.investorID == id {
+			return domain.Errorf(domain.ErrConflict, "investor %s has a wallet and cannot be deleted", id)
+		}
+	}
+	delete(r.store.investors, id)
+	return nil
+}
+
+func (r *memoryInvestorRepository) TotalInvested(ctx context.Context, id uuid.UUID, currency string) (domain.Money, error) {
+	defer r.store.lock(ctx)()
+
+	total := domain.NewMoney(0, currency)
+	for _, loan := range r.store.loans {
+		for _, inv := range loan.Investments {
+			if inv.InvestorID == id && inv.VoidedAt == nil && inv.Amount.Currency == currency {
+				total = total.Add(inv.Amount)
+			}
+		}
+	}
+	return total, nil
+}
+
+func (r *memoryInvestorRepository) Holdings(ctx context.Context, id uuid.UUID) ([]domain.Holding, error) {
+	defer r.store.lock(ctx)()
+
+	var holdings []domain.Holding
+	for _, loan := range r.store.loans {
+		for _, inv := range loan.Investments {
+			if inv.InvestorID == id {
+				holdings = append(holdings, domain.Holding{
+					Investment:    copyInvestment(inv),
+					LoanState:     loan.State,
+					LoanPrincipal: loan.PrincipalAmount,
+					LoanROI:       loan.ROI,
+				})
+			}
+		}
+	}
+	slices.SortFunc(holdings, func(a, b domain.Holding) int {
+		return compareInvestments(a.Investment, b.Investment)
+	})
+	return holdings, nil
+}
+
+type memoryWalletRepository struct {
+	store *MemoryStore
+}
+
+func (r *memoryWalletRepository) List(ctx context.Context, investorID uuid.UUID) ([]*domain.Wallet, error) {
+	defer r.store.lock(ctx)()
+
+	var wallets []*domain.Wallet
+	for k, w := range r.store.wallets {
+		if k.investorID == investorID {
+			c := *w
+			wallets = append(wallets, &c)
+		}
+	}
+	slices.SortFunc(wallets, func(a, b *domain.Wallet) int {
+		return strings.Compare(a.Currency, b.Currency)
+	})
+	return wallets, nil
+}
+
+func (r *memoryWalletRepository) All(ctx context.Context) ([]*domain.Wallet, error) {
+	defer r.store.lock(ctx)()
+
+	var wallets []*domain.Wallet
+	for _, w := range r.store.wallets {
+		c := *w
+		wallets = append(wallets, &c)
+	}
+	slices.SortFunc(wallets, func(a, b *domain.Wallet) int {
+		return cmp.Or(bytes.Compare(a.InvestorID[:], b.InvestorID[:]), strings.Compare(a.Currency, b.Currency))
+	})
+	return wallets, nil
+}
+
+// GetForUpdate needs no lock of its own: transactions on the store already
+// run one at a time.
+func (r *memoryWalletRepository) GetForUpdate(ctx context.Context, investorID uuid.UUID, currency string) (*domain.Wallet, error) {
+	defer r.store.lock(ctx)()
+
+	if _, ok := r.store.investors[investorID]; !ok {
+		return nil, constraintErrors["investor_wallets_investor_id_fkey"]
+	}
+	k := walletKey{investorID: investorID, currency: currency}
+	w, ok := r.store.wallets[k]
+	if !ok {
+		w = domain.NewWallet(investorID, currency, dbTime(time.Now()))
+		r.store.wallets[k] = w
+	}
+	c := *w
+	return &c, nil
+}
+
+func (r *memoryWalletRepository) Save(ctx context.Context, w *domain.Wallet) error {
+	defer r.store.lock(ctx)()
+
+	k := walletKey{investorID: w.InvestorID, currency: w.Currency}
+	if _, ok := r.store.wallets[k]; !ok {
+		return domain.Errorf(domain.ErrNotFound, "investor %s has no %s wallet", w.InvestorID, w.Currency)
+	}
+	if w.Available.MinorUnits < 0 || w.Reserved.MinorUnits < 0 {
+		return constraintErrors["investor_wallets_balances_check"]
+	}
+
+	c := *w
+	c.UpdatedAt = dbTime(w.UpdatedAt)
+	r.store.wallets[k] = &c
+	return nil
+}
+
+type memoryOutboxRepository struct {
+	store *MemoryStore
+}
+
+func (r *memoryOutboxRepository) Enqueue(ctx context.Context, messages ...*domain.OutboxMessage) error {
+	defer r.store.lock(ctx)()
+
+	for _, m := range messages {
+		if _, ok := r.store.outbox[m.ID]; ok {
+			return &domain.Error{Kind: domain.ErrConflict, Message: "resource already exists"}
+		}
+	}
+	for _, m := range messages {
+		r.store.outbox[m.ID] = copyOutboxMessage(m)
+	}
+	return nil
+}
+
+func (r *memoryOutboxRepository) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.OutboxMessage, error) {
+	defer r.store.lock(ctx)()
+
+	var due []*domain.OutboxMessage
+	for _, m := range r.store.outbox {
+		if m.Status == domain.OutboxStatusPending && !m.NextAttemptAt.After(now) {
+			due = append(due, m)
+		}
+	}
+	slices.SortFunc(due, func(a, b *domain.OutboxMessage) int {
+		return a.NextAttemptAt.Compare(b.NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*domain.OutboxMessage, len(due))
+	for i, m := range due {
+		m.NextAttemptAt = now.Add(lease)
+		claimed[i] = copyOutboxMessage(m)
+	}
+	return claimed, nil
+}
+
+func (r *memoryOutboxRepository) Save(ctx context.Context, m *domain.OutboxMessage) error {
+	defer r.store.lock(ctx)()
+
+	if _, ok := r.store.outbox[m.ID]; !ok {
+		return domain.Errorf(domain.ErrNotFound, "outbox message %s not found", m.ID)
+	}
+	r.store.outbox[m.ID] = copyOutboxMessage(m)
+	return nil
+}
+
+func (r *memoryOutboxRepository) Get(ctx context.Context, id uuid.UUID) (*domain.OutboxMessage, error) {
+	defer r.store.lock(ctx)()
+
+	m, ok := r.store.outbox[id]
+	if !ok {
+		return nil, domain.Errorf(domain.ErrNotFound, "outbox message %s not found", id)
+	}
+	return copyOutboxMessage(m), nil
+}
+
+func (r *memoryOutboxRepository) List(ctx context.Context, status domain.OutboxStatus, limit int) ([]*domain.OutboxMessage, error) {
+	defer r.store.lock(ctx)()
+
+	var messages []*domain.OutboxMessage
+	for _, m := range r.store.outbox {
+		if status == "" || m.Status == status {
+			messages = append(messages, copyOutboxMessage(m))
+		}
+	}
+	slices.SortFunc(messages, func(a, b *domain.OutboxMessage) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID.String(), a.ID.String())
+	})
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// dbTime truncates t to the microsecond precision of Postgres timestamps.
+func dbTime(t time.Time) time.Time {
+	return t.Truncate(time.Microsecond)
+}
+
+func copyLoan(loan *domain.Loan) *domain.Loan {
+	c := *loan
+	if loan.ApprovalDetails != nil {
+		approval := *loan.ApprovalDetails
+		c.ApprovalDetails = &approval
+	}
+	if loan.DisbursementDetails != nil {
+		disbursement := *loan.DisbursementDetails
+		c.DisbursementDetails = &disbursement
+	}
+	if loan.ClosureDetails != nil {
+		closure := *loan.ClosureDetails
+		c.ClosureDetails = &closure
+	}
+	c.Investments = nil
+	for _, inv := range loan.Investments {
+		c.Investments = append(c.Investments, copyInvestment(inv))
+	}
+	return &c
+}
+
+func copyInvestment(inv domain.Investment) domain.Investment {
+	if inv.VoidedAt != nil {
+		voidedAt := *inv.VoidedAt
+		inv.VoidedAt = &voidedAt
+	}
+	return inv
+}
+
+func copyInvestor(i *domain.Investor) *domain.Investor {
+	c := *i
+	if i.Limits.PerLoan != nil {
+		perLoan := *i.Limits.PerLoan
+		c.Limits.PerLoan = &perLoan
+	}
+	if i.Limits.Total != nil {
+		total := *i.Limits.Total
+		c.Limits.Total = &total
+	}
+	return &c
+}
+
+func copyOutboxMessage(m *domain.OutboxMessage) *domain.OutboxMessage {
+	c := *m
+	c.Payload = slices.Clone(m.Payload)
+	if m.SentAt != nil {
+		sentAt := *m.SentAt
+		c.SentAt = &sentAt
+	}
+	return &c
+}
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMemoryLoan(t *testing.T, repo LoanRepository, state domain.LoanState, principal int64) *domain.Loan {
+	t.Helper()
+	now := time.Now()
+	loan := &domain.Loan{
+		ID:               uuid.New(),
+		BorrowerIDNumber: "B-" + uuid.NewString(),
+		PrincipalAmount:  domain.NewMoney(principal, "IDR"),
+		Rate:             domain.Percent(500),
+		ROI:              domain.Percent(400),
+		State:            state,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	require.NoError(t, repo.Create(context.Background(), loan))
+	return loan
+}
+
+func TestMemoryLoanRepositoryCopiesValues(t *testing.T) {
+	repo := NewMemoryStore().Loans()
+	ctx := context.Background()
+	loan := newMemoryLoan(t, repo, domain.LoanStateProposed, 10000)
+	assert.Equal(t, int64(1), loan.Version)
+
+	loan.State = domain.LoanStateApproved
+	got, err := repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateProposed, got.State, "callers' changes are not visible until Update")
+
+	got.ApprovalDetails = &domain.ApprovalDetails{FieldValidatorID: "V1"}
+	got.State = domain.LoanStateApproved
+	require.NoError(t, repo.Update(ctx, got))
+	assert.Equal(t, int64(2), got.Version)
+
+	got.ApprovalDetails.FieldValidatorID = "changed"
+	again, err := repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "V1", again.ApprovalDetails.FieldValidatorID)
+
+	_, err = repo.GetByID(ctx, uuid.New())
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestMemoryLoanRepositoryUpdateChecksVersionAndState(t *testing.T) {
+	repo := NewMemoryStore().Loans()
+	ctx := context.Background()
+	loan := newMemoryLoan(t, repo, domain.LoanStateProposed, 10000)
+
+	stale := *loan
+	require.NoError(t, repo.Update(ctx, loan))
+	assert.ErrorIs(t, repo.Update(ctx, &stale), domain.ErrConflict)
+
+	loan.State = "approved"
+	assert.ErrorIs(t, repo.Update(ctx, loan), domain.ErrValidation)
+
+	assert.ErrorIs(t, repo.Update(ctx, &domain.Loan{ID: uuid.New(), Version: 1}), domain.ErrNotFound)
+}
+
+func TestMemoryAddInvestmentCannotOverFund(t *testing.T) {
+	repo := NewMemoryStore().Loans()
+	ctx := context.Background()
+	loan := newMemoryLoan(t, repo, domain.LoanStateApproved, 100000)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.AddInvestment(ctx, &domain.Investment{
+				ID:         uuid.New(),
+				LoanID:     loan.ID,
+				InvestorID: uuid.New(),
+				Amount:     domain.NewMoney(10000, "IDR"),
+				CreatedAt:  time.Now(),
+			})
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, succeeded)
+	got, err := repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateInvested, got.State)
+	assert.Len(t, got.Investments, 10)
+	assert.Equal(t, int64(11), got.Version)
+}
+
+func TestMemoryTransactorRollsBack(t *testing.T) {
+	store := NewMemoryStore()
+	loans, schedules, repayments := store.Loans(), store.Schedules(), store.Repayments()
+	outbox, ledger, tx := store.Outbox(), store.Ledger(), store.Transactor()
+	ctx := context.Background()
+	loan := newMemoryLoan(t, loans, domain.LoanStateApproved, 10000)
+
+	boom := errors.New("boom")
+	err := tx.WithinTx(ctx, func(ctx context.Context) error {
+		investment := &domain.Investment{
+			ID: uuid.New(), LoanID: loan.ID, InvestorID: uuid.New(),
+			Amount: domain.NewMoney(10000, "IDR"), CreatedAt: time.Now(),
+		}
+		_, err := loans.AddInvestment(ctx, investment)
+		require.NoError(t, err)
+		entry, err := domain.InvestmentEntry(*investment)
+		require.NoError(t, err)
+		require.NoError(t, ledger.Post(ctx, entry))
+		m, err := domain.NewOutboxMessage("test", nil, time.Now())
+		require.NoError(t, err)
+		require.NoError(t, outbox.Enqueue(ctx, m))
+		require.NoError(t, schedules.Save(ctx, loan.ID, []domain.Installment{
+			domain.NewInstallment(1, time.Now(), domain.NewMoney(10000, "IDR"), domain.NewMoney(100, "IDR")),
+		}))
+		zero := domain.NewMoney(0, "IDR")
+		require.NoError(t, repayments.Create(ctx, &domain.Repayment{
+			ID: uuid.New(), LoanID: loan.ID, Amount: domain.NewMoney(100, "IDR"), Interest: domain.NewMoney(100, "IDR"),
+			Principal: zero, LateFee: zero, PlatformFee: domain.NewMoney(100, "IDR"), PaidAt: time.Now(),
+		}))
+		return boom
+	})
+	assert.ErrorIs(t, err, boom)
+
+	got, err := loans.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateApproved, got.State)
+	assert.Empty(t, got.Investments)
+
+	messages, err := outbox.List(ctx, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+
+	installments, err := schedules.List(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Empty(t, installments)
+
+	paid, err := repayments.List(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Empty(t, paid)
+
+	entries, err := ledger.Entries(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestMemoryListPaginates(t *testing.T) {
+	repo := NewMemoryStore().Loans()
+	ctx := context.Background()
+	for _, principal := range []int64{300, 100, 200, 100, 500} {
+		newMemoryLoan(t, repo, domain.LoanStateProposed, principal)
+	}
+
+	var got []int64
+	filter := domain.LoanFilter{Sort: domain.LoanSortPrincipalAsc, Limit: 2}
+	for {
+		page, err := repo.List(ctx, filter)
+		require.NoError(t, err)
+		for _, loan := range page.Loans {
+			got = append(got, loan.PrincipalAmount.MinorUnits)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []int64{100, 100, 200, 300, 500}, got)
+
+	_, err := repo.List(ctx, domain.LoanFilter{Sort: domain.LoanSortCreatedAtAsc, Cursor: filter.Cursor})
+	assert.ErrorIs(t, err, domain.ErrInvalidCursor)
+}
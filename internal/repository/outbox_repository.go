@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type OutboxRepository interface {
+	// Enqueue joins the transaction carried by ctx, so messages are only
+	// visible to the dispatcher once the state change that produced them has
+	// committed.
+	Enqueue(ctx context.Context, messages ...*domain.OutboxMessage) error
+	// Claim returns up to limit pending messages due at now and pushes their
+	// next attempt out by lease, so concurrent dispatchers do not pick up the
+	// same message while it is being delivered.
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.OutboxMessage, error)
+	// Save records the outcome of a delivery attempt.
+	Save(ctx context.Context, message *domain.OutboxMessage) error
+	Get(ctx context.Context, id uuid.UUID) (*domain.OutboxMessage, error)
+	// List returns the most recently created messages, optionally only those
+	// with the given status.
+	List(ctx context.Context, status domain.OutboxStatus, limit int) ([]*domain.OutboxMessage, error)
+}
+
+type outboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+const outboxColumns = `
+		id, topic, payload, status, attempts, next_attempt_at,
+		last_error, created_at, sent_at`
+
+func (r *outboxRepository) Enqueue(ctx context.Context, messages ...*domain.OutboxMessage) error {
+	query := `
+		INSERT INTO outbox (
+			id, topic, payload, status, attempts, next_attempt_at, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, m := range messages {
+			if _, err := tx.ExecContext(ctx, query,
+				m.ID, m.Topic, []byte(m.Payload), m.Status, m.Attempts, m.NextAttemptAt, m.CreatedAt,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *outboxRepository) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.OutboxMessage, error) {
+	query := `
+		UPDATE outbox SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE status = $3 AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + outboxColumns
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query,
+		now, now.Add(lease), domain.OutboxStatusPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanOutboxMessages(rows)
+}
+
+func (r *outboxRepository) Save(ctx context.Context, m *domain.OutboxMessage) error {
+	query := `
+		UPDATE outbox
+		SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4, sent_at = $5
+		WHERE id = $6`
+
+	var sentAt sql.NullTime
+	if m.SentAt != nil {
+		sentAt = sql.NullTime{Time: *m.SentAt, Valid: true}
+	}
+
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, query,
+			m.Status, m.Attempts, m.NextAttemptAt,
+			sql.NullString{String: m.LastError, Valid: m.LastError != ""},
+			sentAt, m.ID,
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return domain.Errorf(domain.ErrNotFound, "outbox message %s not found", m.ID)
+		}
+		return nil
+	})
+}
+
+func (r *outboxRepository) Get(ctx context.Context, id uuid.UUID) (*domain.OutboxMessage, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx,
+		`SELECT `+outboxColumns+` FROM outbox WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := scanOutboxMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, domain.Errorf(domain.ErrNotFound, "outbox message %s not found", id)
+	}
+	return messages[0], nil
+}
+
+func (r *outboxRepository) List(ctx context.Context, status domain.OutboxStatus, limit int) ([]*domain.OutboxMessage, error) {
+	query := `
+		SELECT ` + outboxColumns + `
+		FROM outbox
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanOutboxMessages(rows)
+}
+
+func scanOutboxMessages(rows *sql.Rows) ([]*domain.OutboxMessage, error) {
+	defer rows.Close()
+
+	var messages []*domain.OutboxMessage
+	for rows.Next() {
+		var (
+			m         domain.OutboxMessage
+			payload   []byte
+			lastError sql.NullString
+			sentAt    sql.NullTime
+		)
+		if err := rows.Scan(
+			&m.ID, &m.Topic, &payload, &m.Status, &m.Attempts, &m.NextAttemptAt,
+			&lastError, &m.CreatedAt, &sentAt,
+		); err != nil {
+			return nil, err
+		}
+		m.Payload = payload
+		m.LastError = lastError.String
+		if sentAt.Valid {
+			m.SentAt = &sentAt.Time
+		}
+		messages = append(messages, &m)
+	}
+	return messages, rows.Err()
+}
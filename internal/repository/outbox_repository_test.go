@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxEnqueueFollowsTransaction(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewOutboxRepository(db)
+	tx := NewTransactor(db)
+	ctx := context.Background()
+
+	// Far in the past so these messages are claimed ahead of any others.
+	at := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	committed, err := domain.NewOutboxMessage("test", map[string]string{"n": "1"}, at)
+	require.NoError(t, err)
+	rolledBack, err := domain.NewOutboxMessage("test", map[string]string{"n": "2"}, at)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM outbox WHERE id IN ($1, $2)`, committed.ID, rolledBack.ID)
+	})
+
+	require.NoError(t, tx.WithinTx(ctx, func(ctx context.Context) error {
+		return repo.Enqueue(ctx, committed)
+	}))
+	boom := errors.New("boom")
+	assert.ErrorIs(t, tx.WithinTx(ctx, func(ctx context.Context) error {
+		require.NoError(t, repo.Enqueue(ctx, rolledBack))
+		return boom
+	}), boom)
+
+	_, err = repo.Get(ctx, rolledBack.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	now := time.Now()
+	claimed, err := repo.Claim(ctx, now, time.Minute, 1)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, committed.ID, claimed[0].ID)
+	assert.JSONEq(t, `{"n":"1"}`, string(claimed[0].Payload))
+
+	// The lease hides the message from other dispatchers.
+	again, err := repo.Claim(ctx, now, time.Minute, 100)
+	require.NoError(t, err)
+	for _, m := range again {
+		assert.NotEqual(t, committed.ID, m.ID)
+	}
+
+	claimed[0].Status = domain.OutboxStatusSent
+	claimed[0].Attempts = 1
+	claimed[0].SentAt = &now
+	require.NoError(t, repo.Save(ctx, claimed[0]))
+
+	saved, err := repo.Get(ctx, committed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OutboxStatusSent, saved.Status)
+	assert.NotNil(t, saved.SentAt)
+}
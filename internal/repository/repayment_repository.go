@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type RepaymentRepository interface {
+	// Create records a repayment and its payouts.
+	Create(ctx context.Context, r *domain.Repayment) error
+	// List returns the loan's repayments in the order they were paid.
+	List(ctx context.Context, loanID uuid.UUID) ([]*domain.Repayment, error)
+}
+
+type repaymentRepository struct {
+	db *sql.DB
+}
+
+func NewRepaymentRepository(db *sql.DB) RepaymentRepository {
+	return &repaymentRepository{db: db}
+}
+
+func (r *repaymentRepository) Create(ctx context.Context, rp *domain.Repayment) error {
+	query := `
+		INSERT INTO repayments (
+			id, loan_id, amount, interest_amount, principal_amount,
+			late_fee, platform_fee, currency, paid_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	payoutQuery := `
+		INSERT INTO repayment_payouts (
+			repayment_id, investment_id, investor_id,
+			principal_amount, interest_amount, currency
+		) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, query,
+			rp.ID, rp.LoanID, rp.Amount.String(), rp.Interest.String(), rp.Principal.String(),
+			rp.LateFee.String(), rp.PlatformFee.String(), rp.Amount.Currency, rp.PaidAt,
+		); err != nil {
+			return err
+		}
+		for _, p := range rp.Payouts {
+			if _, err := tx.ExecContext(ctx, payoutQuery,
+				rp.ID, p.InvestmentID, p.InvestorID,
+				p.Principal.String(), p.Interest.String(), p.Amount.Currency,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return dbError(err)
+}
+
+func (r *repaymentRepository) List(ctx context.Context, loanID uuid.UUID) ([]*domain.Repayment, error) {
+	query := `
+		SELECT id, loan_id, amount, interest_amount, principal_amount,
+			late_fee, platform_fee, currency, paid_at
+		FROM repayments
+		WHERE loan_id = $1
+		ORDER BY paid_at, id`
+
+	q := conn(ctx, r.db)
+	rows, err := q.QueryContext(ctx, query, loanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var repayments []*domain.Repayment
+	byID := make(map[uuid.UUID]*domain.Repayment)
+	for rows.Next() {
+		var (
+			rp       domain.Repayment
+			amounts  [5]string
+			currency string
+		)
+		if err := rows.Scan(&rp.ID, &rp.LoanID, &amounts[0], &amounts[1], &amounts[2],
+			&amounts[3], &amounts[4], &currency, &rp.PaidAt); err != nil {
+			return nil, err
+		}
+		for i, dst := range []*domain.Money{&rp.Amount, &rp.Interest, &rp.Principal, &rp.LateFee, &rp.PlatformFee} {
+			if *dst, err = domain.ParseMoney(amounts[i], currency); err != nil {
+				return nil, err
+			}
+		}
+		rp.Payouts = []domain.Payout{}
+		repayments = append(repayments, &rp)
+		byID[rp.ID] = &rp
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(repayments) == 0 {
+		return nil, nil
+	}
+
+	// Payouts follow the order of the loan's investments, as they were
+	// allocated.
+	payoutQuery := `
+		SELECT p.repayment_id, p.investment_id, p.investor_id,
+			p.principal_amount, p.interest_amount, p.currency
+		FROM repayment_payouts p
+		JOIN repayments r ON r.id = p.repayment_id
+		JOIN investments i ON i.id = p.investment_id
+		WHERE r.loan_id = $1
+		ORDER BY i.created_at, i.id`
+
+	payoutRows, err := q.QueryContext(ctx, payoutQuery, loanID)
+	if err != nil {
+		return nil, err
+	}
+	defer payoutRows.Close()
+
+	for payoutRows.Next() {
+		var (
+			repaymentID, investmentID, investorID uuid.UUID
+			principal, interest, currency         string
+		)
+		if err := payoutRows.Scan(&repaymentID, &investmentID, &investorID, &principal, &interest, &currency); err != nil {
+			return nil, err
+		}
+		p, err := domain.ParseMoney(principal, currency)
+		if err != nil {
+			return nil, err
+		}
+		i, err := domain.ParseMoney(interest, currency)
+		if err != nil {
+			return nil, err
+		}
+		rp := byID[repaymentID]
+		rp.Payouts = append(rp.Payouts, domain.NewPayout(investmentID, investorID, p, i))
+	}
+	return repayments, payoutRows.Err()
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type RepaymentScheduleRepository interface {
+	// Save writes the installments of a loan's schedule. A loan's schedule
+	// is written once; saving another fails with domain.ErrConflict.
+	Save(ctx context.Context, loanID uuid.UUID, installments []domain.Installment) error
+	// Update writes what has been paid on the installments, and their late
+	// fees. Their due dates and amounts stay as saved.
+	Update(ctx context.Context, loanID uuid.UUID, installments []domain.Installment) error
+	// List returns the loan's installments in order, or none if it has no
+	// schedule.
+	List(ctx context.Context, loanID uuid.UUID) ([]domain.Installment, error)
+}
+
+type repaymentScheduleRepository struct {
+	db *sql.DB
+}
+
+func NewRepaymentScheduleRepository(db *sql.DB) RepaymentScheduleRepository {
+	return &repaymentScheduleRepository{db: db}
+}
+
+func (r *repaymentScheduleRepository) Save(ctx context.Context, loanID uuid.UUID, installments []domain.Installment) error {
+	query := `
+		INSERT INTO repayment_installments (
+			loan_id, number, due_date, principal_amount, interest_amount, currency,
+			late_fee, paid_interest, paid_principal, paid_late_fee, settled_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, inst := range installments {
+			// Sent as a date so the session time zone cannot move it.
+			if _, err := tx.ExecContext(ctx, query,
+				loanID, inst.Number, inst.DueDate.Format(time.DateOnly),
+				inst.Principal.String(), inst.Interest.String(), inst.Principal.Currency,
+				inst.LateFee.String(), inst.PaidInterest.String(), inst.PaidPrincipal.String(), inst.PaidLateFee.String(),
+				inst.SettledAt,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return dbError(err)
+}
+
+func (r *repaymentScheduleRepository) Update(ctx context.Context, loanID uuid.UUID, installments []domain.Installment) error {
+	query := `
+		UPDATE repayment_installments
+		SET late_fee = $1,
+			paid_interest = $2,
+			paid_principal = $3,
+			paid_late_fee = $4,
+			settled_at = $5
+		WHERE loan_id = $6 AND number = $7`
+
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, inst := range installments {
+			res, err := tx.ExecContext(ctx, query,
+				inst.LateFee.String(), inst.PaidInterest.String(), inst.PaidPrincipal.String(), inst.PaidLateFee.String(),
+				inst.SettledAt, loanID, inst.Number,
+			)
+			if err != nil {
+				return err
+			}
+			if n, err := res.RowsAffected(); err != nil {
+				return err
+			} else if n == 0 {
+				return domain.Errorf(domain.ErrNotFound, "installment %d of loan %s not found", inst.Number, loanID)
+			}
+		}
+		return nil
+	})
+	return dbError(err)
+}
+
+func (r *repaymentScheduleRepository) List(ctx context.Context, loanID uuid.UUID) ([]domain.Installment, error) {
+	query := `
+		SELECT number, due_date, principal_amount, interest_amount, currency,
+			late_fee, paid_interest, paid_principal, paid_late_fee, settled_at
+		FROM repayment_installments
+		WHERE loan_id = $1
+		ORDER BY number`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, loanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var installments []domain.Installment
+	for rows.Next() {
+		var (
+			number                        int
+			dueDate                       time.Time
+			principal, interest, currency string
+			amounts                       [4]string
+			settledAt                     sql.NullTime
+		)
+		if err := rows.Scan(&number, &dueDate, &principal, &interest, &currency,
+			&amounts[0], &amounts[1], &amounts[2], &amounts[3], &settledAt); err != nil {
+			return nil, err
+		}
+		p, err := domain.ParseMoney(principal, currency)
+		if err != nil {
+			return nil, err
+		}
+		i, err := domain.ParseMoney(interest, currency)
+		if err != nil {
+			return nil, err
+		}
+		y, m, d := dueDate.Date()
+		inst := domain.NewInstallment(number, time.Date(y, m, d, 0, 0, 0, 0, time.UTC), p, i)
+		for j, dst := range []*domain.Money{&inst.LateFee, &inst.PaidInterest, &inst.PaidPrincipal, &inst.PaidLateFee} {
+			if *dst, err = domain.ParseMoney(amounts[j], currency); err != nil {
+				return nil, err
+			}
+		}
+		if settledAt.Valid {
+			inst.SettledAt = &settledAt.Time
+		}
+		installments = append(installments, inst)
+	}
+	return installments, rows.Err()
+}
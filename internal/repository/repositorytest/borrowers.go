@@ -0,0 +1,160 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// BorrowerRepository runs the BorrowerRepository contract. newRepos returns
+// a borrower repository and the loan repository whose loans refer to its
+// borrowers, sharing one store.
+func BorrowerRepository(t *testing.T, newRepos func(t *testing.T) (repository.LoanRepository, repository.BorrowerRepository)) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, loans repository.LoanRepository, borrowers repository.BorrowerRepository)
+	}{
+		{"CreateAndGet", testBorrowerCreateAndGet},
+		{"CreateDuplicateNationalID", testBorrowerCreateDuplicate},
+		{"GetNotFound", testBorrowerGetNotFound},
+		{"Update", testBorrowerUpdate},
+		{"UpdateNotFound", testBorrowerUpdateNotFound},
+		{"ListByKYCStatus", testBorrowerListByStatus},
+		{"Delete", testBorrowerDelete},
+		{"DeleteWithLoans", testBorrowerDeleteWithLoans},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			loans, borrowers := newRepos(t)
+			c.run(t, loans, borrowers)
+		})
+	}
+}
+
+func createBorrower(t *testing.T, repo repository.BorrowerRepository, status domain.KYCStatus, offset time.Duration) *domain.Borrower {
+	t.Helper()
+	b := &domain.Borrower{
+		ID:               uuid.New(),
+		NationalIDNumber: "contract-" + uuid.NewString(),
+		BorrowerDetails: domain.BorrowerDetails{
+			Name:    "Siti Rahma",
+			Email:   "siti@example.com",
+			Phone:   "+62 812 0000 0000",
+			Address: "Jl. Merdeka 1, Jakarta",
+		},
+		KYCStatus: status,
+		CreatedAt: at.Add(offset),
+		UpdatedAt: at.Add(offset),
+	}
+	require.NoError(t, repo.Create(context.Background(), b))
+	return b
+}
+
+func testBorrowerCreateAndGet(t *testing.T, _ repository.LoanRepository, repo repository.BorrowerRepository) {
+	ctx := context.Background()
+	b := createBorrower(t, repo, domain.KYCStatusPending, 0)
+
+	got, err := repo.GetByID(ctx, b.ID)
+	require.NoError(t, err)
+	assert.Equal(t, b.NationalIDNumber, got.NationalIDNumber)
+	assert.Equal(t, b.BorrowerDetails, got.BorrowerDetails)
+	assert.Equal(t, domain.KYCStatusPending, got.KYCStatus)
+	assert.True(t, b.CreatedAt.Equal(got.CreatedAt))
+
+	got, err = repo.GetByNationalID(ctx, b.NationalIDNumber)
+	require.NoError(t, err)
+	assert.Equal(t, b.ID, got.ID)
+}
+
+func testBorrowerCreateDuplicate(t *testing.T, _ repository.LoanRepository, repo repository.BorrowerRepository) {
+	b := createBorrower(t, repo, domain.KYCStatusPending, 0)
+
+	dup := *b
+	dup.ID = uuid.New()
+	assert.ErrorIs(t, repo.Create(context.Background(), &dup), domain.ErrAlreadyExists)
+}
+
+func testBorrowerGetNotFound(t *testing.T, _ repository.LoanRepository, repo repository.BorrowerRepository) {
+	ctx := context.Background()
+
+	_, err := repo.GetByID(ctx, uuid.New())
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = repo.GetByNationalID(ctx, "missing-"+uuid.NewString())
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func testBorrowerUpdate(t *testing.T, _ repository.LoanRepository, repo repository.BorrowerRepository) {
+	ctx := context.Background()
+	b := createBorrower(t, repo, domain.KYCStatusPending, 0)
+
+	b.Phone = "+62 813 1111 1111"
+	b.KYCStatus = domain.KYCStatusVerified
+	b.UpdatedAt = at.Add(time.Hour)
+	require.NoError(t, repo.Update(ctx, b))
+
+	got, err := repo.GetByID(ctx, b.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "+62 813 1111 1111", got.Phone)
+	assert.Equal(t, domain.KYCStatusVerified, got.KYCStatus)
+	assert.True(t, at.Add(time.Hour).Equal(got.UpdatedAt))
+	assert.True(t, at.Equal(got.CreatedAt))
+
+	b.KYCStatus = "MAYBE"
+	assert.ErrorIs(t, repo.Update(ctx, b), domain.ErrValidation)
+}
+
+func testBorrowerUpdateNotFound(t *testing.T, _ repository.LoanRepository, repo repository.BorrowerRepository) {
+	b := &domain.Borrower{ID: uuid.New(), KYCStatus: domain.KYCStatusPending, UpdatedAt: at}
+	assert.ErrorIs(t, repo.Update(context.Background(), b), domain.ErrNotFound)
+}
+
+func testBorrowerListByStatus(t *testing.T, _ repository.LoanRepository, repo repository.BorrowerRepository) {
+	// Far in the future so they list first even if the store is shared.
+	future := 1000 * 24 * time.Hour
+	older := createBorrower(t, repo, domain.KYCStatusRejected, future)
+	newer := createBorrower(t, repo, domain.KYCStatusRejected, future+time.Minute)
+	createBorrower(t, repo, domain.KYCStatusVerified, future+2*time.Minute)
+
+	got, err := repo.List(context.Background(), domain.KYCStatusRejected, 2)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, newer.ID, got[0].ID)
+	assert.Equal(t, older.ID, got[1].ID)
+}
+
+func testBorrowerDelete(t *testing.T, _ repository.LoanRepository, repo repository.BorrowerRepository) {
+	ctx := context.Background()
+	b := createBorrower(t, repo, domain.KYCStatusPending, 0)
+
+	require.NoError(t, repo.Delete(ctx, b.ID))
+	_, err := repo.GetByID(ctx, b.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.ErrorIs(t, repo.Delete(ctx, b.ID), domain.ErrNotFound)
+}
+
+func testBorrowerDeleteWithLoans(t *testing.T, loans repository.LoanRepository, repo repository.BorrowerRepository) {
+	ctx := context.Background()
+	b := createBorrower(t, repo, domain.KYCStatusVerified, 0)
+	loan := &domain.Loan{
+		ID:               uuid.New(),
+		BorrowerIDNumber: b.NationalIDNumber,
+		PrincipalAmount:  domain.NewMoney(100000, "IDR"),
+		Rate:             domain.Percent(1000),
+		ROI:              domain.Percent(800),
+		State:            domain.LoanStateProposed,
+		CreatedAt:        at,
+		UpdatedAt:        at,
+	}
+	require.NoError(t, loans.Create(ctx, loan))
+
+	assert.ErrorIs(t, repo.Delete(ctx, b.ID), domain.ErrConflict)
+	_, err := repo.GetByID(ctx, b.ID)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,480 @@
+// Package repositorytest holds conformance suites that every implementation
+// of the repository interfaces must pass, so the in-memory and Postgres
+// repositories cannot drift apart.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// LoanRepository runs the LoanRepository contract against the repositories
+// returned by newRepo, which is called once per case. Repositories may
+// share storage between cases; every case works on loans of its own.
+func LoanRepository(t *testing.T, newRepo func(t *testing.T) repository.LoanRepository) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, repo repository.LoanRepository)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"CreateDuplicate", testCreateDuplicate},
+		{"CreateWithRepaymentTerms", testCreateWithRepaymentTerms},
+		{"GetNotFound", testGetNotFound},
+		{"UpdatePersistsDetails", testUpdatePersistsDetails},
+		{"UpdateLeavesAbsentDetailsNil", testUpdateLeavesAbsentDetailsNil},
+		{"UpdatePersistsClosure", testUpdatePersistsClosure},
+		{"UpdateStaleVersion", testUpdateStaleVersion},
+		{"UpdateNotFound", testUpdateNotFound},
+		{"AddInvestment", testAddInvestment},
+		{"AddInvestmentRejected", testAddInvestmentRejected},
+		{"VoidInvestments", testVoidInvestments},
+		{"ConcurrentInvestorsCannotOverFund", testConcurrentInvestors},
+		{"ConcurrentUpdatesConflict", testConcurrentUpdates},
+		{"ListByBorrower", testListByBorrower},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.run(t, newRepo(t))
+		})
+	}
+}
+
+// at is a fixed time at the microsecond precision both implementations keep.
+var at = time.Date(2025, 3, 1, 9, 30, 0, 123456000, time.UTC)
+
+func createLoan(t *testing.T, repo repository.LoanRepository, state domain.LoanState, principal int64) *domain.Loan {
+	t.Helper()
+	loan := &domain.Loan{
+		ID:               uuid.New(),
+		BorrowerIDNumber: "contract-" + uuid.NewString(),
+		PrincipalAmount:  domain.NewMoney(principal, "IDR"),
+		Rate:             domain.Percent(1250),
+		ROI:              domain.Percent(1000),
+		State:            state,
+		CreatedAt:        at,
+		UpdatedAt:        at,
+	}
+	require.NoError(t, repo.Create(context.Background(), loan))
+	return loan
+}
+
+func newInvestment(loanID uuid.UUID, amount int64, offset time.Duration) *domain.Investment {
+	return &domain.Investment{
+		ID:         uuid.New(),
+		LoanID:     loanID,
+		InvestorID: uuid.New(),
+		Amount:     domain.NewMoney(amount, "IDR"),
+		CreatedAt:  at.Add(offset),
+	}
+}
+
+func testCreateAndGet(t *testing.T, repo repository.LoanRepository) {
+	loan := createLoan(t, repo, domain.LoanStateProposed, 500000)
+	assert.Equal(t, int64(1), loan.Version)
+
+	got, err := repo.GetByID(context.Background(), loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, loan.ID, got.ID)
+	assert.Equal(t, loan.BorrowerIDNumber, got.BorrowerIDNumber)
+	assert.Equal(t, loan.PrincipalAmount, got.PrincipalAmount)
+	assert.Equal(t, loan.Rate, got.Rate)
+	assert.Equal(t, loan.ROI, got.ROI)
+	assert.Equal(t, domain.LoanStateProposed, got.State)
+	assert.Equal(t, int64(1), got.Version)
+	assert.True(t, got.CreatedAt.Equal(at), "created_at %s", got.CreatedAt)
+	assert.True(t, got.UpdatedAt.Equal(at), "updated_at %s", got.UpdatedAt)
+	assert.Nil(t, got.ApprovalDetails)
+	assert.Nil(t, got.DisbursementDetails)
+	assert.Empty(t, got.Investments)
+	assert.Empty(t, got.AgreementLetterURL)
+	assert.True(t, got.RepaymentTerms.IsZero(), "loans may have no repayment terms")
+}
+
+func testCreateWithRepaymentTerms(t *testing.T, repo repository.LoanRepository) {
+	ctx := context.Background()
+	loan := &domain.Loan{
+		ID:               uuid.New(),
+		BorrowerIDNumber: "contract-" + uuid.NewString(),
+		PrincipalAmount:  domain.NewMoney(500000, "IDR"),
+		Rate:             domain.Percent(1250),
+		ROI:              domain.Percent(1000),
+		RepaymentTerms:   domain.RepaymentTerms{TenorMonths: 12, RepaymentType: domain.RepaymentFlat},
+		State:            domain.LoanStateProposed,
+		CreatedAt:        at,
+		UpdatedAt:        at,
+	}
+	require.NoError(t, repo.Create(ctx, loan))
+
+	got, err := repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, loan.RepaymentTerms, got.RepaymentTerms)
+
+	for _, terms := range []domain.RepaymentTerms{
+		{TenorMonths: 0, RepaymentType: domain.RepaymentEMI},
+		{TenorMonths: domain.MaxTenorMonths + 1, RepaymentType: domain.RepaymentEMI},
+		{TenorMonths: 12, RepaymentType: "BALLOON"},
+	} {
+		invalid := *loan
+		invalid.ID = uuid.New()
+		invalid.RepaymentTerms = terms
+		assert.ErrorIs(t, repo.Create(ctx, &invalid), domain.ErrValidation, "%+v", terms)
+	}
+}
+
+func testCreateDuplicate(t *testing.T, repo repository.LoanRepository) {
+	loan := createLoan(t, repo, domain.LoanStateProposed, 500000)
+	dup := *loan
+	assert.ErrorIs(t, repo.Create(context.Background(), &dup), domain.ErrConflict)
+}
+
+func testGetNotFound(t *testing.T, repo repository.LoanRepository) {
+	_, err := repo.GetByID(context.Background(), uuid.New())
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func testUpdatePersistsDetails(t *testing.T, repo repository.LoanRepository) {
+	ctx := context.Background()
+	loan := createLoan(t, repo, domain.LoanStateInvested, 500000)
+
+	loan.State = domain.LoanStateDisbursed
+	loan.ApprovalDetails = &domain.ApprovalDetails{
+		FieldValidatorID: "validator-1",
+		ProofImageURL:    "loans/proof.jpg",
+		ApprovedAt:       at,
+	}
+	loan.DisbursementDetails = &domain.DisbursementDetails{
+		FieldOfficerID:     "officer-1",
+		SignedAgreementURL: "loans/signed.pdf",
+		DisbursedAt:        at.Add(time.Hour),
+	}
+	loan.AgreementLetterURL = "loans/agreement.pdf"
+	loan.UpdatedAt = at.Add(time.Hour)
+	require.NoError(t, repo.Update(ctx, loan))
+	assert.Equal(t, int64(2), loan.Version)
+
+	got, err := repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateDisbursed, got.State)
+	assert.Equal(t, int64(2), got.Version)
+	assert.True(t, got.UpdatedAt.Equal(loan.UpdatedAt), "updated_at %s", got.UpdatedAt)
+	assert.Equal(t, "loans/agreement.pdf", got.AgreementLetterURL)
+	if assert.NotNil(t, got.ApprovalDetails) {
+		assert.Equal(t, "validator-1", got.ApprovalDetails.FieldValidatorID)
+		assert.Equal(t, "loans/proof.jpg", got.ApprovalDetails.ProofImageURL)
+		assert.True(t, got.ApprovalDetails.ApprovedAt.Equal(at))
+	}
+	if assert.NotNil(t, got.DisbursementDetails) {
+		assert.Equal(t, "officer-1", got.DisbursementDetails.FieldOfficerID)
+		assert.Equal(t, "loans/signed.pdf", got.DisbursementDetails.SignedAgreementURL)
+		assert.True(t, got.DisbursementDetails.DisbursedAt.Equal(at.Add(time.Hour)))
+	}
+}
+
+// testUpdateLeavesAbsentDetailsNil covers details that were never set, and
+// details that were set and then removed, both of which must read back as
+// nil rather than as empty structs.
+func testUpdateLeavesAbsentDetailsNil(t *testing.T, repo repository.LoanRepository) {
+	ctx := context.Background()
+	loan := createLoan(t, repo, domain.LoanStateProposed, 500000)
+
+	loan.State = domain.LoanStateApproved
+	loan.ApprovalDetails = &domain.ApprovalDetails{FieldValidatorID: "validator-1", ApprovedAt: at}
+	require.NoError(t, repo.Update(ctx, loan))
+
+	got, err := repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, got.ApprovalDetails)
+	assert.Nil(t, got.DisbursementDetails)
+
+	got.ApprovalDetails = nil
+	require.NoError(t, repo.Update(ctx, got))
+
+	got, err = repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.ApprovalDetails)
+	assert.Nil(t, got.DisbursementDetails)
+}
+
+func testUpdatePersistsClosure(t *testing.T, repo repository.LoanRepository) {
+	ctx := context.Background()
+	loan := createLoan(t, repo, domain.LoanStateProposed, 500000)
+
+	loan.State = domain.LoanStateRejected
+	loan.ClosureDetails = &domain.ClosureDetails{
+		ActorID:  "validator-1",
+		Reason:   "address does not exist",
+		ClosedAt: at.Add(time.Hour),
+	}
+	require.NoError(t, repo.Update(ctx, loan))
+
+	got, err := repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateRejected, got.State)
+	if assert.NotNil(t, got.ClosureDetails) {
+		assert.Equal(t, "validator-1", got.ClosureDetails.ActorID)
+		assert.Equal(t, "address does not exist", got.ClosureDetails.Reason)
+		assert.True(t, got.ClosureDetails.ClosedAt.Equal(at.Add(time.Hour)))
+	}
+	assert.Nil(t, got.ApprovalDetails)
+}
+
+func testUpdateStaleVersion(t *testing.T, repo repository.LoanRepository) {
+	ctx := context.Background()
+	loan := createLoan(t, repo, domain.LoanStateProposed, 500000)
+
+	stale := *loan
+	loan.State = domain.LoanStateApproved
+	require.NoError(t, repo.Update(ctx, loan))
+
+	stale.AgreementLetterURL = "loans/stale.pdf"
+	assert.ErrorIs(t, repo.Update(ctx, &stale), domain.ErrConflict)
+
+	got, err := repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateApproved, got.State)
+	assert.Empty(t, got.AgreementLetterURL, "a conflicting update writes nothing")
+	assert.Equal(t, int64(2), got.Version)
+}
+
+func testUpdateNotFound(t *testing.T, repo repository.LoanRepository) {
+	loan := &domain.Loan{
+		ID:              uuid.New(),
+		PrincipalAmount: domain.NewMoney(500000, "IDR"),
+		Rate:            domain.Percent(1250),
+		ROI:             domain.Percent(1000),
+		State:           domain.LoanStateProposed,
+		Version:         1,
+		UpdatedAt:       at,
+	}
+	assert.ErrorIs(t, repo.Update(context.Background(), loan), domain.ErrNotFound)
+}
+
+func testAddInvestment(t *testing.T, repo repository.LoanRepository) {
+	ctx := context.Background()
+	loan := createLoan(t, repo, domain.LoanStateApproved, 500000)
+
+	first := newInvestment(loan.ID, 200000, time.Minute)
+	got, err := repo.AddInvestment(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateApproved, got.State)
+	assert.Equal(t, int64(2), got.Version, "every investment bumps the version")
+	assert.Equal(t, domain.NewMoney(300000, "IDR"), got.RemainingAmount())
+
+	second := newInvestment(loan.ID, 300000, 2*time.Minute)
+	got, err = repo.AddInvestment(ctx, second)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateInvested, got.State)
+	assert.Equal(t, int64(3), got.Version)
+	assert.True(t, got.UpdatedAt.Equal(second.CreatedAt), "updated_at %s", got.UpdatedAt)
+
+	stored, err := repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateInvested, stored.State)
+	assert.Equal(t, int64(3), stored.Version)
+	require.Len(t, stored.Investments, 2)
+	for i, want := range []*domain.Investment{first, second} {
+		inv := stored.Investments[i]
+		assert.Equal(t, want.ID, inv.ID)
+		assert.Equal(t, loan.ID, inv.LoanID)
+		assert.Equal(t, want.InvestorID, inv.InvestorID)
+		assert.Equal(t, want.Amount, inv.Amount)
+		assert.True(t, inv.CreatedAt.Equal(want.CreatedAt), "created_at %s", inv.CreatedAt)
+	}
+}
+
+func testAddInvestmentRejected(t *testing.T, repo repository.LoanRepository) {
+	ctx := context.Background()
+
+	_, err := repo.AddInvestment(ctx, newInvestment(uuid.New(), 1000, 0))
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	proposed := createLoan(t, repo, domain.LoanStateProposed, 500000)
+	_, err = repo.AddInvestment(ctx, newInvestment(proposed.ID, 1000, 0))
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition)
+
+	approved := createLoan(t, repo, domain.LoanStateApproved, 500000)
+	_, err = repo.AddInvestment(ctx, newInvestment(approved.ID, 500001, 0))
+	assert.ErrorIs(t, err, domain.ErrOverInvestment)
+
+	foreign := newInvestment(approved.ID, 1000, 0)
+	foreign.Amount = domain.NewMoney(1000, "USD")
+	_, err = repo.AddInvestment(ctx, foreign)
+	assert.ErrorIs(t, err, domain.ErrValidation)
+
+	got, err := repo.GetByID(ctx, approved.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got.Investments, "rejected investments are not stored")
+	assert.Equal(t, int64(1), got.Version)
+}
+
+func testVoidInvestments(t *testing.T, repo repository.LoanRepository) {
+	ctx := context.Background()
+	loan := createLoan(t, repo, domain.LoanStateApproved, 500000)
+
+	first := newInvestment(loan.ID, 100000, time.Minute)
+	second := newInvestment(loan.ID, 200000, 2*time.Minute)
+	for _, inv := range []*domain.Investment{second, first} {
+		_, err := repo.AddInvestment(ctx, inv)
+		require.NoError(t, err)
+	}
+
+	voidedAt := at.Add(time.Hour)
+	voided, err := repo.VoidInvestments(ctx, loan.ID, voidedAt)
+	require.NoError(t, err)
+	require.Len(t, voided, 2)
+	assert.Equal(t, first.ID, voided[0].ID, "voided investments come back oldest first")
+	assert.Equal(t, second.ID, voided[1].ID)
+	for _, inv := range voided {
+		if assert.NotNil(t, inv.VoidedAt) {
+			assert.True(t, inv.VoidedAt.Equal(voidedAt))
+		}
+	}
+
+	got, err := repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	require.Len(t, got.Investments, 2, "voided investments are kept")
+	for _, inv := range got.Investments {
+		if assert.NotNil(t, inv.VoidedAt) {
+			assert.True(t, inv.VoidedAt.Equal(voidedAt))
+		}
+	}
+	assert.True(t, got.TotalInvestedAmount().IsZero())
+
+	voided, err = repo.VoidInvestments(ctx, loan.ID, voidedAt.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, voided, "investments are voided once")
+
+	// Voided investments free up the principal again.
+	_, err = repo.AddInvestment(ctx, newInvestment(loan.ID, 500000, 3*time.Minute))
+	require.NoError(t, err)
+}
+
+func testConcurrentInvestors(t *testing.T, repo repository.LoanRepository) {
+	ctx := context.Background()
+	loan := createLoan(t, repo, domain.LoanStateApproved, 100000)
+
+	const investors = 10
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		accepted int
+	)
+	for i := 0; i < investors; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Each investor asks for 30% of the principal; at most three fit.
+			_, err := repo.AddInvestment(ctx, newInvestment(loan.ID, 30000, time.Duration(i)*time.Second))
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				accepted++
+			case !errors.Is(err, domain.ErrOverInvestment):
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	got, err := repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, accepted)
+	assert.Len(t, got.Investments, 3)
+	assert.Equal(t, domain.NewMoney(90000, "IDR"), got.TotalInvestedAmount())
+	assert.Equal(t, domain.LoanStateApproved, got.State)
+	assert.Equal(t, int64(4), got.Version)
+}
+
+func testConcurrentUpdates(t *testing.T, repo repository.LoanRepository) {
+	ctx := context.Background()
+	loan := createLoan(t, repo, domain.LoanStateProposed, 100000)
+
+	const writers = 8
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Every writer read version 1, so only one of them may win.
+			update := *loan
+			update.State = domain.LoanStateApproved
+			err := repo.Update(ctx, &update)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case !errors.Is(err, domain.ErrConflict):
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := repo.GetByID(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, succeeded)
+	assert.Equal(t, int64(2), got.Version)
+}
+
+func testListByBorrower(t *testing.T, repo repository.LoanRepository) {
+	ctx := context.Background()
+	borrower := "contract-list-" + uuid.NewString()
+
+	var ids []uuid.UUID
+	for i := 0; i < 3; i++ {
+		loan := &domain.Loan{
+			ID:               uuid.New(),
+			BorrowerIDNumber: borrower,
+			PrincipalAmount:  domain.NewMoney(int64(i+1)*100000, "IDR"),
+			Rate:             domain.Percent(1250),
+			ROI:              domain.Percent(1000),
+			State:            domain.LoanStateProposed,
+			CreatedAt:        at.Add(time.Duration(i) * time.Minute),
+			UpdatedAt:        at,
+		}
+		require.NoError(t, repo.Create(ctx, loan))
+		ids = append(ids, loan.ID)
+	}
+
+	filter := domain.LoanFilter{
+		BorrowerIDNumber: borrower,
+		Sort:             domain.LoanSortCreatedAtAsc,
+		Limit:            2,
+	}
+	page, err := repo.List(ctx, filter)
+	require.NoError(t, err)
+	require.Len(t, page.Loans, 2)
+	assert.Equal(t, ids[0], page.Loans[0].ID)
+	assert.Equal(t, ids[1], page.Loans[1].ID)
+	require.NotEmpty(t, page.NextCursor)
+
+	filter.Cursor = page.NextCursor
+	page, err = repo.List(ctx, filter)
+	require.NoError(t, err)
+	require.Len(t, page.Loans, 1)
+	assert.Equal(t, ids[2], page.Loans[0].ID)
+	assert.Empty(t, page.NextCursor)
+
+	filter = domain.LoanFilter{BorrowerIDNumber: borrower, Sort: domain.LoanSortPrincipalDesc}
+	page, err = repo.List(ctx, filter)
+	require.NoError(t, err)
+	require.Len(t, page.Loans, 3)
+	assert.Equal(t, ids[2], page.Loans[0].ID)
+	assert.Equal(t, ids[0], page.Loans[2].ID)
+}
@@ -0,0 +1,119 @@
+package repositorytest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// LoanEventStore runs the LoanEventStore contract. newRepos returns an event
+// store and the loan repository whose loans it records, sharing one store.
+func LoanEventStore(t *testing.T, newRepos func(t *testing.T) (repository.LoanRepository, repository.LoanEventStore)) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, loans repository.LoanRepository, events repository.LoanEventStore)
+	}{
+		{"AppendNumbersAndLoads", testEventsAppendAndLoad},
+		{"LoadAsOf", testEventsLoadAsOf},
+		{"UnknownLoan", testEventsUnknownLoan},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			loans, events := newRepos(t)
+			c.run(t, loans, events)
+		})
+	}
+}
+
+func changeRecord(t *testing.T, loan *domain.Loan, version int64, change domain.LoanChange, offset time.Duration) *domain.LoanChangeRecord {
+	t.Helper()
+	loan.Version = version
+	r, err := domain.NewLoanChangeRecord(loan, change, at.Add(offset))
+	require.NoError(t, err)
+	return r
+}
+
+func testEventsAppendAndLoad(t *testing.T, loans repository.LoanRepository, events repository.LoanEventStore) {
+	ctx := context.Background()
+	loan := createLoan(t, loans, domain.LoanStateProposed, 500000)
+
+	created := changeRecord(t, loan, 1, &domain.LoanCreated{
+		BorrowerIDNumber: loan.BorrowerIDNumber,
+		PrincipalAmount:  loan.PrincipalAmount,
+		Rate:             loan.Rate,
+		ROI:              loan.ROI,
+	}, 0)
+	approved := changeRecord(t, loan, 2, &domain.LoanApproved{ApprovalDetails: domain.ApprovalDetails{
+		FieldValidatorID: "validator-1",
+		ProofImageURL:    "loans/proof.jpg",
+		ApprovedAt:       at.Add(time.Minute),
+	}}, time.Minute)
+	invested := changeRecord(t, loan, 3, &domain.InvestmentAdded{Investment: *newInvestment(loan.ID, 500000, 2*time.Minute)}, 2*time.Minute)
+	funded := changeRecord(t, loan, 3, &domain.LoanFullyInvested{}, 2*time.Minute)
+
+	require.NoError(t, events.Append(ctx, created, approved))
+	require.NoError(t, events.Append(ctx, invested, funded))
+	for i, r := range []*domain.LoanChangeRecord{created, approved, invested, funded} {
+		assert.Equal(t, int64(i+1), r.Sequence, r.Type)
+	}
+
+	got, err := events.Load(ctx, loan.ID, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, got, 4)
+	for i, want := range []*domain.LoanChangeRecord{created, approved, invested, funded} {
+		assert.Equal(t, want.LoanID, got[i].LoanID)
+		assert.Equal(t, want.Sequence, got[i].Sequence)
+		assert.Equal(t, want.Version, got[i].Version)
+		assert.Equal(t, want.Type, got[i].Type)
+		assert.JSONEq(t, string(want.Data), string(got[i].Data))
+		assert.True(t, want.OccurredAt.Equal(got[i].OccurredAt), "occurred_at %s", got[i].OccurredAt)
+	}
+
+	rebuilt, err := domain.FoldLoan(got)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateInvested, rebuilt.State)
+	assert.Equal(t, int64(3), rebuilt.Version)
+}
+
+func testEventsLoadAsOf(t *testing.T, loans repository.LoanRepository, events repository.LoanEventStore) {
+	ctx := context.Background()
+	loan := createLoan(t, loans, domain.LoanStateProposed, 500000)
+
+	var records []*domain.LoanChangeRecord
+	for i, url := range []string{"agreement-a", "agreement-b", "agreement-c"} {
+		records = append(records, changeRecord(t, loan, int64(i+1),
+			&domain.AgreementLetterAttached{AgreementLetterURL: url}, time.Duration(i)*time.Hour))
+	}
+	require.NoError(t, events.Append(ctx, records...))
+
+	got, err := events.Load(ctx, loan.ID, at.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	var last domain.AgreementLetterAttached
+	require.NoError(t, json.Unmarshal(got[1].Data, &last))
+	assert.Equal(t, "agreement-b", last.AgreementLetterURL)
+
+	got, err = events.Load(ctx, loan.ID, at.Add(-time.Second))
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func testEventsUnknownLoan(t *testing.T, _ repository.LoanRepository, events repository.LoanEventStore) {
+	ctx := context.Background()
+	missing := &domain.Loan{ID: uuid.New()}
+
+	err := events.Append(ctx, changeRecord(t, missing, 1, &domain.LoanFullyInvested{}, 0))
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	got, err := events.Load(ctx, missing.ID, time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
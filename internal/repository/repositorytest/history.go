@@ -0,0 +1,104 @@
+package repositorytest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// LoanHistoryRepository runs the LoanHistoryRepository contract. newRepos
+// returns a history repository and the loan repository whose loans it
+// records, sharing one store.
+func LoanHistoryRepository(t *testing.T, newRepos func(t *testing.T) (repository.LoanRepository, repository.LoanHistoryRepository)) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, loans repository.LoanRepository, history repository.LoanHistoryRepository)
+	}{
+		{"AppendAndList", testHistoryAppendAndList},
+		{"DuplicateVersion", testHistoryDuplicateVersion},
+		{"UnknownLoan", testHistoryUnknownLoan},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			loans, history := newRepos(t)
+			c.run(t, loans, history)
+		})
+	}
+}
+
+func historyEntry(loan *domain.Loan, version int64, event domain.LoanEvent, from, to domain.LoanState) *domain.LoanHistoryEntry {
+	return &domain.LoanHistoryEntry{
+		LoanID:     loan.ID,
+		Version:    version,
+		Event:      event,
+		FromState:  from,
+		ToState:    to,
+		OccurredAt: at.Add(time.Duration(version) * time.Minute),
+	}
+}
+
+func testHistoryAppendAndList(t *testing.T, loans repository.LoanRepository, history repository.LoanHistoryRepository) {
+	ctx := context.Background()
+	loan := createLoan(t, loans, domain.LoanStateProposed, 500000)
+
+	created := historyEntry(loan, 1, domain.LoanEventCreate, "", domain.LoanStateProposed)
+	approved := historyEntry(loan, 2, domain.LoanEventApprove, domain.LoanStateProposed, domain.LoanStateApproved)
+	approved.ActorID = "validator-1"
+	approved.RequestID = "host/abc-000001"
+	approved.Payload = json.RawMessage(`{"field_validator_id":"validator-1","proof_image_url":"loans/proof.jpg"}`)
+	invested := historyEntry(loan, 3, domain.LoanEventInvest, domain.LoanStateApproved, domain.LoanStateApproved)
+
+	// Appended out of order, listed by version.
+	require.NoError(t, history.Append(ctx, created, invested))
+	require.NoError(t, history.Append(ctx, approved))
+
+	got, err := history.List(ctx, loan.ID)
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	for i, want := range []*domain.LoanHistoryEntry{created, approved, invested} {
+		assert.Equal(t, want.LoanID, got[i].LoanID)
+		assert.Equal(t, want.Version, got[i].Version)
+		assert.Equal(t, want.Event, got[i].Event)
+		assert.Equal(t, want.FromState, got[i].FromState)
+		assert.Equal(t, want.ToState, got[i].ToState)
+		assert.Equal(t, want.ActorID, got[i].ActorID)
+		assert.Equal(t, want.RequestID, got[i].RequestID)
+		assert.True(t, want.OccurredAt.Equal(got[i].OccurredAt), "occurred_at %s", got[i].OccurredAt)
+	}
+	assert.Empty(t, got[0].Payload)
+	assert.JSONEq(t, string(approved.Payload), string(got[1].Payload))
+}
+
+func testHistoryDuplicateVersion(t *testing.T, loans repository.LoanRepository, history repository.LoanHistoryRepository) {
+	ctx := context.Background()
+	loan := createLoan(t, loans, domain.LoanStateProposed, 500000)
+	require.NoError(t, history.Append(ctx, historyEntry(loan, 1, domain.LoanEventCreate, "", domain.LoanStateProposed)))
+
+	err := history.Append(ctx, historyEntry(loan, 1, domain.LoanEventApprove, domain.LoanStateProposed, domain.LoanStateApproved))
+	assert.ErrorIs(t, err, domain.ErrConflict)
+
+	got, err := history.List(ctx, loan.ID)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, domain.LoanEventCreate, got[0].Event)
+}
+
+func testHistoryUnknownLoan(t *testing.T, _ repository.LoanRepository, history repository.LoanHistoryRepository) {
+	ctx := context.Background()
+	missing := &domain.Loan{ID: uuid.New()}
+
+	err := history.Append(ctx, historyEntry(missing, 1, domain.LoanEventCreate, "", domain.LoanStateProposed))
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	got, err := history.List(ctx, missing.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
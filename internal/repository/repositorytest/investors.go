@@ -0,0 +1,266 @@
+package repositorytest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// InvestorRepository runs the InvestorRepository contract. newRepos returns
+// an investor repository and the loan repository holding its investors'
+// investments, sharing one store.
+func InvestorRepository(t *testing.T, newRepos func(t *testing.T) (repository.LoanRepository, repository.InvestorRepository)) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, loans repository.LoanRepository, investors repository.InvestorRepository)
+	}{
+		{"CreateAndGet", testInvestorCreateAndGet},
+		{"CreateDuplicateEmail", testInvestorCreateDuplicateEmail},
+		{"CreateInvalidLimits", testInvestorCreateInvalidLimits},
+		{"GetNotFound", testInvestorGetNotFound},
+		{"Update", testInvestorUpdate},
+		{"UpdateNotFound", testInvestorUpdateNotFound},
+		{"ListByStatus", testInvestorListByStatus},
+		{"Delete", testInvestorDelete},
+		{"DeleteWithInvestments", testInvestorDeleteWithInvestments},
+		{"TotalInvested", testInvestorTotalInvested},
+		{"Holdings", testInvestorHoldings},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			loans, investors := newRepos(t)
+			c.run(t, loans, investors)
+		})
+	}
+}
+
+func createInvestor(t *testing.T, repo repository.InvestorRepository, status domain.InvestorStatus, offset time.Duration) *domain.Investor {
+	t.Helper()
+	perLoan := domain.NewMoney(5000000, "IDR")
+	i := &domain.Investor{
+		ID: uuid.New(),
+		InvestorDetails: domain.InvestorDetails{
+			Name:   "Budi Santoso",
+			Email:  "budi-" + uuid.NewString() + "@example.com",
+			Limits: domain.InvestmentLimits{PerLoan: &perLoan},
+		},
+		KYCStatus: domain.KYCStatusVerified,
+		Status:    status,
+		CreatedAt: at.Add(offset),
+		UpdatedAt: at.Add(offset),
+	}
+	require.NoError(t, repo.Create(context.Background(), i))
+	return i
+}
+
+func testInvestorCreateAndGet(t *testing.T, _ repository.LoanRepository, repo repository.InvestorRepository) {
+	ctx := context.Background()
+	i := createInvestor(t, repo, domain.InvestorStatusActive, 0)
+
+	got, err := repo.GetByID(ctx, i.ID)
+	require.NoError(t, err)
+	assert.Equal(t, i.InvestorDetails, got.InvestorDetails)
+	assert.Nil(t, got.Limits.Total)
+	assert.Equal(t, domain.KYCStatusVerified, got.KYCStatus)
+	assert.Equal(t, domain.InvestorStatusActive, got.Status)
+	assert.True(t, i.CreatedAt.Equal(got.CreatedAt))
+
+	got, err = repo.GetForUpdate(ctx, i.ID)
+	require.NoError(t, err)
+	assert.Equal(t, i.ID, got.ID)
+}
+
+func testInvestorCreateDuplicateEmail(t *testing.T, _ repository.LoanRepository, repo repository.InvestorRepository) {
+	i := createInvestor(t, repo, domain.InvestorStatusActive, 0)
+
+	dup := *i
+	dup.ID = uuid.New()
+	dup.Email = strings.ToUpper(i.Email)
+	assert.ErrorIs(t, repo.Create(context.Background(), &dup), domain.ErrAlreadyExists)
+
+	// Investors without an email address do not clash.
+	for range 2 {
+		blank := *i
+		blank.ID = uuid.New()
+		blank.Email = ""
+		assert.NoError(t, repo.Create(context.Background(), &blank))
+	}
+}
+
+func testInvestorCreateInvalidLimits(t *testing.T, _ repository.LoanRepository, repo repository.InvestorRepository) {
+	zero := domain.NewMoney(0, "IDR")
+	i := &domain.Investor{
+		ID:              uuid.New(),
+		InvestorDetails: domain.InvestorDetails{Limits: domain.InvestmentLimits{Total: &zero}},
+		KYCStatus:       domain.KYCStatusVerified,
+		Status:          domain.InvestorStatusActive,
+		CreatedAt:       at,
+		UpdatedAt:       at,
+	}
+	assert.ErrorIs(t, repo.Create(context.Background(), i), domain.ErrValidation)
+
+	i.Limits.Total = nil
+	i.Status = "RETIRED"
+	assert.ErrorIs(t, repo.Create(context.Background(), i), domain.ErrValidation)
+}
+
+func testInvestorGetNotFound(t *testing.T, _ repository.LoanRepository, repo repository.InvestorRepository) {
+	_, err := repo.GetByID(context.Background(), uuid.New())
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func testInvestorUpdate(t *testing.T, _ repository.LoanRepository, repo repository.InvestorRepository) {
+	ctx := context.Background()
+	i := createInvestor(t, repo, domain.InvestorStatusActive, 0)
+
+	total := domain.NewMoney(20000000, "IDR")
+	i.Limits = domain.InvestmentLimits{Total: &total}
+	i.Status = domain.InvestorStatusSuspended
+	i.UpdatedAt = at.Add(time.Hour)
+	require.NoError(t, repo.Update(ctx, i))
+
+	got, err := repo.GetByID(ctx, i.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.Limits.PerLoan)
+	if assert.NotNil(t, got.Limits.Total) {
+		assert.Equal(t, total, *got.Limits.Total)
+	}
+	assert.Equal(t, domain.InvestorStatusSuspended, got.Status)
+	assert.True(t, at.Add(time.Hour).Equal(got.UpdatedAt))
+	assert.True(t, at.Equal(got.CreatedAt))
+
+	other := createInvestor(t, repo, domain.InvestorStatusActive, 0)
+	i.Email = other.Email
+	assert.ErrorIs(t, repo.Update(ctx, i), domain.ErrAlreadyExists)
+}
+
+func testInvestorUpdateNotFound(t *testing.T, _ repository.LoanRepository, repo repository.InvestorRepository) {
+	i := &domain.Investor{ID: uuid.New(), KYCStatus: domain.KYCStatusPending, Status: domain.InvestorStatusActive, UpdatedAt: at}
+	assert.ErrorIs(t, repo.Update(context.Background(), i), domain.ErrNotFound)
+}
+
+func testInvestorListByStatus(t *testing.T, _ repository.LoanRepository, repo repository.InvestorRepository) {
+	// Far in the future so they list first even if the store is shared.
+	future := 1000 * 24 * time.Hour
+	older := createInvestor(t, repo, domain.InvestorStatusSuspended, future)
+	newer := createInvestor(t, repo, domain.InvestorStatusSuspended, future+time.Minute)
+	createInvestor(t, repo, domain.InvestorStatusActive, future+2*time.Minute)
+
+	got, err := repo.List(context.Background(), domain.InvestorStatusSuspended, 2)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, newer.ID, got[0].ID)
+	assert.Equal(t, older.ID, got[1].ID)
+}
+
+func testInvestorDelete(t *testing.T, _ repository.LoanRepository, repo repository.InvestorRepository) {
+	ctx := context.Background()
+	i := createInvestor(t, repo, domain.InvestorStatusActive, 0)
+
+	require.NoError(t, repo.Delete(ctx, i.ID))
+	_, err := repo.GetByID(ctx, i.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.ErrorIs(t, repo.Delete(ctx, i.ID), domain.ErrNotFound)
+}
+
+func testInvestorDeleteWithInvestments(t *testing.T, loans repository.LoanRepository, repo repository.InvestorRepository) {
+	ctx := context.Background()
+	i := createInvestor(t, repo, domain.InvestorStatusActive, 0)
+	loan := createLoan(t, loans, domain.LoanStateApproved, 500000)
+	inv := newInvestment(loan.ID, 100000, time.Minute)
+	inv.InvestorID = i.ID
+	_, err := loans.AddInvestment(ctx, inv)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, repo.Delete(ctx, i.ID), domain.ErrConflict)
+	_, err = repo.GetByID(ctx, i.ID)
+	assert.NoError(t, err)
+}
+
+func testInvestorTotalInvested(t *testing.T, loans repository.LoanRepository, repo repository.InvestorRepository) {
+	ctx := context.Background()
+	i := createInvestor(t, repo, domain.InvestorStatusActive, 0)
+
+	invest := func(loan *domain.Loan, amount int64) {
+		t.Helper()
+		inv := newInvestment(loan.ID, amount, time.Minute)
+		inv.InvestorID = i.ID
+		inv.Amount.Currency = loan.PrincipalAmount.Currency
+		_, err := loans.AddInvestment(ctx, inv)
+		require.NoError(t, err)
+	}
+
+	first := createLoan(t, loans, domain.LoanStateApproved, 500000)
+	invest(first, 100000)
+	invest(first, 50000)
+	invest(createLoan(t, loans, domain.LoanStateApproved, 500000), 200000)
+	// Voided investments do not count.
+	voided := createLoan(t, loans, domain.LoanStateApproved, 500000)
+	invest(voided, 300000)
+	_, err := loans.VoidInvestments(ctx, voided.ID, at.Add(time.Hour))
+	require.NoError(t, err)
+	// Someone else's investment does not count.
+	_, err = loans.AddInvestment(ctx, newInvestment(first.ID, 10000, time.Minute))
+	require.NoError(t, err)
+
+	total, err := repo.TotalInvested(ctx, i.ID, "IDR")
+	require.NoError(t, err)
+	assert.Equal(t, domain.NewMoney(350000, "IDR"), total)
+
+	total, err = repo.TotalInvested(ctx, i.ID, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, domain.NewMoney(0, "USD"), total)
+}
+
+func testInvestorHoldings(t *testing.T, loans repository.LoanRepository, repo repository.InvestorRepository) {
+	ctx := context.Background()
+	i := createInvestor(t, repo, domain.InvestorStatusActive, 0)
+
+	approved := createLoan(t, loans, domain.LoanStateApproved, 500000)
+	voided := createLoan(t, loans, domain.LoanStateApproved, 400000)
+	invest := func(loan *domain.Loan, amount int64, offset time.Duration) *domain.Investment {
+		t.Helper()
+		inv := newInvestment(loan.ID, amount, offset)
+		inv.InvestorID = i.ID
+		_, err := loans.AddInvestment(ctx, inv)
+		require.NoError(t, err)
+		return inv
+	}
+	second := invest(approved, 50000, 2*time.Minute)
+	first := invest(voided, 100000, time.Minute)
+	_, err := loans.VoidInvestments(ctx, voided.ID, at.Add(time.Hour))
+	require.NoError(t, err)
+	_, err = loans.AddInvestment(ctx, newInvestment(approved.ID, 10000, 0))
+	require.NoError(t, err)
+
+	got, err := repo.Holdings(ctx, i.ID)
+	require.NoError(t, err)
+	require.Len(t, got, 2, "only the investor's investments")
+
+	assert.Equal(t, first.ID, got[0].Investment.ID, "oldest first")
+	assert.Equal(t, domain.NewMoney(100000, "IDR"), got[0].Investment.Amount)
+	assert.Equal(t, domain.NewMoney(400000, "IDR"), got[0].LoanPrincipal)
+	if assert.NotNil(t, got[0].Investment.VoidedAt) {
+		assert.True(t, at.Add(time.Hour).Equal(*got[0].Investment.VoidedAt))
+	}
+
+	assert.Equal(t, second.ID, got[1].Investment.ID)
+	assert.Equal(t, approved.ID, got[1].Investment.LoanID)
+	assert.Equal(t, i.ID, got[1].Investment.InvestorID)
+	assert.Equal(t, domain.LoanStateApproved, got[1].LoanState)
+	assert.Equal(t, approved.ROI, got[1].LoanROI)
+	assert.True(t, second.CreatedAt.Equal(got[1].Investment.CreatedAt))
+	assert.Nil(t, got[1].Investment.VoidedAt)
+
+	got, err = repo.Holdings(ctx, uuid.New())
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
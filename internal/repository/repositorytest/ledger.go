@@ -0,0 +1,193 @@
+package repositorytest
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// LedgerRepository runs the LedgerRepository contract. newRepos returns a
+// ledger repository and the loan repository whose loans entries are posted
+// for, sharing one store.
+func LedgerRepository(t *testing.T, newRepos func(t *testing.T) (repository.LoanRepository, repository.LedgerRepository)) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, loans repository.LoanRepository, ledger repository.LedgerRepository)
+	}{
+		{"PostAndEntries", testLedgerPostAndEntries},
+		{"Balances", testLedgerBalances},
+		{"PostedOnce", testLedgerPostedOnce},
+		{"UnknownLoan", testLedgerUnknownLoan},
+		{"MustBalance", testLedgerMustBalance},
+		{"DepositsAndWithdrawals", testLedgerDepositsAndWithdrawals},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			loans, ledger := newRepos(t)
+			c.run(t, loans, ledger)
+		})
+	}
+}
+
+// postFunding posts the investments of a loan from fundedLoan and its
+// disbursement.
+func postFunding(t *testing.T, ledger repository.LedgerRepository, loan *domain.Loan, investments []*domain.Investment) []*domain.JournalEntry {
+	t.Helper()
+	var entries []*domain.JournalEntry
+	for _, inv := range investments {
+		e, err := domain.InvestmentEntry(*inv)
+		require.NoError(t, err)
+		entries = append(entries, e)
+	}
+	e, err := domain.DisbursementEntry(loan, at.Add(time.Hour))
+	require.NoError(t, err)
+	entries = append(entries, e)
+	require.NoError(t, ledger.Post(context.Background(), entries...))
+	return entries
+}
+
+func testLedgerPostAndEntries(t *testing.T, loans repository.LoanRepository, ledger repository.LedgerRepository) {
+	ctx := context.Background()
+	loan, investments := fundedLoan(t, loans)
+	other, otherInvestments := fundedLoan(t, loans)
+
+	// Posted out of order, read back by when they were posted.
+	funding := postFunding(t, ledger, loan, investments)
+	repayment, err := domain.RepaymentEntry(loan, newRepayment(loan.ID, at.Add(24*time.Hour), investments...))
+	require.NoError(t, err)
+	refund, err := domain.RefundEntry(*investments[0], at.Add(30*time.Second))
+	require.NoError(t, err)
+	require.NoError(t, ledger.Post(ctx, repayment, refund))
+	postFunding(t, ledger, other, otherInvestments)
+
+	got, err := ledger.Entries(ctx, loan.ID)
+	require.NoError(t, err)
+	want := []*domain.JournalEntry{refund, funding[0], funding[1], funding[2], repayment}
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.True(t, want[i].PostedAt.Equal(got[i].PostedAt))
+		got[i].PostedAt = want[i].PostedAt
+		assert.Equal(t, want[i], got[i])
+	}
+
+	none, err := ledger.Entries(ctx, uuid.New())
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func testLedgerBalances(t *testing.T, loans repository.LoanRepository, ledger repository.LedgerRepository) {
+	ctx := context.Background()
+	loan, investments := fundedLoan(t, loans)
+	postFunding(t, ledger, loan, investments)
+	repayment := newRepayment(loan.ID, at.Add(24*time.Hour), investments...)
+	e, err := domain.RepaymentEntry(loan, repayment)
+	require.NoError(t, err)
+	require.NoError(t, ledger.Post(ctx, e))
+
+	balances, err := ledger.Balances(ctx)
+	require.NoError(t, err)
+	assert.True(t, slices.IsSortedFunc(balances, func(a, b domain.AccountBalance) int {
+		return domain.CompareAccounts(a.Account, b.Account)
+	}))
+	assert.True(t, domain.NewTrialBalance(balances).Balanced)
+
+	// Other tests may share the ledger, so only this loan's accounts are
+	// compared.
+	owners := []string{loan.ID.String(), loan.BorrowerIDNumber, investments[0].InvestorID.String(), investments[1].InvestorID.String()}
+	var got []domain.AccountBalance
+	for _, b := range balances {
+		if slices.Contains(owners, b.Account.OwnerID) {
+			got = append(got, b)
+		}
+	}
+	idr := func(v int64) domain.Money { return domain.NewMoney(v, "IDR") }
+	want := []domain.AccountBalance{
+		domain.NewAccountBalance(domain.BorrowerAccount(loan.BorrowerIDNumber, "IDR"), idr(11000), idr(100000)),
+		domain.NewAccountBalance(domain.InvestorWalletAccount(investments[0].InvestorID, "IDR"), idr(60000), idr(6300)),
+		domain.NewAccountBalance(domain.InvestorWalletAccount(investments[1].InvestorID, "IDR"), idr(40000), idr(4200)),
+		domain.NewAccountBalance(domain.LoanFundingAccount(loan.ID, "IDR"), idr(10000), idr(100000)),
+		domain.NewAccountBalance(domain.LoanReceivableAccount(loan.ID, "IDR"), idr(100000), idr(10000)),
+	}
+	slices.SortFunc(want, func(a, b domain.AccountBalance) int {
+		return domain.CompareAccounts(a.Account, b.Account)
+	})
+	assert.Equal(t, want, got)
+	assert.Equal(t, idr(90000), got[len(got)-1].Balance, "receivable is what the borrower still owes")
+}
+
+func testLedgerPostedOnce(t *testing.T, loans repository.LoanRepository, ledger repository.LedgerRepository) {
+	ctx := context.Background()
+	loan, investments := fundedLoan(t, loans)
+	first, err := domain.InvestmentEntry(*investments[0])
+	require.NoError(t, err)
+	again, err := domain.InvestmentEntry(*investments[0])
+	require.NoError(t, err)
+	second, err := domain.InvestmentEntry(*investments[1])
+	require.NoError(t, err)
+
+	require.NoError(t, ledger.Post(ctx, first))
+	assert.ErrorIs(t, ledger.Post(ctx, second, again), domain.ErrConflict)
+
+	got, err := ledger.Entries(ctx, loan.ID)
+	require.NoError(t, err)
+	require.Len(t, got, 1, "entries are posted together or not at all")
+	assert.Equal(t, first.ID, got[0].ID)
+}
+
+func testLedgerUnknownLoan(t *testing.T, _ repository.LoanRepository, ledger repository.LedgerRepository) {
+	e, err := domain.InvestmentEntry(*newInvestment(uuid.New(), 1000, 0))
+	require.NoError(t, err)
+	assert.ErrorIs(t, ledger.Post(context.Background(), e), domain.ErrNotFound)
+}
+
+func testLedgerMustBalance(t *testing.T, loans repository.LoanRepository, ledger repository.LedgerRepository) {
+	ctx := context.Background()
+	loan, investments := fundedLoan(t, loans)
+	e, err := domain.InvestmentEntry(*investments[0])
+	require.NoError(t, err)
+	e.Lines[1].Amount = domain.NewMoney(50000, "IDR")
+
+	assert.ErrorIs(t, ledger.Post(ctx, e), domain.ErrValidation)
+	got, err := ledger.Entries(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+	unbalanced, err := ledger.UnbalancedEntries(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, unbalanced)
+}
+
+func testLedgerDepositsAndWithdrawals(t *testing.T, loans repository.LoanRepository, ledger repository.LedgerRepository) {
+	ctx := context.Background()
+	investorID := uuid.New()
+	idr := func(v int64) domain.Money { return domain.NewMoney(v, "IDR") }
+	deposit, err := domain.DepositEntry(investorID, uuid.New(), idr(50000), at)
+	require.NoError(t, err)
+	withdrawal, err := domain.WithdrawalEntry(investorID, uuid.New(), idr(20000), at.Add(time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, ledger.Post(ctx, deposit, withdrawal))
+
+	balances, err := ledger.Balances(ctx)
+	require.NoError(t, err)
+	wallet := domain.InvestorWalletAccount(investorID, "IDR")
+	i := slices.IndexFunc(balances, func(b domain.AccountBalance) bool { return b.Account == wallet })
+	require.GreaterOrEqual(t, i, 0)
+	assert.Equal(t, domain.NewAccountBalance(wallet, idr(20000), idr(50000)), balances[i])
+
+	// Only deposits and withdrawals are for no loan.
+	loan, _ := fundedLoan(t, loans)
+	stray, err := domain.DepositEntry(investorID, uuid.New(), idr(1000), at)
+	require.NoError(t, err)
+	stray.LoanID = loan.ID
+	assert.ErrorIs(t, ledger.Post(ctx, stray), domain.ErrValidation)
+	got, err := ledger.Entries(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
@@ -0,0 +1,119 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RepaymentRepository runs the RepaymentRepository contract. newRepos
+// returns a repayment repository and the loan repository whose loans are
+// repaid, sharing one store.
+func RepaymentRepository(t *testing.T, newRepos func(t *testing.T) (repository.LoanRepository, repository.RepaymentRepository)) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, loans repository.LoanRepository, repayments repository.RepaymentRepository)
+	}{
+		{"CreateAndList", testRepaymentCreateAndList},
+		{"UnknownLoan", testRepaymentUnknownLoan},
+		{"UnknownInvestment", testRepaymentUnknownInvestment},
+		{"AmountsMustAddUp", testRepaymentAmounts},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			loans, repayments := newRepos(t)
+			c.run(t, loans, repayments)
+		})
+	}
+}
+
+// fundedLoan returns a loan with two investments of 60% and 40% of the
+// principal.
+func fundedLoan(t *testing.T, loans repository.LoanRepository) (*domain.Loan, []*domain.Investment) {
+	t.Helper()
+	loan := createLoan(t, loans, domain.LoanStateApproved, 100000)
+	investments := []*domain.Investment{newInvestment(loan.ID, 60000, time.Minute), newInvestment(loan.ID, 40000, 2*time.Minute)}
+	for _, inv := range investments {
+		_, err := loans.AddInvestment(context.Background(), inv)
+		require.NoError(t, err)
+	}
+	return loan, investments
+}
+
+func newRepayment(loanID uuid.UUID, paidAt time.Time, investments ...*domain.Investment) *domain.Repayment {
+	idr := func(v int64) domain.Money { return domain.NewMoney(v, "IDR") }
+	r := &domain.Repayment{
+		ID:          uuid.New(),
+		LoanID:      loanID,
+		Amount:      idr(11000),
+		Interest:    idr(1000),
+		Principal:   idr(10000),
+		LateFee:     idr(0),
+		PlatformFee: idr(500),
+		Payouts:     []domain.Payout{},
+		PaidAt:      paidAt,
+	}
+	for i, inv := range investments {
+		principal := idr(6000)
+		interest := idr(300)
+		if i > 0 {
+			principal, interest = idr(4000), idr(200)
+		}
+		r.Payouts = append(r.Payouts, domain.NewPayout(inv.ID, inv.InvestorID, principal, interest))
+	}
+	return r
+}
+
+func testRepaymentCreateAndList(t *testing.T, loans repository.LoanRepository, repayments repository.RepaymentRepository) {
+	ctx := context.Background()
+	loan, investments := fundedLoan(t, loans)
+	other, otherInvestments := fundedLoan(t, loans)
+
+	second := newRepayment(loan.ID, at.Add(48*time.Hour), investments...)
+	first := newRepayment(loan.ID, at.Add(24*time.Hour), investments...)
+	require.NoError(t, repayments.Create(ctx, second))
+	require.NoError(t, repayments.Create(ctx, first))
+	require.NoError(t, repayments.Create(ctx, newRepayment(other.ID, at, otherInvestments...)))
+
+	got, err := repayments.List(ctx, loan.ID)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	for i, want := range []*domain.Repayment{first, second} {
+		assert.True(t, want.PaidAt.Equal(got[i].PaidAt))
+		got[i].PaidAt = want.PaidAt
+		assert.Equal(t, want, got[i])
+	}
+
+	none, err := repayments.List(ctx, uuid.New())
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func testRepaymentUnknownLoan(t *testing.T, _ repository.LoanRepository, repayments repository.RepaymentRepository) {
+	assert.ErrorIs(t, repayments.Create(context.Background(), newRepayment(uuid.New(), at)), domain.ErrNotFound)
+}
+
+func testRepaymentUnknownInvestment(t *testing.T, loans repository.LoanRepository, repayments repository.RepaymentRepository) {
+	ctx := context.Background()
+	loan, investments := fundedLoan(t, loans)
+	unknown := newInvestment(loan.ID, 1, 0)
+
+	assert.ErrorIs(t, repayments.Create(ctx, newRepayment(loan.ID, at, investments[0], unknown)), domain.ErrNotFound)
+	got, err := repayments.List(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got, "the repayment is written with its payouts or not at all")
+}
+
+func testRepaymentAmounts(t *testing.T, loans repository.LoanRepository, repayments repository.RepaymentRepository) {
+	loan, _ := fundedLoan(t, loans)
+	r := newRepayment(loan.ID, at)
+	r.Amount = domain.NewMoney(12000, "IDR")
+	assert.ErrorIs(t, repayments.Create(context.Background(), r), domain.ErrValidation)
+}
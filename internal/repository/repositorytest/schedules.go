@@ -0,0 +1,111 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RepaymentScheduleRepository runs the RepaymentScheduleRepository
+// contract. newRepos returns a schedule repository and the loan repository
+// whose loans it schedules, sharing one store.
+func RepaymentScheduleRepository(t *testing.T, newRepos func(t *testing.T) (repository.LoanRepository, repository.RepaymentScheduleRepository)) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, loans repository.LoanRepository, schedules repository.RepaymentScheduleRepository)
+	}{
+		{"SaveAndList", testScheduleSaveAndList},
+		{"SaveTwice", testScheduleSaveTwice},
+		{"Update", testScheduleUpdate},
+		{"UnknownLoan", testScheduleUnknownLoan},
+		{"NoSchedule", testScheduleNone},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			loans, schedules := newRepos(t)
+			c.run(t, loans, schedules)
+		})
+	}
+}
+
+func installments(n int) []domain.Installment {
+	var out []domain.Installment
+	for i := 1; i <= n; i++ {
+		out = append(out, domain.NewInstallment(i, time.Date(2025, time.Month(3+i), 1, 0, 0, 0, 0, time.UTC),
+			domain.NewMoney(int64(100000*i), "IDR"), domain.NewMoney(5025, "IDR")))
+	}
+	return out
+}
+
+func testScheduleSaveAndList(t *testing.T, loans repository.LoanRepository, schedules repository.RepaymentScheduleRepository) {
+	ctx := context.Background()
+	loan := createLoan(t, loans, domain.LoanStateDisbursed, 600000)
+	other := createLoan(t, loans, domain.LoanStateDisbursed, 100000)
+
+	want := installments(3)
+	require.NoError(t, schedules.Save(ctx, loan.ID, want))
+	require.NoError(t, schedules.Save(ctx, other.ID, installments(1)))
+
+	got, err := schedules.List(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func testScheduleSaveTwice(t *testing.T, loans repository.LoanRepository, schedules repository.RepaymentScheduleRepository) {
+	ctx := context.Background()
+	loan := createLoan(t, loans, domain.LoanStateDisbursed, 600000)
+	require.NoError(t, schedules.Save(ctx, loan.ID, installments(3)))
+
+	assert.ErrorIs(t, schedules.Save(ctx, loan.ID, installments(2)), domain.ErrConflict)
+	got, err := schedules.List(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Len(t, got, 3)
+}
+
+func testScheduleUpdate(t *testing.T, loans repository.LoanRepository, schedules repository.RepaymentScheduleRepository) {
+	ctx := context.Background()
+	loan := createLoan(t, loans, domain.LoanStateDisbursed, 600000)
+	want := installments(3)
+	require.NoError(t, schedules.Save(ctx, loan.ID, want))
+
+	settledAt := at.Add(time.Hour)
+	want[0].LateFee = domain.NewMoney(5251, "IDR")
+	want[0].PaidInterest = want[0].Interest
+	want[0].PaidPrincipal = want[0].Principal
+	want[0].PaidLateFee = want[0].LateFee
+	want[0].SettledAt = &settledAt
+	want[1].PaidInterest = domain.NewMoney(25, "IDR")
+	require.NoError(t, schedules.Update(ctx, loan.ID, want[:2]))
+
+	got, err := schedules.List(ctx, loan.ID)
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	assert.True(t, settledAt.Equal(*got[0].SettledAt))
+	got[0].SettledAt = &settledAt
+	assert.Equal(t, want, got)
+
+	overpaid := want[1]
+	overpaid.PaidPrincipal = overpaid.Principal.Add(domain.NewMoney(1, "IDR"))
+	assert.ErrorIs(t, schedules.Update(ctx, loan.ID, []domain.Installment{overpaid}), domain.ErrValidation)
+
+	missing := installments(4)[3]
+	assert.ErrorIs(t, schedules.Update(ctx, loan.ID, []domain.Installment{missing}), domain.ErrNotFound)
+}
+
+func testScheduleUnknownLoan(t *testing.T, _ repository.LoanRepository, schedules repository.RepaymentScheduleRepository) {
+	assert.ErrorIs(t, schedules.Save(context.Background(), uuid.New(), installments(1)), domain.ErrNotFound)
+}
+
+func testScheduleNone(t *testing.T, loans repository.LoanRepository, schedules repository.RepaymentScheduleRepository) {
+	loan := createLoan(t, loans, domain.LoanStateProposed, 600000)
+	got, err := schedules.List(context.Background(), loan.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
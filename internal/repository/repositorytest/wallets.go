@@ -0,0 +1,187 @@
+package repositorytest
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// WalletRepository runs the WalletRepository contract. newRepos returns a
+// wallet repository, the investor repository holding its wallets' owners
+// and a transactor, sharing one store.
+func WalletRepository(t *testing.T, newRepos func(t *testing.T) (repository.InvestorRepository, repository.WalletRepository, repository.Transactor)) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, investors repository.InvestorRepository, wallets repository.WalletRepository, tx repository.Transactor)
+	}{
+		{"OpenSaveAndList", testWalletOpenSaveAndList},
+		{"UnknownInvestor", testWalletUnknownInvestor},
+		{"SaveUnopened", testWalletSaveUnopened},
+		{"NegativeBalance", testWalletNegativeBalance},
+		{"ConcurrentReservationsCannotOverdraw", testWalletConcurrentReservations},
+		{"RolledBack", testWalletRolledBack},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			investors, wallets, tx := newRepos(t)
+			c.run(t, investors, wallets, tx)
+		})
+	}
+}
+
+// depositWallet opens the investor's IDR wallet with amount available.
+func depositWallet(t *testing.T, wallets repository.WalletRepository, investorID uuid.UUID, amount int64) *domain.Wallet {
+	t.Helper()
+	ctx := context.Background()
+	w, err := wallets.GetForUpdate(ctx, investorID, "IDR")
+	require.NoError(t, err)
+	require.NoError(t, w.Deposit(domain.NewMoney(amount, "IDR"), at))
+	require.NoError(t, wallets.Save(ctx, w))
+	return w
+}
+
+func testWalletOpenSaveAndList(t *testing.T, investors repository.InvestorRepository, wallets repository.WalletRepository, _ repository.Transactor) {
+	ctx := context.Background()
+	i := createInvestor(t, investors, domain.InvestorStatusActive, 0)
+
+	none, err := wallets.List(ctx, i.ID)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	usd, err := wallets.GetForUpdate(ctx, i.ID, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, domain.NewMoney(0, "USD"), usd.Available)
+	assert.Equal(t, domain.NewMoney(0, "USD"), usd.Reserved)
+
+	idr := depositWallet(t, wallets, i.ID, 50000)
+	require.NoError(t, idr.Reserve(domain.NewMoney(20000, "IDR"), at))
+	require.NoError(t, wallets.Save(ctx, idr))
+
+	got, err := wallets.List(ctx, i.ID)
+	require.NoError(t, err)
+	require.Len(t, got, 2, "ordered by currency")
+	assert.Equal(t, "IDR", got[0].Currency)
+	assert.Equal(t, domain.NewMoney(30000, "IDR"), got[0].Available)
+	assert.Equal(t, domain.NewMoney(20000, "IDR"), got[0].Reserved)
+	assert.True(t, at.Equal(got[0].UpdatedAt))
+	assert.Equal(t, "USD", got[1].Currency)
+
+	all, err := wallets.All(ctx)
+	require.NoError(t, err)
+	assert.True(t, slices.IsSortedFunc(all, func(a, b *domain.Wallet) int {
+		return cmp.Or(bytes.Compare(a.InvestorID[:], b.InvestorID[:]), strings.Compare(a.Currency, b.Currency))
+	}))
+	assert.Len(t, slices.DeleteFunc(all, func(w *domain.Wallet) bool { return w.InvestorID != i.ID }), 2)
+
+	again, err := wallets.GetForUpdate(ctx, i.ID, "IDR")
+	require.NoError(t, err)
+	assert.Equal(t, got[0].Available, again.Available, "an open wallet is not reopened")
+}
+
+func testWalletUnknownInvestor(t *testing.T, _ repository.InvestorRepository, wallets repository.WalletRepository, _ repository.Transactor) {
+	_, err := wallets.GetForUpdate(context.Background(), uuid.New(), "IDR")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func testWalletSaveUnopened(t *testing.T, investors repository.InvestorRepository, wallets repository.WalletRepository, _ repository.Transactor) {
+	i := createInvestor(t, investors, domain.InvestorStatusActive, 0)
+	w := domain.NewWallet(i.ID, "IDR", at)
+	assert.ErrorIs(t, wallets.Save(context.Background(), w), domain.ErrNotFound)
+}
+
+func testWalletNegativeBalance(t *testing.T, investors repository.InvestorRepository, wallets repository.WalletRepository, _ repository.Transactor) {
+	ctx := context.Background()
+	i := createInvestor(t, investors, domain.InvestorStatusActive, 0)
+	w := depositWallet(t, wallets, i.ID, 1000)
+
+	w.Available = domain.NewMoney(-1, "IDR")
+	assert.ErrorIs(t, wallets.Save(ctx, w), domain.ErrInsufficientFunds)
+
+	got, err := wallets.List(ctx, i.ID)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, domain.NewMoney(1000, "IDR"), got[0].Available)
+}
+
+func testWalletConcurrentReservations(t *testing.T, investors repository.InvestorRepository, wallets repository.WalletRepository, tx repository.Transactor) {
+	ctx := context.Background()
+	i := createInvestor(t, investors, domain.InvestorStatusActive, 0)
+	depositWallet(t, wallets, i.ID, 5000)
+
+	const attempts = 10
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		reserved int
+		errs     []error
+	)
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := tx.WithinTx(ctx, func(ctx context.Context) error {
+				w, err := wallets.GetForUpdate(ctx, i.ID, "IDR")
+				if err != nil {
+					return err
+				}
+				if err := w.Reserve(domain.NewMoney(1000, "IDR"), at); err != nil {
+					return err
+				}
+				return wallets.Save(ctx, w)
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				reserved++
+			} else if !errors.Is(err, domain.ErrInsufficientFunds) {
+				errs = append(errs, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Empty(t, errs)
+	assert.Equal(t, 5, reserved)
+	got, err := wallets.List(ctx, i.ID)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, domain.NewMoney(0, "IDR"), got[0].Available)
+	assert.Equal(t, domain.NewMoney(5000, "IDR"), got[0].Reserved)
+}
+
+func testWalletRolledBack(t *testing.T, investors repository.InvestorRepository, wallets repository.WalletRepository, tx repository.Transactor) {
+	ctx := context.Background()
+	i := createInvestor(t, investors, domain.InvestorStatusActive, 0)
+	failed := errors.New("failed")
+
+	err := tx.WithinTx(ctx, func(ctx context.Context) error {
+		w, err := wallets.GetForUpdate(ctx, i.ID, "IDR")
+		if err != nil {
+			return err
+		}
+		if err := w.Deposit(domain.NewMoney(1000, "IDR"), at); err != nil {
+			return err
+		}
+		if err := wallets.Save(ctx, w); err != nil {
+			return err
+		}
+		return failed
+	})
+	assert.ErrorIs(t, err, failed)
+
+	got, err := wallets.List(ctx, i.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got, "the wallet opened in the transaction is gone too")
+}
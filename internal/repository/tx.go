@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Transactor runs work in a single database transaction. Repository methods
+// called with the context it hands to fn join that transaction instead of
+// starting their own, so writes across repositories commit or roll back
+// together.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+type transactor struct {
+	db *sql.DB
+}
+
+func NewTransactor(db *sql.DB) Transactor {
+	return &transactor{db: db}
+}
+
+// WithinTx commits when fn returns nil and rolls back otherwise. Nested calls
+// reuse the outer transaction. Constraints checked at commit are reported as
+// domain errors, as they are by the repositories.
+func (t *transactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := inTx(ctx, t.db, func(tx *sql.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+	return dbError(err)
+}
+
+// inTx runs fn in the transaction carried by ctx or, without one, in a new
+// transaction committed when fn succeeds.
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(tx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// conn returns the transaction carried by ctx, or db outside one.
+func conn(ctx context.Context, db *sql.DB) queryer {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
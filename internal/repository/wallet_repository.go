@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type WalletRepository interface {
+	// List returns the investor's wallets ordered by currency. Investors
+	// have no wallet in a currency until money first moves through one.
+	List(ctx context.Context, investorID uuid.UUID) ([]*domain.Wallet, error)
+	// All returns every wallet, ordered by investor ID and currency.
+	All(ctx context.Context) ([]*domain.Wallet, error)
+	// GetForUpdate returns the investor's wallet in currency, opening an
+	// empty one if they have none, and locks it for the rest of the
+	// transaction carried by ctx. It fails with domain.ErrNotFound if the
+	// investor is not registered. Callers locking several wallets lock them
+	// in order of investor ID, after any loan they change.
+	GetForUpdate(ctx context.Context, investorID uuid.UUID, currency string) (*domain.Wallet, error)
+	// Save writes the wallet's balances.
+	Save(ctx context.Context, wallet *domain.Wallet) error
+}
+
+type walletRepository struct {
+	db *sql.DB
+}
+
+func NewWalletRepository(db *sql.DB) WalletRepository {
+	return &walletRepository{db: db}
+}
+
+func (r *walletRepository) List(ctx context.Context, investorID uuid.UUID) ([]*domain.Wallet, error) {
+	query := `
+		SELECT investor_id, currency, available, reserved, updated_at
+		FROM investor_wallets
+		WHERE investor_id = $1
+		ORDER BY currency`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, investorID)
+	if err != nil {
+		return nil, err
+	}
+	return scanWallets(rows)
+}
+
+func (r *walletRepository) All(ctx context.Context) ([]*domain.Wallet, error) {
+	query := `
+		SELECT investor_id, currency, available, reserved, updated_at
+		FROM investor_wallets
+		ORDER BY investor_id, currency`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return scanWallets(rows)
+}
+
+func (r *walletRepository) GetForUpdate(ctx context.Context, investorID uuid.UUID, currency string) (*domain.Wallet, error) {
+	open := `
+		INSERT INTO investor_wallets (investor_id, currency, available, reserved, updated_at)
+		VALUES ($1, $2, 0, 0, $3)
+		ON CONFLICT (investor_id, currency) DO NOTHING`
+	query := `
+		SELECT investor_id, currency, available, reserved, updated_at
+		FROM investor_wallets
+		WHERE investor_id = $1 AND currency = $2
+		FOR UPDATE`
+
+	var wallet *domain.Wallet
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, open, investorID, currency, time.Now()); err != nil {
+			return err
+		}
+		rows, err := tx.QueryContext(ctx, query, investorID, currency)
+		if err != nil {
+			return err
+		}
+		wallets, err := scanWallets(rows)
+		if err != nil {
+			return err
+		}
+		wallet = wallets[0]
+		return nil
+	})
+	if err != nil {
+		return nil, dbError(err)
+	}
+	return wallet, nil
+}
+
+func (r *walletRepository) Save(ctx context.Context, w *domain.Wallet) error {
+	query := `
+		UPDATE investor_wallets
+		SET available = $1, reserved = $2, updated_at = $3
+		WHERE investor_id = $4 AND currency = $5`
+
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, query,
+			w.Available.String(), w.Reserved.String(), w.UpdatedAt, w.InvestorID, w.Currency)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return domain.Errorf(domain.ErrNotFound, "investor %s has no %s wallet", w.InvestorID, w.Currency)
+		}
+		return nil
+	})
+	return dbError(err)
+}
+
+func scanWallets(rows *sql.Rows) ([]*domain.Wallet, error) {
+	defer rows.Close()
+
+	var wallets []*domain.Wallet
+	for rows.Next() {
+		var (
+			w                   domain.Wallet
+			available, reserved string
+		)
+		if err := rows.Scan(&w.InvestorID, &w.Currency, &available, &reserved, &w.UpdatedAt); err != nil {
+			return nil, err
+		}
+		var err error
+		if w.Available, err = domain.ParseMoney(available, w.Currency); err != nil {
+			return nil, err
+		}
+		if w.Reserved, err = domain.ParseMoney(reserved, w.Currency); err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, &w)
+	}
+	return wallets, rows.Err()
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultBorrowerPageSize = 50
+	MaxBorrowerPageSize     = 500
+)
+
+type BorrowerService interface {
+	// RegisterBorrower adds a borrower pending KYC.
+	RegisterBorrower(ctx context.Context, nationalID string, details domain.BorrowerDetails) (*domain.Borrower, error)
+	GetBorrower(ctx context.Context, id uuid.UUID) (*domain.Borrower, error)
+	ListBorrowers(ctx context.Context, status domain.KYCStatus, limit int) ([]*domain.Borrower, error)
+	// UpdateBorrower replaces the borrower's details and, unless status is
+	// empty, their KYC status.
+	UpdateBorrower(ctx context.Context, id uuid.UUID, details domain.BorrowerDetails, status domain.KYCStatus) (*domain.Borrower, error)
+	// DeleteBorrower removes a borrower who has no loans.
+	DeleteBorrower(ctx context.Context, id uuid.UUID) error
+}
+
+type borrowerService struct {
+	repo repository.BorrowerRepository
+}
+
+func NewBorrowerService(repo repository.BorrowerRepository) BorrowerService {
+	return &borrowerService{repo: repo}
+}
+
+func (s *borrowerService) RegisterBorrower(ctx context.Context, nationalID string, details domain.BorrowerDetails) (*domain.Borrower, error) {
+	now := time.Now()
+	b := &domain.Borrower{
+		ID:               uuid.New(),
+		NationalIDNumber: nationalID,
+		BorrowerDetails:  details,
+		KYCStatus:        domain.KYCStatusPending,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if err := s.repo.Create(ctx, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *borrowerService) GetBorrower(ctx context.Context, id uuid.UUID) (*domain.Borrower, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *borrowerService) ListBorrowers(ctx context.Context, status domain.KYCStatus, limit int) ([]*domain.Borrower, error) {
+	if limit <= 0 {
+		limit = DefaultBorrowerPageSize
+	}
+	if limit > MaxBorrowerPageSize {
+		limit = MaxBorrowerPageSize
+	}
+	return s.repo.List(ctx, status, limit)
+}
+
+func (s *borrowerService) UpdateBorrower(ctx context.Context, id uuid.UUID, details domain.BorrowerDetails, status domain.KYCStatus) (*domain.Borrower, error) {
+	b, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	b.BorrowerDetails = details
+	if status != "" {
+		b.KYCStatus = status
+	}
+	b.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *borrowerService) DeleteBorrower(ctx context.Context, id uuid.UUID) error {
+	return s.repo.Delete(ctx, id)
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBorrowerLifecycle(t *testing.T) {
+	store := repository.NewMemoryStore()
+	service := NewBorrowerService(store.Borrowers())
+	ctx := context.Background()
+	details := domain.BorrowerDetails{Name: "Siti", Email: "siti@example.com", Phone: "+62 812", Address: "Jakarta"}
+
+	b, err := service.RegisterBorrower(ctx, "3171-01", details)
+	require.NoError(t, err)
+	assert.Equal(t, domain.KYCStatusPending, b.KYCStatus)
+
+	_, err = service.RegisterBorrower(ctx, "3171-01", details)
+	assert.ErrorIs(t, err, domain.ErrAlreadyExists)
+
+	details.Phone = "+62 813"
+	b, err = service.UpdateBorrower(ctx, b.ID, details, "")
+	require.NoError(t, err)
+	assert.Equal(t, "+62 813", b.Phone)
+	assert.Equal(t, domain.KYCStatusPending, b.KYCStatus, "an empty status leaves KYC alone")
+
+	b, err = service.UpdateBorrower(ctx, b.ID, details, domain.KYCStatusVerified)
+	require.NoError(t, err)
+	assert.Equal(t, domain.KYCStatusVerified, b.KYCStatus)
+
+	verified, err := service.ListBorrowers(ctx, domain.KYCStatusVerified, 0)
+	require.NoError(t, err)
+	require.Len(t, verified, 1)
+	assert.Equal(t, b.ID, verified[0].ID)
+
+	require.NoError(t, service.DeleteBorrower(ctx, b.ID))
+	_, err = service.GetBorrower(ctx, b.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
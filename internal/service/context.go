@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+)
+
+type expectedVersionKey struct{}
+
+// WithExpectedVersion makes loan transitions run with ctx fail with
+// domain.ErrConflict unless the loan is still at version. Handlers use it to
+// honour If-Match.
+func WithExpectedVersion(ctx context.Context, version int64) context.Context {
+	return context.WithValue(ctx, expectedVersionKey{}, version)
+}
+
+func expectedVersion(ctx context.Context) (int64, bool) {
+	version, ok := ctx.Value(expectedVersionKey{}).(int64)
+	return version, ok
+}
+
+type requestIDKey struct{}
+
+// WithRequestID tags changes made with ctx with the ID of the request that
+// made them, so a loan's history can be traced back to it.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+)
+
+// DocumentStore persists generated and uploaded documents. Keys are
+// slash-separated paths such as "agreements/<loan id>.pdf"; writing an
+// existing key replaces it.
+//
+// Put returns the document's stable link, which identifies it whichever
+// backend holds the bytes but does not grant access to it. Key maps a link
+// back to its key, and SignedURL issues a short-lived URL that does.
+type DocumentStore interface {
+	Put(ctx context.Context, key string, body io.Reader, contentType string) (string, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// Key returns the key of a link returned by Put. Links to documents
+	// kept anywhere else fail with domain.ErrNotFound.
+	Key(link string) (string, error)
+}
+
+// ErrInvalidSignature is returned for document links whose signature does
+// not match or has expired.
+var ErrInvalidSignature = errors.New("invalid or expired document link")
+
+// URLSigner issues and checks expiring HMAC signatures for document links
+// served by this API. S3 presigns its own URLs; the local store uses this.
+type URLSigner interface {
+	Sign(rawURL, key string, expires time.Time) string
+	Verify(key string, query url.Values, now time.Time) error
+}
+
+type urlSigner struct {
+	secret []byte
+}
+
+// NewURLSigner signs with secret. Without a secret anyone could compute a
+// signature, so an empty one verifies nothing.
+func NewURLSigner(secret string) URLSigner {
+	return &urlSigner{secret: []byte(secret)}
+}
+
+func (s *urlSigner) Sign(rawURL, key string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	q := url.Values{}
+	q.Set("expires", exp)
+	q.Set("signature", s.signature(key, exp))
+	return rawURL + "?" + q.Encode()
+}
+
+func (s *urlSigner) Verify(key string, query url.Values, now time.Time) error {
+	if len(s.secret) == 0 {
+		return ErrInvalidSignature
+	}
+	exp := query.Get("expires")
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || now.Unix() > expires {
+		return ErrInvalidSignature
+	}
+
+	got, err := hex.DecodeString(query.Get("signature"))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	want, _ := hex.DecodeString(s.signature(key, exp))
+	if !hmac.Equal(got, want) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (s *urlSigner) signature(key, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type localDocumentStore struct {
+	dir     string
+	baseURL string
+	signer  URLSigner
+}
+
+// NewLocalDocumentStore keeps documents under dir. Links are baseURL + "/" +
+// key, and signed links add an expiry and signature checked by signer.
+func NewLocalDocumentStore(dir, baseURL string, signer URLSigner) DocumentStore {
+	return &localDocumentStore{
+		dir:     dir,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		signer:  signer,
+	}
+}
+
+func (s *localDocumentStore) Put(ctx context.Context, key string, body io.Reader, contentType string) (string, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first so readers never see a partial document.
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return "", err
+	}
+
+	return s.baseURL + "/" + key, nil
+}
+
+func (s *localDocumentStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return nil, domain.Errorf(domain.ErrNotFound, "document %s not found", key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(name)
+}
+
+func (s *localDocumentStore) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *localDocumentStore) Key(link string) (string, error) {
+	return linkKey(s.baseURL, link)
+}
+
+func (s *localDocumentStore) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	return s.signer.Sign(s.baseURL+"/"+key, key, time.Now().Add(ttl)), nil
+}
+
+// path maps a key to a file under dir, refusing keys that would escape it.
+func (s *localDocumentStore) path(key string) (string, error) {
+	if err := validateDocumentKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// linkKey returns the key of a link under baseURL.
+func linkKey(baseURL, link string) (string, error) {
+	key, ok := strings.CutPrefix(link, baseURL+"/")
+	if !ok || validateDocumentKey(key) != nil {
+		return "", domain.Errorf(domain.ErrNotFound, "%s is not a link to a stored document", link)
+	}
+	return key, nil
+}
+
+func validateDocumentKey(key string) error {
+	clean := path.Clean("/" + key)[1:]
+	if clean == "" || clean != key {
+		return domain.Errorf(domain.ErrValidation, "invalid document key %q", key)
+	}
+	return nil
+}
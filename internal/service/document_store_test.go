@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalDocumentStoreRoundTrip(t *testing.T) {
+	store := NewLocalDocumentStore(t.TempDir(), "http://localhost/documents/", NewURLSigner("secret"))
+	ctx := context.Background()
+
+	link, err := store.Put(ctx, "agreements/a.pdf", strings.NewReader("v1"), "application/pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost/documents/agreements/a.pdf", link)
+
+	_, err = store.Put(ctx, "agreements/a.pdf", strings.NewReader("v2"), "application/pdf")
+	require.NoError(t, err)
+
+	body, err := store.Get(ctx, "agreements/a.pdf")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(body)
+	body.Close()
+	assert.Equal(t, "v2", string(data))
+
+	require.NoError(t, store.Delete(ctx, "agreements/a.pdf"))
+	require.NoError(t, store.Delete(ctx, "agreements/a.pdf"))
+
+	_, err = store.Get(ctx, "agreements/a.pdf")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = store.Get(ctx, "agreements")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestLocalDocumentStoreRejectsEscapingKeys(t *testing.T) {
+	store := NewLocalDocumentStore(t.TempDir(), "http://localhost/documents", NewURLSigner("secret"))
+
+	for _, key := range []string{"", "/etc/passwd", "../secret", "a/../../b", "a//b"} {
+		_, err := store.Put(context.Background(), key, strings.NewReader("x"), "")
+		assert.ErrorIs(t, err, domain.ErrValidation, key)
+	}
+}
+
+func TestLocalDocumentStoreKey(t *testing.T) {
+	store := NewLocalDocumentStore(t.TempDir(), "http://localhost/documents", NewURLSigner("secret"))
+
+	key, err := store.Key("http://localhost/documents/agreements/a.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "agreements/a.pdf", key)
+
+	for _, link := range []string{"https://example.com/documents/a.pdf", "http://localhost/documents/../a.pdf", "http://localhost/documents/"} {
+		_, err := store.Key(link)
+		assert.ErrorIs(t, err, domain.ErrNotFound, link)
+	}
+}
+
+func TestURLSignerVerify(t *testing.T) {
+	signer := NewURLSigner("secret")
+	now := time.Unix(1700000000, 0)
+
+	signed, err := url.Parse(signer.Sign("http://localhost/documents/a.pdf", "a.pdf", now.Add(time.Minute)))
+	require.NoError(t, err)
+	query := signed.Query()
+
+	assert.NoError(t, signer.Verify("a.pdf", query, now))
+	assert.ErrorIs(t, signer.Verify("b.pdf", query, now), ErrInvalidSignature)
+	assert.ErrorIs(t, signer.Verify("a.pdf", query, now.Add(2*time.Minute)), ErrInvalidSignature)
+	assert.ErrorIs(t, NewURLSigner("other").Verify("a.pdf", query, now), ErrInvalidSignature)
+
+	query.Set("expires", "1800000000")
+	assert.ErrorIs(t, signer.Verify("a.pdf", query, now), ErrInvalidSignature)
+}
+
+func TestURLSignerWithoutSecretVerifiesNothing(t *testing.T) {
+	signer := NewURLSigner("")
+	now := time.Unix(1700000000, 0)
+
+	signed, err := url.Parse(signer.Sign("http://localhost/documents/a.pdf", "a.pdf", now.Add(time.Minute)))
+	require.NoError(t, err)
+	assert.ErrorIs(t, signer.Verify("a.pdf", signed.Query(), now), ErrInvalidSignature)
+}
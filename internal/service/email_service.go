@@ -1,18 +1,27 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"slices"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
 
-	"gopkg.in/gomail.v2"
 	"github.com/google/uuid"
+	"gopkg.in/gomail.v2"
 )
 
 type EmailService interface {
-	SendInvestmentAgreement(investorID uuid.UUID, agreementURL string) error
+	SendInvestmentAgreement(ctx context.Context, investorID uuid.UUID, agreementURL string) error
+	SendInvestmentRefund(ctx context.Context, investorID, loanID uuid.UUID, amount domain.Money) error
 }
 
 type emailService struct {
 	smtpConfig SMTPConfig
+	investors  repository.InvestorRepository
 }
 
 type SMTPConfig struct {
@@ -22,26 +31,124 @@ type SMTPConfig struct {
 	Password string
 }
 
-func NewEmailService(config SMTPConfig) EmailService {
+// NewEmailService sends investors email at the address they registered
+// with, looked up in investors.
+func NewEmailService(config SMTPConfig, investors repository.InvestorRepository) EmailService {
 	return &emailService{
 		smtpConfig: config,
+		investors:  investors,
+	}
+}
+
+// recipient returns the investor's email address. Investors registered by
+// the investors migration have none until their details are completed, and
+// their emails fail until then.
+func (s *emailService) recipient(ctx context.Context, investorID uuid.UUID) (string, error) {
+	investor, err := s.investors.GetByID(ctx, investorID)
+	if err != nil {
+		return "", err
 	}
+	if investor.Email == "" {
+		return "", fmt.Errorf("investor %s has no email address", investorID)
+	}
+	return investor.Email, nil
 }
 
-func (s *emailService) SendInvestmentAgreement(investorID uuid.UUID, agreementURL string) error {
+func (s *emailService) SendInvestmentAgreement(ctx context.Context, investorID uuid.UUID, agreementURL string) error {
+	to, err := s.recipient(ctx, investorID)
+	if err != nil {
+		return err
+	}
+
 	m := gomail.NewMessage()
 	m.SetHeader("From", s.smtpConfig.Username)
-	//TODO: Replace with actual email
-	m.SetHeader("To", fmt.Sprintf("investor-%s@example.com", investorID))
+	m.SetHeader("To", to)
 	m.SetHeader("Subject", "Loan Investment Agreement")
 	m.SetBody("text/html", fmt.Sprintf(`
 		<h1>Investment Agreement</h1>
 		<p>Please find your investment agreement at the following link:</p>
 		<p><a href="%s">View Agreement</a></p>
-	`, agreementURL))
+		<p>The link is valid for %d days.</p>
+	`, agreementURL, int(agreementLinkTTL/(24*time.Hour))))
+
+	d := gomail.NewDialer(s.smtpConfig.Host, s.smtpConfig.Port,
+		s.smtpConfig.Username, s.smtpConfig.Password)
+
+	return d.DialAndSend(m)
+}
+
+func (s *emailService) SendInvestmentRefund(ctx context.Context, investorID, loanID uuid.UUID, amount domain.Money) error {
+	to, err := s.recipient(ctx, investorID)
+	if err != nil {
+		return err
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.smtpConfig.Username)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", "Loan Investment Refunded")
+	m.SetBody("text/html", fmt.Sprintf(`
+		<h1>Investment Refunded</h1>
+		<p>Loan %s was closed before it was funded.</p>
+		<p>Your investment of %s %s has been refunded.</p>
+	`, loanID, amount, amount.Currency))
 
 	d := gomail.NewDialer(s.smtpConfig.Host, s.smtpConfig.Port,
 		s.smtpConfig.Username, s.smtpConfig.Password)
 
 	return d.DialAndSend(m)
 }
+
+// agreementLinkTTL is how long the link in an agreement email stays valid,
+// the longest S3 allows.
+const agreementLinkTTL = maxPresignTTL
+
+// NewInvestmentAgreementEmailHandler delivers TopicInvestmentAgreementEmail
+// messages. They are queued by the TopicAgreementLetter job in the
+// transaction that attaches the letter, so the letter is always there. Only
+// investors holding a live investment in the loan are emailed, and the email
+// carries a link to the letter signed by documents.
+func NewInvestmentAgreementEmailHandler(loans repository.LoanRepository, documents DocumentStore, emailService EmailService) OutboxHandler {
+	return func(ctx context.Context, m *domain.OutboxMessage) error {
+		var payload domain.InvestmentAgreementEmail
+		if err := json.Unmarshal(m.Payload, &payload); err != nil {
+			return err
+		}
+
+		loan, err := loans.GetByID(ctx, payload.LoanID)
+		if err != nil {
+			return err
+		}
+		if loan.AgreementLetterURL == "" {
+			return fmt.Errorf("agreement letter for loan %s has not been generated yet", loan.ID)
+		}
+		invested := slices.ContainsFunc(loan.Investments, func(inv domain.Investment) bool {
+			return inv.InvestorID == payload.InvestorID && inv.VoidedAt == nil
+		})
+		if !invested {
+			return domain.Errorf(domain.ErrValidation, "investor %s has not invested in loan %s", payload.InvestorID, loan.ID)
+		}
+
+		key, err := documents.Key(loan.AgreementLetterURL)
+		if err != nil {
+			return err
+		}
+		signed, err := documents.SignedURL(ctx, key, agreementLinkTTL)
+		if err != nil {
+			return err
+		}
+		return emailService.SendInvestmentAgreement(ctx, payload.InvestorID, signed)
+	}
+}
+
+// NewInvestmentRefundHandler delivers TopicInvestmentRefund messages by
+// telling the investor their investment has been returned.
+func NewInvestmentRefundHandler(emailService EmailService) OutboxHandler {
+	return func(ctx context.Context, m *domain.OutboxMessage) error {
+		var payload domain.InvestmentRefund
+		if err := json.Unmarshal(m.Payload, &payload); err != nil {
+			return err
+		}
+		return emailService.SendInvestmentRefund(ctx, payload.InvestorID, payload.LoanID, payload.Amount)
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailServiceRecipient(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	withEmail, withoutEmail := uuid.New(), uuid.New()
+	for id, email := range map[uuid.UUID]string{withEmail: "budi@example.com", withoutEmail: ""} {
+		require.NoError(t, store.Investors().Create(ctx, &domain.Investor{
+			ID:              id,
+			InvestorDetails: domain.InvestorDetails{Email: email},
+			KYCStatus:       domain.KYCStatusPending,
+			Status:          domain.InvestorStatusActive,
+		}))
+	}
+	s := NewEmailService(SMTPConfig{}, store.Investors()).(*emailService)
+
+	to, err := s.recipient(ctx, withEmail)
+	require.NoError(t, err)
+	assert.Equal(t, "budi@example.com", to)
+
+	_, err = s.recipient(ctx, withoutEmail)
+	assert.Error(t, err)
+
+	// Unknown investors and those without an address fail before anything
+	// is sent, so the outbox retries the message.
+	err = s.SendInvestmentRefund(ctx, uuid.New(), uuid.New(), domain.NewMoney(100, "IDR"))
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultInvestorPageSize = 50
+	MaxInvestorPageSize     = 500
+)
+
+type InvestorService interface {
+	// RegisterInvestor adds an active investor pending KYC.
+	RegisterInvestor(ctx context.Context, details domain.InvestorDetails) (*domain.Investor, error)
+	GetInvestor(ctx context.Context, id uuid.UUID) (*domain.Investor, error)
+	ListInvestors(ctx context.Context, status domain.InvestorStatus, limit int) ([]*domain.Investor, error)
+	// UpdateInvestor replaces the investor's details and, unless they are
+	// empty, their KYC status and status.
+	UpdateInvestor(ctx context.Context, id uuid.UUID, details domain.InvestorDetails, kyc domain.KYCStatus, status domain.InvestorStatus) (*domain.Investor, error)
+	// DeleteInvestor removes an investor who has never invested.
+	DeleteInvestor(ctx context.Context, id uuid.UUID) error
+	// GetPortfolio lists the investor's investments, refunded ones
+	// included, with what they expect to earn from each.
+	GetPortfolio(ctx context.Context, id uuid.UUID) (*domain.Portfolio, error)
+}
+
+type investorService struct {
+	repo repository.InvestorRepository
+}
+
+func NewInvestorService(repo repository.InvestorRepository) InvestorService {
+	return &investorService{repo: repo}
+}
+
+func (s *investorService) RegisterInvestor(ctx context.Context, details domain.InvestorDetails) (*domain.Investor, error) {
+	if err := details.Limits.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	i := &domain.Investor{
+		ID:              uuid.New(),
+		InvestorDetails: details,
+		KYCStatus:       domain.KYCStatusPending,
+		Status:          domain.InvestorStatusActive,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := s.repo.Create(ctx, i); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (s *investorService) GetInvestor(ctx context.Context, id uuid.UUID) (*domain.Investor, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *investorService) ListInvestors(ctx context.Context, status domain.InvestorStatus, limit int) ([]*domain.Investor, error) {
+	if limit <= 0 {
+		limit = DefaultInvestorPageSize
+	}
+	if limit > MaxInvestorPageSize {
+		limit = MaxInvestorPageSize
+	}
+	return s.repo.List(ctx, status, limit)
+}
+
+func (s *investorService) UpdateInvestor(ctx context.Context, id uuid.UUID, details domain.InvestorDetails, kyc domain.KYCStatus, status domain.InvestorStatus) (*domain.Investor, error) {
+	if err := details.Limits.Validate(); err != nil {
+		return nil, err
+	}
+
+	i, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	i.InvestorDetails = details
+	if kyc != "" {
+		i.KYCStatus = kyc
+	}
+	if status != "" {
+		i.Status = status
+	}
+	i.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, i); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (s *investorService) DeleteInvestor(ctx context.Context, id uuid.UUID) error {
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *investorService) GetPortfolio(ctx context.Context, id uuid.UUID) (*domain.Portfolio, error) {
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	holdings, err := s.repo.Holdings(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewPortfolio(id, holdings), nil
+}
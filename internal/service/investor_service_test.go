@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvestorLifecycle(t *testing.T) {
+	store := repository.NewMemoryStore()
+	service := NewInvestorService(store.Investors())
+	ctx := context.Background()
+	perLoan := domain.NewMoney(1000000, "IDR")
+	details := domain.InvestorDetails{Name: "Budi", Email: "budi@example.com", Limits: domain.InvestmentLimits{PerLoan: &perLoan}}
+
+	i, err := service.RegisterInvestor(ctx, details)
+	require.NoError(t, err)
+	assert.Equal(t, domain.KYCStatusPending, i.KYCStatus)
+	assert.Equal(t, domain.InvestorStatusActive, i.Status)
+
+	_, err = service.RegisterInvestor(ctx, details)
+	assert.ErrorIs(t, err, domain.ErrAlreadyExists, "email addresses are unique")
+
+	total := domain.NewMoney(500000, "USD")
+	details.Limits.Total = &total
+	_, err = service.UpdateInvestor(ctx, i.ID, details, "", "")
+	assert.ErrorIs(t, err, domain.ErrValidation, "limits are in one currency")
+
+	details.Limits.Total = nil
+	i, err = service.UpdateInvestor(ctx, i.ID, details, domain.KYCStatusVerified, "")
+	require.NoError(t, err)
+	assert.Equal(t, domain.KYCStatusVerified, i.KYCStatus)
+	assert.Equal(t, domain.InvestorStatusActive, i.Status, "an empty status leaves it alone")
+
+	i, err = service.UpdateInvestor(ctx, i.ID, details, "", domain.InvestorStatusSuspended)
+	require.NoError(t, err)
+	assert.Equal(t, domain.KYCStatusVerified, i.KYCStatus)
+
+	suspended, err := service.ListInvestors(ctx, domain.InvestorStatusSuspended, 0)
+	require.NoError(t, err)
+	require.Len(t, suspended, 1)
+	assert.Equal(t, i.ID, suspended[0].ID)
+
+	require.NoError(t, service.DeleteInvestor(ctx, i.ID))
+	_, err = service.GetInvestor(ctx, i.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestGetPortfolio(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1")
+	loans := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), new(MockPDFService), nil)
+	service := NewInvestorService(store.Investors())
+	ctx := context.Background()
+
+	investor := uuid.New()
+	registerInvestors(t, store.Investors(), investor)
+	fundInvestors(t, store, domain.NewMoney(100000000, "IDR"), investor)
+	for _, amount := range []int64{25000, 40000} {
+		loan, err := loans.CreateLoan(ctx, "B-1", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+		require.NoError(t, err)
+		require.NoError(t, loans.ApproveLoan(ctx, loan.ID, "V1", "https://example.com/proof.jpg"))
+		require.NoError(t, loans.InvestInLoan(ctx, loan.ID, investor, domain.NewMoney(amount, "IDR")))
+		if amount == 40000 {
+			require.NoError(t, loans.CancelLoan(ctx, loan.ID, "ops-1", "borrower withdrew"))
+		}
+	}
+
+	p, err := service.GetPortfolio(ctx, investor)
+	require.NoError(t, err)
+	require.Len(t, p.Investments, 2)
+	assert.Equal(t, domain.LoanStateApproved, p.Investments[0].LoanState)
+	assert.Equal(t, domain.Percent(2500), p.Investments[0].Share)
+	assert.Equal(t, domain.NewMoney(2000, "IDR"), p.Investments[0].ExpectedReturn)
+	assert.Equal(t, domain.LoanStateCancelled, p.Investments[1].LoanState)
+	assert.NotNil(t, p.Investments[1].RefundedAt)
+	assert.Equal(t, []domain.PortfolioTotal{
+		{Investments: 1, Invested: domain.NewMoney(25000, "IDR"), ExpectedReturn: domain.NewMoney(2000, "IDR")},
+	}, p.Total)
+
+	_, err = service.GetPortfolio(ctx, uuid.New())
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+)
+
+// ledgerCheckPageSize is how many loans Check reads at a time.
+const ledgerCheckPageSize = 100
+
+// LedgerService reports on the ledger the loan service posts every money
+// movement to.
+type LedgerService interface {
+	// TrialBalance returns every account's balance and their totals.
+	TrialBalance(ctx context.Context) (*domain.TrialBalance, error)
+	// Check looks for entries that do not balance, for loan and platform fee
+	// accounts whose balances differ from what the loans' investments,
+	// disbursements and repayments add up to, and for wallet accounts whose
+	// balances differ from what the investors' wallets have available. Money
+	// that moves while it runs can show up as a discrepancy that is gone on
+	// the next run.
+	Check(ctx context.Context) (*domain.LedgerCheck, error)
+}
+
+type ledgerService struct {
+	ledger     repository.LedgerRepository
+	loans      repository.LoanRepository
+	repayments repository.RepaymentRepository
+	wallets    repository.WalletRepository
+}
+
+func NewLedgerService(ledger repository.LedgerRepository, loans repository.LoanRepository, repayments repository.RepaymentRepository, wallets repository.WalletRepository) LedgerService {
+	return &ledgerService{ledger: ledger, loans: loans, repayments: repayments, wallets: wallets}
+}
+
+func (s *ledgerService) TrialBalance(ctx context.Context) (*domain.TrialBalance, error) {
+	balances, err := s.ledger.Balances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewTrialBalance(balances), nil
+}
+
+func (s *ledgerService) Check(ctx context.Context) (*domain.LedgerCheck, error) {
+	audit := domain.NewLedgerAudit()
+	filter := domain.LoanFilter{Sort: domain.LoanSortCreatedAtAsc, Limit: ledgerCheckPageSize}
+	for {
+		page, err := s.loans.List(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, loan := range page.Loans {
+			repayments, err := s.repayments.List(ctx, loan.ID)
+			if err != nil {
+				return nil, err
+			}
+			audit.AddLoan(loan, repayments)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	wallets, err := s.wallets.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range wallets {
+		audit.AddWallet(w)
+	}
+
+	balances, err := s.ledger.Balances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	check := audit.Check(balances)
+
+	unbalanced, err := s.ledger.UnbalancedEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	check.UnbalancedEntries = append(check.UnbalancedEntries, unbalanced...)
+	return check, nil
+}
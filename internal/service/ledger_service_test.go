@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedgerWithMemoryStore(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1", "B-2")
+	loans := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), new(MockPDFService), nil)
+	ledger := NewLedgerService(store.Ledger(), store.Loans(), store.Repayments(), store.Wallets())
+	ctx := context.Background()
+	first, second := uuid.New(), uuid.New()
+	registerInvestors(t, store.Investors(), first, second)
+	idr := func(v int64) domain.Money { return domain.NewMoney(v, "IDR") }
+	fundInvestors(t, store, idr(250000), first)
+	fundInvestors(t, store, idr(150000), second)
+	_, err := NewWalletService(store.Wallets(), store.Investors(), store.Ledger(), store.Transactor()).Withdraw(ctx, second, idr(10000))
+	require.NoError(t, err)
+
+	repaid, err := loans.CreateLoan(ctx, "B-1", idr(300000), domain.Percent(1200), domain.Percent(200),
+		domain.RepaymentTerms{TenorMonths: 3, RepaymentType: domain.RepaymentFlat})
+	require.NoError(t, err)
+	require.NoError(t, loans.ApproveLoan(ctx, repaid.ID, "V1", "https://example.com/proof.jpg"))
+	require.NoError(t, loans.InvestInLoan(ctx, repaid.ID, first, idr(200000)))
+	require.NoError(t, loans.InvestInLoan(ctx, repaid.ID, second, idr(100000)))
+	require.NoError(t, loans.DisburseLoan(ctx, repaid.ID, "F1", "https://example.com/signed.pdf"))
+	_, err = loans.RecordRepayment(ctx, repaid.ID, idr(103000))
+	require.NoError(t, err)
+
+	cancelled, err := loans.CreateLoan(ctx, "B-2", idr(100000), domain.Percent(1000), domain.Percent(800), emiTerms)
+	require.NoError(t, err)
+	require.NoError(t, loans.ApproveLoan(ctx, cancelled.ID, "V1", "https://example.com/proof.jpg"))
+	require.NoError(t, loans.InvestInLoan(ctx, cancelled.ID, second, idr(40000)))
+	require.NoError(t, loans.CancelLoan(ctx, cancelled.ID, "ops-1", "borrower withdrew"))
+
+	entries, err := store.Ledger().Entries(ctx, repaid.ID)
+	require.NoError(t, err)
+	var kinds []domain.EntryKind
+	for _, e := range entries {
+		kinds = append(kinds, e.Kind)
+	}
+	assert.Equal(t, []domain.EntryKind{domain.EntryInvestment, domain.EntryInvestment, domain.EntryDisbursement, domain.EntryRepayment}, kinds)
+
+	tb, err := ledger.TrialBalance(ctx)
+	require.NoError(t, err)
+	assert.True(t, tb.Balanced)
+	balances := make(map[domain.Account]domain.Money)
+	for _, b := range tb.Accounts {
+		balances[b.Account] = b.Balance
+	}
+	assert.Equal(t, idr(200000), balances[domain.LoanFundingAccount(repaid.ID, "IDR")], "principal not yet repaid to investors")
+	assert.Equal(t, idr(200000), balances[domain.LoanReceivableAccount(repaid.ID, "IDR")])
+	assert.Equal(t, idr(197000), balances[domain.BorrowerAccount("B-1", "IDR")])
+	assert.Equal(t, idr(1000), balances[domain.PlatformFeesAccount("IDR")])
+	assert.Equal(t, idr(250000-200000+66667+1333), balances[domain.InvestorWalletAccount(first, "IDR")])
+	assert.Equal(t, idr(150000-10000-100000+33333+667), balances[domain.InvestorWalletAccount(second, "IDR")],
+		"the cancelled loan's investment was refunded")
+	assert.Equal(t, idr(390000), balances[domain.DepositsAccount("IDR")])
+	assert.Equal(t, idr(0), balances[domain.LoanFundingAccount(cancelled.ID, "IDR")], "the investment was refunded")
+
+	check, err := ledger.Check(ctx)
+	require.NoError(t, err)
+	assert.True(t, check.OK(), "%+v", check)
+	wallets, err := store.Wallets().List(ctx, second)
+	require.NoError(t, err)
+	require.Len(t, wallets, 1)
+	assert.Equal(t, balances[domain.InvestorWalletAccount(second, "IDR")], wallets[0].Available)
+	assert.Equal(t, idr(0), wallets[0].Reserved, "captured on disbursement and released on cancellation")
+
+	// Money posted outside the loan service shows up as a discrepancy.
+	stray, err := domain.DisbursementEntry(cancelled, cancelled.CreatedAt)
+	require.NoError(t, err)
+	require.NoError(t, store.Ledger().Post(ctx, stray))
+	check, err = ledger.Check(ctx)
+	require.NoError(t, err)
+	assert.False(t, check.OK())
+	assert.Equal(t, []domain.LedgerDiscrepancy{{
+		Account:  domain.LoanReceivableAccount(cancelled.ID, "IDR"),
+		Expected: idr(0),
+		Actual:   idr(100000),
+	}}, check.Discrepancies)
+}
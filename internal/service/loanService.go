@@ -1,93 +1,232 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
 	"time"
 
 	"vibhordubey333/loan-service/internal/domain"
 	"vibhordubey333/loan-service/internal/repository"
 
 	"github.com/google/uuid"
-	"errors"
 	"log"
 )
 
 type LoanService interface {
-	CreateLoan(ctx context.Context, borrowerID string, amount, rate, roi float64) (*domain.Loan, error)
+	// CreateLoan proposes a loan to the borrower registered under
+	// borrowerID, their national ID number, who must have passed KYC. The
+	// loan is repaid on terms once disbursed.
+	CreateLoan(ctx context.Context, borrowerID string, principal domain.Money, rate, roi domain.Percent, terms domain.RepaymentTerms) (*domain.Loan, error)
 	GetLoan(ctx context.Context, id uuid.UUID) (*domain.Loan, error)
+	// GetLoanAsOf rebuilds the loan from its event stream as it stood at
+	// asOf. A loan that did not exist yet is not found.
+	GetLoanAsOf(ctx context.Context, id uuid.UUID, asOf time.Time) (*domain.Loan, error)
+	ListLoans(ctx context.Context, filter domain.LoanFilter) (*domain.LoanPage, error)
 	ApproveLoan(ctx context.Context, id uuid.UUID, validatorID, proofImageURL string) error
-	InvestInLoan(ctx context.Context, loanID, investorID uuid.UUID, amount float64) error
+	// ApproveLoanWithProof stores the proof-of-visit image and approves the
+	// loan with a link to it.
+	ApproveLoanWithProof(ctx context.Context, id uuid.UUID, validatorID string, proof Upload) error
+	// InvestInLoan invests for a registered investor, who must be active,
+	// have passed KYC and stay within their investment limits. The amount is
+	// reserved from their wallet, which fails with
+	// domain.ErrInsufficientFunds unless it is available there. The
+	// investment that fully funds the loan queues its agreement letter.
+	InvestInLoan(ctx context.Context, loanID, investorID uuid.UUID, amount domain.Money) error
+	// AttachAgreementLetter generates the agreement letter of a fully
+	// invested loan, attaches it and queues an agreement email to each of
+	// the loan's investors. It does nothing once the letter is attached.
+	AttachAgreementLetter(ctx context.Context, id uuid.UUID) error
+	// DisburseLoan disburses the loan, captures its investments from the
+	// investors' wallets and draws up its repayment schedule.
 	DisburseLoan(ctx context.Context, id uuid.UUID, officerID, signedAgreementURL string) error
+	// DisburseLoanWithAgreement stores the signed agreement and disburses the
+	// loan with a link to it.
+	DisburseLoanWithAgreement(ctx context.Context, id uuid.UUID, officerID string, agreement Upload) error
+	// RejectLoan closes a PROPOSED loan that failed the field visit.
+	RejectLoan(ctx context.Context, id uuid.UUID, validatorID, reason string) error
+	// CancelLoan closes a PROPOSED or APPROVED loan. Investments already
+	// made are voided and released back to the investors' wallets.
+	CancelLoan(ctx context.Context, id uuid.UUID, actorID, reason string) error
+	// ExpireLoans closes APPROVED loans approved before approvedBefore that
+	// are still not fully funded, refunding their investments, and returns
+	// how many it expired.
+	ExpireLoans(ctx context.Context, approvedBefore time.Time) (int, error)
+	// GetLoanHistory returns every change made to the loan, oldest first.
+	GetLoanHistory(ctx context.Context, id uuid.UUID) ([]*domain.LoanHistoryEntry, error)
+	// GetRepaymentSchedule returns the installments the borrower repays a
+	// disbursed loan in. Loans not yet disbursed, or proposed without
+	// repayment terms, have no schedule and are not found.
+	GetRepaymentSchedule(ctx context.Context, id uuid.UUID) (*domain.RepaymentSchedule, error)
+	// RecordRepayment applies a payment from the borrower to a DISBURSED
+	// loan's schedule and pays it out to the loan's investors' wallets. The
+	// payment that settles the schedule moves the loan to REPAID.
+	RecordRepayment(ctx context.Context, id uuid.UUID, amount domain.Money) (*domain.Repayment, error)
+	// ListRepayments returns the loan's repayments, oldest first.
+	ListRepayments(ctx context.Context, id uuid.UUID) ([]*domain.Repayment, error)
+	// DefaultLoans declares DISBURSED loans in default when an installment
+	// due before overdueBefore is still owed, and returns how many it
+	// defaulted.
+	DefaultLoans(ctx context.Context, overdueBefore time.Time) (int, error)
+	// DocumentURL returns a URL to one of the loan's documents, signed for
+	// ttl. It fails with domain.ErrNotFound unless the loan has the document
+	// in the document store; a proof image given as a URL is not.
+	DocumentURL(ctx context.Context, id uuid.UUID, document LoanDocument, ttl time.Duration) (string, error)
+}
+
+// LoanDocument names a document kept for a loan.
+type LoanDocument string
+
+const (
+	LoanDocumentProofImage      LoanDocument = "proof-image"
+	LoanDocumentAgreementLetter LoanDocument = "agreement-letter"
+	LoanDocumentSignedAgreement LoanDocument = "signed-agreement"
+)
+
+// Upload is a file received from a client whose type and size the caller
+// has already checked.
+type Upload struct {
+	Body        io.Reader
+	ContentType string
+	// Extension, including the dot, is appended to the stored document key.
+	Extension string
 }
 
 type loanService struct {
-	repo         repository.LoanRepository
-	emailService EmailService
-	pdfService   PDFService
+	repo       repository.LoanRepository
+	borrowers  repository.BorrowerRepository
+	investors  repository.InvestorRepository
+	outbox     repository.OutboxRepository
+	history    repository.LoanHistoryRepository
+	events     repository.LoanEventStore
+	schedules  repository.RepaymentScheduleRepository
+	repayments repository.RepaymentRepository
+	ledger     repository.LedgerRepository
+	wallets    repository.WalletRepository
+	tx         repository.Transactor
+	pdfService PDFService
+	documents  DocumentStore
 }
 
-func NewLoanService(repo repository.LoanRepository, emailService EmailService, pdfService PDFService) LoanService {
+func NewLoanService(repo repository.LoanRepository, borrowers repository.BorrowerRepository, investors repository.InvestorRepository, outbox repository.OutboxRepository, history repository.LoanHistoryRepository, events repository.LoanEventStore, schedules repository.RepaymentScheduleRepository, repayments repository.RepaymentRepository, ledger repository.LedgerRepository, wallets repository.WalletRepository, tx repository.Transactor, pdfService PDFService, documents DocumentStore) LoanService {
 	return &loanService{
-		repo:         repo,
-		emailService: emailService,
-		pdfService:   pdfService,
+		repo:       repo,
+		borrowers:  borrowers,
+		investors:  investors,
+		outbox:     outbox,
+		history:    history,
+		events:     events,
+		schedules:  schedules,
+		repayments: repayments,
+		ledger:     ledger,
+		wallets:    wallets,
+		tx:         tx,
+		pdfService: pdfService,
+		documents:  documents,
 	}
 }
 
 func (s *loanService) ApproveLoan(ctx context.Context, id uuid.UUID, validatorID, proofImageURL string) error {
-	loan, err := s.repo.GetByID(ctx, id)
+	loan, err := s.getLoanForUpdate(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.approve(ctx, loan, validatorID, proofImageURL)
+}
+
+func (s *loanService) ApproveLoanWithProof(ctx context.Context, id uuid.UUID, validatorID string, proof Upload) error {
+	loan, err := s.getLoanForUpdate(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Check the state before storing anything so a rejected request does not
+	// leave a file behind.
+	if err := domain.LoanLifecycle.Check(loan, domain.LoanEventApprove); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("loans/%s/proof-of-visit/%s%s", loan.ID, uuid.New(), proof.Extension)
+	proofURL, err := s.documents.Put(ctx, key, proof.Body, proof.ContentType)
 	if err != nil {
 		return err
 	}
 
-	if !loan.CanApprove() {
-		return errors.New("loan cannot be approved in current state")
+	if err := s.approve(ctx, loan, validatorID, proofURL); err != nil {
+		s.discardDocument(ctx, key)
+		return err
 	}
+	return nil
+}
 
-	loan.State = domain.LoanStateApproved
-	loan.ApprovalDetails = &domain.ApprovalDetails{
+func (s *loanService) approve(ctx context.Context, loan *domain.Loan, validatorID, proofImageURL string) error {
+	now := time.Now()
+	from := loan.State
+	approved := &domain.LoanApproved{ApprovalDetails: domain.ApprovalDetails{
 		FieldValidatorID: validatorID,
 		ProofImageURL:    proofImageURL,
-		ApprovedAt:       time.Now(),
+		ApprovedAt:       now,
+	}}
+	if err := approved.Apply(loan, now); err != nil {
+		return err
 	}
-	loan.UpdatedAt = time.Now()
 
-	return s.repo.Update(ctx, loan)
+	return s.update(ctx, loan, domain.LoanEventApprove, from, validatorID, &approved.ApprovalDetails, now, approved)
 }
 
-func (s *loanService) CreateLoan(ctx context.Context, borrowerID string, amount, rate, roi float64) (*domain.Loan, error) {
-	loan := &domain.Loan{
-		ID:               uuid.New(),
+func (s *loanService) CreateLoan(ctx context.Context, borrowerID string, principal domain.Money, rate, roi domain.Percent, terms domain.RepaymentTerms) (*domain.Loan, error) {
+	if err := terms.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	created := &domain.LoanCreated{
 		BorrowerIDNumber: borrowerID,
-		PrincipalAmount:  amount,
+		PrincipalAmount:  principal,
 		Rate:             rate,
 		ROI:              roi,
-		State:            domain.LoanStateProposed,
-		CreatedAt:        time.Now(),
-		UpdatedAt:        time.Now(),
+		RepaymentTerms:   terms,
 	}
-
-	if err := s.repo.Create(ctx, loan); err != nil {
+	loan := &domain.Loan{ID: uuid.New(), Version: 1}
+	if err := created.Apply(loan, now); err != nil {
 		return nil, err
 	}
 
-	return loan, nil
-}
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		// The borrower stays locked until the loan commits.
+		borrower, err := s.borrowers.GetByNationalID(ctx, borrowerID)
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.Errorf(domain.ErrValidation, "borrower %s is not registered", borrowerID)
+		}
+		if err != nil {
+			return err
+		}
+		if err := borrower.CheckCanBorrow(); err != nil {
+			return err
+		}
 
-func (s *loanService) InvestInLoan(ctx context.Context, loanID, investorID uuid.UUID, amount float64) error {
-	loan, err := s.repo.GetByID(ctx, loanID)
+		if err := s.repo.Create(ctx, loan); err != nil {
+			return err
+		}
+		if err := s.record(ctx, loan, domain.LoanEventCreate, "", "", loan, now); err != nil {
+			return err
+		}
+		return s.appendChanges(ctx, loan, now, created)
+	})
 	if err != nil {
-		return err
-	}
-
-	if !loan.CanInvest() {
-		return errors.New("loan is not available for investment")
+		return nil, err
 	}
 
-	if loan.TotalInvestedAmount()+amount > loan.PrincipalAmount {
-		return errors.New("investment amount exceeds remaining principal")
-	}
+	return loan, nil
+}
 
+func (s *loanService) InvestInLoan(ctx context.Context, loanID, investorID uuid.UUID, amount domain.Money) error {
 	investment := &domain.Investment{
 		ID:         uuid.New(),
 		LoanID:     loanID,
@@ -96,61 +235,701 @@ func (s *loanService) InvestInLoan(ctx context.Context, loanID, investorID uuid.
 		CreatedAt:  time.Now(),
 	}
 
-	if err := s.repo.AddInvestment(ctx, investment); err != nil {
+	// The repository re-checks state and remaining principal under a row lock
+	// and flips the loan to INVESTED. The wallet reservation, the ledger
+	// entry, the history entry, the changes and, once the loan is fully
+	// invested, the agreement letter job are written in the same
+	// transaction. The outbox dispatcher generates the letter and then sends
+	// the agreement emails, retrying each until it succeeds, so a failing
+	// PDF generator or mail server never holds up or fails the investor's
+	// request.
+	var loan *domain.Loan
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		// The investor stays locked until the investment commits, so their
+		// concurrent investments are checked against their limits in turn.
+		investor, err := s.investors.GetForUpdate(ctx, investorID)
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.Errorf(domain.ErrValidation, "investor %s is not registered", investorID)
+		}
+		if err != nil {
+			return err
+		}
+		if err := investor.CheckCanInvest(); err != nil {
+			return err
+		}
+
+		loan, err = s.repo.AddInvestment(ctx, investment)
+		if err != nil {
+			return err
+		}
+		// The loan stays locked until the investment commits, and adding it
+		// bumped the version by one, so this compares If-Match with the
+		// version the investment was actually made against.
+		if version, ok := expectedVersion(ctx); ok && version != loan.Version-1 {
+			return domain.ErrConflict
+		}
+		if err := s.checkInvestmentLimits(ctx, investor, loan); err != nil {
+			return err
+		}
+		// Adding the investment filled in its currency if it was left out.
+		reserve := map[uuid.UUID]domain.Money{investorID: investment.Amount}
+		if err := s.moveWallets(ctx, reserve, investment.CreatedAt, (*domain.Wallet).Reserve); err != nil {
+			return err
+		}
+		entry, err := domain.InvestmentEntry(*investment)
+		if err != nil {
+			return err
+		}
+		if err := s.ledger.Post(ctx, entry); err != nil {
+			return err
+		}
+		// Investments are only accepted while the loan is APPROVED.
+		err = s.record(ctx, loan, domain.LoanEventInvest, domain.LoanStateApproved,
+			investorID.String(), investment, investment.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		changes := []domain.LoanChange{&domain.InvestmentAdded{Investment: *investment}}
+		if loan.State == domain.LoanStateInvested {
+			changes = append(changes, &domain.LoanFullyInvested{})
+		}
+		if err := s.appendChanges(ctx, loan, investment.CreatedAt, changes...); err != nil {
+			return err
+		}
+		if loan.State != domain.LoanStateInvested {
+			return nil
+		}
+
+		letter, err := domain.NewOutboxMessage(domain.TopicAgreementLetter,
+			domain.AgreementLetter{LoanID: loan.ID}, investment.CreatedAt)
+		if err != nil {
+			return err
+		}
+		return s.outbox.Enqueue(ctx, letter)
+	})
+	if err != nil {
 		log.Println("Error while adding investment")
 		return err
 	}
 
-	loan.Investments = append(loan.Investments, *investment)
-
-	if loan.IsFullyInvested() {
+	if loan.State == domain.LoanStateInvested {
 		log.Println("Loan is fully invested")
-		loan.State = domain.LoanStateInvested
-		loan.UpdatedAt = time.Now()
+	}
+	return nil
+}
 
-		//Todo: Generate agreement letter
+func (s *loanService) AttachAgreementLetter(ctx context.Context, id uuid.UUID) error {
+	loan, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	// A repeated delivery finds the letter attached and its emails queued.
+	if loan.AgreementLetterURL != "" {
+		return nil
+	}
 
-		//Todo: Send emails to all investors
-		agreementURL := "test.com"
+	// Generating the letter again replaces it with an identical one, so a
+	// failure from here on is simply retried.
+	agreementURL, err := s.pdfService.GenerateAgreementLetter(ctx, loan)
+	if err != nil {
+		return err
+	}
 
-		// Send emails to all investors
-		for _, inv := range loan.Investments {
-			if err := s.emailService.SendInvestmentAgreement(inv.InvestorID, agreementURL); err != nil {
-				//Todo: Implement retry mechanism
-				log.Println("Failed to send email to investor", inv.InvestorID)
-				continue
-			}
+	now := time.Now()
+	attached := &domain.AgreementLetterAttached{AgreementLetterURL: agreementURL}
+	if err := attached.Apply(loan, now); err != nil {
+		return err
+	}
+	messages, err := agreementEmails(loan, now)
+	if err != nil {
+		return err
+	}
+
+	// A concurrent change to the loan, such as its disbursement, fails the
+	// update with domain.ErrConflict and the letter is attached on retry.
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.update(ctx, loan, domain.LoanEventAttachAgreement, loan.State, "", attached, now, attached); err != nil {
+			return err
 		}
-		// Persist the updated loan state
-		if err := s.repo.Update(ctx, loan); err != nil {
+		return s.outbox.Enqueue(ctx, messages...)
+	})
+}
+
+// NewAgreementLetterHandler delivers TopicAgreementLetter messages.
+func NewAgreementLetterHandler(loans LoanService) OutboxHandler {
+	return func(ctx context.Context, m *domain.OutboxMessage) error {
+		var payload domain.AgreementLetter
+		if err := json.Unmarshal(m.Payload, &payload); err != nil {
 			return err
 		}
+		return loans.AttachAgreementLetter(ctx, payload.LoanID)
+	}
+}
+
+// checkInvestmentLimits checks the investor's limits once their investment
+// has been added to loan, so the totals include it.
+func (s *loanService) checkInvestmentLimits(ctx context.Context, investor *domain.Investor, loan *domain.Loan) error {
+	limits := investor.Limits
+	if limits.PerLoan == nil && limits.Total == nil {
+		return nil
 	}
 
+	inLoan := domain.NewMoney(0, loan.PrincipalAmount.Currency)
+	for _, inv := range loan.Investments {
+		if inv.InvestorID == investor.ID && inv.VoidedAt == nil {
+			inLoan = inLoan.Add(inv.Amount)
+		}
+	}
+	total := inLoan
+	if limits.Total != nil {
+		var err error
+		total, err = s.investors.TotalInvested(ctx, investor.ID, loan.PrincipalAmount.Currency)
+		if err != nil {
+			return err
+		}
+	}
+	return limits.CheckLimits(inLoan, total)
+}
+
+// moveWallets applies change to the wallet of each investor in amounts, in
+// the amount's currency, skipping zero amounts. Wallets are locked after the
+// loan and in order of investor ID, so transactions moving money for the
+// same investors cannot deadlock.
+func (s *loanService) moveWallets(ctx context.Context, amounts map[uuid.UUID]domain.Money, at time.Time, change func(*domain.Wallet, domain.Money, time.Time) error) error {
+	ids := slices.SortedFunc(maps.Keys(amounts), func(a, b uuid.UUID) int {
+		return bytes.Compare(a[:], b[:])
+	})
+	for _, id := range ids {
+		amount := amounts[id]
+		if amount.IsZero() {
+			continue
+		}
+		wallet, err := s.wallets.GetForUpdate(ctx, id, amount.Currency)
+		if err != nil {
+			return err
+		}
+		if err := change(wallet, amount, at); err != nil {
+			return err
+		}
+		if err := s.wallets.Save(ctx, wallet); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// byInvestor sums investments by investor.
+func byInvestor(investments []domain.Investment) map[uuid.UUID]domain.Money {
+	amounts := make(map[uuid.UUID]domain.Money)
+	for _, inv := range investments {
+		addAmount(amounts, inv.InvestorID, inv.Amount)
+	}
+	return amounts
+}
+
+func addAmount(amounts map[uuid.UUID]domain.Money, investorID uuid.UUID, amount domain.Money) {
+	if sum, ok := amounts[investorID]; ok {
+		amount = sum.Add(amount)
+	}
+	amounts[investorID] = amount
+}
+
+// agreementEmails builds one agreement email per investor in the loan, in
+// the order they first invested, once its agreement letter is attached.
+func agreementEmails(loan *domain.Loan, at time.Time) ([]*domain.OutboxMessage, error) {
+	var (
+		messages []*domain.OutboxMessage
+		seen     = make(map[uuid.UUID]bool)
+	)
+	for _, inv := range loan.Investments {
+		if seen[inv.InvestorID] {
+			continue
+		}
+		seen[inv.InvestorID] = true
+
+		m, err := domain.NewOutboxMessage(domain.TopicInvestmentAgreementEmail,
+			domain.InvestmentAgreementEmail{LoanID: loan.ID, InvestorID: inv.InvestorID}, at)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
 func (s *loanService) DisburseLoan(ctx context.Context, id uuid.UUID, officerID, signedAgreementURL string) error {
-	loan, err := s.repo.GetByID(ctx, id)
+	loan, err := s.getLoanForUpdate(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	if !loan.CanDisburse() {
-		return errors.New("loan cannot be disbursed in current state")
+	return s.disburse(ctx, loan, officerID, signedAgreementURL)
+}
+
+func (s *loanService) DisburseLoanWithAgreement(ctx context.Context, id uuid.UUID, officerID string, agreement Upload) error {
+	loan, err := s.getLoanForUpdate(ctx, id)
+	if err != nil {
+		return err
 	}
 
-	loan.State = domain.LoanStateDisbursed
-	loan.DisbursementDetails = &domain.DisbursementDetails{
+	if err := domain.LoanLifecycle.Check(loan, domain.LoanEventDisburse); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("loans/%s/signed-agreement/%s%s", loan.ID, uuid.New(), agreement.Extension)
+	agreementURL, err := s.documents.Put(ctx, key, agreement.Body, agreement.ContentType)
+	if err != nil {
+		return err
+	}
+
+	if err := s.disburse(ctx, loan, officerID, agreementURL); err != nil {
+		s.discardDocument(ctx, key)
+		return err
+	}
+	return nil
+}
+
+func (s *loanService) disburse(ctx context.Context, loan *domain.Loan, officerID, signedAgreementURL string) error {
+	now := time.Now()
+	from := loan.State
+	disbursed := &domain.LoanDisbursed{DisbursementDetails: domain.DisbursementDetails{
 		FieldOfficerID:     officerID,
 		SignedAgreementURL: signedAgreementURL,
-		DisbursedAt:        time.Now(),
+		DisbursedAt:        now,
+	}}
+	if err := disbursed.Apply(loan, now); err != nil {
+		return err
+	}
+
+	// Loans proposed before repayment terms were recorded are disbursed
+	// without a schedule.
+	var installments []domain.Installment
+	if !loan.RepaymentTerms.IsZero() {
+		var err error
+		if installments, err = domain.ScheduleInstallments(loan, now); err != nil {
+			return err
+		}
+	}
+	entry, err := domain.DisbursementEntry(loan, now)
+	if err != nil {
+		return err
+	}
+	var live []domain.Investment
+	for _, inv := range loan.Investments {
+		if inv.VoidedAt == nil {
+			live = append(live, inv)
+		}
 	}
-	loan.UpdatedAt = time.Now()
 
-	return s.repo.Update(ctx, loan)
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.update(ctx, loan, domain.LoanEventDisburse, from, officerID, &disbursed.DisbursementDetails, now, disbursed); err != nil {
+			return err
+		}
+		if err := s.moveWallets(ctx, byInvestor(live), now, (*domain.Wallet).Capture); err != nil {
+			return err
+		}
+		if err := s.ledger.Post(ctx, entry); err != nil {
+			return err
+		}
+		if len(installments) == 0 {
+			return nil
+		}
+		return s.schedules.Save(ctx, loan.ID, installments)
+	})
+}
+
+func (s *loanService) RejectLoan(ctx context.Context, id uuid.UUID, validatorID, reason string) error {
+	loan, err := s.getLoanForUpdate(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.close(ctx, loan, domain.LoanEventReject, validatorID, reason)
+}
+
+func (s *loanService) CancelLoan(ctx context.Context, id uuid.UUID, actorID, reason string) error {
+	loan, err := s.getLoanForUpdate(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.close(ctx, loan, domain.LoanEventCancel, actorID, reason)
+}
+
+// expiryPageSize is how many APPROVED loans ExpireLoans reads at a time.
+const expiryPageSize = 100
+
+func (s *loanService) ExpireLoans(ctx context.Context, approvedBefore time.Time) (int, error) {
+	filter := domain.LoanFilter{
+		States: []domain.LoanState{domain.LoanStateApproved},
+		Sort:   domain.LoanSortCreatedAtAsc,
+		Limit:  expiryPageSize,
+	}
+
+	var stale []*domain.Loan
+	for {
+		page, err := s.repo.List(ctx, filter)
+		if err != nil {
+			return 0, err
+		}
+		for _, loan := range page.Loans {
+			if loan.ApprovalDetails != nil && loan.ApprovalDetails.ApprovedAt.Before(approvedBefore) {
+				stale = append(stale, loan)
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	expired := 0
+	for _, loan := range stale {
+		err := s.close(ctx, loan, domain.LoanEventExpire, "", "funding window elapsed")
+		switch {
+		case err == nil:
+			expired++
+		case errors.Is(err, domain.ErrConflict):
+			// An investment or cancellation got there first; the next run
+			// looks at the loan again if it is still APPROVED.
+		default:
+			return expired, err
+		}
+	}
+	return expired, nil
+}
+
+// DefaultLoans reads DISBURSED loans a page at a time, as ExpireLoans reads
+// APPROVED ones.
+func (s *loanService) DefaultLoans(ctx context.Context, overdueBefore time.Time) (int, error) {
+	filter := domain.LoanFilter{
+		States: []domain.LoanState{domain.LoanStateDisbursed},
+		Sort:   domain.LoanSortCreatedAtAsc,
+		Limit:  expiryPageSize,
+	}
+
+	var loans []*domain.Loan
+	for {
+		page, err := s.repo.List(ctx, filter)
+		if err != nil {
+			return 0, err
+		}
+		loans = append(loans, page.Loans...)
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	defaulted := 0
+	for _, loan := range loans {
+		installments, err := s.schedules.List(ctx, loan.ID)
+		if err != nil {
+			return defaulted, err
+		}
+		i := slices.IndexFunc(installments, func(inst domain.Installment) bool {
+			return inst.OverdueAt(overdueBefore)
+		})
+		if i < 0 {
+			continue
+		}
+
+		overdue := installments[i]
+		reason := fmt.Sprintf("installment %d overdue since %s", overdue.Number, overdue.DueDate.Format(time.DateOnly))
+		err = s.declareDefault(ctx, loan, reason)
+		switch {
+		case err == nil:
+			defaulted++
+		case errors.Is(err, domain.ErrConflict):
+			// A repayment got there first; the next run looks at the loan
+			// again if it is still overdue.
+		default:
+			return defaulted, err
+		}
+	}
+	return defaulted, nil
+}
+
+// declareDefault moves the loan to DEFAULTED. Unlike close, it leaves the
+// investments in place: investors keep their claim on the loan.
+func (s *loanService) declareDefault(ctx context.Context, loan *domain.Loan, reason string) error {
+	now := time.Now()
+	from := loan.State
+	defaulted := &domain.LoanDefaulted{ClosureDetails: domain.ClosureDetails{Reason: reason, ClosedAt: now}}
+	if err := defaulted.Apply(loan, now); err != nil {
+		return err
+	}
+	return s.update(ctx, loan, domain.LoanEventDefault, from, "", &defaulted.ClosureDetails, now, defaulted)
+}
+
+// close fires a closing event on the loan and, in the same transaction,
+// records it in the loan's history and event stream, voids its investments,
+// releases them back to the investors' wallets, posts their refunds to the
+// ledger and queues a refund for each. The version check in Update makes an
+// investment that lands first fail the close with domain.ErrConflict, and
+// one that lands after it sees the closed state.
+func (s *loanService) close(ctx context.Context, loan *domain.Loan, event domain.LoanEvent, actorID, reason string) error {
+	now := time.Now()
+	from := loan.State
+	closed := &domain.LoanClosed{Event: event, ClosureDetails: domain.ClosureDetails{
+		ActorID:  actorID,
+		Reason:   reason,
+		ClosedAt: now,
+	}}
+	if err := closed.Apply(loan, now); err != nil {
+		return err
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.update(ctx, loan, event, from, actorID, &closed.ClosureDetails, now, closed); err != nil {
+			return err
+		}
+
+		voided, err := s.repo.VoidInvestments(ctx, loan.ID, now)
+		if err != nil || len(voided) == 0 {
+			return err
+		}
+
+		entries := make([]*domain.JournalEntry, len(voided))
+		messages := make([]*domain.OutboxMessage, len(voided))
+		for i, inv := range voided {
+			if entries[i], err = domain.RefundEntry(inv, now); err != nil {
+				return err
+			}
+			messages[i], err = domain.NewOutboxMessage(domain.TopicInvestmentRefund, domain.InvestmentRefund{
+				LoanID:       loan.ID,
+				InvestmentID: inv.ID,
+				InvestorID:   inv.InvestorID,
+				Amount:       inv.Amount,
+			}, now)
+			if err != nil {
+				return err
+			}
+		}
+		if err := s.moveWallets(ctx, byInvestor(voided), now, (*domain.Wallet).Release); err != nil {
+			return err
+		}
+		if err := s.ledger.Post(ctx, entries...); err != nil {
+			return err
+		}
+		return s.outbox.Enqueue(ctx, messages...)
+	})
 }
 
 func (s *loanService) GetLoan(ctx context.Context, id uuid.UUID) (*domain.Loan, error) {
 	return s.repo.GetByID(ctx, id)
 }
+
+func (s *loanService) GetLoanAsOf(ctx context.Context, id uuid.UUID, asOf time.Time) (*domain.Loan, error) {
+	records, err := s.events.Load(ctx, id, asOf)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, domain.Errorf(domain.ErrNotFound, "loan %s did not exist at %s", id, asOf.Format(time.RFC3339))
+	}
+	return domain.FoldLoan(records)
+}
+
+func (s *loanService) GetLoanHistory(ctx context.Context, id uuid.UUID) ([]*domain.LoanHistoryEntry, error) {
+	// An unknown loan is not found rather than an empty history.
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.history.List(ctx, id)
+}
+
+func (s *loanService) GetRepaymentSchedule(ctx context.Context, id uuid.UUID) (*domain.RepaymentSchedule, error) {
+	loan, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	installments, err := s.schedules.List(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(installments) == 0 {
+		return nil, domain.Errorf(domain.ErrNotFound, "loan %s has no repayment schedule", id)
+	}
+	return domain.NewRepaymentSchedule(loan, installments), nil
+}
+
+func (s *loanService) RecordRepayment(ctx context.Context, id uuid.UUID, amount domain.Money) (*domain.Repayment, error) {
+	loan, err := s.getLoanForUpdate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := domain.LoanLifecycle.Check(loan, domain.LoanEventSettle); err != nil {
+		return nil, err
+	}
+
+	installments, err := s.schedules.List(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(installments) == 0 {
+		return nil, domain.Errorf(domain.ErrValidation, "loan %s has no repayment schedule to repay", id)
+	}
+	schedule := domain.NewRepaymentSchedule(loan, installments)
+
+	now := time.Now()
+	from := loan.State
+	repayment, err := domain.NewRepayment(loan, schedule, amount, now)
+	if err != nil {
+		return nil, err
+	}
+	changes := []domain.LoanChange{&domain.RepaymentReceived{Repayment: *repayment}}
+	if schedule.Outstanding.IsZero() {
+		changes = append(changes, &domain.LoanRepaid{})
+	}
+	for _, change := range changes {
+		if err := change.Apply(loan, now); err != nil {
+			return nil, err
+		}
+	}
+	entry, err := domain.RepaymentEntry(loan, repayment)
+	if err != nil {
+		return nil, err
+	}
+	payouts := make(map[uuid.UUID]domain.Money)
+	for _, p := range repayment.Payouts {
+		addAmount(payouts, p.InvestorID, p.Amount)
+	}
+
+	// The version check in Update makes concurrent repayments, which were
+	// allocated against the same schedule, fail with domain.ErrConflict.
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.update(ctx, loan, domain.LoanEventRepay, from, loan.BorrowerIDNumber, repayment, now, changes...); err != nil {
+			return err
+		}
+		if err := s.schedules.Update(ctx, loan.ID, schedule.Installments); err != nil {
+			return err
+		}
+		if err := s.repayments.Create(ctx, repayment); err != nil {
+			return err
+		}
+		if err := s.moveWallets(ctx, payouts, now, (*domain.Wallet).Credit); err != nil {
+			return err
+		}
+		return s.ledger.Post(ctx, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repayment, nil
+}
+
+func (s *loanService) ListRepayments(ctx context.Context, id uuid.UUID) ([]*domain.Repayment, error) {
+	// An unknown loan is not found rather than never repaid.
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repayments.List(ctx, id)
+}
+
+func (s *loanService) ListLoans(ctx context.Context, filter domain.LoanFilter) (*domain.LoanPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = domain.DefaultLoanPageSize
+	}
+	if filter.Limit > domain.MaxLoanPageSize {
+		filter.Limit = domain.MaxLoanPageSize
+	}
+	return s.repo.List(ctx, filter)
+}
+
+func (s *loanService) DocumentURL(ctx context.Context, id uuid.UUID, document LoanDocument, ttl time.Duration) (string, error) {
+	loan, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	var link string
+	switch document {
+	case LoanDocumentProofImage:
+		if loan.ApprovalDetails != nil {
+			link = loan.ApprovalDetails.ProofImageURL
+		}
+	case LoanDocumentAgreementLetter:
+		link = loan.AgreementLetterURL
+	case LoanDocumentSignedAgreement:
+		if loan.DisbursementDetails != nil {
+			link = loan.DisbursementDetails.SignedAgreementURL
+		}
+	}
+	if link == "" {
+		return "", domain.Errorf(domain.ErrNotFound, "loan %s has no %s document", id, document)
+	}
+
+	key, err := s.documents.Key(link)
+	if err != nil {
+		return "", err
+	}
+	return s.documents.SignedURL(ctx, key, ttl)
+}
+
+// update writes the loan, to which changes have been applied, and in the
+// same transaction records event as the change that moved it from the from
+// state and appends changes to its event stream.
+func (s *loanService) update(ctx context.Context, loan *domain.Loan, event domain.LoanEvent, from domain.LoanState, actorID string, payload any, at time.Time, changes ...domain.LoanChange) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Update(ctx, loan); err != nil {
+			return err
+		}
+		if err := s.record(ctx, loan, event, from, actorID, payload, at); err != nil {
+			return err
+		}
+		return s.appendChanges(ctx, loan, at, changes...)
+	})
+}
+
+// record appends event to the loan's history as the change that brought it
+// to its current version. It must run in the transaction that made the
+// change.
+func (s *loanService) record(ctx context.Context, loan *domain.Loan, event domain.LoanEvent, from domain.LoanState, actorID string, payload any, at time.Time) error {
+	entry, err := domain.NewLoanHistoryEntry(loan, event, from, actorID, payload, at)
+	if err != nil {
+		return err
+	}
+	entry.RequestID = requestID(ctx)
+	return s.history.Append(ctx, entry)
+}
+
+// appendChanges adds changes made to the loan at its current version to its
+// event stream. It must run in the transaction that writes the loan.
+func (s *loanService) appendChanges(ctx context.Context, loan *domain.Loan, at time.Time, changes ...domain.LoanChange) error {
+	records := make([]*domain.LoanChangeRecord, len(changes))
+	for i, change := range changes {
+		var err error
+		records[i], err = domain.NewLoanChangeRecord(loan, change, at)
+		if err != nil {
+			return err
+		}
+	}
+	return s.events.Append(ctx, records...)
+}
+
+// discardDocument removes a document stored for a transition that did not
+// go through, e.g. because a concurrent request changed the loan first.
+func (s *loanService) discardDocument(ctx context.Context, key string) {
+	if err := s.documents.Delete(ctx, key); err != nil {
+		log.Printf("Failed to delete orphaned document %s: %v", key, err)
+	}
+}
+
+// getLoanForUpdate loads a loan ahead of a transition and rejects it with
+// domain.ErrConflict if the caller expected a different version. The
+// repository re-checks the version when the change is written.
+func (s *loanService) getLoanForUpdate(ctx context.Context, id uuid.UUID) (*domain.Loan, error) {
+	loan, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if version, ok := expectedVersion(ctx); ok && version != loan.Version {
+		return nil, domain.ErrConflict
+	}
+
+	return loan, nil
+}
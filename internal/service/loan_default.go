@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunLoanDefaults declares DISBURSED loans in default once an installment
+// has been overdue for longer than after, checking every interval until ctx
+// is cancelled.
+func RunLoanDefaults(ctx context.Context, loans LoanService, after, interval time.Duration) {
+	for {
+		n, err := loans.DefaultLoans(ctx, time.Now().Add(-after))
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Loan defaults failed: %v", err)
+		}
+		if n > 0 {
+			log.Printf("Defaulted %d loans", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunLoanExpiry expires APPROVED loans that have waited longer than window
+// for funding, checking every interval until ctx is cancelled.
+func RunLoanExpiry(ctx context.Context, loans LoanService, window, interval time.Duration) {
+	for {
+		n, err := loans.ExpireLoans(ctx, time.Now().Add(-window))
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Loan expiry failed: %v", err)
+		}
+		if n > 0 {
+			log.Printf("Expired %d loans", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
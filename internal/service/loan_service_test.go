@@ -2,14 +2,24 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
-	_ "time"
+	"time"
 
 	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type MockLoanRepository struct {
@@ -24,6 +34,140 @@ type MockEmailService struct {
 	mock.Mock
 }
 
+type MockOutboxRepository struct {
+	mock.Mock
+}
+
+// nopTransactor runs fn directly; the mocks have nothing to roll back.
+type nopTransactor struct{}
+
+func (nopTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// emiTerms are the repayment terms of loans whose terms do not matter to
+// the test.
+var emiTerms = domain.RepaymentTerms{TenorMonths: 12, RepaymentType: domain.RepaymentEMI}
+
+// newStoreWithBorrowers returns a memory store in which each national ID
+// number belongs to a borrower who has passed KYC.
+func newStoreWithBorrowers(t *testing.T, nationalIDs ...string) *repository.MemoryStore {
+	t.Helper()
+	store := repository.NewMemoryStore()
+	for _, id := range nationalIDs {
+		require.NoError(t, store.Borrowers().Create(context.Background(), &domain.Borrower{
+			ID:               uuid.New(),
+			NationalIDNumber: id,
+			KYCStatus:        domain.KYCStatusVerified,
+		}))
+	}
+	return store
+}
+
+// registerInvestors adds an active, KYC-verified investor without limits
+// under each ID.
+func registerInvestors(t *testing.T, repo repository.InvestorRepository, ids ...uuid.UUID) repository.InvestorRepository {
+	t.Helper()
+	for _, id := range ids {
+		require.NoError(t, repo.Create(context.Background(), &domain.Investor{
+			ID:        id,
+			KYCStatus: domain.KYCStatusVerified,
+			Status:    domain.InvestorStatusActive,
+		}))
+	}
+	return repo
+}
+
+// fundInvestors deposits amount into each investor's wallet.
+func fundInvestors(t *testing.T, store *repository.MemoryStore, amount domain.Money, ids ...uuid.UUID) {
+	t.Helper()
+	wallets := NewWalletService(store.Wallets(), store.Investors(), store.Ledger(), store.Transactor())
+	for _, id := range ids {
+		_, err := wallets.Deposit(context.Background(), id, amount)
+		require.NoError(t, err)
+	}
+}
+
+// attachAgreementLetters runs the agreement letter jobs queued in store.
+func attachAgreementLetters(t *testing.T, ctx context.Context, store *repository.MemoryStore, loans LoanService) {
+	t.Helper()
+	dispatcher := NewOutboxDispatcher(store.Outbox(), DispatcherConfig{BatchSize: 10, MaxAttempts: 3, Lease: time.Minute},
+		map[string]OutboxHandler{domain.TopicAgreementLetter: NewAgreementLetterHandler(loans)})
+	_, err := dispatcher.DispatchOnce(ctx)
+	require.NoError(t, err)
+}
+
+// historyRecorder keeps the entries appended to it.
+type historyRecorder struct {
+	entries []*domain.LoanHistoryEntry
+}
+
+func (h *historyRecorder) Append(ctx context.Context, entries ...*domain.LoanHistoryEntry) error {
+	h.entries = append(h.entries, entries...)
+	return nil
+}
+
+func (h *historyRecorder) List(ctx context.Context, loanID uuid.UUID) ([]*domain.LoanHistoryEntry, error) {
+	var entries []*domain.LoanHistoryEntry
+	for _, e := range h.entries {
+		if e.LoanID == loanID {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// ledgerRecorder keeps the journal entries posted to it.
+type ledgerRecorder struct {
+	entries []*domain.JournalEntry
+}
+
+func (l *ledgerRecorder) Post(ctx context.Context, entries ...*domain.JournalEntry) error {
+	l.entries = append(l.entries, entries...)
+	return nil
+}
+
+func (l *ledgerRecorder) Entries(ctx context.Context, loanID uuid.UUID) ([]*domain.JournalEntry, error) {
+	var entries []*domain.JournalEntry
+	for _, e := range l.entries {
+		if e.LoanID == loanID {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (l *ledgerRecorder) Balances(ctx context.Context) ([]domain.AccountBalance, error) {
+	return nil, nil
+}
+
+func (l *ledgerRecorder) UnbalancedEntries(ctx context.Context) ([]uuid.UUID, error) {
+	return nil, nil
+}
+
+// changeRecorder keeps the changes appended to it, numbered per loan.
+type changeRecorder struct {
+	records []*domain.LoanChangeRecord
+}
+
+func (c *changeRecorder) Append(ctx context.Context, records ...*domain.LoanChangeRecord) error {
+	for _, r := range records {
+		r.Sequence = int64(len(c.records)) + 1
+		c.records = append(c.records, r)
+	}
+	return nil
+}
+
+func (c *changeRecorder) Load(ctx context.Context, loanID uuid.UUID, asOf time.Time) ([]*domain.LoanChangeRecord, error) {
+	var records []*domain.LoanChangeRecord
+	for _, r := range c.records {
+		if r.LoanID == loanID && (asOf.IsZero() || !r.OccurredAt.After(asOf)) {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
 func (m *MockLoanRepository) Create(ctx context.Context, loan *domain.Loan) error {
 	args := m.Called(ctx, loan)
 	return args.Error(0)
@@ -39,36 +183,77 @@ func (m *MockLoanRepository) Update(ctx context.Context, loan *domain.Loan) erro
 	return args.Error(0)
 }
 
-func (m *MockLoanRepository) AddInvestment(ctx context.Context, investment *domain.Investment) error {
+func (m *MockLoanRepository) AddInvestment(ctx context.Context, investment *domain.Investment) (*domain.Loan, error) {
 	args := m.Called(ctx, investment)
+	return args.Get(0).(*domain.Loan), args.Error(1)
+}
+
+func (m *MockLoanRepository) VoidInvestments(ctx context.Context, loanID uuid.UUID, at time.Time) ([]domain.Investment, error) {
+	args := m.Called(ctx, loanID, at)
+	return args.Get(0).([]domain.Investment), args.Error(1)
+}
+
+func (m *MockLoanRepository) List(ctx context.Context, filter domain.LoanFilter) (*domain.LoanPage, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*domain.LoanPage), args.Error(1)
+}
+
+func (m *MockEmailService) SendInvestmentAgreement(ctx context.Context, investorID uuid.UUID, agreementURL string) error {
+	args := m.Called(ctx, investorID, agreementURL)
+	return args.Error(0)
+}
+
+func (m *MockEmailService) SendInvestmentRefund(ctx context.Context, investorID, loanID uuid.UUID, amount domain.Money) error {
+	args := m.Called(ctx, investorID, loanID, amount)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) Enqueue(ctx context.Context, messages ...*domain.OutboxMessage) error {
+	args := m.Called(ctx, messages)
 	return args.Error(0)
 }
 
-func (m *MockEmailService) SendInvestmentAgreement(investorID uuid.UUID, agreementURL string) error {
-	args := m.Called(investorID, agreementURL)
+func (m *MockOutboxRepository) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.OutboxMessage, error) {
+	args := m.Called(ctx, now, lease, limit)
+	return args.Get(0).([]*domain.OutboxMessage), args.Error(1)
+}
+
+func (m *MockOutboxRepository) Save(ctx context.Context, message *domain.OutboxMessage) error {
+	args := m.Called(ctx, message)
 	return args.Error(0)
 }
 
-func (m *MockPDFService) GenerateAgreementLetter(loan *domain.Loan) (string, error) {
-	args := m.Called(loan)
+func (m *MockOutboxRepository) Get(ctx context.Context, id uuid.UUID) (*domain.OutboxMessage, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*domain.OutboxMessage), args.Error(1)
+}
+
+func (m *MockOutboxRepository) List(ctx context.Context, status domain.OutboxStatus, limit int) ([]*domain.OutboxMessage, error) {
+	args := m.Called(ctx, status, limit)
+	return args.Get(0).([]*domain.OutboxMessage), args.Error(1)
+}
+
+func (m *MockPDFService) GenerateAgreementLetter(ctx context.Context, loan *domain.Loan) (string, error) {
+	args := m.Called(ctx, loan)
 	return args.String(0), args.Error(1)
 }
 
 func TestCreateLoan(t *testing.T) {
 	repo := new(MockLoanRepository)
-	emailService := new(MockEmailService)
 	pdfService := new(MockPDFService)
-	service := NewLoanService(repo, emailService, pdfService)
+	borrowers := newStoreWithBorrowers(t, "12345").Borrowers()
+	service := NewLoanService(repo, borrowers, nil, new(MockOutboxRepository), new(historyRecorder), new(changeRecorder), nil, nil, new(ledgerRecorder), nil, nopTransactor{}, pdfService, nil)
 
 	ctx := context.Background()
 	borrowerID := "12345"
-	amount := 1000.0
-	rate := 5.0
-	roi := 8.0
+	amount := domain.NewMoney(100000, "IDR")
+	rate := domain.Percent(500)
+	roi := domain.Percent(800)
+	terms := domain.RepaymentTerms{TenorMonths: 6, RepaymentType: domain.RepaymentFlat}
 
 	repo.On("Create", ctx, mock.AnythingOfType("*domain.Loan")).Return(nil)
 
-	loan, err := service.CreateLoan(ctx, borrowerID, amount, rate, roi)
+	loan, err := service.CreateLoan(ctx, borrowerID, amount, rate, roi, terms)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, loan)
@@ -76,16 +261,19 @@ func TestCreateLoan(t *testing.T) {
 	assert.Equal(t, amount, loan.PrincipalAmount)
 	assert.Equal(t, rate, loan.Rate)
 	assert.Equal(t, roi, loan.ROI)
+	assert.Equal(t, terms, loan.RepaymentTerms)
 	assert.Equal(t, domain.LoanStateProposed, loan.State)
 
+	_, err = service.CreateLoan(ctx, borrowerID, amount, rate, roi, domain.RepaymentTerms{})
+	assert.ErrorIs(t, err, domain.ErrValidation, "loans need repayment terms")
+
 	repo.AssertExpectations(t)
 }
 
 func TestApproveLoan(t *testing.T) {
 	repo := new(MockLoanRepository)
-	emailService := new(MockEmailService)
 	pdfService := new(MockPDFService)
-	service := NewLoanService(repo, emailService, pdfService)
+	service := NewLoanService(repo, nil, nil, new(MockOutboxRepository), new(historyRecorder), new(changeRecorder), nil, nil, new(ledgerRecorder), nil, nopTransactor{}, pdfService, nil)
 
 	ctx := context.Background()
 	loanID := uuid.New()
@@ -108,40 +296,115 @@ func TestApproveLoan(t *testing.T) {
 
 func TestInvestInLoan(t *testing.T) {
 	repo := new(MockLoanRepository)
-	emailService := new(MockEmailService)
+	outbox := new(MockOutboxRepository)
 	pdfService := new(MockPDFService)
-	service := NewLoanService(repo, emailService, pdfService)
+	investorID := uuid.New()
+	store := repository.NewMemoryStore()
+	registerInvestors(t, store.Investors(), investorID)
+	fundInvestors(t, store, domain.NewMoney(80000, "IDR"), investorID)
+	service := NewLoanService(repo, nil, store.Investors(), outbox, new(historyRecorder), new(changeRecorder), nil, nil, new(ledgerRecorder), store.Wallets(), nopTransactor{}, pdfService, nil)
 
 	ctx := context.Background()
 	loanID := uuid.New()
-	investorID := uuid.New()
-	amount := 1000.0
+	earlierInvestorID := uuid.New()
+	amount := domain.NewMoney(50000, "IDR")
 
-	existingLoan := &domain.Loan{
+	investedLoan := &domain.Loan{
 		ID:              loanID,
-		State:           domain.LoanStateApproved,
-		PrincipalAmount: 1000.0,
+		State:           domain.LoanStateInvested,
+		PrincipalAmount: domain.NewMoney(100000, "IDR"),
+		Investments: []domain.Investment{
+			{LoanID: loanID, InvestorID: earlierInvestorID, Amount: domain.NewMoney(25000, "IDR")},
+			{LoanID: loanID, InvestorID: earlierInvestorID, Amount: domain.NewMoney(25000, "IDR")},
+			{LoanID: loanID, InvestorID: investorID, Amount: amount},
+		},
 	}
 
-	repo.On("GetByID", ctx, loanID).Return(existingLoan, nil)
-	repo.On("AddInvestment", ctx, mock.AnythingOfType("*domain.Investment")).Return(nil)
-	repo.On("Update", ctx, mock.AnythingOfType("*domain.Loan")).Return(nil)
-	pdfService.On("GenerateAgreementLetter", mock.AnythingOfType("*domain.Loan")).Return("https://example.com/agreement.pdf", nil)
-	emailService.On("SendInvestmentAgreement", investorID, mock.AnythingOfType("string")).Return(nil)
+	repo.On("AddInvestment", ctx, mock.MatchedBy(func(inv *domain.Investment) bool {
+		return inv.LoanID == loanID && inv.InvestorID == investorID && inv.Amount == amount
+	})).Return(investedLoan, nil)
+	outbox.On("Enqueue", ctx, mock.MatchedBy(func(messages []*domain.OutboxMessage) bool {
+		var payload domain.AgreementLetter
+		return len(messages) == 1 &&
+			messages[0].Topic == domain.TopicAgreementLetter &&
+			json.Unmarshal(messages[0].Payload, &payload) == nil &&
+			payload.LoanID == loanID
+	})).Return(nil)
 
 	err := service.InvestInLoan(ctx, loanID, investorID, amount)
 
 	assert.NoError(t, err)
 	repo.AssertExpectations(t)
-	emailService.AssertExpectations(t)
-	//	pdfService.AssertExpectations(t)
+	outbox.AssertExpectations(t)
+	pdfService.AssertNotCalled(t, "GenerateAgreementLetter", mock.Anything, mock.Anything)
+	wallets, err := store.Wallets().List(ctx, investorID)
+	require.NoError(t, err)
+	require.Len(t, wallets, 1)
+	assert.Equal(t, domain.NewMoney(30000, "IDR"), wallets[0].Available)
+	assert.Equal(t, amount, wallets[0].Reserved)
+}
+
+func TestInvestInLoanFailsWhenEmailsCannotBeQueued(t *testing.T) {
+	repo := new(MockLoanRepository)
+	outbox := new(MockOutboxRepository)
+	pdfService := new(MockPDFService)
+	investorID := uuid.New()
+	store := repository.NewMemoryStore()
+	registerInvestors(t, store.Investors(), investorID)
+	fundInvestors(t, store, domain.NewMoney(80000, "IDR"), investorID)
+	service := NewLoanService(repo, nil, store.Investors(), outbox, new(historyRecorder), new(changeRecorder), nil, nil, new(ledgerRecorder), store.Wallets(), nopTransactor{}, pdfService, nil)
+
+	ctx := context.Background()
+	loanID := uuid.New()
+
+	repo.On("AddInvestment", ctx, mock.Anything).Return(&domain.Loan{
+		ID:          loanID,
+		State:       domain.LoanStateInvested,
+		Investments: []domain.Investment{{LoanID: loanID, InvestorID: investorID}},
+	}, nil)
+	outbox.On("Enqueue", ctx, mock.Anything).Return(errors.New("connection reset"))
+
+	err := service.InvestInLoan(ctx, loanID, investorID, domain.NewMoney(100, "IDR"))
+
+	assert.Error(t, err)
+	pdfService.AssertNotCalled(t, "GenerateAgreementLetter", mock.Anything, mock.Anything)
+}
+
+func TestInvestInLoanWithStaleVersion(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1")
+	service := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), new(MockPDFService), nil)
+	ctx := context.Background()
+	investor := uuid.New()
+	registerInvestors(t, store.Investors(), investor)
+	fundInvestors(t, store, domain.NewMoney(100000, "IDR"), investor)
+
+	loan, err := service.CreateLoan(ctx, "B-1", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+	require.NoError(t, err)
+	require.NoError(t, service.ApproveLoan(ctx, loan.ID, "V1", "https://example.com/proof.jpg"))
+	approved, err := service.GetLoan(ctx, loan.ID)
+	require.NoError(t, err)
+
+	stale := WithExpectedVersion(ctx, approved.Version)
+	require.NoError(t, service.InvestInLoan(stale, loan.ID, investor, domain.NewMoney(10000, "IDR")))
+	err = service.InvestInLoan(stale, loan.ID, investor, domain.NewMoney(10000, "IDR"))
+	assert.ErrorIs(t, err, domain.ErrConflict)
+
+	got, err := service.GetLoan(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Len(t, got.Investments, 1, "the conflicting investment is rolled back")
+	wallets, err := store.Wallets().List(ctx, investor)
+	require.NoError(t, err)
+	assert.Equal(t, domain.NewMoney(10000, "IDR"), wallets[0].Reserved)
+
+	current := WithExpectedVersion(ctx, got.Version)
+	assert.NoError(t, service.InvestInLoan(current, loan.ID, investor, domain.NewMoney(10000, "IDR")))
 }
 
 func TestDisburseLoan(t *testing.T) {
 	repo := new(MockLoanRepository)
-	emailService := new(MockEmailService)
 	pdfService := new(MockPDFService)
-	service := NewLoanService(repo, emailService, pdfService)
+	ledger := new(ledgerRecorder)
+	service := NewLoanService(repo, nil, nil, new(MockOutboxRepository), new(historyRecorder), new(changeRecorder), nil, nil, ledger, nil, nopTransactor{}, pdfService, nil)
 
 	ctx := context.Background()
 	loanID := uuid.New()
@@ -149,8 +412,10 @@ func TestDisburseLoan(t *testing.T) {
 	signedAgreementURL := "https://example.com/signed.pdf"
 
 	existingLoan := &domain.Loan{
-		ID:    loanID,
-		State: domain.LoanStateInvested,
+		ID:               loanID,
+		BorrowerIDNumber: "B123",
+		PrincipalAmount:  domain.NewMoney(100000, "IDR"),
+		State:            domain.LoanStateInvested,
 	}
 
 	repo.On("GetByID", ctx, loanID).Return(existingLoan, nil)
@@ -160,4 +425,920 @@ func TestDisburseLoan(t *testing.T) {
 
 	assert.NoError(t, err)
 	repo.AssertExpectations(t)
+	require.Len(t, ledger.entries, 1)
+	assert.Equal(t, domain.EntryDisbursement, ledger.entries[0].Kind)
+	assert.Equal(t, []domain.JournalLine{
+		{Account: domain.LoanReceivableAccount(loanID, "IDR"), Side: domain.Debit, Amount: domain.NewMoney(100000, "IDR")},
+		{Account: domain.BorrowerAccount("B123", "IDR"), Side: domain.Credit, Amount: domain.NewMoney(100000, "IDR")},
+	}, ledger.entries[0].Lines)
+}
+
+func TestApproveLoanWithStaleVersion(t *testing.T) {
+	repo := new(MockLoanRepository)
+	pdfService := new(MockPDFService)
+	service := NewLoanService(repo, nil, nil, new(MockOutboxRepository), new(historyRecorder), new(changeRecorder), nil, nil, new(ledgerRecorder), nil, nopTransactor{}, pdfService, nil)
+
+	ctx := WithExpectedVersion(context.Background(), 1)
+	loanID := uuid.New()
+
+	existingLoan := &domain.Loan{
+		ID:      loanID,
+		State:   domain.LoanStateProposed,
+		Version: 2,
+	}
+
+	repo.On("GetByID", ctx, loanID).Return(existingLoan, nil)
+
+	err := service.ApproveLoan(ctx, loanID, "V123", "https://example.com/proof.jpg")
+
+	assert.ErrorIs(t, err, domain.ErrConflict)
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestListLoansClampsLimit(t *testing.T) {
+	repo := new(MockLoanRepository)
+	service := NewLoanService(repo, nil, nil, new(MockOutboxRepository), new(historyRecorder), new(changeRecorder), nil, nil, new(ledgerRecorder), nil, nopTransactor{}, new(MockPDFService), nil)
+
+	ctx := context.Background()
+	page := &domain.LoanPage{}
+
+	repo.On("List", ctx, domain.LoanFilter{Limit: domain.MaxLoanPageSize}).Return(page, nil)
+	repo.On("List", ctx, domain.LoanFilter{Limit: domain.DefaultLoanPageSize}).Return(page, nil)
+
+	_, err := service.ListLoans(ctx, domain.LoanFilter{Limit: 1000})
+	assert.NoError(t, err)
+	_, err = service.ListLoans(ctx, domain.LoanFilter{})
+	assert.NoError(t, err)
+
+	repo.AssertExpectations(t)
+}
+
+func TestDisburseLoanInWrongState(t *testing.T) {
+	repo := new(MockLoanRepository)
+	service := NewLoanService(repo, nil, nil, new(MockOutboxRepository), new(historyRecorder), new(changeRecorder), nil, nil, new(ledgerRecorder), nil, nopTransactor{}, new(MockPDFService), nil)
+
+	ctx := context.Background()
+	loanID := uuid.New()
+
+	repo.On("GetByID", ctx, loanID).Return(&domain.Loan{ID: loanID, State: domain.LoanStateApproved}, nil)
+
+	err := service.DisburseLoan(ctx, loanID, "O123", "https://example.com/signed.pdf")
+
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition)
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestApproveLoanWithProofStoresDocument(t *testing.T) {
+	repo := new(MockLoanRepository)
+	dir := t.TempDir()
+	documents := NewLocalDocumentStore(dir, "https://api.example.com/api/v1/documents", NewURLSigner("secret"))
+	service := NewLoanService(repo, nil, nil, new(MockOutboxRepository), new(historyRecorder), new(changeRecorder), nil, nil, new(ledgerRecorder), nil, nopTransactor{}, new(MockPDFService), documents)
+
+	ctx := context.Background()
+	loanID := uuid.New()
+
+	repo.On("GetByID", ctx, loanID).Return(&domain.Loan{ID: loanID, State: domain.LoanStateProposed}, nil)
+	var stored string
+	repo.On("Update", ctx, mock.MatchedBy(func(loan *domain.Loan) bool {
+		stored = loan.ApprovalDetails.ProofImageURL
+		return true
+	})).Return(nil)
+
+	err := service.ApproveLoanWithProof(ctx, loanID, "V123", Upload{
+		Body:        strings.NewReader("jpeg bytes"),
+		ContentType: "image/jpeg",
+		Extension:   ".jpg",
+	})
+
+	require.NoError(t, err)
+	prefix := "https://api.example.com/api/v1/documents/loans/" + loanID.String() + "/proof-of-visit/"
+	require.True(t, strings.HasPrefix(stored, prefix), stored)
+	assert.True(t, strings.HasSuffix(stored, ".jpg"))
+
+	body, err := documents.Get(ctx, strings.TrimPrefix(stored, "https://api.example.com/api/v1/documents/"))
+	require.NoError(t, err)
+	data, _ := io.ReadAll(body)
+	body.Close()
+	assert.Equal(t, "jpeg bytes", string(data))
+}
+
+func TestDocumentURL(t *testing.T) {
+	repo := new(MockLoanRepository)
+	documents := NewLocalDocumentStore(t.TempDir(), "https://api.example.com/api/v1/documents", NewURLSigner("secret"))
+	service := NewLoanService(repo, nil, nil, new(MockOutboxRepository), new(historyRecorder), new(changeRecorder), nil, nil, new(ledgerRecorder), nil, nopTransactor{}, new(MockPDFService), documents)
+
+	ctx := context.Background()
+	loanID := uuid.New()
+	repo.On("GetByID", ctx, loanID).Return(&domain.Loan{
+		ID:                 loanID,
+		State:              domain.LoanStateInvested,
+		ApprovalDetails:    &domain.ApprovalDetails{ProofImageURL: "https://example.com/proof.jpg"},
+		AgreementLetterURL: "https://api.example.com/api/v1/documents/agreements/" + loanID.String() + ".pdf",
+	}, nil)
+
+	signed, err := service.DocumentURL(ctx, loanID, LoanDocumentAgreementLetter, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signed, "https://api.example.com/api/v1/documents/agreements/"+loanID.String()+".pdf?"), signed)
+	assert.Contains(t, signed, "signature=")
+
+	for _, document := range []LoanDocument{LoanDocumentProofImage, LoanDocumentSignedAgreement, "passport"} {
+		_, err := service.DocumentURL(ctx, loanID, document, time.Minute)
+		assert.ErrorIs(t, err, domain.ErrNotFound, document)
+	}
+}
+
+func TestApproveLoanWithProofDiscardsDocumentOnConflict(t *testing.T) {
+	repo := new(MockLoanRepository)
+	dir := t.TempDir()
+	documents := NewLocalDocumentStore(dir, "https://api.example.com/api/v1/documents", NewURLSigner("secret"))
+	service := NewLoanService(repo, nil, nil, new(MockOutboxRepository), new(historyRecorder), new(changeRecorder), nil, nil, new(ledgerRecorder), nil, nopTransactor{}, new(MockPDFService), documents)
+
+	ctx := context.Background()
+	loanID := uuid.New()
+
+	repo.On("GetByID", ctx, loanID).Return(&domain.Loan{ID: loanID, State: domain.LoanStateProposed}, nil)
+	repo.On("Update", ctx, mock.Anything).Return(domain.ErrConflict)
+
+	err := service.ApproveLoanWithProof(ctx, loanID, "V123", Upload{
+		Body:        strings.NewReader("jpeg bytes"),
+		ContentType: "image/jpeg",
+		Extension:   ".jpg",
+	})
+
+	assert.ErrorIs(t, err, domain.ErrConflict)
+	entries, err := os.ReadDir(filepath.Join(dir, "loans", loanID.String(), "proof-of-visit"))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDisburseLoanWithAgreementStoresNothingInWrongState(t *testing.T) {
+	repo := new(MockLoanRepository)
+	dir := t.TempDir()
+	documents := NewLocalDocumentStore(dir, "https://api.example.com/api/v1/documents", NewURLSigner("secret"))
+	service := NewLoanService(repo, nil, nil, new(MockOutboxRepository), new(historyRecorder), new(changeRecorder), nil, nil, new(ledgerRecorder), nil, nopTransactor{}, new(MockPDFService), documents)
+
+	ctx := context.Background()
+	loanID := uuid.New()
+
+	repo.On("GetByID", ctx, loanID).Return(&domain.Loan{ID: loanID, State: domain.LoanStateApproved}, nil)
+
+	err := service.DisburseLoanWithAgreement(ctx, loanID, "F1", Upload{
+		Body:        strings.NewReader("%PDF-1.4"),
+		ContentType: "application/pdf",
+		Extension:   ".pdf",
+	})
+
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestLoanLifecycleWithMemoryStore(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1", "B-2")
+	documents := NewLocalDocumentStore(t.TempDir(), "https://api.example.com/api/v1/documents", NewURLSigner("secret"))
+	service := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), NewPDFService(documents), documents)
+	ctx := context.Background()
+
+	loan, err := service.CreateLoan(ctx, "B-1", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+	require.NoError(t, err)
+	require.NoError(t, service.ApproveLoan(ctx, loan.ID, "V1", "https://example.com/proof.jpg"))
+
+	first, second := uuid.New(), uuid.New()
+	registerInvestors(t, store.Investors(), first, second)
+	fundInvestors(t, store, domain.NewMoney(100000000, "IDR"), first, second)
+	require.NoError(t, service.InvestInLoan(ctx, loan.ID, first, domain.NewMoney(60000, "")))
+	err = service.InvestInLoan(ctx, loan.ID, second, domain.NewMoney(50000, ""))
+	assert.ErrorIs(t, err, domain.ErrOverInvestment)
+	require.NoError(t, service.InvestInLoan(ctx, loan.ID, second, domain.NewMoney(40000, "")))
+	attachAgreementLetters(t, ctx, store, service)
+
+	got, err := service.GetLoan(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateInvested, got.State)
+	assert.Len(t, got.Investments, 2)
+	assert.Equal(t, "https://api.example.com/api/v1/documents/agreements/"+loan.ID.String()+".pdf", got.AgreementLetterURL)
+
+	messages, err := store.Outbox().List(ctx, domain.OutboxStatusPending, 10)
+	require.NoError(t, err)
+	assert.Len(t, messages, 2)
+
+	require.NoError(t, service.DisburseLoan(ctx, loan.ID, "F1", "https://example.com/signed.pdf"))
+	err = service.DisburseLoan(ctx, loan.ID, "F1", "https://example.com/signed.pdf")
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition)
+}
+
+func TestAttachAgreementLetterAfterDisbursement(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1")
+	documents := NewLocalDocumentStore(t.TempDir(), "https://api.example.com/api/v1/documents", NewURLSigner("secret"))
+	service := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), NewPDFService(documents), documents)
+	ctx := context.Background()
+
+	loan, err := service.CreateLoan(ctx, "B-1", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+	require.NoError(t, err)
+	require.NoError(t, service.ApproveLoan(ctx, loan.ID, "V1", "https://example.com/proof.jpg"))
+	investor := uuid.New()
+	registerInvestors(t, store.Investors(), investor)
+	fundInvestors(t, store, domain.NewMoney(100000, "IDR"), investor)
+	require.NoError(t, service.InvestInLoan(ctx, loan.ID, investor, domain.NewMoney(100000, "IDR")))
+
+	// The loan is disbursed before the job runs; the job attaches the
+	// letter to the loan as it is then.
+	require.NoError(t, service.DisburseLoan(ctx, loan.ID, "F1", "https://example.com/signed.pdf"))
+	require.NoError(t, service.AttachAgreementLetter(ctx, loan.ID))
+	got, err := service.GetLoan(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateDisbursed, got.State)
+	assert.Equal(t, "https://api.example.com/api/v1/documents/agreements/"+loan.ID.String()+".pdf", got.AgreementLetterURL)
+
+	// A repeated delivery queues no second email.
+	require.NoError(t, service.AttachAgreementLetter(ctx, loan.ID))
+	messages, err := store.Outbox().List(ctx, domain.OutboxStatusPending, 10)
+	require.NoError(t, err)
+	var topics []string
+	for _, m := range messages {
+		topics = append(topics, m.Topic)
+	}
+	assert.ElementsMatch(t, []string{domain.TopicAgreementLetter, domain.TopicInvestmentAgreementEmail}, topics)
+}
+
+func TestAgreementEmailIsSentAfterLetterGenerationIsRetried(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1")
+	documents := NewLocalDocumentStore(t.TempDir(), "https://api.example.com/api/v1/documents", NewURLSigner("secret"))
+	pdfService := new(MockPDFService)
+	service := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), pdfService, documents)
+	emailService := new(MockEmailService)
+	ctx := context.Background()
+
+	loan, err := service.CreateLoan(ctx, "B-1", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+	require.NoError(t, err)
+	require.NoError(t, service.ApproveLoan(ctx, loan.ID, "V1", "https://example.com/proof.jpg"))
+	investor := uuid.New()
+	registerInvestors(t, store.Investors(), investor)
+	fundInvestors(t, store, domain.NewMoney(100000, "IDR"), investor)
+	require.NoError(t, service.InvestInLoan(ctx, loan.ID, investor, domain.NewMoney(100000, "IDR")))
+
+	letter := "https://api.example.com/api/v1/documents/agreements/" + loan.ID.String() + ".pdf"
+	pdfService.On("GenerateAgreementLetter", mock.Anything, mock.Anything).Return("", errors.New("disk full")).Once()
+	pdfService.On("GenerateAgreementLetter", mock.Anything, mock.Anything).Return(letter, nil).Once()
+	signedLetter := mock.MatchedBy(func(link string) bool {
+		return strings.HasPrefix(link, letter+"?") && strings.Contains(link, "signature=")
+	})
+	emailService.On("SendInvestmentAgreement", mock.Anything, investor, signedLetter).Return(nil).Once()
+
+	dispatcher := NewOutboxDispatcher(store.Outbox(), DispatcherConfig{BatchSize: 10, MaxAttempts: 3, BaseBackoff: time.Second, MaxBackoff: time.Minute, Lease: time.Minute},
+		map[string]OutboxHandler{
+			domain.TopicAgreementLetter:          NewAgreementLetterHandler(service),
+			domain.TopicInvestmentAgreementEmail: NewInvestmentAgreementEmailHandler(store.Loans(), documents, emailService),
+		})
+	now := time.Now()
+	dispatcher.now = func() time.Time { return now }
+
+	// The first attempt fails and queues no email.
+	_, err = dispatcher.DispatchOnce(ctx)
+	require.NoError(t, err)
+	got, err := service.GetLoan(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got.AgreementLetterURL)
+	emailService.AssertNotCalled(t, "SendInvestmentAgreement", mock.Anything, mock.Anything, mock.Anything)
+
+	// The retry attaches the letter and queues the email, which the next
+	// pass delivers.
+	now = now.Add(time.Second)
+	_, err = dispatcher.DispatchOnce(ctx)
+	require.NoError(t, err)
+	_, err = dispatcher.DispatchOnce(ctx)
+	require.NoError(t, err)
+
+	got, err = service.GetLoan(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, letter, got.AgreementLetterURL)
+	pdfService.AssertExpectations(t)
+	emailService.AssertExpectations(t)
+	pending, err := store.Outbox().List(ctx, domain.OutboxStatusPending, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestRejectLoan(t *testing.T) {
+	repo := new(MockLoanRepository)
+	service := NewLoanService(repo, nil, nil, new(MockOutboxRepository), new(historyRecorder), new(changeRecorder), nil, nil, new(ledgerRecorder), nil, nopTransactor{}, new(MockPDFService), nil)
+	ctx := context.Background()
+
+	loan := &domain.Loan{ID: uuid.New(), State: domain.LoanStateProposed, Version: 1}
+	repo.On("GetByID", ctx, loan.ID).Return(loan, nil)
+	repo.On("Update", ctx, loan).Return(nil)
+	repo.On("VoidInvestments", ctx, loan.ID, mock.AnythingOfType("time.Time")).Return([]domain.Investment(nil), nil)
+
+	require.NoError(t, service.RejectLoan(ctx, loan.ID, "V1", "address does not exist"))
+	assert.Equal(t, domain.LoanStateRejected, loan.State)
+	require.NotNil(t, loan.ClosureDetails)
+	assert.Equal(t, "V1", loan.ClosureDetails.ActorID)
+	assert.Equal(t, "address does not exist", loan.ClosureDetails.Reason)
+
+	err := service.RejectLoan(ctx, loan.ID, "V1", "again")
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition)
+	repo.AssertNumberOfCalls(t, "Update", 1)
+}
+
+func TestCancelLoanRefundsInvestments(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1", "B-2")
+	service := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), new(MockPDFService), nil)
+	ctx := context.Background()
+
+	loan, err := service.CreateLoan(ctx, "B-1", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+	require.NoError(t, err)
+	require.NoError(t, service.ApproveLoan(ctx, loan.ID, "V1", "https://example.com/proof.jpg"))
+	investor := uuid.New()
+	registerInvestors(t, store.Investors(), investor)
+	fundInvestors(t, store, domain.NewMoney(100000, "IDR"), investor)
+	require.NoError(t, service.InvestInLoan(ctx, loan.ID, investor, domain.NewMoney(60000, "IDR")))
+
+	require.NoError(t, service.CancelLoan(ctx, loan.ID, "ops-1", "borrower withdrew"))
+	wallets, err := store.Wallets().List(ctx, investor)
+	require.NoError(t, err)
+	require.Len(t, wallets, 1)
+	assert.Equal(t, domain.NewMoney(100000, "IDR"), wallets[0].Available, "the reservation is released")
+	assert.Equal(t, domain.NewMoney(0, "IDR"), wallets[0].Reserved)
+
+	got, err := service.GetLoan(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateCancelled, got.State)
+	assert.Equal(t, "ops-1", got.ClosureDetails.ActorID)
+	require.Len(t, got.Investments, 1)
+	assert.NotNil(t, got.Investments[0].VoidedAt)
+
+	messages, err := store.Outbox().List(ctx, domain.OutboxStatusPending, 10)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, domain.TopicInvestmentRefund, messages[0].Topic)
+	var refund domain.InvestmentRefund
+	require.NoError(t, json.Unmarshal(messages[0].Payload, &refund))
+	assert.Equal(t, investor, refund.InvestorID)
+	assert.Equal(t, got.Investments[0].ID, refund.InvestmentID)
+	assert.Equal(t, domain.NewMoney(60000, "IDR"), refund.Amount)
+
+	err = service.InvestInLoan(ctx, loan.ID, investor, domain.NewMoney(1000, "IDR"))
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition)
+	err = service.CancelLoan(ctx, loan.ID, "ops-1", "again")
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition)
+}
+
+func TestCancelLoanRollsBackWhenRefundsCannotBeQueued(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1", "B-2")
+	outbox := new(MockOutboxRepository)
+	service := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), outbox, store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), new(MockPDFService), nil)
+	ctx := context.Background()
+
+	loan, err := service.CreateLoan(ctx, "B-1", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+	require.NoError(t, err)
+	require.NoError(t, service.ApproveLoan(ctx, loan.ID, "V1", "https://example.com/proof.jpg"))
+	_, err = store.Loans().AddInvestment(ctx, &domain.Investment{
+		ID: uuid.New(), LoanID: loan.ID, InvestorID: uuid.New(),
+		Amount: domain.NewMoney(60000, "IDR"), CreatedAt: time.Now(),
+	})
+	require.NoError(t, err)
+
+	outbox.On("Enqueue", mock.Anything, mock.Anything).Return(errors.New("outbox unavailable"))
+	assert.Error(t, service.CancelLoan(ctx, loan.ID, "ops-1", "borrower withdrew"))
+
+	got, err := service.GetLoan(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateApproved, got.State)
+	assert.Nil(t, got.ClosureDetails)
+	assert.Nil(t, got.Investments[0].VoidedAt)
+
+	history, err := service.GetLoanHistory(ctx, loan.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, domain.LoanEventApprove, history[1].Event)
+}
+
+func TestExpireLoans(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1", "B-2")
+	service := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), new(MockPDFService), nil)
+	ctx := context.Background()
+
+	newApproved := func() *domain.Loan {
+		loan, err := service.CreateLoan(ctx, "B-1", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+		require.NoError(t, err)
+		require.NoError(t, service.ApproveLoan(ctx, loan.ID, "V1", "https://example.com/proof.jpg"))
+		return loan
+	}
+	stale := newApproved()
+	investor := uuid.New()
+	registerInvestors(t, store.Investors(), investor)
+	fundInvestors(t, store, domain.NewMoney(50000, "IDR"), investor)
+	require.NoError(t, service.InvestInLoan(ctx, stale.ID, investor, domain.NewMoney(50000, "IDR")))
+	cutoff := time.Now()
+	fresh := newApproved()
+	proposed, err := service.CreateLoan(ctx, "B-2", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+	require.NoError(t, err)
+
+	n, err := service.ExpireLoans(ctx, cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	for id, want := range map[uuid.UUID]domain.LoanState{
+		stale.ID:    domain.LoanStateExpired,
+		fresh.ID:    domain.LoanStateApproved,
+		proposed.ID: domain.LoanStateProposed,
+	} {
+		got, err := service.GetLoan(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, want, got.State)
+	}
+
+	got, err := service.GetLoan(ctx, stale.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.ClosureDetails)
+	assert.Empty(t, got.ClosureDetails.ActorID)
+
+	wallets, err := store.Wallets().List(ctx, investor)
+	require.NoError(t, err)
+	require.Len(t, wallets, 1)
+	assert.Equal(t, domain.NewMoney(50000, "IDR"), wallets[0].Available, "the reservation is released")
+	assert.Equal(t, domain.NewMoney(0, "IDR"), wallets[0].Reserved)
+}
+
+func TestLoanHistoryWithMemoryStore(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1", "B-2")
+	documents := NewLocalDocumentStore(t.TempDir(), "https://api.example.com/api/v1/documents", NewURLSigner("secret"))
+	service := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), NewPDFService(documents), documents)
+	ctx := WithRequestID(context.Background(), "req-1")
+
+	loan, err := service.CreateLoan(ctx, "B-1", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+	require.NoError(t, err)
+	require.NoError(t, service.ApproveLoan(ctx, loan.ID, "V1", "https://example.com/proof.jpg"))
+	investor := uuid.New()
+	registerInvestors(t, store.Investors(), investor)
+	fundInvestors(t, store, domain.NewMoney(100000000, "IDR"), investor)
+	require.NoError(t, service.InvestInLoan(ctx, loan.ID, investor, domain.NewMoney(40000, "IDR")))
+	err = service.InvestInLoan(ctx, loan.ID, investor, domain.NewMoney(70000, "IDR"))
+	assert.ErrorIs(t, err, domain.ErrOverInvestment)
+	require.NoError(t, service.InvestInLoan(ctx, loan.ID, investor, domain.NewMoney(60000, "IDR")))
+	attachAgreementLetters(t, ctx, store, service)
+	require.NoError(t, service.DisburseLoan(ctx, loan.ID, "F1", "https://example.com/signed.pdf"))
+
+	history, err := service.GetLoanHistory(ctx, loan.ID)
+	require.NoError(t, err)
+
+	type step struct {
+		event    domain.LoanEvent
+		from, to domain.LoanState
+		actor    string
+	}
+	want := []step{
+		{domain.LoanEventCreate, "", domain.LoanStateProposed, ""},
+		{domain.LoanEventApprove, domain.LoanStateProposed, domain.LoanStateApproved, "V1"},
+		{domain.LoanEventInvest, domain.LoanStateApproved, domain.LoanStateApproved, investor.String()},
+		{domain.LoanEventInvest, domain.LoanStateApproved, domain.LoanStateInvested, investor.String()},
+		{domain.LoanEventAttachAgreement, domain.LoanStateInvested, domain.LoanStateInvested, ""},
+		{domain.LoanEventDisburse, domain.LoanStateInvested, domain.LoanStateDisbursed, "F1"},
+	}
+	require.Len(t, history, len(want))
+	for i, w := range want {
+		got := history[i]
+		assert.Equal(t, int64(i+1), got.Version)
+		assert.Equal(t, w, step{got.Event, got.FromState, got.ToState, got.ActorID}, "entry %d", i)
+		assert.Equal(t, "req-1", got.RequestID)
+	}
+
+	var approval domain.ApprovalDetails
+	require.NoError(t, json.Unmarshal(history[1].Payload, &approval))
+	assert.Equal(t, "https://example.com/proof.jpg", approval.ProofImageURL)
+
+	final, err := service.GetLoan(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, final.Version, history[len(history)-1].Version)
+
+	_, err = service.GetLoanHistory(ctx, uuid.New())
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestRepaymentScheduleWithMemoryStore(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1")
+	service := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), new(MockPDFService), nil)
+	ctx := context.Background()
+	investor := uuid.New()
+	registerInvestors(t, store.Investors(), investor)
+	fundInvestors(t, store, domain.NewMoney(100000000, "IDR"), investor)
+
+	fund := func(terms domain.RepaymentTerms) *domain.Loan {
+		t.Helper()
+		loan, err := service.CreateLoan(ctx, "B-1", domain.NewMoney(300000, "IDR"), domain.Percent(1200), domain.Percent(800), terms)
+		require.NoError(t, err)
+		require.NoError(t, service.ApproveLoan(ctx, loan.ID, "V1", "https://example.com/proof.jpg"))
+		require.NoError(t, service.InvestInLoan(ctx, loan.ID, investor, domain.NewMoney(300000, "IDR")))
+		return loan
+	}
+
+	loan := fund(domain.RepaymentTerms{TenorMonths: 3, RepaymentType: domain.RepaymentFlat})
+	_, err := service.GetRepaymentSchedule(ctx, loan.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound, "no schedule before disbursement")
+
+	require.NoError(t, service.DisburseLoan(ctx, loan.ID, "F1", "https://example.com/signed.pdf"))
+	disbursed, err := service.GetLoan(ctx, loan.ID)
+	require.NoError(t, err)
+
+	schedule, err := service.GetRepaymentSchedule(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, loan.ID, schedule.LoanID)
+	assert.Equal(t, domain.RepaymentTerms{TenorMonths: 3, RepaymentType: domain.RepaymentFlat}, schedule.RepaymentTerms)
+	require.Len(t, schedule.Installments, 3)
+	y, m, _ := disbursed.DisbursementDetails.DisbursedAt.UTC().Date()
+	nextMonth := time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+	firstDue := schedule.Installments[0].DueDate
+	assert.Equal(t, nextMonth.Month(), firstDue.Month(), "due a month after disbursement")
+	assert.Equal(t, nextMonth.Year(), firstDue.Year())
+	assert.Equal(t, domain.NewMoney(100000, "IDR"), schedule.Installments[0].Principal)
+	assert.Equal(t, domain.NewMoney(3000, "IDR"), schedule.Installments[0].Interest)
+	assert.Equal(t, domain.NewMoney(9000, "IDR"), schedule.TotalInterest)
+	assert.Equal(t, domain.NewMoney(309000, "IDR"), schedule.TotalAmount)
+
+	_, err = service.GetRepaymentSchedule(ctx, uuid.New())
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	// A schedule that cannot be saved leaves the loan undisbursed.
+	clash := fund(emiTerms)
+	require.NoError(t, store.Schedules().Save(ctx, clash.ID, []domain.Installment{
+		domain.NewInstallment(1, time.Now(), domain.NewMoney(1, "IDR"), domain.NewMoney(0, "IDR")),
+	}))
+	err = service.DisburseLoan(ctx, clash.ID, "F1", "https://example.com/signed.pdf")
+	assert.ErrorIs(t, err, domain.ErrConflict)
+	got, err := service.GetLoan(ctx, clash.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateInvested, got.State)
+
+	// Loans proposed before repayment terms were recorded are disbursed
+	// without a schedule.
+	legacy := &domain.Loan{
+		ID: uuid.New(), BorrowerIDNumber: "B-1", PrincipalAmount: domain.NewMoney(300000, "IDR"),
+		Rate: domain.Percent(1200), ROI: domain.Percent(800), State: domain.LoanStateInvested,
+		CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}
+	require.NoError(t, store.Loans().Create(ctx, legacy))
+	require.NoError(t, service.DisburseLoan(ctx, legacy.ID, "F1", "https://example.com/signed.pdf"))
+	_, err = service.GetRepaymentSchedule(ctx, legacy.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestRecordRepaymentWithMemoryStore(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1")
+	service := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), new(MockPDFService), nil)
+	ctx := context.Background()
+	first, second := uuid.New(), uuid.New()
+	registerInvestors(t, store.Investors(), first, second)
+	fundInvestors(t, store, domain.NewMoney(100000000, "IDR"), first, second)
+
+	loan, err := service.CreateLoan(ctx, "B-1", domain.NewMoney(300000, "IDR"), domain.Percent(1200), domain.Percent(200),
+		domain.RepaymentTerms{TenorMonths: 3, RepaymentType: domain.RepaymentFlat})
+	require.NoError(t, err)
+	require.NoError(t, service.ApproveLoan(ctx, loan.ID, "V1", "https://example.com/proof.jpg"))
+	require.NoError(t, service.InvestInLoan(ctx, loan.ID, first, domain.NewMoney(200000, "IDR")))
+	require.NoError(t, service.InvestInLoan(ctx, loan.ID, second, domain.NewMoney(100000, "IDR")))
+
+	_, err = service.RecordRepayment(ctx, loan.ID, domain.NewMoney(103000, "IDR"))
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition, "not disbursed yet")
+	require.NoError(t, service.DisburseLoan(ctx, loan.ID, "F1", "https://example.com/signed.pdf"))
+
+	_, err = service.RecordRepayment(ctx, loan.ID, domain.NewMoney(309001, "IDR"))
+	assert.ErrorIs(t, err, domain.ErrValidation, "more than is owed")
+
+	// The first installment: interest, then principal. The 2% ROI is two
+	// thirds of the schedule's interest.
+	repayment, err := service.RecordRepayment(ctx, loan.ID, domain.NewMoney(103000, ""))
+	require.NoError(t, err)
+	assert.Equal(t, domain.NewMoney(3000, "IDR"), repayment.Interest)
+	assert.Equal(t, domain.NewMoney(100000, "IDR"), repayment.Principal)
+	require.Len(t, repayment.Payouts, 2)
+	assert.Equal(t, first, repayment.Payouts[0].InvestorID)
+	assert.Equal(t, domain.NewMoney(66667, "IDR"), repayment.Payouts[0].Principal)
+	assert.Equal(t, domain.NewMoney(1333, "IDR"), repayment.Payouts[0].Interest)
+	assert.Equal(t, domain.NewMoney(33333, "IDR"), repayment.Payouts[1].Principal)
+	assert.Equal(t, domain.NewMoney(667, "IDR"), repayment.Payouts[1].Interest)
+	assert.Equal(t, domain.NewMoney(1000, "IDR"), repayment.PlatformFee)
+
+	schedule, err := service.GetRepaymentSchedule(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, schedule.Installments[0].SettledAt)
+	assert.Equal(t, domain.NewMoney(206000, "IDR"), schedule.Outstanding)
+	got, err := service.GetLoan(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateDisbursed, got.State)
+
+	_, err = service.RecordRepayment(ctx, loan.ID, domain.NewMoney(206000, "IDR"))
+	require.NoError(t, err)
+	got, err = service.GetLoan(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateRepaid, got.State)
+
+	repayments, err := service.ListRepayments(ctx, loan.ID)
+	require.NoError(t, err)
+	require.Len(t, repayments, 2)
+	assert.Equal(t, repayment.ID, repayments[0].ID)
+	var interest int64
+	for _, r := range repayments {
+		for _, p := range r.Payouts {
+			interest += p.Interest.MinorUnits
+		}
+	}
+	assert.Equal(t, int64(6000), interest, "investors earn the ROI on the principal")
+
+	history, err := service.GetLoanHistory(ctx, loan.ID)
+	require.NoError(t, err)
+	last := history[len(history)-1]
+	assert.Equal(t, domain.LoanEventRepay, last.Event)
+	assert.Equal(t, domain.LoanStateDisbursed, last.FromState)
+	assert.Equal(t, domain.LoanStateRepaid, last.ToState)
+	assert.Equal(t, "B-1", last.ActorID)
+
+	replayed, err := service.GetLoanAsOf(ctx, loan.ID, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateRepaid, replayed.State)
+	assert.Equal(t, got.Version, replayed.Version)
+
+	_, err = service.RecordRepayment(ctx, loan.ID, domain.NewMoney(1, "IDR"))
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition, "nothing is owed on a repaid loan")
+
+	_, err = service.ListRepayments(ctx, uuid.New())
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestDefaultLoans(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1")
+	service := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), new(MockPDFService), nil)
+	ctx := context.Background()
+	investor := uuid.New()
+	registerInvestors(t, store.Investors(), investor)
+	fundInvestors(t, store, domain.NewMoney(100000000, "IDR"), investor)
+
+	disburse := func() *domain.Loan {
+		t.Helper()
+		loan, err := service.CreateLoan(ctx, "B-1", domain.NewMoney(300000, "IDR"), domain.Percent(1200), domain.Percent(200), emiTerms)
+		require.NoError(t, err)
+		require.NoError(t, service.ApproveLoan(ctx, loan.ID, "V1", "https://example.com/proof.jpg"))
+		require.NoError(t, service.InvestInLoan(ctx, loan.ID, investor, domain.NewMoney(300000, "IDR")))
+		require.NoError(t, service.DisburseLoan(ctx, loan.ID, "F1", "https://example.com/signed.pdf"))
+		return loan
+	}
+	overdue := disburse()
+	current := disburse()
+	approved, err := service.CreateLoan(ctx, "B-1", domain.NewMoney(300000, "IDR"), domain.Percent(1200), domain.Percent(200), emiTerms)
+	require.NoError(t, err)
+
+	n, err := service.DefaultLoans(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, n, "nothing is due yet")
+
+	// The current loan has paid the two installments due by the cutoff.
+	schedule, err := service.GetRepaymentSchedule(ctx, current.ID)
+	require.NoError(t, err)
+	_, err = service.RecordRepayment(ctx, current.ID, schedule.Installments[0].Amount.Add(schedule.Installments[1].Amount))
+	require.NoError(t, err)
+
+	n, err = service.DefaultLoans(ctx, time.Now().AddDate(0, 2, 1))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	got, err := service.GetLoan(ctx, overdue.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateDefaulted, got.State)
+	require.NotNil(t, got.ClosureDetails)
+	assert.Empty(t, got.ClosureDetails.ActorID)
+	assert.Equal(t, "installment 1 overdue since "+schedule.Installments[0].DueDate.Format(time.DateOnly), got.ClosureDetails.Reason)
+	assert.Nil(t, got.Investments[0].VoidedAt, "investors keep their claim")
+
+	for _, id := range []uuid.UUID{current.ID, approved.ID} {
+		got, err := service.GetLoan(ctx, id)
+		require.NoError(t, err)
+		assert.NotEqual(t, domain.LoanStateDefaulted, got.State)
+	}
+	_, err = service.RecordRepayment(ctx, overdue.ID, domain.NewMoney(100, "IDR"))
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition)
+}
+
+func TestGetLoanAsOfWithMemoryStore(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1", "B-2")
+	documents := NewLocalDocumentStore(t.TempDir(), "https://api.example.com/api/v1/documents", NewURLSigner("secret"))
+	service := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), NewPDFService(documents), documents)
+	ctx := context.Background()
+
+	// Checkpoints are taken between changes, a millisecond clear of either
+	// side so the stored times cannot round across them.
+	checkpoint := func() time.Time {
+		time.Sleep(time.Millisecond)
+		at := time.Now()
+		time.Sleep(time.Millisecond)
+		return at
+	}
+
+	beforeCreate := checkpoint()
+	loan, err := service.CreateLoan(ctx, "B-1", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+	require.NoError(t, err)
+	require.NoError(t, service.ApproveLoan(ctx, loan.ID, "V1", "https://example.com/proof.jpg"))
+	approved := checkpoint()
+	investor := uuid.New()
+	registerInvestors(t, store.Investors(), investor)
+	fundInvestors(t, store, domain.NewMoney(100000000, "IDR"), investor)
+	require.NoError(t, service.InvestInLoan(ctx, loan.ID, investor, domain.NewMoney(40000, "IDR")))
+	partlyInvested := checkpoint()
+	require.NoError(t, service.InvestInLoan(ctx, loan.ID, investor, domain.NewMoney(60000, "IDR")))
+	require.NoError(t, service.DisburseLoan(ctx, loan.ID, "F1", "https://example.com/signed.pdf"))
+
+	_, err = service.GetLoanAsOf(ctx, loan.ID, beforeCreate)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	got, err := service.GetLoanAsOf(ctx, loan.ID, approved)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateApproved, got.State)
+	assert.Equal(t, int64(2), got.Version)
+	require.NotNil(t, got.ApprovalDetails)
+	assert.Equal(t, "V1", got.ApprovalDetails.FieldValidatorID)
+	assert.Empty(t, got.Investments)
+
+	got, err = service.GetLoanAsOf(ctx, loan.ID, partlyInvested)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateApproved, got.State)
+	assert.Equal(t, int64(3), got.Version)
+	require.Len(t, got.Investments, 1)
+	assert.Equal(t, domain.NewMoney(60000, "IDR"), got.RemainingAmount())
+
+	// Folding the whole stream gives the projection.
+	rebuilt, err := service.GetLoanAsOf(ctx, loan.ID, time.Now())
+	require.NoError(t, err)
+	current, err := service.GetLoan(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, current.State, rebuilt.State)
+	assert.Equal(t, current.Version, rebuilt.Version)
+	assert.Equal(t, current.AgreementLetterURL, rebuilt.AgreementLetterURL)
+	assert.Equal(t, current.DisbursementDetails.FieldOfficerID, rebuilt.DisbursementDetails.FieldOfficerID)
+	assert.True(t, current.UpdatedAt.Equal(rebuilt.UpdatedAt))
+	require.Len(t, rebuilt.Investments, len(current.Investments))
+	for i := range current.Investments {
+		assert.Equal(t, current.Investments[i].ID, rebuilt.Investments[i].ID)
+		assert.Equal(t, current.Investments[i].Amount, rebuilt.Investments[i].Amount)
+	}
+
+	_, err = service.GetLoanAsOf(ctx, uuid.New(), time.Now())
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestCancelLoanAppendsClosure(t *testing.T) {
+	events := new(changeRecorder)
+	store := newStoreWithBorrowers(t, "B-1", "B-2")
+	service := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), events, store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), new(MockPDFService), nil)
+	ctx := context.Background()
+
+	loan, err := service.CreateLoan(ctx, "B-1", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+	require.NoError(t, err)
+	require.NoError(t, service.ApproveLoan(ctx, loan.ID, "V1", "https://example.com/proof.jpg"))
+	investor := uuid.New()
+	registerInvestors(t, store.Investors(), investor)
+	fundInvestors(t, store, domain.NewMoney(100000000, "IDR"), investor)
+	require.NoError(t, service.InvestInLoan(ctx, loan.ID, investor, domain.NewMoney(60000, "IDR")))
+	require.NoError(t, service.CancelLoan(ctx, loan.ID, "ops-1", "borrower withdrew"))
+
+	var types []string
+	for _, r := range events.records {
+		types = append(types, r.Type)
+	}
+	assert.Equal(t, []string{"LoanCreated", "LoanApproved", "InvestmentAdded", "LoanClosed"}, types)
+
+	rebuilt, err := domain.FoldLoan(events.records)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoanStateCancelled, rebuilt.State)
+	assert.Equal(t, int64(4), rebuilt.Version)
+	require.Len(t, rebuilt.Investments, 1)
+	assert.NotNil(t, rebuilt.Investments[0].VoidedAt)
+	assert.True(t, rebuilt.TotalInvestedAmount().IsZero())
+}
+
+func TestCreateLoanRequiresVerifiedBorrower(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1")
+	service := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), new(MockPDFService), nil)
+	ctx := context.Background()
+
+	pending, err := NewBorrowerService(store.Borrowers()).RegisterBorrower(ctx, "B-2", domain.BorrowerDetails{Name: "Budi"})
+	require.NoError(t, err)
+
+	for _, borrowerID := range []string{"B-2", "unregistered"} {
+		_, err := service.CreateLoan(ctx, borrowerID, domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+		assert.ErrorIs(t, err, domain.ErrValidation, borrowerID)
+	}
+	page, err := service.ListLoans(ctx, domain.LoanFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Loans)
+
+	_, err = NewBorrowerService(store.Borrowers()).UpdateBorrower(ctx, pending.ID, pending.BorrowerDetails, domain.KYCStatusVerified)
+	require.NoError(t, err)
+	_, err = service.CreateLoan(ctx, "B-2", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+	assert.NoError(t, err)
+}
+
+func TestInvestInLoanChecksInvestor(t *testing.T) {
+	store := newStoreWithBorrowers(t, "B-1")
+	service := NewLoanService(store.Loans(), store.Borrowers(), store.Investors(), store.Outbox(), store.History(), store.Events(), store.Schedules(), store.Repayments(), store.Ledger(), store.Wallets(), store.Transactor(), new(MockPDFService), nil)
+	investors := NewInvestorService(store.Investors())
+	ctx := context.Background()
+
+	newLoan := func() *domain.Loan {
+		t.Helper()
+		loan, err := service.CreateLoan(ctx, "B-1", domain.NewMoney(100000, "IDR"), domain.Percent(1000), domain.Percent(800), emiTerms)
+		require.NoError(t, err)
+		require.NoError(t, service.ApproveLoan(ctx, loan.ID, "V1", "https://example.com/proof.jpg"))
+		return loan
+	}
+	register := func(limits domain.InvestmentLimits, kyc domain.KYCStatus, status domain.InvestorStatus) uuid.UUID {
+		t.Helper()
+		i, err := investors.RegisterInvestor(ctx, domain.InvestorDetails{Name: "Budi", Limits: limits})
+		require.NoError(t, err)
+		_, err = investors.UpdateInvestor(ctx, i.ID, i.InvestorDetails, kyc, status)
+		require.NoError(t, err)
+		fundInvestors(t, store, domain.NewMoney(100000000, "IDR"), i.ID)
+		return i.ID
+	}
+	money := func(amount int64, currency string) *domain.Money {
+		m := domain.NewMoney(amount, currency)
+		return &m
+	}
+	loan := newLoan()
+
+	t.Run("not allowed to invest", func(t *testing.T) {
+		for name, id := range map[string]uuid.UUID{
+			"unregistered": uuid.New(),
+			"pending KYC":  register(domain.InvestmentLimits{}, domain.KYCStatusPending, domain.InvestorStatusActive),
+			"suspended":    register(domain.InvestmentLimits{}, domain.KYCStatusVerified, domain.InvestorStatusSuspended),
+			"USD limits":   register(domain.InvestmentLimits{PerLoan: money(1000000, "USD")}, domain.KYCStatusVerified, domain.InvestorStatusActive),
+		} {
+			err := service.InvestInLoan(ctx, loan.ID, id, domain.NewMoney(1000, "IDR"))
+			assert.ErrorIs(t, err, domain.ErrValidation, name)
+		}
+		got, err := service.GetLoan(ctx, loan.ID)
+		require.NoError(t, err)
+		assert.Empty(t, got.Investments)
+	})
+
+	t.Run("per-loan limit", func(t *testing.T) {
+		id := register(domain.InvestmentLimits{PerLoan: money(30000, "IDR")}, domain.KYCStatusVerified, domain.InvestorStatusActive)
+		require.NoError(t, service.InvestInLoan(ctx, loan.ID, id, domain.NewMoney(20000, "IDR")))
+		err := service.InvestInLoan(ctx, loan.ID, id, domain.NewMoney(20000, "IDR"))
+		assert.ErrorIs(t, err, domain.ErrValidation)
+		require.NoError(t, service.InvestInLoan(ctx, loan.ID, id, domain.NewMoney(10000, "IDR")))
+
+		// The limit is per loan.
+		require.NoError(t, service.InvestInLoan(ctx, newLoan().ID, id, domain.NewMoney(30000, "IDR")))
+	})
+
+	t.Run("total limit", func(t *testing.T) {
+		id := register(domain.InvestmentLimits{Total: money(50000, "IDR")}, domain.KYCStatusVerified, domain.InvestorStatusActive)
+		require.NoError(t, service.InvestInLoan(ctx, newLoan().ID, id, domain.NewMoney(30000, "IDR")))
+		cancelled := newLoan()
+		require.NoError(t, service.InvestInLoan(ctx, cancelled.ID, id, domain.NewMoney(20000, "IDR")))
+
+		err := service.InvestInLoan(ctx, newLoan().ID, id, domain.NewMoney(10000, "IDR"))
+		assert.ErrorIs(t, err, domain.ErrValidation)
+
+		// Refunded investments no longer count.
+		require.NoError(t, service.CancelLoan(ctx, cancelled.ID, "ops-1", "borrower withdrew"))
+		assert.NoError(t, service.InvestInLoan(ctx, newLoan().ID, id, domain.NewMoney(10000, "IDR")))
+	})
+
+	t.Run("wallet funds", func(t *testing.T) {
+		i, err := investors.RegisterInvestor(ctx, domain.InvestorDetails{Name: "Budi"})
+		require.NoError(t, err)
+		_, err = investors.UpdateInvestor(ctx, i.ID, i.InvestorDetails, domain.KYCStatusVerified, domain.InvestorStatusActive)
+		require.NoError(t, err)
+		err = service.InvestInLoan(ctx, loan.ID, i.ID, domain.NewMoney(1000, "IDR"))
+		assert.ErrorIs(t, err, domain.ErrInsufficientFunds, "nothing deposited")
+		fundInvestors(t, store, domain.NewMoney(25000, "IDR"), i.ID)
+
+		// Concurrent investments cannot reserve more than was deposited.
+		var (
+			wg       sync.WaitGroup
+			invested atomic.Int32
+		)
+		for range 5 {
+			loan := newLoan()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := service.InvestInLoan(ctx, loan.ID, i.ID, domain.NewMoney(10000, "IDR"))
+				if err == nil {
+					invested.Add(1)
+				} else {
+					assert.ErrorIs(t, err, domain.ErrInsufficientFunds)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(2), invested.Load())
+
+		wallets, err := store.Wallets().List(ctx, i.ID)
+		require.NoError(t, err)
+		require.Len(t, wallets, 1)
+		assert.Equal(t, domain.NewMoney(5000, "IDR"), wallets[0].Available)
+		assert.Equal(t, domain.NewMoney(20000, "IDR"), wallets[0].Reserved)
+	})
 }
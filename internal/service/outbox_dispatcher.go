@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+)
+
+// OutboxHandler delivers one outbox message. An error schedules a retry.
+// Delivery is at least once, so handlers must tolerate repeats.
+type OutboxHandler func(ctx context.Context, message *domain.OutboxMessage) error
+
+type DispatcherConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// MaxAttempts is how many deliveries are tried before a message is
+	// dead-lettered.
+	MaxAttempts int
+	// Retries wait BaseBackoff, then twice as long after each further
+	// failure, up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Lease bounds a single delivery. A message claimed by a dispatcher that
+	// dies is picked up again once it expires.
+	Lease time.Duration
+}
+
+// OutboxDispatcher delivers due outbox messages to the handler registered
+// for their topic.
+type OutboxDispatcher struct {
+	repo     repository.OutboxRepository
+	handlers map[string]OutboxHandler
+	config   DispatcherConfig
+	now      func() time.Time
+}
+
+func NewOutboxDispatcher(repo repository.OutboxRepository, config DispatcherConfig, handlers map[string]OutboxHandler) *OutboxDispatcher {
+	return &OutboxDispatcher{
+		repo:     repo,
+		handlers: handlers,
+		config:   config,
+		now:      time.Now,
+	}
+}
+
+// Run dispatches until ctx is cancelled. A full batch is followed straight
+// away by the next one; otherwise it waits PollInterval.
+func (d *OutboxDispatcher) Run(ctx context.Context) {
+	for {
+		n, err := d.DispatchOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Outbox dispatch failed: %v", err)
+		}
+
+		if n < d.config.BatchSize {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(d.config.PollInterval):
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// DispatchOnce claims one batch of due messages, delivers them and records
+// each outcome. It returns how many messages were claimed.
+func (d *OutboxDispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	messages, err := d.repo.Claim(ctx, d.now(), d.config.Lease, d.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range messages {
+		d.deliver(ctx, m)
+	}
+	return len(messages), nil
+}
+
+func (d *OutboxDispatcher) deliver(ctx context.Context, m *domain.OutboxMessage) {
+	err := d.handle(ctx, m)
+
+	now := d.now()
+	m.Attempts++
+	switch {
+	case err == nil:
+		m.Status = domain.OutboxStatusSent
+		m.SentAt = &now
+		m.LastError = ""
+	case m.Attempts >= d.config.MaxAttempts:
+		m.Status = domain.OutboxStatusDead
+		m.LastError = err.Error()
+		log.Printf("Outbox message %s (%s) dead-lettered after %d attempts: %v", m.ID, m.Topic, m.Attempts, err)
+	default:
+		m.NextAttemptAt = now.Add(d.backoff(m.Attempts))
+		m.LastError = err.Error()
+	}
+
+	// If this fails the message is retried once its lease expires.
+	if err := d.repo.Save(ctx, m); err != nil {
+		log.Printf("Failed to record outbox message %s: %v", m.ID, err)
+	}
+}
+
+func (d *OutboxDispatcher) handle(ctx context.Context, m *domain.OutboxMessage) error {
+	handler, ok := d.handlers[m.Topic]
+	if !ok {
+		return fmt.Errorf("no handler for topic %q", m.Topic)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.config.Lease)
+	defer cancel()
+	return handler(ctx, m)
+}
+
+// backoff is the delay before the next attempt after the given number of
+// failed ones.
+func (d *OutboxDispatcher) backoff(attempts int) time.Duration {
+	delay := d.config.BaseBackoff
+	for i := 1; i < attempts && delay < d.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.config.MaxBackoff)
+}
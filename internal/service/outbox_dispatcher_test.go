@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var testDispatcherConfig = DispatcherConfig{
+	PollInterval: time.Second,
+	BatchSize:    10,
+	MaxAttempts:  3,
+	BaseBackoff:  time.Second,
+	MaxBackoff:   5 * time.Second,
+	Lease:        time.Minute,
+}
+
+func newTestDispatcher(repo *MockOutboxRepository, handler OutboxHandler, now time.Time) *OutboxDispatcher {
+	d := NewOutboxDispatcher(repo, testDispatcherConfig, map[string]OutboxHandler{"test": handler})
+	d.now = func() time.Time { return now }
+	return d
+}
+
+func TestDispatchOnceRecordsOutcome(t *testing.T) {
+	now := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	failing := errors.New("smtp: 421 try again later")
+
+	cases := []struct {
+		name          string
+		topic         string
+		priorAttempts int
+		handlerErr    error
+		status        domain.OutboxStatus
+		nextAttemptAt time.Time
+		lastError     string
+	}{
+		{"delivered", "test", 0, nil, domain.OutboxStatusSent, now, ""},
+		{"first failure", "test", 0, failing, domain.OutboxStatusPending, now.Add(time.Second), failing.Error()},
+		{"second failure backs off", "test", 1, failing, domain.OutboxStatusPending, now.Add(2 * time.Second), failing.Error()},
+		{"last attempt dead-letters", "test", 2, failing, domain.OutboxStatusDead, now, failing.Error()},
+		{"unknown topic", "other", 0, nil, domain.OutboxStatusPending, now.Add(time.Second), `no handler for topic "other"`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockOutboxRepository)
+			m := &domain.OutboxMessage{
+				ID:            uuid.New(),
+				Topic:         tc.topic,
+				Status:        domain.OutboxStatusPending,
+				Attempts:      tc.priorAttempts,
+				NextAttemptAt: now,
+			}
+			repo.On("Claim", ctx, now, time.Minute, 10).Return([]*domain.OutboxMessage{m}, nil)
+			repo.On("Save", ctx, m).Return(nil)
+
+			d := newTestDispatcher(repo, func(ctx context.Context, got *domain.OutboxMessage) error {
+				assert.Equal(t, m.ID, got.ID)
+				return tc.handlerErr
+			}, now)
+
+			n, err := d.DispatchOnce(ctx)
+
+			require.NoError(t, err)
+			assert.Equal(t, 1, n)
+			repo.AssertExpectations(t)
+			assert.Equal(t, tc.status, m.Status)
+			assert.Equal(t, tc.priorAttempts+1, m.Attempts)
+			assert.Equal(t, tc.nextAttemptAt, m.NextAttemptAt)
+			assert.Equal(t, tc.lastError, m.LastError)
+			if tc.status == domain.OutboxStatusSent {
+				require.NotNil(t, m.SentAt)
+				assert.Equal(t, now, *m.SentAt)
+			} else {
+				assert.Nil(t, m.SentAt)
+			}
+		})
+	}
+}
+
+func TestDispatcherBackoffIsCapped(t *testing.T) {
+	d := newTestDispatcher(new(MockOutboxRepository), nil, time.Now())
+
+	var got []time.Duration
+	for attempts := 1; attempts <= 6; attempts++ {
+		got = append(got, d.backoff(attempts))
+	}
+	assert.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second, 5 * time.Second,
+	}, got)
+}
+
+func TestInvestmentAgreementEmailWaitsForAgreementLetter(t *testing.T) {
+	ctx := context.Background()
+	loans := new(MockLoanRepository)
+	emailService := new(MockEmailService)
+	documents := NewLocalDocumentStore(t.TempDir(), "https://api.example.com/api/v1/documents", NewURLSigner("secret"))
+	handler := NewInvestmentAgreementEmailHandler(loans, documents, emailService)
+
+	loanID, investorID := uuid.New(), uuid.New()
+	m, err := domain.NewOutboxMessage(domain.TopicInvestmentAgreementEmail,
+		domain.InvestmentAgreementEmail{LoanID: loanID, InvestorID: investorID}, time.Now())
+	require.NoError(t, err)
+
+	investments := []domain.Investment{{ID: uuid.New(), InvestorID: investorID}}
+	loans.On("GetByID", ctx, loanID).Return(&domain.Loan{ID: loanID, Investments: investments}, nil).Once()
+	assert.Error(t, handler(ctx, m))
+	emailService.AssertNotCalled(t, "SendInvestmentAgreement", mock.Anything, mock.Anything, mock.Anything)
+
+	loans.On("GetByID", ctx, loanID).Return(&domain.Loan{
+		ID:                 loanID,
+		AgreementLetterURL: "https://api.example.com/api/v1/documents/agreements/" + loanID.String() + ".pdf",
+		Investments:        investments,
+	}, nil)
+	signedLetter := mock.MatchedBy(func(link string) bool {
+		return strings.HasPrefix(link, "https://api.example.com/api/v1/documents/agreements/"+loanID.String()+".pdf?") &&
+			strings.Contains(link, "signature=")
+	})
+	emailService.On("SendInvestmentAgreement", ctx, investorID, signedLetter).Return(nil)
+	assert.NoError(t, handler(ctx, m))
+	emailService.AssertExpectations(t)
+}
+
+func TestInvestmentAgreementEmailOnlyGoesToInvestors(t *testing.T) {
+	ctx := context.Background()
+	loans := new(MockLoanRepository)
+	emailService := new(MockEmailService)
+	documents := NewLocalDocumentStore(t.TempDir(), "https://api.example.com/api/v1/documents", NewURLSigner("secret"))
+	handler := NewInvestmentAgreementEmailHandler(loans, documents, emailService)
+
+	loanID, investorID := uuid.New(), uuid.New()
+	voidedAt := time.Now()
+	loans.On("GetByID", ctx, loanID).Return(&domain.Loan{
+		ID:                 loanID,
+		AgreementLetterURL: "https://api.example.com/api/v1/documents/agreements/" + loanID.String() + ".pdf",
+		Investments:        []domain.Investment{{ID: uuid.New(), InvestorID: investorID, VoidedAt: &voidedAt}},
+	}, nil)
+
+	for _, id := range []uuid.UUID{investorID, uuid.New()} {
+		m, err := domain.NewOutboxMessage(domain.TopicInvestmentAgreementEmail,
+			domain.InvestmentAgreementEmail{LoanID: loanID, InvestorID: id}, time.Now())
+		require.NoError(t, err)
+		assert.ErrorIs(t, handler(ctx, m), domain.ErrValidation)
+	}
+	emailService.AssertNotCalled(t, "SendInvestmentAgreement", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestInvestmentRefundHandler(t *testing.T) {
+	emailService := new(MockEmailService)
+	handler := NewInvestmentRefundHandler(emailService)
+
+	refund := domain.InvestmentRefund{
+		LoanID:       uuid.New(),
+		InvestmentID: uuid.New(),
+		InvestorID:   uuid.New(),
+		Amount:       domain.NewMoney(60000, "IDR"),
+	}
+	m, err := domain.NewOutboxMessage(domain.TopicInvestmentRefund, refund, time.Now())
+	require.NoError(t, err)
+
+	emailService.On("SendInvestmentRefund", mock.Anything, refund.InvestorID, refund.LoanID, refund.Amount).Return(nil)
+	assert.NoError(t, handler(context.Background(), m))
+	emailService.AssertExpectations(t)
+}
+
+func TestRetryMessageOnlyRequeuesDeadMessages(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockOutboxRepository)
+	s := NewOutboxService(repo)
+
+	sent := &domain.OutboxMessage{ID: uuid.New(), Status: domain.OutboxStatusSent, Attempts: 1}
+	dead := &domain.OutboxMessage{ID: uuid.New(), Status: domain.OutboxStatusDead, Attempts: 3, LastError: "boom"}
+	repo.On("Get", ctx, sent.ID).Return(sent, nil)
+	repo.On("Get", ctx, dead.ID).Return(dead, nil)
+	repo.On("Save", ctx, dead).Return(nil)
+
+	_, err := s.RetryMessage(ctx, sent.ID)
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition)
+
+	retried, err := s.RetryMessage(ctx, dead.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OutboxStatusPending, retried.Status)
+	assert.Zero(t, retried.Attempts)
+	repo.AssertExpectations(t)
+}
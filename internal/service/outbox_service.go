@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultOutboxPageSize = 50
+	MaxOutboxPageSize     = 500
+)
+
+// OutboxService lets operators inspect outbox messages and requeue
+// dead-lettered ones.
+type OutboxService interface {
+	ListMessages(ctx context.Context, status domain.OutboxStatus, limit int) ([]*domain.OutboxMessage, error)
+	// RetryMessage makes a dead message pending again with a fresh set of
+	// attempts.
+	RetryMessage(ctx context.Context, id uuid.UUID) (*domain.OutboxMessage, error)
+}
+
+type outboxService struct {
+	repo repository.OutboxRepository
+}
+
+func NewOutboxService(repo repository.OutboxRepository) OutboxService {
+	return &outboxService{repo: repo}
+}
+
+func (s *outboxService) ListMessages(ctx context.Context, status domain.OutboxStatus, limit int) ([]*domain.OutboxMessage, error) {
+	if limit <= 0 {
+		limit = DefaultOutboxPageSize
+	}
+	if limit > MaxOutboxPageSize {
+		limit = MaxOutboxPageSize
+	}
+	return s.repo.List(ctx, status, limit)
+}
+
+func (s *outboxService) RetryMessage(ctx context.Context, id uuid.UUID) (*domain.OutboxMessage, error) {
+	m, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Status != domain.OutboxStatusDead {
+		return nil, domain.Errorf(domain.ErrInvalidTransition, "only dead messages can be retried; message %s is %s", m.ID, m.Status)
+	}
+
+	m.Status = domain.OutboxStatusPending
+	m.Attempts = 0
+	m.NextAttemptAt = time.Now()
+	if err := s.repo.Save(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
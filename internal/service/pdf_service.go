@@ -1,24 +1,164 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"strings"
 
 	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/pdf"
 )
 
 type PDFService interface {
-	GenerateAgreementLetter(loan *domain.Loan) (string, error)
+	// GenerateAgreementLetter renders the agreement for a fully invested loan,
+	// stores it and returns its URL. Rendering is deterministic, so calling it
+	// again for the same loan replaces the document with an identical one.
+	GenerateAgreementLetter(ctx context.Context, loan *domain.Loan) (string, error)
 }
 
-type pdfService struct{}
+type pdfService struct {
+	store DocumentStore
+}
+
+func NewPDFService(store DocumentStore) PDFService {
+	return &pdfService{store: store}
+}
+
+func (s *pdfService) GenerateAgreementLetter(ctx context.Context, loan *domain.Loan) (string, error) {
+	doc := renderAgreement(loan)
+	key := fmt.Sprintf("agreements/%s.pdf", loan.ID)
+	return s.store.Put(ctx, key, bytes.NewReader(doc), "application/pdf")
+}
+
+const (
+	pageMargin = 56.0
+	dateFormat = "02 January 2006"
+)
 
-func NewPDFService() PDFService {
-	return &pdfService{}
+// agreementWriter lays text out top to bottom, starting new pages as needed.
+type agreementWriter struct {
+	doc *pdf.Document
+	y   float64
 }
 
-func (s *pdfService) GenerateAgreementLetter(loan *domain.Loan) (string, error) {
-	//TODO: Implement wkhtmltopdf
+func (w *agreementWriter) ensure(height float64) {
+	if w.y-height < pageMargin {
+		w.doc.AddPage()
+		w.y = pdf.PageHeight - pageMargin
+	}
+}
+
+func (w *agreementWriter) line(font pdf.Font, size float64, text string) {
+	w.ensure(size * 1.5)
+	w.y -= size * 1.5
+	w.doc.Text(pageMargin, w.y, font, size, text)
+}
+
+func (w *agreementWriter) columns(font pdf.Font, size float64, xs []float64, cells ...string) {
+	w.ensure(size * 1.6)
+	w.y -= size * 1.6
+	for i, cell := range cells {
+		w.doc.Text(pageMargin+xs[i], w.y, font, size, cell)
+	}
+}
+
+func (w *agreementWriter) paragraph(size float64, text string) {
+	width := pdf.PageWidth - 2*pageMargin
+	var current string
+	for _, word := range strings.Fields(text) {
+		candidate := strings.TrimSpace(current + " " + word)
+		if current != "" && pdf.TextWidth(candidate, size) > width {
+			w.line(pdf.Helvetica, size, current)
+			candidate = word
+		}
+		current = candidate
+	}
+	if current != "" {
+		w.line(pdf.Helvetica, size, current)
+	}
+}
+
+func (w *agreementWriter) rule() {
+	w.ensure(12)
+	w.y -= 8
+	w.doc.Line(pageMargin, w.y, pdf.PageWidth-pageMargin, w.y)
+}
+
+func (w *agreementWriter) gap(height float64) {
+	w.y -= height
+}
+
+// renderAgreement lays out the agreement letter. It only reads the loan, so
+// the same loan always renders the same bytes.
+func renderAgreement(loan *domain.Loan) []byte {
+	doc := pdf.New(fmt.Sprintf("Loan Agreement %s", loan.ID))
+	doc.AddPage()
+	w := &agreementWriter{doc: doc, y: pdf.PageHeight - pageMargin}
+
+	w.line(pdf.HelveticaBold, 20, "Loan Agreement")
+	w.line(pdf.Helvetica, 10, "Agreement reference: "+loan.ID.String())
+	w.rule()
+	w.gap(6)
+
+	w.line(pdf.HelveticaBold, 12, "Loan terms")
+	terms := [][2]string{
+		{"Borrower ID number", loan.BorrowerIDNumber},
+		{"Principal amount", formatMoney(loan.PrincipalAmount)},
+		{"Borrower interest rate", loan.Rate.String() + "%"},
+		{"Investor return (ROI)", loan.ROI.String() + "%"},
+		{"Proposed on", loan.CreatedAt.UTC().Format(dateFormat)},
+	}
+	if loan.ApprovalDetails != nil {
+		terms = append(terms,
+			[2]string{"Approved on", loan.ApprovalDetails.ApprovedAt.UTC().Format(dateFormat)},
+			[2]string{"Field validator", loan.ApprovalDetails.FieldValidatorID},
+		)
+	}
+	if fundedAt, ok := loan.FullyInvestedAt(); ok {
+		terms = append(terms, [2]string{"Fully invested on", fundedAt.UTC().Format(dateFormat)})
+	}
+	for _, term := range terms {
+		w.columns(pdf.Helvetica, 10, []float64{0, 170}, term[0], term[1])
+	}
+	w.gap(10)
+
+	w.line(pdf.HelveticaBold, 12, "Investor allocations")
+	xs := []float64{0, 250, 350, 410}
+	w.columns(pdf.HelveticaBold, 9, xs, "Investor", "Amount", "Share", "Invested on")
+	w.rule()
+	for _, inv := range loan.Investments {
+		w.columns(pdf.Helvetica, 9, xs,
+			inv.InvestorID.String(),
+			formatMoney(inv.Amount),
+			share(inv.Amount, loan.PrincipalAmount),
+			inv.CreatedAt.UTC().Format(dateFormat),
+		)
+	}
+	w.rule()
+	w.columns(pdf.HelveticaBold, 9, xs, "Total", formatMoney(loan.TotalInvestedAmount()), "", "")
+	w.gap(14)
+
+	w.line(pdf.HelveticaBold, 12, "Terms")
+	w.paragraph(10, fmt.Sprintf(
+		"The investors listed above have jointly funded the principal of %s to the borrower "+
+			"identified by %s at an interest rate of %s%%. Each investor is entitled to a return "+
+			"of %s%% on the amount they invested, paid in proportion to their share of the "+
+			"principal. The loan is disbursed once the borrower has signed this agreement in "+
+			"the presence of a field officer.",
+		formatMoney(loan.PrincipalAmount), loan.BorrowerIDNumber, loan.Rate, loan.ROI))
+
+	return doc.Bytes()
+}
+
+func formatMoney(m domain.Money) string {
+	return m.String() + " " + m.Currency
+}
 
-	//Returning mock
-	return fmt.Sprintf("https://vibhordubey.com/agreements/%s.pdf", loan.ID), nil
+// share formats part as a percentage of whole with two decimals.
+func share(part, whole domain.Money) string {
+	if whole.MinorUnits == 0 {
+		return "-"
+	}
+	return part.ShareOf(whole).String() + "%"
 }
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateAgreementLetterStoresPDF(t *testing.T) {
+	dir := t.TempDir()
+	service := NewPDFService(NewLocalDocumentStore(dir, "https://docs.example.com/", NewURLSigner("secret")))
+
+	at := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	loan := &domain.Loan{
+		ID:               uuid.New(),
+		BorrowerIDNumber: "3171-0000-1111",
+		PrincipalAmount:  domain.NewMoney(100000, "IDR"),
+		Rate:             domain.Percent(1250),
+		ROI:              domain.Percent(1000),
+		State:            domain.LoanStateInvested,
+		CreatedAt:        at,
+		UpdatedAt:        at,
+		Investments: []domain.Investment{
+			{InvestorID: uuid.New(), Amount: domain.NewMoney(66667, "IDR"), CreatedAt: at},
+			{InvestorID: uuid.New(), Amount: domain.NewMoney(33333, "IDR"), CreatedAt: at},
+		},
+	}
+
+	url, err := service.GenerateAgreementLetter(context.Background(), loan)
+	require.NoError(t, err)
+	assert.Equal(t, "https://docs.example.com/agreements/"+loan.ID.String()+".pdf", url)
+
+	data, err := os.ReadFile(filepath.Join(dir, "agreements", loan.ID.String()+".pdf"))
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-")))
+	for _, want := range []string{"3171-0000-1111", "1000.00 IDR", "12.50%", "666.67 IDR", "66.67%", "33.33%"} {
+		assert.Contains(t, string(data), want)
+	}
+
+	assert.Equal(t, data, renderAgreement(loan), "rendering should be deterministic")
+}
+
+func TestAgreementLetterRenderedAfterDisbursementIsUnchanged(t *testing.T) {
+	approvedAt := time.Date(2025, 2, 20, 9, 0, 0, 0, time.UTC)
+	fundedAt := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	loan := &domain.Loan{
+		ID:               uuid.New(),
+		BorrowerIDNumber: "3171-0000-1111",
+		PrincipalAmount:  domain.NewMoney(100000, "IDR"),
+		Rate:             domain.Percent(1250),
+		ROI:              domain.Percent(1000),
+		State:            domain.LoanStateApproved,
+		ApprovalDetails:  &domain.ApprovalDetails{FieldValidatorID: "V1", ProofImageURL: "https://example.com/proof.jpg", ApprovedAt: approvedAt},
+		CreatedAt:        approvedAt,
+		UpdatedAt:        approvedAt,
+	}
+	require.NoError(t, loan.AddInvestment(&domain.Investment{InvestorID: uuid.New(), Amount: domain.NewMoney(100000, "IDR"), CreatedAt: fundedAt}, fundedAt))
+	invested := renderAgreement(loan)
+
+	disbursedAt := time.Date(2025, 3, 20, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, domain.LoanLifecycle.Fire(loan, domain.LoanEventDisburse,
+		&domain.DisbursementDetails{FieldOfficerID: "F1", SignedAgreementURL: "https://example.com/signed.pdf", DisbursedAt: disbursedAt}, disbursedAt))
+	disbursed := renderAgreement(loan)
+
+	assert.Contains(t, string(disbursed), "01 March 2025")
+	assert.NotContains(t, string(disbursed), "20 March 2025")
+	assert.Equal(t, invested, disbursed)
+}
+
+func TestShareOfLargePrincipal(t *testing.T) {
+	whole := domain.NewMoney(4_000_000_000_000_000, "IDR")
+	assert.Equal(t, "25.00%", share(domain.NewMoney(1_000_000_000_000_000, "IDR"), whole))
+	assert.Equal(t, "-", share(domain.NewMoney(0, "IDR"), domain.NewMoney(0, "IDR")))
+}
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+)
+
+// S3Config points at an S3-compatible bucket (AWS S3, MinIO, Ceph, ...).
+// Objects are addressed path-style as Endpoint/Bucket/key.
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// maxPresignTTL is the longest expiry S3 accepts for a presigned URL.
+const maxPresignTTL = 7 * 24 * time.Hour
+
+type s3DocumentStore struct {
+	config  S3Config
+	baseURL string
+	client  *http.Client
+	signer  sigV4
+	now     func() time.Time
+}
+
+// NewS3DocumentStore stores documents in an S3-compatible bucket using
+// Signature Version 4 requests. Links returned by Put are baseURL + "/" + key,
+// like the local store; SignedURL presigns a direct GET on the bucket.
+func NewS3DocumentStore(config S3Config, baseURL string, client *http.Client) DocumentStore {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &s3DocumentStore{
+		config:  config,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  client,
+		signer: sigV4{
+			accessKeyID:     config.AccessKeyID,
+			secretAccessKey: config.SecretAccessKey,
+			region:          config.Region,
+			service:         "s3",
+		},
+		now: time.Now,
+	}
+}
+
+func (s *s3DocumentStore) objectURL(key string) *url.URL {
+	u, _ := url.Parse(strings.TrimSuffix(s.config.Endpoint, "/"))
+	u.Path = "/" + s.config.Bucket + "/" + key
+	return u
+}
+
+func (s *s3DocumentStore) Put(ctx context.Context, key string, body io.Reader, contentType string) (string, error) {
+	if err := validateDocumentKey(key); err != nil {
+		return "", err
+	}
+
+	// The payload hash is part of the signature, so the body is buffered.
+	// Documents are bounded by the upload limits in the handlers.
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req, data)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	return s.baseURL + "/" + key, nil
+}
+
+func (s *s3DocumentStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateDocumentKey(key); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *s3DocumentStore) Delete(ctx context.Context, key string) error {
+	if err := validateDocumentKey(key); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req, nil)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3DocumentStore) Key(link string) (string, error) {
+	return linkKey(s.baseURL, link)
+}
+
+func (s *s3DocumentStore) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if err := validateDocumentKey(key); err != nil {
+		return "", err
+	}
+	if ttl > maxPresignTTL {
+		ttl = maxPresignTTL
+	}
+	return s.signer.presign(http.MethodGet, s.objectURL(key), s.now(), ttl), nil
+}
+
+// do signs and sends req, turning non-2xx responses into errors.
+func (s *s3DocumentStore) do(req *http.Request, payload []byte) (*http.Response, error) {
+	sum := sha256.Sum256(payload)
+	s.signer.sign(req, hex.EncodeToString(sum[:]), s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, domain.Errorf(domain.ErrNotFound, "document %s not found", strings.TrimPrefix(req.URL.Path, "/"+s.config.Bucket+"/"))
+	}
+	return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, bytes.TrimSpace(detail))
+}
+
+// sigV4 implements AWS Signature Version 4 for header-signed requests and
+// presigned URLs.
+type sigV4 struct {
+	accessKeyID     string
+	secretAccessKey string
+	region          string
+	service         string
+}
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	amzDateFormat   = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// sign adds X-Amz-Date, X-Amz-Content-Sha256 and Authorization headers.
+// Host and every header already set on req are signed.
+func (s sigV4) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := s.scope(now)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.accessKeyID, scope, signedHeaders, s.signature(now, amzDate, scope, canonicalRequest)))
+}
+
+// presign returns u with query parameters authorising method until ttl has
+// passed. Only the host header is signed.
+func (s sigV4) presign(method string, u *url.URL, now time.Time, ttl time.Duration) string {
+	amzDate := now.UTC().Format(amzDateFormat)
+	scope := s.scope(now)
+
+	q := u.Query()
+	q.Set("X-Amz-Algorithm", sigV4Algorithm)
+	q.Set("X-Amz-Credential", s.accessKeyID+"/"+scope)
+	q.Set("X-Amz-Date", amzDate)
+	q.Set("X-Amz-Expires", strconv.Itoa(int(ttl.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalURI(u),
+		canonicalQuery(q),
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+
+	q.Set("X-Amz-Signature", s.signature(now, amzDate, scope, canonicalRequest))
+
+	signed := *u
+	signed.RawQuery = canonicalQuery(q)
+	return signed.String()
+}
+
+func (s sigV4) scope(now time.Time) string {
+	return now.UTC().Format("20060102") + "/" + s.region + "/" + s.service + "/aws4_request"
+}
+
+func (s sigV4) signature(now time.Time, amzDate, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, hex.EncodeToString(hash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), now.UTC().Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func canonicalURI(u *url.URL) string {
+	segments := strings.Split(u.Path, "/")
+	for i, segment := range segments {
+		segments[i] = awsEscape(segment)
+	}
+	uri := strings.Join(segments, "/")
+	if uri == "" {
+		return "/"
+	}
+	return uri
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes everything but RFC 3986 unreserved characters.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
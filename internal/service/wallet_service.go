@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// WalletService moves investors' money in and out of their wallets. The
+// loan service reserves investments from them.
+type WalletService interface {
+	// GetWallets returns the investor's wallets, one per currency money has
+	// moved through.
+	GetWallets(ctx context.Context, investorID uuid.UUID) ([]*domain.Wallet, error)
+	// Deposit pays amount into the investor's wallet in its currency.
+	Deposit(ctx context.Context, investorID uuid.UUID, amount domain.Money) (*domain.Wallet, error)
+	// Withdraw pays amount out of the investor's wallet in its currency. It
+	// fails with domain.ErrInsufficientFunds if more than is available is
+	// asked for; reserved money cannot be withdrawn.
+	Withdraw(ctx context.Context, investorID uuid.UUID, amount domain.Money) (*domain.Wallet, error)
+}
+
+type walletService struct {
+	wallets   repository.WalletRepository
+	investors repository.InvestorRepository
+	ledger    repository.LedgerRepository
+	tx        repository.Transactor
+}
+
+func NewWalletService(wallets repository.WalletRepository, investors repository.InvestorRepository, ledger repository.LedgerRepository, tx repository.Transactor) WalletService {
+	return &walletService{wallets: wallets, investors: investors, ledger: ledger, tx: tx}
+}
+
+func (s *walletService) GetWallets(ctx context.Context, investorID uuid.UUID) ([]*domain.Wallet, error) {
+	// An unknown investor is not found rather than without wallets.
+	if _, err := s.investors.GetByID(ctx, investorID); err != nil {
+		return nil, err
+	}
+	return s.wallets.List(ctx, investorID)
+}
+
+func (s *walletService) Deposit(ctx context.Context, investorID uuid.UUID, amount domain.Money) (*domain.Wallet, error) {
+	return s.move(ctx, investorID, amount, (*domain.Wallet).Deposit, domain.DepositEntry)
+}
+
+func (s *walletService) Withdraw(ctx context.Context, investorID uuid.UUID, amount domain.Money) (*domain.Wallet, error) {
+	return s.move(ctx, investorID, amount, (*domain.Wallet).Withdraw, domain.WithdrawalEntry)
+}
+
+// move applies change to the investor's locked wallet and posts the entry
+// recording it in the same transaction, so the wallet and its ledger
+// account move together.
+func (s *walletService) move(ctx context.Context, investorID uuid.UUID, amount domain.Money,
+	change func(*domain.Wallet, domain.Money, time.Time) error,
+	newEntry func(investorID, referenceID uuid.UUID, amount domain.Money, at time.Time) (*domain.JournalEntry, error),
+) (*domain.Wallet, error) {
+	now := time.Now()
+	entry, err := newEntry(investorID, uuid.New(), amount, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallet *domain.Wallet
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		wallet, err = s.wallets.GetForUpdate(ctx, investorID, amount.Currency)
+		if err != nil {
+			return err
+		}
+		if err := change(wallet, amount, now); err != nil {
+			return err
+		}
+		if err := s.wallets.Save(ctx, wallet); err != nil {
+			return err
+		}
+		return s.ledger.Post(ctx, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"vibhordubey333/loan-service/internal/domain"
+	"vibhordubey333/loan-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalletDepositsAndWithdrawals(t *testing.T) {
+	store := repository.NewMemoryStore()
+	service := NewWalletService(store.Wallets(), store.Investors(), store.Ledger(), store.Transactor())
+	ctx := context.Background()
+	idr := func(v int64) domain.Money { return domain.NewMoney(v, "IDR") }
+	investor := uuid.New()
+	registerInvestors(t, store.Investors(), investor)
+
+	w, err := service.Deposit(ctx, investor, idr(100000))
+	require.NoError(t, err)
+	assert.Equal(t, idr(100000), w.Available)
+
+	_, err = service.Withdraw(ctx, investor, idr(100001))
+	assert.ErrorIs(t, err, domain.ErrInsufficientFunds)
+	w, err = service.Withdraw(ctx, investor, idr(40000))
+	require.NoError(t, err)
+	assert.Equal(t, idr(60000), w.Available)
+
+	wallets, err := service.GetWallets(ctx, investor)
+	require.NoError(t, err)
+	require.Len(t, wallets, 1)
+	assert.Equal(t, idr(60000), wallets[0].Available)
+
+	// Every movement is in the ledger, and failed ones leave no trace.
+	balances, err := store.Ledger().Balances(ctx)
+	require.NoError(t, err)
+	got := make(map[domain.Account]domain.Money)
+	for _, b := range balances {
+		got[b.Account] = b.Balance
+	}
+	assert.Equal(t, map[domain.Account]domain.Money{
+		domain.InvestorWalletAccount(investor, "IDR"): idr(60000),
+		domain.DepositsAccount("IDR"):                 idr(60000),
+	}, got)
+
+	_, err = service.Deposit(ctx, investor, idr(0))
+	assert.ErrorIs(t, err, domain.ErrValidation)
+	_, err = service.Deposit(ctx, uuid.New(), idr(1000))
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = service.GetWallets(ctx, uuid.New())
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
@@ -0,0 +1,76 @@
+// Package testdb gives database tests a Postgres schema of their own on the
+// server named by TEST_DATABASE_URL (for example the docker-compose db
+// started by make test-integration). Tests are skipped when it is unset.
+package testdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"vibhordubey333/loan-service/internal/migrate"
+	"vibhordubey333/loan-service/schema"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+// OpenEmpty creates an empty schema and returns a handle whose connections
+// use it as their search_path. The schema is dropped when the test ends, so
+// tests neither see nor leave behind each other's rows.
+func OpenEmpty(t *testing.T) *sql.DB {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	admin, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec(`CREATE SCHEMA ` + name); err != nil {
+		admin.Close()
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(`DROP SCHEMA ` + name + ` CASCADE`)
+		admin.Close()
+	})
+
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("postgres", fmt.Sprintf("%s%ssearch_path=%s", url, sep, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Registered after the schema cleanup, so it runs first.
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Open is OpenEmpty with every migration applied.
+func Open(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db := OpenEmpty(t)
+	migrations, err := migrate.Load(schema.Migrations, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrate.New(db, migrations).Up(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
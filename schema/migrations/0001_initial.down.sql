@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS outbox;
+DROP TABLE IF EXISTS investments;
+DROP TABLE IF EXISTS loans;
@@ -1,11 +1,16 @@
+/* Baseline schema. IF NOT EXISTS lets databases created before migrations
+   were tracked adopt it. */
+
 /* Storing loan information and state*/
 CREATE TABLE IF NOT EXISTS loans (
     id UUID PRIMARY KEY,
     borrower_id_number TEXT NOT NULL,
     principal_amount DECIMAL(15,2) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'IDR',
     rate DECIMAL(5,2) NOT NULL,
     roi DECIMAL(5,2) NOT NULL,
     state TEXT NOT NULL,
+    version BIGINT NOT NULL DEFAULT 1,
     approval_details JSONB,
     disbursement_details JSONB,
     agreement_letter_url TEXT,
@@ -23,30 +28,27 @@ CREATE TABLE IF NOT EXISTS investments (
     loan_id UUID NOT NULL,
     investor_id UUID NOT NULL,
     amount DECIMAL(15,2) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'IDR',
     created_at TIMESTAMPTZ NOT NULL
 );
 
 CREATE INDEX IF NOT EXISTS idx_investments_loan ON investments(loan_id);
 CREATE INDEX IF NOT EXISTS idx_investments_investor ON investments(investor_id);
 
--- Inserting mock data
-
-INSERT INTO loans (
-    id, borrower_id_number, principal_amount, rate, roi, state,
-    approval_details, disbursement_details, agreement_letter_url,
-    created_at, updated_at
-) VALUES
-(
-'550e8400-e29b-41d4-a716-446655440000', '123-45-6789', 10000.00, 5.00, 6.00, 'approved',
-'{"approved_by": "Alice Johnson", "approval_date": "2023-01-15"}'::jsonb,
-'{"disbursed_by": "Bob Smith", "disbursement_date": "2023-01-20"}'::jsonb,
-'https://example.com/agreements/loan_550e8400-e29b-41d4-a716-446655440000.pdf',
-'2023-01-15T10:00:00Z', '2023-01-20T10:00:00Z'
-)
-
-INSERT INTO investments (
-id, loan_id, investor_id, amount, created_at
-) VALUES
-(
-'860e8400-e29b-41d4-a716-446655440000', '550e8400-e29b-41d4-a716-446655440000', '550e8400-e29b-41d4-a716-446655440000', 5000.00, '2023-01-15T10:30:00Z'
-)
\ No newline at end of file
+
+/* Side effects written with the state change that causes them and
+   delivered by the outbox dispatcher */
+CREATE TABLE IF NOT EXISTS outbox (
+    id UUID PRIMARY KEY,
+    topic TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_due ON outbox(next_attempt_at) WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS idx_outbox_created ON outbox(created_at);
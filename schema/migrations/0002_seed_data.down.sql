@@ -0,0 +1,2 @@
+DELETE FROM investments WHERE id = '860e8400-e29b-41d4-a716-446655440000';
+DELETE FROM loans WHERE id = '550e8400-e29b-41d4-a716-446655440000';
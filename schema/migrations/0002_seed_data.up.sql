@@ -0,0 +1,17 @@
+/* Sample data for local development: an approved loan that is half
+   invested. */
+INSERT INTO loans (
+    id, borrower_id_number, principal_amount, currency, rate, roi, state,
+    approval_details, created_at, updated_at
+) VALUES (
+    '550e8400-e29b-41d4-a716-446655440000', '123-45-6789', 10000.00, 'IDR', 5.00, 6.00, 'APPROVED',
+    '{"field_validator_id": "Alice Johnson", "proof_image_url": "https://example.com/proofs/550e8400.jpg", "approved_at": "2023-01-15T10:00:00Z"}'::jsonb,
+    '2023-01-10T10:00:00Z', '2023-01-15T10:30:00Z'
+);
+
+INSERT INTO investments (
+    id, loan_id, investor_id, amount, currency, created_at
+) VALUES (
+    '860e8400-e29b-41d4-a716-446655440000', '550e8400-e29b-41d4-a716-446655440000',
+    '770e8400-e29b-41d4-a716-446655440000', 5000.00, 'IDR', '2023-01-15T10:30:00Z'
+);
@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS investments_within_principal ON investments;
+DROP FUNCTION IF EXISTS check_investment_within_principal();
+
+ALTER TABLE investments
+    DROP CONSTRAINT IF EXISTS investments_amount_check,
+    DROP CONSTRAINT IF EXISTS investments_loan_id_fkey;
+
+ALTER TABLE loans
+    DROP CONSTRAINT IF EXISTS loans_version_check,
+    DROP CONSTRAINT IF EXISTS loans_roi_check,
+    DROP CONSTRAINT IF EXISTS loans_rate_check,
+    DROP CONSTRAINT IF EXISTS loans_principal_amount_check,
+    DROP CONSTRAINT IF EXISTS loans_state_check;
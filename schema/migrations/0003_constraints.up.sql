@@ -0,0 +1,61 @@
+/* Enforce in the database what the domain already assumes, so bad rows
+   cannot be written by other clients or by a bug that skips the domain
+   checks. */
+
+-- Early seed data used lowercase states.
+UPDATE loans SET state = upper(state) WHERE state <> upper(state);
+
+-- A CHECK rather than an enum type: new states are added by replacing the
+-- constraint inside a migration's transaction, which ALTER TYPE ... ADD VALUE
+-- does not allow.
+ALTER TABLE loans
+    ADD CONSTRAINT loans_state_check
+        CHECK (state IN ('PROPOSED', 'APPROVED', 'INVESTED', 'DISBURSED')),
+    ADD CONSTRAINT loans_principal_amount_check CHECK (principal_amount > 0),
+    ADD CONSTRAINT loans_rate_check CHECK (rate > 0),
+    ADD CONSTRAINT loans_roi_check CHECK (roi > 0),
+    ADD CONSTRAINT loans_version_check CHECK (version > 0);
+
+ALTER TABLE investments
+    ADD CONSTRAINT investments_loan_id_fkey
+        FOREIGN KEY (loan_id) REFERENCES loans(id),
+    ADD CONSTRAINT investments_amount_check CHECK (amount > 0);
+
+/* Investments must be in the loan's currency and together never exceed its
+   principal. The loan row is locked so concurrent inserts are checked one
+   after another. */
+CREATE OR REPLACE FUNCTION check_investment_within_principal() RETURNS trigger AS $$
+DECLARE
+    loan_principal DECIMAL(15,2);
+    loan_currency CHAR(3);
+    invested DECIMAL(15,2);
+BEGIN
+    SELECT principal_amount, currency INTO loan_principal, loan_currency
+    FROM loans WHERE id = NEW.loan_id
+    FOR UPDATE;
+
+    -- A missing loan is reported by the foreign key.
+    IF NOT FOUND THEN
+        RETURN NEW;
+    END IF;
+
+    IF NEW.currency <> loan_currency THEN
+        RAISE EXCEPTION 'investment currency % does not match loan currency %', NEW.currency, loan_currency
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'investments_currency_check';
+    END IF;
+
+    SELECT COALESCE(SUM(amount), 0) INTO invested
+    FROM investments WHERE loan_id = NEW.loan_id AND id <> NEW.id;
+
+    IF invested + NEW.amount > loan_principal THEN
+        RAISE EXCEPTION 'investments of % exceed loan principal %', invested + NEW.amount, loan_principal
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'investments_within_principal';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER investments_within_principal
+    BEFORE INSERT OR UPDATE ON investments
+    FOR EACH ROW EXECUTE FUNCTION check_investment_within_principal();
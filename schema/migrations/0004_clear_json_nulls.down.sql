@@ -0,0 +1,2 @@
+-- Nothing to revert: SQL NULL is what every version reads as absent details.
+SELECT 1;
@@ -0,0 +1,4 @@
+-- Earlier versions stored absent approval and disbursement details as the
+-- JSON literal null, which read back as empty details. Store them as NULL.
+UPDATE loans SET approval_details = NULL WHERE approval_details = 'null'::jsonb;
+UPDATE loans SET disbursement_details = NULL WHERE disbursement_details = 'null'::jsonb;
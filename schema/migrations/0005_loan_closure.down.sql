@@ -0,0 +1,39 @@
+-- Fails while closed loans exist: they have no state to go back to.
+ALTER TABLE loans
+    DROP CONSTRAINT loans_state_check,
+    ADD CONSTRAINT loans_state_check
+        CHECK (state IN ('PROPOSED', 'APPROVED', 'INVESTED', 'DISBURSED')),
+    DROP COLUMN closure_details;
+
+ALTER TABLE investments DROP COLUMN voided_at;
+
+CREATE OR REPLACE FUNCTION check_investment_within_principal() RETURNS trigger AS $$
+DECLARE
+    loan_principal DECIMAL(15,2);
+    loan_currency CHAR(3);
+    invested DECIMAL(15,2);
+BEGIN
+    SELECT principal_amount, currency INTO loan_principal, loan_currency
+    FROM loans WHERE id = NEW.loan_id
+    FOR UPDATE;
+
+    IF NOT FOUND THEN
+        RETURN NEW;
+    END IF;
+
+    IF NEW.currency <> loan_currency THEN
+        RAISE EXCEPTION 'investment currency % does not match loan currency %', NEW.currency, loan_currency
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'investments_currency_check';
+    END IF;
+
+    SELECT COALESCE(SUM(amount), 0) INTO invested
+    FROM investments WHERE loan_id = NEW.loan_id AND id <> NEW.id;
+
+    IF invested + NEW.amount > loan_principal THEN
+        RAISE EXCEPTION 'investments of % exceed loan principal %', invested + NEW.amount, loan_principal
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'investments_within_principal';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,51 @@
+/* Loans can be rejected, cancelled or expire before disbursement. Their
+   investments are then voided rather than deleted, so the refunds owed
+   stay on record. */
+
+ALTER TABLE loans
+    DROP CONSTRAINT loans_state_check,
+    ADD CONSTRAINT loans_state_check
+        CHECK (state IN ('PROPOSED', 'APPROVED', 'INVESTED', 'DISBURSED',
+                         'REJECTED', 'CANCELLED', 'EXPIRED')),
+    ADD COLUMN closure_details JSONB;
+
+ALTER TABLE investments ADD COLUMN voided_at TIMESTAMPTZ;
+
+-- As in 0003, but voided investments no longer count towards the principal,
+-- and voiding one is always allowed.
+CREATE OR REPLACE FUNCTION check_investment_within_principal() RETURNS trigger AS $$
+DECLARE
+    loan_principal DECIMAL(15,2);
+    loan_currency CHAR(3);
+    invested DECIMAL(15,2);
+BEGIN
+    IF NEW.voided_at IS NOT NULL THEN
+        RETURN NEW;
+    END IF;
+
+    SELECT principal_amount, currency INTO loan_principal, loan_currency
+    FROM loans WHERE id = NEW.loan_id
+    FOR UPDATE;
+
+    -- A missing loan is reported by the foreign key.
+    IF NOT FOUND THEN
+        RETURN NEW;
+    END IF;
+
+    IF NEW.currency <> loan_currency THEN
+        RAISE EXCEPTION 'investment currency % does not match loan currency %', NEW.currency, loan_currency
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'investments_currency_check';
+    END IF;
+
+    SELECT COALESCE(SUM(amount), 0) INTO invested
+    FROM investments
+    WHERE loan_id = NEW.loan_id AND id <> NEW.id AND voided_at IS NULL;
+
+    IF invested + NEW.amount > loan_principal THEN
+        RAISE EXCEPTION 'investments of % exceed loan principal %', invested + NEW.amount, loan_principal
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'investments_within_principal';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,14 @@
+/* One row per change to a loan, written in the same transaction as the
+   change. Each change bumps the loan's version, which orders the rows. */
+CREATE TABLE loan_events (
+    loan_id UUID NOT NULL REFERENCES loans(id),
+    version BIGINT NOT NULL,
+    event TEXT NOT NULL,
+    from_state TEXT,
+    to_state TEXT NOT NULL,
+    actor_id TEXT,
+    request_id TEXT,
+    payload JSONB,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT loan_events_pkey PRIMARY KEY (loan_id, version)
+);
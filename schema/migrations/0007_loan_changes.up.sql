@@ -0,0 +1,74 @@
+/* The loan event store: each loan's changes in the order they were made.
+   The loans and investments tables are a projection of it, updated in the
+   same transaction as each append. */
+CREATE TABLE loan_changes (
+    loan_id UUID NOT NULL REFERENCES loans(id),
+    sequence BIGINT NOT NULL,
+    version BIGINT NOT NULL,
+    type TEXT NOT NULL,
+    data JSONB NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT loan_changes_pkey PRIMARY KEY (loan_id, sequence)
+);
+
+/* Give existing loans a stream reconstructed from their current row, in
+   lifecycle order. Only creation is known to be version 1; later changes all
+   carry the loan's current version. */
+WITH funded AS (
+    SELECT loan_id, MAX(created_at) AS at
+    FROM investments
+    WHERE voided_at IS NULL
+    GROUP BY loan_id
+), facts AS (
+    SELECT id AS loan_id, created_at AS occurred_at, 0 AS step, '' AS tiebreak,
+        1::bigint AS version, 'LoanCreated' AS type,
+        jsonb_build_object(
+            'borrower_id_number', borrower_id_number,
+            'principal_amount', jsonb_build_object('amount', principal_amount::text, 'currency', currency),
+            'rate', rate::text,
+            'roi', roi::text) AS data
+    FROM loans
+
+    UNION ALL
+    SELECT id, (approval_details->>'approved_at')::timestamptz, 1, '', version, 'LoanApproved',
+        jsonb_build_object('approval_details', approval_details)
+    FROM loans WHERE approval_details IS NOT NULL
+
+    UNION ALL
+    SELECT i.loan_id, i.created_at, 2, i.id::text, l.version, 'InvestmentAdded',
+        jsonb_build_object('investment', jsonb_build_object(
+            'id', i.id,
+            'loan_id', i.loan_id,
+            'investor_id', i.investor_id,
+            'amount', jsonb_build_object('amount', i.amount::text, 'currency', i.currency),
+            'created_at', i.created_at))
+    FROM investments i JOIN loans l ON l.id = i.loan_id
+
+    UNION ALL
+    SELECT l.id, f.at, 3, '', l.version, 'LoanFullyInvested', '{}'::jsonb
+    FROM loans l JOIN funded f ON f.loan_id = l.id
+    WHERE l.state IN ('INVESTED', 'DISBURSED')
+
+    UNION ALL
+    SELECT l.id, COALESCE(f.at, l.updated_at), 4, '', l.version, 'AgreementLetterAttached',
+        jsonb_build_object('agreement_letter_url', l.agreement_letter_url)
+    FROM loans l LEFT JOIN funded f ON f.loan_id = l.id
+    WHERE l.agreement_letter_url IS NOT NULL
+
+    UNION ALL
+    SELECT id, (disbursement_details->>'disbursed_at')::timestamptz, 5, '', version, 'LoanDisbursed',
+        jsonb_build_object('disbursement_details', disbursement_details)
+    FROM loans WHERE disbursement_details IS NOT NULL
+
+    UNION ALL
+    SELECT id, (closure_details->>'closed_at')::timestamptz, 6, '', version, 'LoanClosed',
+        jsonb_build_object(
+            'event', CASE state WHEN 'REJECTED' THEN 'reject' WHEN 'CANCELLED' THEN 'cancel' ELSE 'expire' END,
+            'closure_details', closure_details)
+    FROM loans WHERE closure_details IS NOT NULL
+)
+INSERT INTO loan_changes (loan_id, sequence, version, type, data, occurred_at)
+SELECT loan_id,
+    ROW_NUMBER() OVER (PARTITION BY loan_id ORDER BY step, occurred_at, tiebreak),
+    version, type, data, occurred_at
+FROM facts;
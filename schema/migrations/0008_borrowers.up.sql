@@ -0,0 +1,26 @@
+/* Borrowers, referred to from loans by national ID number
+   (loans.borrower_id_number). */
+CREATE TABLE borrowers (
+    id UUID PRIMARY KEY,
+    national_id_number TEXT NOT NULL,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL,
+    phone TEXT NOT NULL,
+    address TEXT NOT NULL,
+    kyc_status TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT borrowers_national_id_number_key UNIQUE (national_id_number),
+    CONSTRAINT borrowers_kyc_status_check CHECK (kyc_status IN ('PENDING', 'VERIFIED', 'REJECTED'))
+);
+
+CREATE INDEX idx_borrowers_kyc_status ON borrowers(kyc_status, created_at);
+
+/* Register the borrowers of existing loans. Their details are unknown, so
+   they are pending KYC and cannot take out new loans until completed. */
+INSERT INTO borrowers (
+    id, national_id_number, name, email, phone, address, kyc_status, created_at, updated_at
+)
+SELECT gen_random_uuid(), borrower_id_number, '', '', '', '', 'PENDING', MIN(created_at), MIN(created_at)
+FROM loans
+GROUP BY borrower_id_number;
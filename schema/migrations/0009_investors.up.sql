@@ -0,0 +1,34 @@
+/* Investors, referred to from investments by id (investments.investor_id).
+   Both investment limits are in limit_currency, which is set exactly when
+   a limit is. */
+CREATE TABLE investors (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL,
+    kyc_status TEXT NOT NULL,
+    status TEXT NOT NULL,
+    per_loan_limit DECIMAL(15,2),
+    total_limit DECIMAL(15,2),
+    limit_currency CHAR(3),
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT investors_kyc_status_check CHECK (kyc_status IN ('PENDING', 'VERIFIED', 'REJECTED')),
+    CONSTRAINT investors_status_check CHECK (status IN ('ACTIVE', 'SUSPENDED')),
+    CONSTRAINT investors_limits_check CHECK (
+        (per_loan_limit IS NULL OR per_loan_limit > 0)
+        AND (total_limit IS NULL OR total_limit > 0)
+        AND ((per_loan_limit IS NULL AND total_limit IS NULL) = (limit_currency IS NULL))
+    )
+);
+
+/* Investors registered by the backfill below have no email yet. */
+CREATE UNIQUE INDEX investors_email_key ON investors (lower(email)) WHERE email <> '';
+CREATE INDEX idx_investors_status ON investors(status, created_at);
+
+/* Register the investors of existing investments. Their details are
+   unknown, so they are pending KYC and cannot invest again, or be emailed,
+   until completed. */
+INSERT INTO investors (id, name, email, kyc_status, status, created_at, updated_at)
+SELECT investor_id, '', '', 'PENDING', 'ACTIVE', MIN(created_at), MIN(created_at)
+FROM investments
+GROUP BY investor_id;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS repayment_installments;
+
+ALTER TABLE loans
+    DROP CONSTRAINT IF EXISTS loans_repayment_terms_check,
+    DROP COLUMN IF EXISTS repayment_type,
+    DROP COLUMN IF EXISTS tenor_months;
@@ -0,0 +1,26 @@
+/* Repayment terms are set when a loan is proposed. Loans proposed before
+   they were recorded have none, and get no repayment schedule. */
+ALTER TABLE loans
+    ADD COLUMN tenor_months INT,
+    ADD COLUMN repayment_type TEXT,
+    ADD CONSTRAINT loans_repayment_terms_check CHECK (
+        (tenor_months IS NULL AND repayment_type IS NULL)
+        OR (tenor_months BETWEEN 1 AND 360 AND repayment_type IN ('FLAT', 'EMI', 'BULLET'))
+    );
+
+/* The installments a disbursed loan is repaid in, written when it is
+   disbursed. Due dates are calendar dates. */
+CREATE TABLE repayment_installments (
+    loan_id UUID NOT NULL REFERENCES loans(id),
+    number INT NOT NULL,
+    due_date DATE NOT NULL,
+    principal_amount DECIMAL(15,2) NOT NULL,
+    interest_amount DECIMAL(15,2) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    CONSTRAINT repayment_installments_pkey PRIMARY KEY (loan_id, number),
+    CONSTRAINT repayment_installments_amounts_check CHECK (
+        principal_amount >= 0 AND interest_amount >= 0
+    )
+);
+
+CREATE INDEX idx_repayment_installments_due ON repayment_installments(due_date);
@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS repayment_payouts;
+DROP TABLE IF EXISTS repayments;
+
+ALTER TABLE repayment_installments
+    DROP CONSTRAINT IF EXISTS repayment_installments_paid_check,
+    DROP COLUMN IF EXISTS settled_at,
+    DROP COLUMN IF EXISTS paid_late_fee,
+    DROP COLUMN IF EXISTS paid_principal,
+    DROP COLUMN IF EXISTS paid_interest,
+    DROP COLUMN IF EXISTS late_fee;
+
+-- Fails while repaid or defaulted loans exist: they have no state to go
+-- back to.
+ALTER TABLE loans
+    DROP CONSTRAINT loans_state_check,
+    ADD CONSTRAINT loans_state_check
+        CHECK (state IN ('PROPOSED', 'APPROVED', 'INVESTED', 'DISBURSED',
+                         'REJECTED', 'CANCELLED', 'EXPIRED'));
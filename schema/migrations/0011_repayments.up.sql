@@ -0,0 +1,59 @@
+/* Disbursed loans end REPAID once their schedule is settled, or DEFAULTED,
+   with closure_details saying why. */
+ALTER TABLE loans
+    DROP CONSTRAINT loans_state_check,
+    ADD CONSTRAINT loans_state_check
+        CHECK (state IN ('PROPOSED', 'APPROVED', 'INVESTED', 'DISBURSED', 'REPAID', 'DEFAULTED',
+                         'REJECTED', 'CANCELLED', 'EXPIRED'));
+
+/* What has been paid on each installment, and the late fee charged once it
+   was overdue. */
+ALTER TABLE repayment_installments
+    ADD COLUMN late_fee DECIMAL(15,2) NOT NULL DEFAULT 0,
+    ADD COLUMN paid_interest DECIMAL(15,2) NOT NULL DEFAULT 0,
+    ADD COLUMN paid_principal DECIMAL(15,2) NOT NULL DEFAULT 0,
+    ADD COLUMN paid_late_fee DECIMAL(15,2) NOT NULL DEFAULT 0,
+    ADD COLUMN settled_at TIMESTAMPTZ,
+    ADD CONSTRAINT repayment_installments_paid_check CHECK (
+        late_fee >= 0
+        AND paid_interest BETWEEN 0 AND interest_amount
+        AND paid_principal BETWEEN 0 AND principal_amount
+        AND paid_late_fee BETWEEN 0 AND late_fee
+    );
+
+/* Payments made by borrowers, split by what they paid off. platform_fee is
+   what is left once the payouts below are made. */
+CREATE TABLE repayments (
+    id UUID PRIMARY KEY,
+    loan_id UUID NOT NULL REFERENCES loans(id),
+    amount DECIMAL(15,2) NOT NULL,
+    interest_amount DECIMAL(15,2) NOT NULL,
+    principal_amount DECIMAL(15,2) NOT NULL,
+    late_fee DECIMAL(15,2) NOT NULL,
+    platform_fee DECIMAL(15,2) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    paid_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT repayments_amount_check CHECK (
+        amount > 0
+        AND interest_amount >= 0 AND principal_amount >= 0 AND late_fee >= 0 AND platform_fee >= 0
+        AND amount = interest_amount + principal_amount + late_fee
+    )
+);
+
+CREATE INDEX idx_repayments_loan_id ON repayments(loan_id, paid_at);
+
+/* Each investor's part of a repayment. */
+CREATE TABLE repayment_payouts (
+    repayment_id UUID NOT NULL REFERENCES repayments(id),
+    investment_id UUID NOT NULL REFERENCES investments(id),
+    investor_id UUID NOT NULL,
+    principal_amount DECIMAL(15,2) NOT NULL,
+    interest_amount DECIMAL(15,2) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    CONSTRAINT repayment_payouts_pkey PRIMARY KEY (repayment_id, investment_id),
+    CONSTRAINT repayment_payouts_amounts_check CHECK (
+        principal_amount >= 0 AND interest_amount >= 0
+    )
+);
+
+CREATE INDEX idx_repayment_payouts_investor_id ON repayment_payouts(investor_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ledger_lines;
+DROP TABLE IF EXISTS ledger_entries;
+DROP FUNCTION IF EXISTS check_ledger_entry_balanced();
@@ -0,0 +1,139 @@
+/* Double-entry ledger of the money that moves through loans. Each entry
+   records one movement, identified by its kind and the investment, loan or
+   repayment it moves money for, and its lines balance in every currency. */
+CREATE TABLE ledger_entries (
+    id UUID PRIMARY KEY,
+    seq BIGSERIAL NOT NULL,
+    kind TEXT NOT NULL,
+    loan_id UUID NOT NULL REFERENCES loans(id),
+    reference_id UUID NOT NULL,
+    posted_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT ledger_entries_kind_check CHECK (kind IN ('INVESTMENT', 'REFUND', 'DISBURSEMENT', 'REPAYMENT')),
+    CONSTRAINT ledger_entries_kind_reference_id_key UNIQUE (kind, reference_id)
+);
+
+CREATE INDEX idx_ledger_entries_loan_id ON ledger_entries(loan_id, posted_at, seq);
+
+/* Accounts are identified by type, owner and currency and exist once a line
+   is posted to them. owner_id is empty for the platform's accounts. */
+CREATE TABLE ledger_lines (
+    entry_id UUID NOT NULL REFERENCES ledger_entries(id),
+    line_no INT NOT NULL,
+    account_type TEXT NOT NULL,
+    owner_id TEXT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    side TEXT NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    CONSTRAINT ledger_lines_pkey PRIMARY KEY (entry_id, line_no),
+    CONSTRAINT ledger_lines_check CHECK (
+        account_type IN ('INVESTOR_WALLET', 'LOAN_FUNDING', 'LOAN_RECEIVABLE', 'BORROWER', 'PLATFORM_FEES')
+        AND side IN ('DEBIT', 'CREDIT')
+        AND amount > 0
+    )
+);
+
+CREATE INDEX idx_ledger_lines_account ON ledger_lines(account_type, owner_id, currency);
+
+/* An entry's lines are written one at a time, so whether they balance is
+   checked when the transaction commits. */
+CREATE OR REPLACE FUNCTION check_ledger_entry_balanced()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM ledger_lines
+        WHERE entry_id = NEW.entry_id
+        GROUP BY currency
+        HAVING SUM(CASE side WHEN 'DEBIT' THEN amount ELSE -amount END) <> 0
+    ) THEN
+        RAISE EXCEPTION 'journal entry % does not balance', NEW.entry_id
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'ledger_entries_balanced';
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_entries_balanced
+    AFTER INSERT ON ledger_lines
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_ledger_entry_balanced();
+
+/* Post the money that has already moved: investments, refunds of voided
+   ones, disbursements and repayments, as the service posts them. */
+WITH entries AS (
+    INSERT INTO ledger_entries (id, kind, loan_id, reference_id, posted_at)
+    SELECT gen_random_uuid(), 'INVESTMENT', loan_id, id, created_at
+    FROM investments
+    ORDER BY created_at, id
+    RETURNING id, reference_id
+)
+INSERT INTO ledger_lines (entry_id, line_no, account_type, owner_id, currency, side, amount)
+SELECT e.id, l.line_no, l.account_type, l.owner_id, i.currency, l.side, i.amount
+FROM entries e
+JOIN investments i ON i.id = e.reference_id
+CROSS JOIN LATERAL (VALUES
+    (1, 'INVESTOR_WALLET', i.investor_id::text, 'DEBIT'),
+    (2, 'LOAN_FUNDING', i.loan_id::text, 'CREDIT')
+) AS l(line_no, account_type, owner_id, side);
+
+WITH entries AS (
+    INSERT INTO ledger_entries (id, kind, loan_id, reference_id, posted_at)
+    SELECT gen_random_uuid(), 'REFUND', loan_id, id, voided_at
+    FROM investments
+    WHERE voided_at IS NOT NULL
+    ORDER BY voided_at, id
+    RETURNING id, reference_id
+)
+INSERT INTO ledger_lines (entry_id, line_no, account_type, owner_id, currency, side, amount)
+SELECT e.id, l.line_no, l.account_type, l.owner_id, i.currency, l.side, i.amount
+FROM entries e
+JOIN investments i ON i.id = e.reference_id
+CROSS JOIN LATERAL (VALUES
+    (1, 'LOAN_FUNDING', i.loan_id::text, 'DEBIT'),
+    (2, 'INVESTOR_WALLET', i.investor_id::text, 'CREDIT')
+) AS l(line_no, account_type, owner_id, side);
+
+WITH entries AS (
+    INSERT INTO ledger_entries (id, kind, loan_id, reference_id, posted_at)
+    SELECT gen_random_uuid(), 'DISBURSEMENT', id, id, (disbursement_details->>'disbursed_at')::timestamptz
+    FROM loans
+    WHERE disbursement_details IS NOT NULL
+    ORDER BY 5, id
+    RETURNING id, reference_id
+)
+INSERT INTO ledger_lines (entry_id, line_no, account_type, owner_id, currency, side, amount)
+SELECT e.id, l.line_no, l.account_type, l.owner_id, ln.currency, l.side, ln.principal_amount
+FROM entries e
+JOIN loans ln ON ln.id = e.reference_id
+CROSS JOIN LATERAL (VALUES
+    (1, 'LOAN_RECEIVABLE', ln.id::text, 'DEBIT'),
+    (2, 'BORROWER', ln.borrower_id_number, 'CREDIT')
+) AS l(line_no, account_type, owner_id, side);
+
+WITH entries AS (
+    INSERT INTO ledger_entries (id, kind, loan_id, reference_id, posted_at)
+    SELECT gen_random_uuid(), 'REPAYMENT', loan_id, id, paid_at
+    FROM repayments
+    ORDER BY paid_at, id
+    RETURNING id, reference_id
+)
+INSERT INTO ledger_lines (entry_id, line_no, account_type, owner_id, currency, side, amount)
+SELECT entry_id, line_no, account_type, owner_id, currency, side, amount
+FROM (
+    SELECT e.id AS entry_id, l.line_no, l.account_type, l.owner_id, r.currency, l.side, l.amount
+    FROM entries e
+    JOIN repayments r ON r.id = e.reference_id
+    JOIN loans ln ON ln.id = r.loan_id
+    CROSS JOIN LATERAL (VALUES
+        (1, 'BORROWER', ln.borrower_id_number, 'DEBIT', r.amount),
+        (2, 'LOAN_RECEIVABLE', ln.id::text, 'CREDIT', r.principal_amount),
+        (3, 'LOAN_FUNDING', ln.id::text, 'DEBIT', r.principal_amount),
+        (4, 'PLATFORM_FEES', '', 'CREDIT', r.platform_fee)
+    ) AS l(line_no, account_type, owner_id, side, amount)
+    UNION ALL
+    SELECT e.id, 4 + ROW_NUMBER() OVER (PARTITION BY p.repayment_id ORDER BY p.investment_id)::int,
+        'INVESTOR_WALLET', p.investor_id::text, p.currency, 'CREDIT', p.principal_amount + p.interest_amount
+    FROM entries e
+    JOIN repayment_payouts p ON p.repayment_id = e.reference_id
+) AS lines
+WHERE amount > 0;
@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS investor_wallets;
+
+DELETE FROM ledger_lines
+WHERE entry_id IN (SELECT id FROM ledger_entries WHERE kind IN ('DEPOSIT', 'WITHDRAWAL'));
+DELETE FROM ledger_entries WHERE kind IN ('DEPOSIT', 'WITHDRAWAL');
+
+ALTER TABLE ledger_lines
+    DROP CONSTRAINT ledger_lines_check,
+    ADD CONSTRAINT ledger_lines_check CHECK (
+        account_type IN ('INVESTOR_WALLET', 'LOAN_FUNDING', 'LOAN_RECEIVABLE', 'BORROWER', 'PLATFORM_FEES')
+        AND side IN ('DEBIT', 'CREDIT')
+        AND amount > 0
+    );
+
+ALTER TABLE ledger_entries
+    DROP CONSTRAINT ledger_entries_loan_id_check,
+    DROP CONSTRAINT ledger_entries_kind_check,
+    ADD CONSTRAINT ledger_entries_kind_check CHECK (kind IN ('INVESTMENT', 'REFUND', 'DISBURSEMENT', 'REPAYMENT')),
+    ALTER COLUMN loan_id SET NOT NULL;
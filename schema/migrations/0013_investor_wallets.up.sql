@@ -0,0 +1,77 @@
+/* Deposits and withdrawals move money in and out of investors' wallets
+   rather than for a loan, so they have no loan_id. */
+ALTER TABLE ledger_entries
+    ALTER COLUMN loan_id DROP NOT NULL,
+    DROP CONSTRAINT ledger_entries_kind_check,
+    ADD CONSTRAINT ledger_entries_kind_check CHECK (
+        kind IN ('INVESTMENT', 'REFUND', 'DISBURSEMENT', 'REPAYMENT', 'DEPOSIT', 'WITHDRAWAL')
+    ),
+    ADD CONSTRAINT ledger_entries_loan_id_check CHECK (
+        (loan_id IS NULL) = (kind IN ('DEPOSIT', 'WITHDRAWAL'))
+    );
+
+ALTER TABLE ledger_lines
+    DROP CONSTRAINT ledger_lines_check,
+    ADD CONSTRAINT ledger_lines_check CHECK (
+        account_type IN ('INVESTOR_WALLET', 'LOAN_FUNDING', 'LOAN_RECEIVABLE', 'BORROWER', 'PLATFORM_FEES', 'DEPOSITS')
+        AND side IN ('DEBIT', 'CREDIT')
+        AND amount > 0
+    );
+
+/* One wallet per investor and currency, opened the first time money moves
+   through it. available matches the investor's INVESTOR_WALLET ledger
+   account; reserved is what they have invested in loans not yet
+   disbursed. */
+CREATE TABLE investor_wallets (
+    investor_id UUID NOT NULL REFERENCES investors(id),
+    currency CHAR(3) NOT NULL,
+    available DECIMAL(15,2) NOT NULL,
+    reserved DECIMAL(15,2) NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT investor_wallets_pkey PRIMARY KEY (investor_id, currency),
+    CONSTRAINT investor_wallets_balances_check CHECK (available >= 0 AND reserved >= 0)
+);
+
+/* Investments were accepted before investors had wallets. Post a deposit
+   of everything each investor invested, as of their first investment, so
+   that their wallet accounts hold what was refunded or paid out to them. */
+CREATE TEMPORARY TABLE opening_deposits AS
+SELECT gen_random_uuid() AS entry_id, investor_id, currency,
+    SUM(amount) AS amount, MIN(created_at) AS posted_at
+FROM investments
+GROUP BY investor_id, currency;
+
+INSERT INTO ledger_entries (id, kind, loan_id, reference_id, posted_at)
+SELECT entry_id, 'DEPOSIT', NULL, gen_random_uuid(), posted_at
+FROM opening_deposits
+ORDER BY posted_at, investor_id, currency;
+
+INSERT INTO ledger_lines (entry_id, line_no, account_type, owner_id, currency, side, amount)
+SELECT d.entry_id, l.line_no, l.account_type, l.owner_id, d.currency, l.side, d.amount
+FROM opening_deposits d
+CROSS JOIN LATERAL (VALUES
+    (1, 'DEPOSITS', '', 'DEBIT'),
+    (2, 'INVESTOR_WALLET', d.investor_id::text, 'CREDIT')
+) AS l(line_no, account_type, owner_id, side);
+
+DROP TABLE opening_deposits;
+
+/* Open the wallets: what is available is the balance of the wallet
+   account, and what is reserved the investments in loans that are still
+   being funded. */
+INSERT INTO investor_wallets (investor_id, currency, available, reserved, updated_at)
+SELECT b.owner_id::uuid, b.currency, b.balance, COALESCE(r.reserved, 0), now()
+FROM (
+    SELECT owner_id, currency,
+        SUM(CASE side WHEN 'CREDIT' THEN amount ELSE -amount END) AS balance
+    FROM ledger_lines
+    WHERE account_type = 'INVESTOR_WALLET'
+    GROUP BY owner_id, currency
+) AS b
+LEFT JOIN (
+    SELECT i.investor_id, i.currency, SUM(i.amount) AS reserved
+    FROM investments i
+    JOIN loans l ON l.id = i.loan_id
+    WHERE i.voided_at IS NULL AND l.state IN ('APPROVED', 'INVESTED')
+    GROUP BY i.investor_id, i.currency
+) AS r ON r.investor_id = b.owner_id::uuid AND r.currency = b.currency;
@@ -0,0 +1,2 @@
+-- Agreement emails already queued by the jobs are left to be delivered.
+DELETE FROM outbox WHERE topic = 'agreement_letter';
@@ -0,0 +1,17 @@
+/* Agreement letters are now generated by an outbox job, which queues the
+   agreement emails once the letter is attached. Loans whose letter was
+   never generated get the job, and their agreement emails, which could only
+   fail until they went DEAD, are replaced by the ones the job queues. */
+DELETE FROM outbox o
+USING loans l
+WHERE o.topic = 'investment_agreement_email'
+  AND o.status IN ('PENDING', 'DEAD')
+  AND (o.payload->>'loan_id')::uuid = l.id
+  AND l.agreement_letter_url IS NULL;
+
+INSERT INTO outbox (id, topic, payload, status, attempts, next_attempt_at, created_at)
+SELECT gen_random_uuid(), 'agreement_letter', jsonb_build_object('loan_id', id),
+       'PENDING', 0, now(), now()
+FROM loans
+WHERE agreement_letter_url IS NULL
+  AND state IN ('INVESTED', 'DISBURSED', 'REPAID', 'DEFAULTED');
@@ -0,0 +1,11 @@
+// Package schema embeds the database migrations so the binaries carry
+// them.
+package schema
+
+import "embed"
+
+// Migrations holds migrations/NNNN_name.up.sql and the matching .down.sql
+// files.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS